@@ -3,26 +3,41 @@ package main
 import (
 	"context"
 	"errors"
+	"fmt"
 	"github.com/assylzhan-a/company-task/config"
+	"github.com/assylzhan-a/company-task/internal/auth"
+	"github.com/assylzhan-a/company-task/internal/breach"
 	"github.com/assylzhan-a/company-task/internal/db/repository"
 	handler "github.com/assylzhan-a/company-task/internal/delivery/http"
+	"github.com/assylzhan-a/company-task/internal/domain/entity"
 	uc "github.com/assylzhan-a/company-task/internal/domain/usecase"
+	"github.com/assylzhan-a/company-task/internal/lifecycle"
+	"github.com/assylzhan-a/company-task/internal/mailer"
+	"github.com/assylzhan-a/company-task/internal/oidc"
+	ports "github.com/assylzhan-a/company-task/internal/ports/repository"
+	"github.com/assylzhan-a/company-task/internal/requestid"
 	"github.com/assylzhan-a/company-task/pkg/logger"
+	"github.com/assylzhan-a/company-task/pkg/secretbox"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
-	"time"
 
 	"github.com/assylzhan-a/company-task/internal/db"
 	"github.com/assylzhan-a/company-task/internal/kafka"
 	"github.com/assylzhan-a/company-task/internal/worker"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"github.com/google/uuid"
 )
 
 func main() {
-	cfg := config.Load()
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Invalid configuration:", err)
+		os.Exit(1)
+	}
 	log := logger.NewLogger(cfg.LogLevel)
 
 	// Connect to the database
@@ -31,11 +46,11 @@ func main() {
 		log.Error("Failed to connect to database", "error", err)
 		os.Exit(1)
 	}
-	defer dbPool.Close()
 
 	// Run migrations
 	if err := db.RunMigrations("migrations", cfg.DatabaseURL); err != nil {
 		log.Error("Failed to run migrations", "error", err)
+		dbPool.Close()
 		os.Exit(1)
 	}
 	log.Info("Migrations completed successfully")
@@ -43,56 +58,269 @@ func main() {
 	r := chi.NewRouter()
 
 	//middleware
+	r.Use(requestid.Middleware)
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
 
+	box, err := newSecretBox(cfg)
+	if err != nil {
+		log.Error("Failed to configure the key encryption key", "error", err)
+		dbPool.Close()
+		os.Exit(1)
+	}
+
 	// repositories
 	userRepo := repository.NewUserRepository(dbPool)
 	companyRepo := repository.NewCompanyRepository(dbPool)
+	tokenRepo := repository.NewTokenRepository(dbPool)
+	signingKeyRepo := repository.NewSigningKeyRepository(dbPool)
+	roleRepo := repository.NewRoleRepository(dbPool)
+	organizationRepo := repository.NewOrganizationRepository(dbPool)
+	apiKeyRepo := repository.NewAPIKeyRepository(dbPool)
+	loginThrottleRepo := repository.NewLoginThrottleRepository(dbPool)
+	securityEventRepo := repository.NewSecurityEventRepository(dbPool)
+	mfaRepo := repository.NewMFARepository(dbPool, box)
+	ssoStateRepo := repository.NewSSOStateRepository(dbPool)
+	idempotencyRepo := repository.NewIdempotencyRepository(dbPool)
+	importRepo := repository.NewImportRepository(dbPool)
+
+	r.Use(handler.BodyLimit(cfg.MaxRequestBodyBytes))
+
+	// authentication
+	keySet, err := newKeySet(cfg, signingKeyRepo, box, log)
+	if err != nil {
+		log.Error("Failed to configure signing keys", "error", err)
+		dbPool.Close()
+		os.Exit(1)
+	}
+	tokenService := auth.NewTokenService(keySet, cfg.JWTIssuer, cfg.JWTAudience, cfg.AccessTokenTTL)
+	denylist := auth.NewDenylist(tokenRepo, cfg.DenylistSync, log)
+	authenticator := auth.NewAuthenticator(tokenService, denylist, apiKeyRepo, securityEventRepo)
+
+	smtpMailer, err := newMailer(cfg, log)
+	if err != nil {
+		log.Error("Failed to configure mailer", "error", err)
+		dbPool.Close()
+		os.Exit(1)
+	}
+	mail := mailer.NewQueue(smtpMailer, cfg.MailQueueSize, log)
+
+	// single sign-on is enabled by configuring a provider
+	var ssoProvider oidc.Provider
+	if cfg.OIDCIssuerURL != "" {
+		ssoProvider = oidc.NewClient(oidc.Config{
+			IssuerURL:    cfg.OIDCIssuerURL,
+			ClientID:     cfg.OIDCClientID,
+			ClientSecret: cfg.OIDCClientSecret,
+			RedirectURL:  cfg.OIDCRedirectURL,
+			Scopes:       cfg.OIDCScopes,
+		}, nil)
+	} else if !cfg.LocalLoginEnabled {
+		log.Error("LOCAL_LOGIN_ENABLED=false requires single sign-on to be configured")
+		dbPool.Close()
+		os.Exit(1)
+	}
+	roleMapping, err := entity.ParseRoleMapping(cfg.OIDCRoleMapping)
+	if err != nil {
+		log.Error("Failed to parse OIDC_ROLE_MAPPING", "error", err)
+		dbPool.Close()
+		os.Exit(1)
+	}
+
+	var breaches breach.Checker
+	if cfg.PasswordRejectBreached {
+		breaches = breach.NewClient(cfg.PasswordBreachRangeURL, &http.Client{Timeout: cfg.PasswordBreachTimeout}, log)
+	}
 
 	// use cases
-	userUseCase := uc.NewUserUseCase(userRepo)
-	companyUseCase := uc.NewCompanyUseCase(companyRepo, log)
+	userUseCase := uc.NewUserUseCase(userRepo, tokenRepo, roleRepo, organizationRepo, loginThrottleRepo, securityEventRepo, mfaRepo, mail, breaches, ssoStateRepo, ssoProvider, tokenService, denylist, uc.UserUseCaseConfig{
+		RefreshTokenTTL:       cfg.RefreshTokenTTL,
+		DefaultRole:           cfg.DefaultUserRole,
+		DefaultOrganizationID: cfg.DefaultOrganizationID,
+		PasswordPolicy: entity.PasswordPolicy{
+			MinLength:     cfg.PasswordMinLength,
+			RequireUpper:  cfg.PasswordRequireUpper,
+			RequireLower:  cfg.PasswordRequireLower,
+			RequireDigit:  cfg.PasswordRequireDigit,
+			RequireSymbol: cfg.PasswordRequireSymbol,
+		},
+		LockoutPolicy: entity.LockoutPolicy{
+			FreeAttempts:       cfg.LoginFreeAttempts,
+			BaseDelay:          cfg.LoginBaseDelay,
+			MaxDelay:           cfg.LoginMaxDelay,
+			MaxAccountFailures: cfg.LoginMaxAccountFailures,
+			MaxIPFailures:      cfg.LoginMaxIPFailures,
+			LockoutDuration:    cfg.LoginLockoutDuration,
+			Window:             cfg.LoginFailureWindow,
+		},
+		MFAChallengeTTL:          cfg.MFAChallengeTTL,
+		MFARequiredPermissions:   cfg.MFARequiredPermissions,
+		RequireEmailVerification: cfg.EmailVerificationRequired,
+		EmailVerificationTTL:     cfg.EmailVerificationTTL,
+		PasswordResetTTL:         cfg.PasswordResetTTL,
+		PasswordResetThrottle:    entity.DefaultPasswordResetPolicy(),
+		BaseURL:                  cfg.AppBaseURL,
+		LocalLoginDisabled:       !cfg.LocalLoginEnabled,
+		SSO: uc.SSOConfig{
+			UsernameClaim: cfg.OIDCUsernameClaim,
+			RoleClaim:     cfg.OIDCRoleClaim,
+			RoleMapping:   roleMapping,
+			LoginTimeout:  cfg.OIDCLoginTimeout,
+		},
+	})
+	mfaUseCase := uc.NewMFAUseCase(mfaRepo, userRepo, cfg.MFAIssuer)
+	roleUseCase := uc.NewRoleUseCase(roleRepo, userRepo, securityEventRepo)
+	if cfg.BootstrapAdmin != "" {
+		// Only an existing user is promoted, so register the account first
+		// and restart with BOOTSTRAP_ADMIN_USERNAME set.
+		if err := roleUseCase.BootstrapAdmin(context.Background(), cfg.BootstrapAdmin); err != nil {
+			log.Warn("Failed to grant the bootstrap admin role", "username", cfg.BootstrapAdmin, "error", err)
+		}
+	}
+	auditUseCase := uc.NewAuditUseCase(securityEventRepo)
+	organizationUseCase := uc.NewOrganizationUseCase(organizationRepo)
+	serviceAccountUseCase := uc.NewServiceAccountUseCase(apiKeyRepo)
+	companyUseCase := uc.NewCompanyUseCase(companyRepo, log, uc.CompanyUseCaseConfig{
+		MaxBatchOperations: cfg.CompanyBatchMaxOperations,
+	})
+	importUseCase := uc.NewImportUseCase(importRepo, companyUseCase, userRepo, roleRepo, organizationRepo, apiKeyRepo, log, uc.ImportUseCaseConfig{
+		Lease: cfg.ImportLease,
+	})
+	companyStatsUseCase := uc.NewCompanyStatsUseCase(companyRepo, uc.CompanyStatsUseCaseConfig{
+		CacheTTL:  cfg.CompanyStatsCacheTTL,
+		CacheSize: cfg.CompanyStatsCacheSize,
+	})
 
 	// Initialize handlers
-	handler.NewUserHandler(r, userUseCase)
-	handler.NewCompanyHandler(r, companyUseCase)
+	idempotency := handler.Idempotency(idempotencyRepo, cfg.IdempotencyKeyTTL, log)
+	handler.NewErrorHandler(r)
+	handler.NewUserHandler(r, userUseCase, authenticator, idempotency)
+	handler.NewMFAHandler(r, mfaUseCase, authenticator, idempotency)
+	handler.NewSSOHandler(r, userUseCase)
+	handler.NewCompanyHandler(r, companyUseCase, authenticator, idempotency, log)
+	handler.NewImportHandler(r, importUseCase, authenticator, idempotency, cfg.ImportMaxFileBytes)
+	handler.NewCompanyStatsHandler(r, companyStatsUseCase, authenticator)
+	handler.NewRoleHandler(r, roleUseCase, authenticator, idempotency)
+	handler.NewOrganizationHandler(r, organizationUseCase, authenticator, idempotency)
+	handler.NewServiceAccountHandler(r, serviceAccountUseCase, authenticator, idempotency)
+	handler.NewAuditHandler(r, auditUseCase, authenticator)
+	handler.NewJWKSHandler(r, keySet)
 
 	// Initialize Kafka producer
 	kafkaProducer := kafka.NewProducer(cfg.KafkaBrokers, log)
-	defer kafkaProducer.Close()
 
-	// Initialize and start outbox worker
-	outboxWorker := worker.NewOutboxWorker(companyRepo, kafkaProducer, log)
-	go outboxWorker.Start(context.Background())
+	// Initialize outbox worker; published company events invalidate cached
+	// statistics at once on this instance
+	outboxWorker := worker.NewOutboxWorker(companyRepo, kafkaProducer, log).WithListener(companyStatsUseCase).WithTick(cfg.OutboxWorkerTick)
+
+	// Every instance consumes the company events in a group of its own, so
+	// that events published by other instances invalidate its statistics too
+	companyEventConsumer := worker.NewCompanyEventConsumer(
+		kafka.NewConsumer(cfg.KafkaBrokers, cfg.KafkaClientID+"-stats-"+uuid.NewString(), entity.CompanyEventTypes), log,
+	).WithListener(companyStatsUseCase)
 
 	srv := &http.Server{
 		Addr:    cfg.ServerAddress,
 		Handler: r,
 	}
 
-	// Start server
-	go func() {
-		log.Info("Starting server", "address", cfg.ServerAddress)
-		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
-			log.Error("Server failed to start", "error", err)
-			os.Exit(1)
-		}
-	}()
-
-	// Graceful shutdown
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
-	log.Info("Shutting down server...")
+	// Components are started in the order they are added and stopped in reverse,
+	// so the server stops accepting requests before the worker drains, and the
+	// producer and database outlive everything that uses them.
+	app := lifecycle.NewManager(cfg.ShutdownTimeout, log)
+	app.Add(lifecycle.Hook{
+		ComponentName: "postgres",
+		OnStop: func(ctx context.Context) error {
+			dbPool.Close()
+			return nil
+		},
+	})
+	app.Add(keySet)
+	app.Add(denylist)
+	app.Add(lifecycle.Hook{
+		ComponentName: "kafka_producer",
+		OnStop: func(ctx context.Context) error {
+			return kafkaProducer.Close()
+		},
+	})
+	app.Add(outboxWorker)
+	app.Add(companyEventConsumer)
+	app.Add(worker.NewIdempotencyKeyPurger(idempotencyRepo, cfg.IdempotencyPurgeInterval, log))
+	app.Add(worker.NewSecurityEventAppender(securityEventRepo, cfg.SecurityEventAppendInterval, log))
+	app.Add(worker.NewImportWorker(importUseCase, cfg.ImportWorkerTick, log))
+	app.Add(mail)
+	app.Add(lifecycle.Hook{
+		ComponentName: "http_server",
+		OnStart: func(ctx context.Context) error {
+			ln, err := net.Listen("tcp", srv.Addr)
+			if err != nil {
+				return err
+			}
+			log.Info("Starting server", "address", cfg.ServerAddress)
+			go func() {
+				if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+					app.Fail("http_server", err)
+				}
+			}()
+			return nil
+		},
+		OnStop: srv.Shutdown,
+	})
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
-	if err := srv.Shutdown(ctx); err != nil {
-		log.Error("Server forced to shutdown", "error", err)
+	if err := app.Run(ctx); err != nil {
+		log.Error("Application stopped with errors", "error", err)
+		stop()
 		os.Exit(1)
 	}
 
 	log.Info("Server exiting")
 }
+
+// newSecretBox returns the box that seals signing keys and MFA secrets with
+// KEY_ENCRYPTION_KEY.
+func newSecretBox(cfg config.Config) (*secretbox.Box, error) {
+	if len(cfg.KeyEncryptionKey) == 0 {
+		return nil, errors.New("KEY_ENCRYPTION_KEY is required")
+	}
+	return secretbox.New(cfg.KeyEncryptionKey)
+}
+
+// newKeySet returns the key set for the configured JWT algorithm. HS256 keeps
+// using the shared JWT_SECRET; asymmetric algorithms use rotating keys
+// sealed with box.
+func newKeySet(cfg config.Config, repo ports.SigningKeyRepository, box *secretbox.Box, log *logger.Logger) (*auth.KeySet, error) {
+	if cfg.JWTAlgorithm == auth.AlgorithmHS256 {
+		if cfg.JWTSecret == "" {
+			return nil, errors.New("JWT_SECRET is required for HS256")
+		}
+		return auth.NewHMACKeySet(cfg.JWTSecret), nil
+	}
+
+	return auth.NewRotatingKeySet(cfg.JWTAlgorithm, repo, box, auth.KeyRotation{
+		Interval:      cfg.JWTKeyRotation,
+		Prepublish:    cfg.JWTKeyPrepublish,
+		VerifyGrace:   cfg.AccessTokenTTL,
+		CheckInterval: cfg.JWTKeyCheck,
+	}, log)
+}
+
+// newMailer returns the configured mailer. The log mailer is meant for
+// development only since it writes reset links to the log.
+func newMailer(cfg config.Config, log *logger.Logger) (mailer.Mailer, error) {
+	switch cfg.Mailer {
+	case "smtp":
+		if cfg.SMTPHost == "" {
+			return nil, errors.New("SMTP_HOST is required for the smtp mailer")
+		}
+		return mailer.NewSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.MailFrom), nil
+	case "file":
+		return mailer.NewFileMailer(cfg.MailDir, cfg.MailFrom)
+	case "log", "":
+		return mailer.NewLogMailer(log), nil
+	default:
+		return nil, fmt.Errorf("unknown mailer %q", cfg.Mailer)
+	}
+}
@@ -0,0 +1,194 @@
+// Command company-export writes the companies of an organization as CSV,
+// newline-delimited JSON or XLSX, like GET /v1/companies/export but directly
+// from the database. Filters have the same meaning as the query parameters of
+// the endpoint.
+//
+// Usage:
+//
+//	company-export -org ID [-format csv|ndjson|xlsx] [-type TYPE]... [-registered BOOL] [-min-employees N] [-max-employees N]
+//		[-q TEXT] [-owner ID] [-from TIME] [-to TIME] [-gzip] [-o FILE]
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/assylzhan-a/company-task/config"
+	"github.com/assylzhan-a/company-task/internal/db"
+	"github.com/assylzhan-a/company-task/internal/db/repository"
+	"github.com/assylzhan-a/company-task/internal/domain/entity"
+	uc "github.com/assylzhan-a/company-task/internal/domain/usecase"
+	"github.com/assylzhan-a/company-task/internal/tenant"
+	"github.com/assylzhan-a/company-task/pkg/logger"
+	"github.com/assylzhan-a/company-task/pkg/tabular"
+	"github.com/google/uuid"
+)
+
+// listFlag collects the values of a flag given several times.
+type listFlag []string
+
+func (l *listFlag) String() string { return strings.Join(*l, ",") }
+
+func (l *listFlag) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+func main() {
+	var types listFlag
+	flag.Var(&types, "type", "only companies of the `type`; repeat for several types")
+	var (
+		organization = flag.String("org", "", "export the companies of the organization `ID`")
+		formatName   = flag.String("format", "csv", "write the `format`: csv, ndjson or xlsx")
+		registered   = flag.String("registered", "", "only registered (true) or unregistered (false) companies")
+		minEmployees = flag.String("min-employees", "", "only companies with at least `N` employees")
+		maxEmployees = flag.String("max-employees", "", "only companies with at most `N` employees")
+		query        = flag.String("q", "", "only companies whose name contains the `text`")
+		owner        = flag.String("owner", "", "only companies owned by the user `ID`")
+		from         = flag.String("from", "", "only companies created at or after the RFC 3339 `time`")
+		to           = flag.String("to", "", "only companies created before the RFC 3339 `time`")
+		compress     = flag.Bool("gzip", false, "compress the output with gzip")
+		output       = flag.String("o", "-", "write to `file` instead of standard output")
+	)
+	flag.Parse()
+
+	organizationID, err := uuid.Parse(*organization)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "company-export: -org must be an organization ID")
+		os.Exit(2)
+	}
+	format, ok := tabular.ParseFormat(*formatName)
+	if !ok {
+		fmt.Fprintln(os.Stderr, "company-export: invalid -format", *formatName)
+		os.Exit(2)
+	}
+	filter, err := parseFilter(types, *registered, *minEmployees, *maxEmployees, *query, *owner, *from, *to)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "company-export:", err)
+		os.Exit(2)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "company-export: invalid configuration:", err)
+		os.Exit(2)
+	}
+	// Logs go to standard error so that they do not mix with the export.
+	log := &logger.Logger{Logger: slog.New(slog.NewJSONHandler(os.Stderr, nil))}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ctx = tenant.WithOrganization(ctx, organizationID)
+
+	if err := run(ctx, cfg, log, filter, format, *compress, *output); err != nil {
+		log.Error("Export failed", "error", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, cfg config.Config, log *logger.Logger, filter entity.CompanyFilter, format tabular.Format, compress bool, output string) error {
+	dbPool, err := db.NewPostgresConnection(cfg.DatabaseURL, log)
+	if err != nil {
+		return err
+	}
+	defer dbPool.Close()
+
+	companyUseCase := uc.NewCompanyUseCase(repository.NewCompanyRepository(dbPool), log, uc.CompanyUseCaseConfig{})
+
+	var w io.Writer = os.Stdout
+	if output != "-" {
+		file, err := os.Create(output)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		w = file
+	}
+
+	buffered := bufio.NewWriter(w)
+	w = buffered
+	var compressed *gzip.Writer
+	if compress {
+		compressed = gzip.NewWriter(buffered)
+		w = compressed
+	}
+	if err := companyUseCase.Export(ctx, filter, format, w); err != nil {
+		return err
+	}
+	if compressed != nil {
+		if err := compressed.Close(); err != nil {
+			return err
+		}
+	}
+	if err := buffered.Flush(); err != nil {
+		return err
+	}
+
+	log.Info("Exported companies", "format", format)
+	return nil
+}
+
+func parseFilter(types []string, registered, minEmployees, maxEmployees, query, owner, from, to string) (entity.CompanyFilter, error) {
+	filter := entity.CompanyFilter{Query: query}
+	for _, value := range types {
+		companyType := entity.CompanyType(value)
+		if !companyType.IsValid() {
+			return filter, fmt.Errorf("invalid -type %q", value)
+		}
+		filter.Types = append(filter.Types, companyType)
+	}
+	if registered != "" {
+		b, err := strconv.ParseBool(registered)
+		if err != nil {
+			return filter, fmt.Errorf("invalid -registered: %w", err)
+		}
+		filter.Registered = &b
+	}
+	for name, value := range map[string]string{"min-employees": minEmployees, "max-employees": maxEmployees} {
+		if value == "" {
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return filter, fmt.Errorf("invalid -%s %q", name, value)
+		}
+		if name == "min-employees" {
+			filter.MinEmployees = &n
+		} else {
+			filter.MaxEmployees = &n
+		}
+	}
+	if owner != "" {
+		id, err := uuid.Parse(owner)
+		if err != nil {
+			return filter, fmt.Errorf("invalid -owner: %w", err)
+		}
+		filter.OwnerID = &id
+	}
+	for name, value := range map[string]string{"from": from, "to": to} {
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return filter, fmt.Errorf("invalid -%s: %w", name, err)
+		}
+		if name == "from" {
+			filter.CreatedFrom = t
+		} else {
+			filter.CreatedTo = t
+		}
+	}
+	return filter, nil
+}
@@ -1,8 +1,13 @@
 package config
 
 import (
+	"errors"
+	"fmt"
 	"strings"
+	"time"
 
+	"github.com/assylzhan-a/company-task/pkg/secretbox"
+	"github.com/google/uuid"
 	"github.com/spf13/viper"
 )
 
@@ -11,24 +16,301 @@ type Config struct {
 	DatabaseURL      string
 	ServerAddress    string
 	JWTSecret        string
+	JWTAlgorithm     string
+	JWTIssuer        string
+	JWTAudience      string
+	JWTKeyRotation   time.Duration
+	JWTKeyPrepublish time.Duration
+	JWTKeyCheck      time.Duration
+	// KeyEncryptionKey encrypts secrets stored in the database, such as
+	// private signing keys. It is required for asymmetric JWT algorithms.
+	KeyEncryptionKey []byte
+	AccessTokenTTL   time.Duration
+	RefreshTokenTTL  time.Duration
+	DenylistSync     time.Duration
+	DefaultUserRole  string
+	// BootstrapAdmin names an existing user that is granted the admin role
+	// at startup.
+	BootstrapAdmin string
+	// DefaultOrganizationID is joined by newly registered users; uuid.Nil disables it.
+	DefaultOrganizationID  uuid.UUID
+	PasswordMinLength      int
+	PasswordRequireUpper   bool
+	PasswordRequireLower   bool
+	PasswordRequireDigit   bool
+	PasswordRequireSymbol  bool
+	PasswordRejectBreached bool
+	// PasswordBreachRangeURL is the k-anonymity range API that passwords
+	// are checked against if PasswordRejectBreached is set.
+	PasswordBreachRangeURL string
+	PasswordBreachTimeout  time.Duration
+	// LoginFreeAttempts failures are allowed before LoginBaseDelay applies;
+	// the delay doubles with every further failure up to LoginMaxDelay.
+	LoginFreeAttempts       int
+	LoginBaseDelay          time.Duration
+	LoginMaxDelay           time.Duration
+	LoginMaxAccountFailures int
+	LoginMaxIPFailures      int
+	LoginLockoutDuration    time.Duration
+	LoginFailureWindow      time.Duration
+	// MFAIssuer names the service in authenticator apps.
+	MFAIssuer       string
+	MFAChallengeTTL time.Duration
+	// MFARequiredPermissions are left out of the sessions of users without
+	// MFA enabled.
+	MFARequiredPermissions []string
+	// Mailer selects how emails are delivered: "log", "file" or "smtp".
+	Mailer       string
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+	MailFrom     string
+	MailDir      string
+	// MailQueueSize is how many emails may wait to be sent in the
+	// background.
+	MailQueueSize int
+	// AppBaseURL is the frontend address used in password reset and
+	// verification links.
+	AppBaseURL                string
+	PasswordResetTTL          time.Duration
+	EmailVerificationTTL      time.Duration
+	EmailVerificationRequired bool
+	// LocalLoginEnabled allows registration and password login; turn it off
+	// to only allow single sign-on.
+	LocalLoginEnabled bool
+	// OIDCIssuerURL enables single sign-on with the OpenID Connect provider.
+	OIDCIssuerURL     string
+	OIDCClientID      string
+	OIDCClientSecret  string
+	OIDCRedirectURL   string
+	OIDCScopes        []string
+	OIDCUsernameClaim string
+	OIDCRoleClaim     string
+	// OIDCRoleMapping maps role claim values to roles, e.g. "engineering=editor,it-admins=admin".
+	OIDCRoleMapping  string
+	OIDCLoginTimeout time.Duration
 	LogLevel         string
+	// MaxRequestBodyBytes limits the size of JSON request bodies.
+	MaxRequestBodyBytes int64
+	// IdempotencyKeyTTL is how long responses are replayed for retries with
+	// the same Idempotency-Key.
+	IdempotencyKeyTTL        time.Duration
+	IdempotencyPurgeInterval time.Duration
+	// SecurityEventAppendInterval is how often queued security events are
+	// appended to the audit log.
+	SecurityEventAppendInterval time.Duration
+	// CompanyBatchMaxOperations limits the operations of a company batch.
+	CompanyBatchMaxOperations int
+	// CompanyStatsCacheTTL bounds how long company statistics are cached;
+	// changes invalidate them sooner. CompanyStatsCacheSize limits the number
+	// of cached results.
+	CompanyStatsCacheTTL  time.Duration
+	CompanyStatsCacheSize int
+	// ImportMaxFileBytes limits the size of uploaded import files.
+	ImportMaxFileBytes int64
+	ImportWorkerTick   time.Duration
+	// ImportLease is how long an import stays with a worker that stopped
+	// saving progress before another worker resumes it.
+	ImportLease      time.Duration
 	KafkaBrokers     []string
 	KafkaClientID    string
-	OutboxWorkerTick string
+	OutboxWorkerTick time.Duration
+	ShutdownTimeout  time.Duration
 }
 
-func Load() Config {
+// Load reads the configuration from the environment. It returns an error
+// naming every value that is set but invalid.
+func Load() (Config, error) {
 	viper.AutomaticEnv()
 	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	viper.SetDefault("SHUTDOWN_TIMEOUT", 30*time.Second)
+	viper.SetDefault("JWT_ALGORITHM", "ES256")
+	viper.SetDefault("JWT_ISSUER", "company-service")
+	viper.SetDefault("JWT_AUDIENCE", "company-service")
+	viper.SetDefault("JWT_KEY_ROTATION_INTERVAL", 30*24*time.Hour)
+	viper.SetDefault("JWT_KEY_PREPUBLISH", time.Hour)
+	viper.SetDefault("JWT_KEY_CHECK_INTERVAL", time.Minute)
+	viper.SetDefault("ACCESS_TOKEN_TTL", 15*time.Minute)
+	viper.SetDefault("REFRESH_TOKEN_TTL", 30*24*time.Hour)
+	viper.SetDefault("DENYLIST_SYNC_INTERVAL", 10*time.Second)
+	viper.SetDefault("DEFAULT_USER_ROLE", "viewer")
+	viper.SetDefault("DEFAULT_ORGANIZATION_ID", "00000000-0000-0000-0000-000000000001")
+	viper.SetDefault("PASSWORD_MIN_LENGTH", 8)
+	viper.SetDefault("PASSWORD_REJECT_BREACHED", true)
+	viper.SetDefault("PASSWORD_BREACH_RANGE_URL", "https://api.pwnedpasswords.com/range/")
+	viper.SetDefault("PASSWORD_BREACH_TIMEOUT", 2*time.Second)
+	viper.SetDefault("LOGIN_FREE_ATTEMPTS", 3)
+	viper.SetDefault("LOGIN_BASE_DELAY", time.Second)
+	viper.SetDefault("LOGIN_MAX_DELAY", 30*time.Second)
+	viper.SetDefault("LOGIN_MAX_ACCOUNT_FAILURES", 10)
+	viper.SetDefault("LOGIN_MAX_IP_FAILURES", 50)
+	viper.SetDefault("LOGIN_LOCKOUT_DURATION", 15*time.Minute)
+	viper.SetDefault("LOGIN_FAILURE_WINDOW", 15*time.Minute)
+	viper.SetDefault("MFA_ISSUER", "Company Service")
+	viper.SetDefault("MFA_CHALLENGE_TTL", 5*time.Minute)
+	viper.SetDefault("MFA_REQUIRED_PERMISSIONS", "companies:delete")
+	viper.SetDefault("MAILER", "log")
+	viper.SetDefault("SMTP_PORT", 587)
+	viper.SetDefault("MAIL_FROM", "no-reply@localhost")
+	viper.SetDefault("MAIL_DIR", "mail")
+	viper.SetDefault("MAIL_QUEUE_SIZE", 1000)
+	viper.SetDefault("APP_BASE_URL", "http://localhost:8080")
+	viper.SetDefault("PASSWORD_RESET_TTL", time.Hour)
+	viper.SetDefault("EMAIL_VERIFICATION_TTL", 24*time.Hour)
+	viper.SetDefault("LOCAL_LOGIN_ENABLED", true)
+	viper.SetDefault("OIDC_SCOPES", "openid profile email")
+	viper.SetDefault("OIDC_USERNAME_CLAIM", "preferred_username")
+	viper.SetDefault("OIDC_ROLE_CLAIM", "groups")
+	viper.SetDefault("OIDC_LOGIN_TIMEOUT", 10*time.Minute)
+	viper.SetDefault("MAX_REQUEST_BODY_BYTES", 1<<20)
+	viper.SetDefault("IDEMPOTENCY_KEY_TTL", 24*time.Hour)
+	viper.SetDefault("IDEMPOTENCY_PURGE_INTERVAL", time.Hour)
+	viper.SetDefault("SECURITY_EVENT_APPEND_INTERVAL", time.Second)
+	viper.SetDefault("COMPANY_BATCH_MAX_OPERATIONS", 500)
+	viper.SetDefault("IMPORT_MAX_FILE_BYTES", 10<<20)
+	viper.SetDefault("IMPORT_WORKER_TICK", 2*time.Second)
+	viper.SetDefault("IMPORT_LEASE", time.Minute)
+	viper.SetDefault("COMPANY_STATS_CACHE_TTL", 10*time.Minute)
+	viper.SetDefault("COMPANY_STATS_CACHE_SIZE", 1000)
+	viper.SetDefault("OUTBOX_WORKER_TICK", 5*time.Second)
 
-	return Config{
-		Environment:      viper.GetString("ENVIRONMENT"),
-		DatabaseURL:      viper.GetString("DATABASE_URL"),
-		ServerAddress:    viper.GetString("SERVER_ADDRESS"),
-		JWTSecret:        viper.GetString("JWT_SECRET"),
-		LogLevel:         viper.GetString("LOG_LEVEL"),
-		KafkaBrokers:     strings.Split(viper.GetString("KAFKA_BROKERS"), ","),
-		KafkaClientID:    viper.GetString("KAFKA_CLIENT_ID"),
-		OutboxWorkerTick: viper.GetString("OUTBOX_WORKER_TICK"),
+	var errs []error
+	duration := func(key string) time.Duration {
+		d, err := positiveDuration(key)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		return d
 	}
+	organizationID := func(key string) uuid.UUID {
+		id, err := parseUUID(key)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		return id
+	}
+	encryptionKey := func(key string) []byte {
+		kek, err := keyEncryptionKey(key)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		return kek
+	}
+
+	cfg := Config{
+		Environment:                 viper.GetString("ENVIRONMENT"),
+		DatabaseURL:                 viper.GetString("DATABASE_URL"),
+		ServerAddress:               viper.GetString("SERVER_ADDRESS"),
+		JWTSecret:                   viper.GetString("JWT_SECRET"),
+		JWTAlgorithm:                viper.GetString("JWT_ALGORITHM"),
+		JWTIssuer:                   viper.GetString("JWT_ISSUER"),
+		JWTAudience:                 viper.GetString("JWT_AUDIENCE"),
+		JWTKeyRotation:              duration("JWT_KEY_ROTATION_INTERVAL"),
+		JWTKeyPrepublish:            duration("JWT_KEY_PREPUBLISH"),
+		JWTKeyCheck:                 duration("JWT_KEY_CHECK_INTERVAL"),
+		KeyEncryptionKey:            encryptionKey("KEY_ENCRYPTION_KEY"),
+		AccessTokenTTL:              duration("ACCESS_TOKEN_TTL"),
+		RefreshTokenTTL:             duration("REFRESH_TOKEN_TTL"),
+		DenylistSync:                duration("DENYLIST_SYNC_INTERVAL"),
+		DefaultUserRole:             viper.GetString("DEFAULT_USER_ROLE"),
+		BootstrapAdmin:              viper.GetString("BOOTSTRAP_ADMIN_USERNAME"),
+		DefaultOrganizationID:       organizationID("DEFAULT_ORGANIZATION_ID"),
+		PasswordMinLength:           viper.GetInt("PASSWORD_MIN_LENGTH"),
+		PasswordRequireUpper:        viper.GetBool("PASSWORD_REQUIRE_UPPER"),
+		PasswordRequireLower:        viper.GetBool("PASSWORD_REQUIRE_LOWER"),
+		PasswordRequireDigit:        viper.GetBool("PASSWORD_REQUIRE_DIGIT"),
+		PasswordRequireSymbol:       viper.GetBool("PASSWORD_REQUIRE_SYMBOL"),
+		PasswordRejectBreached:      viper.GetBool("PASSWORD_REJECT_BREACHED"),
+		PasswordBreachRangeURL:      viper.GetString("PASSWORD_BREACH_RANGE_URL"),
+		PasswordBreachTimeout:       duration("PASSWORD_BREACH_TIMEOUT"),
+		LoginFreeAttempts:           viper.GetInt("LOGIN_FREE_ATTEMPTS"),
+		LoginBaseDelay:              duration("LOGIN_BASE_DELAY"),
+		LoginMaxDelay:               duration("LOGIN_MAX_DELAY"),
+		LoginMaxAccountFailures:     viper.GetInt("LOGIN_MAX_ACCOUNT_FAILURES"),
+		LoginMaxIPFailures:          viper.GetInt("LOGIN_MAX_IP_FAILURES"),
+		LoginLockoutDuration:        duration("LOGIN_LOCKOUT_DURATION"),
+		LoginFailureWindow:          duration("LOGIN_FAILURE_WINDOW"),
+		MFAIssuer:                   viper.GetString("MFA_ISSUER"),
+		MFAChallengeTTL:             duration("MFA_CHALLENGE_TTL"),
+		MFARequiredPermissions:      strings.Fields(strings.ReplaceAll(viper.GetString("MFA_REQUIRED_PERMISSIONS"), ",", " ")),
+		Mailer:                      viper.GetString("MAILER"),
+		SMTPHost:                    viper.GetString("SMTP_HOST"),
+		SMTPPort:                    viper.GetInt("SMTP_PORT"),
+		SMTPUsername:                viper.GetString("SMTP_USERNAME"),
+		SMTPPassword:                viper.GetString("SMTP_PASSWORD"),
+		MailFrom:                    viper.GetString("MAIL_FROM"),
+		MailDir:                     viper.GetString("MAIL_DIR"),
+		MailQueueSize:               viper.GetInt("MAIL_QUEUE_SIZE"),
+		AppBaseURL:                  viper.GetString("APP_BASE_URL"),
+		PasswordResetTTL:            duration("PASSWORD_RESET_TTL"),
+		EmailVerificationTTL:        duration("EMAIL_VERIFICATION_TTL"),
+		EmailVerificationRequired:   viper.GetBool("EMAIL_VERIFICATION_REQUIRED"),
+		LocalLoginEnabled:           viper.GetBool("LOCAL_LOGIN_ENABLED"),
+		OIDCIssuerURL:               viper.GetString("OIDC_ISSUER_URL"),
+		OIDCClientID:                viper.GetString("OIDC_CLIENT_ID"),
+		OIDCClientSecret:            viper.GetString("OIDC_CLIENT_SECRET"),
+		OIDCRedirectURL:             viper.GetString("OIDC_REDIRECT_URL"),
+		OIDCScopes:                  strings.Fields(viper.GetString("OIDC_SCOPES")),
+		OIDCUsernameClaim:           viper.GetString("OIDC_USERNAME_CLAIM"),
+		OIDCRoleClaim:               viper.GetString("OIDC_ROLE_CLAIM"),
+		OIDCRoleMapping:             viper.GetString("OIDC_ROLE_MAPPING"),
+		OIDCLoginTimeout:            duration("OIDC_LOGIN_TIMEOUT"),
+		LogLevel:                    viper.GetString("LOG_LEVEL"),
+		MaxRequestBodyBytes:         viper.GetInt64("MAX_REQUEST_BODY_BYTES"),
+		IdempotencyKeyTTL:           duration("IDEMPOTENCY_KEY_TTL"),
+		IdempotencyPurgeInterval:    duration("IDEMPOTENCY_PURGE_INTERVAL"),
+		SecurityEventAppendInterval: duration("SECURITY_EVENT_APPEND_INTERVAL"),
+		CompanyBatchMaxOperations:   viper.GetInt("COMPANY_BATCH_MAX_OPERATIONS"),
+		ImportMaxFileBytes:          viper.GetInt64("IMPORT_MAX_FILE_BYTES"),
+		ImportWorkerTick:            duration("IMPORT_WORKER_TICK"),
+		ImportLease:                 duration("IMPORT_LEASE"),
+		CompanyStatsCacheTTL:        viper.GetDuration("COMPANY_STATS_CACHE_TTL"),
+		CompanyStatsCacheSize:       viper.GetInt("COMPANY_STATS_CACHE_SIZE"),
+		KafkaBrokers:                strings.Split(viper.GetString("KAFKA_BROKERS"), ","),
+		KafkaClientID:               viper.GetString("KAFKA_CLIENT_ID"),
+		OutboxWorkerTick:            duration("OUTBOX_WORKER_TICK"),
+		ShutdownTimeout:             duration("SHUTDOWN_TIMEOUT"),
+	}
+	return cfg, errors.Join(errs...)
+}
+
+// positiveDuration parses the value of the key itself, since viper reads
+// values it cannot parse as 0.
+func positiveDuration(key string) (time.Duration, error) {
+	value := viper.GetString(key)
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s: invalid duration %q", key, value)
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("%s: must be positive, got %q", key, value)
+	}
+	return d, nil
+}
+
+// keyEncryptionKey decodes the base64 encoded key, if it is set.
+func keyEncryptionKey(key string) ([]byte, error) {
+	value := viper.GetString(key)
+	if value == "" {
+		return nil, nil
+	}
+	kek, err := secretbox.ParseKey(value)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", key, err)
+	}
+	return kek, nil
+}
+
+// parseUUID parses the UUID of the key. An empty value is uuid.Nil.
+func parseUUID(key string) (uuid.UUID, error) {
+	value := viper.GetString(key)
+	if value == "" {
+		return uuid.Nil, nil
+	}
+	id, err := uuid.Parse(value)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("%s: invalid UUID %q", key, value)
+	}
+	return id, nil
 }
@@ -0,0 +1,141 @@
+package auth
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	r "github.com/assylzhan-a/company-task/internal/ports/repository"
+	"github.com/assylzhan-a/company-task/pkg/logger"
+	"github.com/google/uuid"
+)
+
+// Denylist holds revoked token and session IDs in memory so that every request
+// can be checked without a database round trip. Revocations are persisted and
+// the in-memory set is periodically reloaded to pick up revocations made by
+// other instances.
+type Denylist struct {
+	repo     r.TokenRepository
+	logger   *logger.Logger
+	interval time.Duration
+
+	mu      sync.RWMutex
+	entries map[uuid.UUID]time.Time
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewDenylist returns a denylist that Start syncs every syncInterval, which
+// must be positive.
+func NewDenylist(repo r.TokenRepository, syncInterval time.Duration, logger *logger.Logger) *Denylist {
+	return &Denylist{
+		repo:     repo,
+		logger:   logger,
+		interval: syncInterval,
+		entries:  make(map[uuid.UUID]time.Time),
+	}
+}
+
+// Revoke puts the IDs on the denylist until expiresAt, after which any token
+// referencing them has expired on its own.
+func (d *Denylist) Revoke(ctx context.Context, expiresAt time.Time, ids ...uuid.UUID) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	if err := d.repo.RevokeTokenIDs(ctx, ids, expiresAt); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	for _, id := range ids {
+		if current, ok := d.entries[id]; !ok || current.Before(expiresAt) {
+			d.entries[id] = expiresAt
+		}
+	}
+	d.mu.Unlock()
+	return nil
+}
+
+// IsRevoked reports whether any of the IDs is on the denylist.
+func (d *Denylist) IsRevoked(ids ...uuid.UUID) bool {
+	now := time.Now()
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	for _, id := range ids {
+		if expiresAt, ok := d.entries[id]; ok && now.Before(expiresAt) {
+			return true
+		}
+	}
+	return false
+}
+
+// Sync replaces the in-memory set with the persisted one and purges expired rows.
+func (d *Denylist) Sync(ctx context.Context) error {
+	if err := d.repo.DeleteExpiredTokens(ctx, time.Now()); err != nil {
+		d.logger.Warn("Failed to purge expired tokens", "error", err)
+	}
+
+	entries, err := d.repo.GetRevokedTokenIDs(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Revocations are never undone, so entries added locally while the query
+	// was running are kept until they expire.
+	now := time.Now()
+	d.mu.Lock()
+	for id, expiresAt := range d.entries {
+		if _, ok := entries[id]; !ok && now.Before(expiresAt) {
+			entries[id] = expiresAt
+		}
+	}
+	d.entries = entries
+	d.mu.Unlock()
+	return nil
+}
+
+func (d *Denylist) Name() string {
+	return "token_denylist"
+}
+
+// Start loads the denylist and keeps it in sync in the background.
+func (d *Denylist) Start(ctx context.Context) error {
+	if err := d.Sync(ctx); err != nil {
+		return err
+	}
+
+	d.stop = make(chan struct{})
+	d.done = make(chan struct{})
+	go func() {
+		defer close(d.done)
+		ticker := time.NewTicker(d.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-d.stop:
+				return
+			case <-ticker.C:
+				if err := d.Sync(ctx); err != nil {
+					d.logger.Error("Failed to sync token denylist", "error", err)
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+func (d *Denylist) Stop(ctx context.Context) error {
+	if d.stop == nil {
+		return nil
+	}
+	close(d.stop)
+	select {
+	case <-d.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
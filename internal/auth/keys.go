@@ -0,0 +1,469 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/assylzhan-a/company-task/internal/domain/entity"
+	r "github.com/assylzhan-a/company-task/internal/ports/repository"
+	"github.com/assylzhan-a/company-task/pkg/logger"
+	"github.com/assylzhan-a/company-task/pkg/secretbox"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+)
+
+const (
+	AlgorithmHS256 = "HS256"
+	AlgorithmRS256 = "RS256"
+	AlgorithmES256 = "ES256"
+	AlgorithmEdDSA = "EdDSA"
+)
+
+var (
+	ErrUnsupportedAlgorithm = errors.New("unsupported signing algorithm")
+	ErrNoSigningKey         = errors.New("no active signing key")
+)
+
+// minReloadInterval limits how often a token with an unknown kid reloads the
+// keys, so that forged kids cannot hammer the database.
+const minReloadInterval = time.Second
+
+// KeyRotation configures how asymmetric signing keys are rotated.
+type KeyRotation struct {
+	// Interval is how long a key is used for signing.
+	Interval time.Duration
+	// Prepublish is how long a key is published in the JWKS before it is used,
+	// giving verifiers time to fetch it.
+	Prepublish time.Duration
+	// VerifyGrace is how long a retired key is still accepted for verification.
+	// It must be at least the access token lifetime.
+	VerifyGrace time.Duration
+	// CheckInterval is how often keys are reloaded and rotated.
+	CheckInterval time.Duration
+}
+
+type signingKey struct {
+	id        string
+	method    jwt.SigningMethod
+	private   interface{}
+	public    interface{}
+	notBefore time.Time
+	retiresAt time.Time
+	expiresAt time.Time
+}
+
+// KeySet holds the keys used to sign and verify access tokens, identified by
+// kid. Asymmetric keys are stored in Postgres, encrypted with the key
+// encryption key, so all instances share them and are rotated on schedule;
+// the HMAC key set holds a single static secret.
+type KeySet struct {
+	algorithm string
+	repo      r.SigningKeyRepository
+	box       *secretbox.Box
+	rotation  KeyRotation
+	logger    *logger.Logger
+
+	mu   sync.RWMutex
+	keys []*signingKey
+
+	reloadMu   sync.Mutex
+	lastReload time.Time
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewHMACKeySet returns a key set signing with HS256 and a shared secret.
+// The secret is never published, so the JWKS of this key set is empty.
+func NewHMACKeySet(secret string) *KeySet {
+	return &KeySet{
+		algorithm: AlgorithmHS256,
+		keys: []*signingKey{{
+			id:      "hs256",
+			method:  jwt.SigningMethodHS256,
+			private: []byte(secret),
+			public:  []byte(secret),
+		}},
+	}
+}
+
+// NewRotatingKeySet returns a key set for RS256, ES256 or EdDSA whose keys are
+// generated and rotated automatically once the key set is started. Private
+// keys are sealed with box before they are stored.
+func NewRotatingKeySet(algorithm string, repo r.SigningKeyRepository, box *secretbox.Box, rotation KeyRotation, logger *logger.Logger) (*KeySet, error) {
+	if _, err := signingMethod(algorithm); err != nil || algorithm == AlgorithmHS256 {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedAlgorithm, algorithm)
+	}
+	if box == nil {
+		return nil, errors.New("a key encryption key is required for rotating keys")
+	}
+	if rotation.Interval <= 0 || rotation.CheckInterval <= 0 {
+		return nil, errors.New("key rotation and check intervals must be positive")
+	}
+	return &KeySet{
+		algorithm: algorithm,
+		repo:      repo,
+		box:       box,
+		rotation:  rotation,
+		logger:    logger,
+	}, nil
+}
+
+func (k *KeySet) Algorithm() string {
+	return k.algorithm
+}
+
+// signingKey returns the newest key that is active at now.
+func (k *KeySet) signingKey(now time.Time) (*signingKey, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	var current *signingKey
+	for _, key := range k.keys {
+		if !key.activeAt(now) {
+			continue
+		}
+		if current == nil || key.notBefore.After(current.notBefore) {
+			current = key
+		}
+	}
+	if current == nil {
+		return nil, ErrNoSigningKey
+	}
+	return current, nil
+}
+
+// verificationKey returns the key with the given kid if it may still verify
+// tokens. An unknown kid reloads the keys once, since another instance may
+// have generated the key since the last check.
+func (k *KeySet) verificationKey(kid string, now time.Time) (*signingKey, bool) {
+	key, known := k.lookup(kid, now)
+	if known || kid == "" || k.repo == nil || !k.reload(kid) {
+		return key, key != nil
+	}
+	key, _ = k.lookup(kid, now)
+	return key, key != nil
+}
+
+// lookup returns the key with the given kid if it may still verify tokens, and
+// whether the kid is known at all.
+func (k *KeySet) lookup(kid string, now time.Time) (*signingKey, bool) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	for _, key := range k.keys {
+		if key.id != kid {
+			continue
+		}
+		if !key.expiresAt.IsZero() && !now.Before(key.expiresAt) {
+			return nil, true
+		}
+		if now.Before(key.notBefore) {
+			return nil, true
+		}
+		return key, true
+	}
+	return nil, false
+}
+
+// reload reloads the keys for an unknown kid, at most once per
+// minReloadInterval. Concurrent misses wait for the reload in progress. It
+// reports whether the keys were reloaded.
+func (k *KeySet) reload(kid string) bool {
+	k.reloadMu.Lock()
+	defer k.reloadMu.Unlock()
+
+	now := time.Now()
+	if now.Sub(k.lastReload) < minReloadInterval {
+		return false
+	}
+	k.lastReload = now
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := k.load(ctx, now); err != nil {
+		k.logger.Error("Failed to reload signing keys", "kid", kid, "error", err)
+		return false
+	}
+	return true
+}
+
+func (k *KeySet) publishedKeys(now time.Time) []*signingKey {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	published := make([]*signingKey, 0, len(k.keys))
+	for _, key := range k.keys {
+		if k.algorithm == AlgorithmHS256 || !now.Before(key.expiresAt) {
+			continue
+		}
+		published = append(published, key)
+	}
+	return published
+}
+
+func (k *KeySet) Name() string {
+	return "signing_keys"
+}
+
+// Start loads the keys, makes sure one is active and keeps rotating them in
+// the background. It is a no-op for the HMAC key set.
+func (k *KeySet) Start(ctx context.Context) error {
+	if k.repo == nil {
+		return nil
+	}
+	if err := k.Rotate(ctx); err != nil {
+		return err
+	}
+
+	k.stop = make(chan struct{})
+	k.done = make(chan struct{})
+	go func() {
+		defer close(k.done)
+		ticker := time.NewTicker(k.rotation.CheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-k.stop:
+				return
+			case <-ticker.C:
+				if err := k.Rotate(ctx); err != nil {
+					k.logger.Error("Failed to rotate signing keys", "error", err)
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+func (k *KeySet) Stop(ctx context.Context) error {
+	if k.stop == nil {
+		return nil
+	}
+	close(k.stop)
+	select {
+	case <-k.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Rotate reloads the keys from the repository and generates new ones when no
+// key is active or the active key retires within the prepublish window without
+// a successor.
+func (k *KeySet) Rotate(ctx context.Context) error {
+	now := time.Now()
+	if err := k.repo.DeleteExpired(ctx, now); err != nil {
+		k.logger.Warn("Failed to delete expired signing keys", "error", err)
+	}
+	if err := k.load(ctx, now); err != nil {
+		return err
+	}
+
+	current, err := k.signingKey(now)
+	if err != nil {
+		return k.generate(ctx, now)
+	}
+
+	if now.Add(k.rotation.Prepublish).Before(current.retiresAt) || k.hasSuccessor(current) {
+		return nil
+	}
+	return k.generate(ctx, current.retiresAt)
+}
+
+func (k *KeySet) hasSuccessor(current *signingKey) bool {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	for _, key := range k.keys {
+		if key.notBefore.After(current.notBefore) {
+			return true
+		}
+	}
+	return false
+}
+
+func (k *KeySet) load(ctx context.Context, now time.Time) error {
+	stored, err := k.repo.List(ctx, k.algorithm, now)
+	if err != nil {
+		return err
+	}
+
+	keys := make([]*signingKey, 0, len(stored))
+	for _, s := range stored {
+		key, err := k.openSigningKey(ctx, s)
+		if err != nil {
+			k.logger.Error("Skipping invalid signing key", "kid", s.ID, "error", err)
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].notBefore.Before(keys[j].notBefore) })
+
+	k.mu.Lock()
+	k.keys = keys
+	k.mu.Unlock()
+	return nil
+}
+
+func (k *KeySet) generate(ctx context.Context, notBefore time.Time) error {
+	der, err := generatePrivateKey(k.algorithm)
+	if err != nil {
+		return err
+	}
+
+	id := uuid.NewString()
+	sealed, err := k.box.Seal(der, []byte(id))
+	if err != nil {
+		return err
+	}
+
+	retiresAt := notBefore.Add(k.rotation.Interval)
+	stored := &entity.SigningKey{
+		ID:         id,
+		Algorithm:  k.algorithm,
+		PrivateKey: sealed,
+		NotBefore:  notBefore,
+		RetiresAt:  retiresAt,
+		ExpiresAt:  retiresAt.Add(k.rotation.VerifyGrace),
+		CreatedAt:  time.Now(),
+	}
+	if err := k.repo.Create(ctx, stored); err != nil {
+		return err
+	}
+
+	key, err := parseSigningKey(stored, der)
+	if err != nil {
+		return err
+	}
+
+	k.mu.Lock()
+	k.keys = append(k.keys, key)
+	k.mu.Unlock()
+
+	k.logger.Info("Generated signing key", "kid", stored.ID, "alg", stored.Algorithm, "not_before", stored.NotBefore)
+	return nil
+}
+
+// openSigningKey decrypts a stored key. Keys stored in plaintext before
+// encryption was introduced are sealed in place.
+func (k *KeySet) openSigningKey(ctx context.Context, stored *entity.SigningKey) (*signingKey, error) {
+	if secretbox.IsSealed(stored.PrivateKey) {
+		der, err := k.box.Open(stored.PrivateKey, []byte(stored.ID))
+		if err != nil {
+			return nil, err
+		}
+		return parseSigningKey(stored, der)
+	}
+
+	key, err := parseSigningKey(stored, stored.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := k.box.Seal(stored.PrivateKey, []byte(stored.ID))
+	if err != nil {
+		return nil, err
+	}
+	if err := k.repo.UpdatePrivateKey(ctx, stored.ID, sealed); err != nil {
+		k.logger.Warn("Failed to encrypt plaintext signing key", "kid", stored.ID, "error", err)
+	}
+	return key, nil
+}
+
+func (key *signingKey) activeAt(now time.Time) bool {
+	if now.Before(key.notBefore) {
+		return false
+	}
+	return key.retiresAt.IsZero() || now.Before(key.retiresAt)
+}
+
+func signingMethod(algorithm string) (jwt.SigningMethod, error) {
+	switch algorithm {
+	case AlgorithmHS256:
+		return jwt.SigningMethodHS256, nil
+	case AlgorithmRS256:
+		return jwt.SigningMethodRS256, nil
+	case AlgorithmES256:
+		return jwt.SigningMethodES256, nil
+	case AlgorithmEdDSA:
+		return jwt.SigningMethodEdDSA, nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedAlgorithm, algorithm)
+	}
+}
+
+// generatePrivateKey returns a new private key for the algorithm in PKCS #8 DER form.
+func generatePrivateKey(algorithm string) ([]byte, error) {
+	var private interface{}
+	var err error
+
+	switch algorithm {
+	case AlgorithmRS256:
+		private, err = rsa.GenerateKey(rand.Reader, 2048)
+	case AlgorithmES256:
+		private, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case AlgorithmEdDSA:
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedAlgorithm, algorithm)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return x509.MarshalPKCS8PrivateKey(private)
+}
+
+// parseSigningKey parses the decrypted PKCS #8 DER form of a stored key.
+func parseSigningKey(stored *entity.SigningKey, der []byte) (*signingKey, error) {
+	method, err := signingMethod(stored.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	private, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, err
+	}
+
+	signer, ok := private.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("%w: key is not a signer", ErrUnsupportedAlgorithm)
+	}
+
+	switch signer.(type) {
+	case *rsa.PrivateKey:
+		ok = stored.Algorithm == AlgorithmRS256
+	case *ecdsa.PrivateKey:
+		ok = stored.Algorithm == AlgorithmES256
+	case ed25519.PrivateKey:
+		ok = stored.Algorithm == AlgorithmEdDSA
+	default:
+		ok = false
+	}
+	if !ok {
+		return nil, fmt.Errorf("%w: key type does not match %s", ErrUnsupportedAlgorithm, stored.Algorithm)
+	}
+
+	return &signingKey{
+		id:        stored.ID,
+		method:    method,
+		private:   signer,
+		public:    signer.Public(),
+		notBefore: stored.NotBefore,
+		retiresAt: stored.RetiresAt,
+		expiresAt: stored.ExpiresAt,
+	}, nil
+}
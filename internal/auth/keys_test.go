@@ -0,0 +1,293 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/assylzhan-a/company-task/internal/domain/entity"
+	"github.com/assylzhan-a/company-task/pkg/logger"
+	"github.com/assylzhan-a/company-task/pkg/secretbox"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type signingKeyRepo struct {
+	mu    sync.Mutex
+	keys  map[string]*entity.SigningKey
+	lists int
+}
+
+func newSigningKeyRepo() *signingKeyRepo {
+	return &signingKeyRepo{keys: make(map[string]*entity.SigningKey)}
+}
+
+func (f *signingKeyRepo) Create(ctx context.Context, key *entity.SigningKey) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	stored := *key
+	f.keys[key.ID] = &stored
+	return nil
+}
+
+func (f *signingKeyRepo) List(ctx context.Context, algorithm string, now time.Time) ([]*entity.SigningKey, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.lists++
+	var keys []*entity.SigningKey
+	for _, key := range f.keys {
+		if key.Algorithm == algorithm && key.ExpiresAt.After(now) {
+			stored := *key
+			keys = append(keys, &stored)
+		}
+	}
+	return keys, nil
+}
+
+func (f *signingKeyRepo) UpdatePrivateKey(ctx context.Context, id string, privateKey []byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.keys[id].PrivateKey = privateKey
+	return nil
+}
+
+func (f *signingKeyRepo) DeleteExpired(ctx context.Context, now time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for id, key := range f.keys {
+		if !key.ExpiresAt.After(now) {
+			delete(f.keys, id)
+		}
+	}
+	return nil
+}
+
+// update changes a stored key, e.g. to move its schedule.
+func (f *signingKeyRepo) update(id string, fn func(key *entity.SigningKey)) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	fn(f.keys[id])
+}
+
+func (f *signingKeyRepo) stored() []*entity.SigningKey {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	keys := make([]*entity.SigningKey, 0, len(f.keys))
+	for _, key := range f.keys {
+		stored := *key
+		keys = append(keys, &stored)
+	}
+	return keys
+}
+
+func (f *signingKeyRepo) listCalls() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.lists
+}
+
+var testRotation = KeyRotation{
+	Interval:      time.Hour,
+	Prepublish:    10 * time.Minute,
+	VerifyGrace:   15 * time.Minute,
+	CheckInterval: time.Minute,
+}
+
+func newTestBox(t *testing.T) *secretbox.Box {
+	t.Helper()
+	box, err := secretbox.New(bytes.Repeat([]byte{3}, secretbox.KeySize))
+	require.NoError(t, err)
+	return box
+}
+
+func newTestKeySet(t *testing.T, algorithm string, repo *signingKeyRepo) *KeySet {
+	t.Helper()
+	keys, err := NewRotatingKeySet(algorithm, repo, newTestBox(t), testRotation, logger.NewLogger("error"))
+	require.NoError(t, err)
+	return keys
+}
+
+func jwkIDs(jwks JWKS) []string {
+	ids := make([]string, 0, len(jwks.Keys))
+	for _, key := range jwks.Keys {
+		ids = append(ids, key.KeyID)
+	}
+	return ids
+}
+
+func TestNewRotatingKeySet(t *testing.T) {
+	repo := newSigningKeyRepo()
+	log := logger.NewLogger("error")
+
+	_, err := NewRotatingKeySet(AlgorithmHS256, repo, newTestBox(t), testRotation, log)
+	assert.ErrorIs(t, err, ErrUnsupportedAlgorithm)
+	_, err = NewRotatingKeySet(AlgorithmES256, repo, nil, testRotation, log)
+	assert.Error(t, err, "a key encryption key is required")
+	_, err = NewRotatingKeySet(AlgorithmES256, repo, newTestBox(t), KeyRotation{}, log)
+	assert.Error(t, err)
+}
+
+func TestKeySetRotation(t *testing.T) {
+	repo := newSigningKeyRepo()
+	keys := newTestKeySet(t, AlgorithmES256, repo)
+	ctx := context.Background()
+
+	require.NoError(t, keys.Rotate(ctx))
+	stored := repo.stored()
+	require.Len(t, stored, 1)
+	first := stored[0]
+
+	// The private key is sealed with the key encryption key.
+	assert.True(t, secretbox.IsSealed(first.PrivateKey))
+	_, err := x509.ParsePKCS8PrivateKey(first.PrivateKey)
+	assert.Error(t, err)
+
+	current, err := keys.signingKey(time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, first.ID, current.id)
+
+	// Rotating again while the key is far from retirement is a no-op.
+	require.NoError(t, keys.Rotate(ctx))
+	assert.Len(t, repo.stored(), 1)
+
+	// Within the prepublish window a successor is generated to take over
+	// when the current key retires, and published right away.
+	retiresAt := time.Now().Add(5 * time.Minute)
+	repo.update(first.ID, func(key *entity.SigningKey) { key.RetiresAt = retiresAt })
+	require.NoError(t, keys.Rotate(ctx))
+	require.Len(t, repo.stored(), 2)
+	require.NoError(t, keys.Rotate(ctx))
+	require.Len(t, repo.stored(), 2, "a key with a successor is not rotated again")
+
+	current, err = keys.signingKey(time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, first.ID, current.id, "the successor is not used before the current key retires")
+
+	next, err := keys.signingKey(retiresAt)
+	require.NoError(t, err)
+	assert.NotEqual(t, first.ID, next.id)
+	assert.True(t, next.notBefore.Equal(retiresAt))
+	assert.ElementsMatch(t, []string{first.ID, next.id}, jwkIDs(keys.JWKS()))
+
+	// A retired key still verifies tokens during the grace period, but not
+	// once it has expired.
+	_, ok := keys.verificationKey(first.ID, retiresAt.Add(time.Minute))
+	assert.True(t, ok)
+	_, ok = keys.verificationKey(first.ID, first.ExpiresAt)
+	assert.False(t, ok)
+	_, ok = keys.verificationKey(next.id, time.Now())
+	assert.False(t, ok, "a prepublished key does not verify tokens before it is active")
+
+	// Once the successor is active, expired keys are deleted and no longer
+	// published.
+	repo.update(next.id, func(key *entity.SigningKey) { key.NotBefore = time.Now().Add(-time.Second) })
+	repo.update(first.ID, func(key *entity.SigningKey) { key.ExpiresAt = time.Now() })
+	require.NoError(t, keys.Rotate(ctx))
+	assert.Len(t, repo.stored(), 1)
+	assert.Equal(t, []string{next.id}, jwkIDs(keys.JWKS()))
+}
+
+func TestKeySetSealsPlaintextKeys(t *testing.T) {
+	repo := newSigningKeyRepo()
+	der, err := generatePrivateKey(AlgorithmES256)
+	require.NoError(t, err)
+	now := time.Now()
+	require.NoError(t, repo.Create(context.Background(), &entity.SigningKey{
+		ID:         uuid.NewString(),
+		Algorithm:  AlgorithmES256,
+		PrivateKey: der,
+		NotBefore:  now.Add(-time.Minute),
+		RetiresAt:  now.Add(time.Hour),
+		ExpiresAt:  now.Add(2 * time.Hour),
+		CreatedAt:  now,
+	}))
+
+	keys := newTestKeySet(t, AlgorithmES256, repo)
+	require.NoError(t, keys.Rotate(context.Background()))
+
+	stored := repo.stored()
+	require.Len(t, stored, 1, "the plaintext key is still used")
+	assert.True(t, secretbox.IsSealed(stored[0].PrivateKey))
+
+	// The sealed key loads again, but not with another key encryption key.
+	require.NoError(t, keys.Rotate(context.Background()))
+	_, err = keys.signingKey(time.Now())
+	require.NoError(t, err)
+
+	other, err := secretbox.New(bytes.Repeat([]byte{4}, secretbox.KeySize))
+	require.NoError(t, err)
+	_, err = other.Open(stored[0].PrivateKey, []byte(stored[0].ID))
+	assert.ErrorIs(t, err, secretbox.ErrInvalidCiphertext)
+}
+
+func TestKeySetReloadsUnknownKid(t *testing.T) {
+	repo := newSigningKeyRepo()
+	ctx := context.Background()
+	issuer := newTestKeySet(t, AlgorithmES256, repo)
+	verifier := newTestKeySet(t, AlgorithmES256, repo)
+	require.NoError(t, issuer.Rotate(ctx))
+	require.NoError(t, verifier.Rotate(ctx))
+
+	// The issuer retires its key early and generates a new one the verifier
+	// has not loaded yet.
+	for _, key := range repo.stored() {
+		repo.update(key.ID, func(key *entity.SigningKey) { key.RetiresAt = time.Now() })
+	}
+	require.NoError(t, issuer.Rotate(ctx))
+	require.Len(t, repo.stored(), 2)
+
+	tokens := NewTokenService(issuer, "test", "test", time.Minute)
+	token, _, err := tokens.IssueAccessToken(&entity.User{ID: uuid.New()}, uuid.New(), nil)
+	require.NoError(t, err)
+
+	lists := repo.listCalls()
+	claims, err := NewTokenService(verifier, "test", "test", time.Minute).ParseAccessToken(token)
+	require.NoError(t, err)
+	assert.NotEqual(t, uuid.Nil, claims.UserID)
+	assert.Equal(t, lists+1, repo.listCalls(), "the unknown kid reloads the keys once")
+
+	// Unknown kids do not reload again right away, and known kids never do.
+	unverified, _, err := new(jwt.Parser).ParseUnverified(token, &Claims{})
+	require.NoError(t, err)
+	_, ok := verifier.verificationKey("unknown", time.Now())
+	assert.False(t, ok)
+	_, ok = verifier.verificationKey(unverified.Header["kid"].(string), time.Now())
+	assert.True(t, ok)
+	assert.Equal(t, lists+1, repo.listCalls())
+}
+
+func TestJWKS(t *testing.T) {
+	for _, algorithm := range []string{AlgorithmRS256, AlgorithmES256, AlgorithmEdDSA} {
+		t.Run(algorithm, func(t *testing.T) {
+			keys := newTestKeySet(t, algorithm, newSigningKeyRepo())
+			require.NoError(t, keys.Rotate(context.Background()))
+
+			jwks := keys.JWKS()
+			require.Len(t, jwks.Keys, 1)
+			jwk := jwks.Keys[0]
+			assert.Equal(t, algorithm, jwk.Algorithm)
+			assert.Equal(t, "sig", jwk.Use)
+
+			// A token signed with the key verifies with the published key.
+			tokens := NewTokenService(keys, "test", "test", time.Minute)
+			token, _, err := tokens.IssueAccessToken(&entity.User{ID: uuid.New()}, uuid.New(), nil)
+			require.NoError(t, err)
+
+			public, err := jwk.PublicKey()
+			require.NoError(t, err)
+			parsed, err := jwt.Parse(token, func(token *jwt.Token) (interface{}, error) {
+				assert.Equal(t, jwk.KeyID, token.Header["kid"])
+				return public, nil
+			}, jwt.WithValidMethods([]string{algorithm}))
+			require.NoError(t, err)
+			assert.True(t, parsed.Valid)
+		})
+	}
+
+	assert.Empty(t, NewHMACKeySet("secret").JWKS().Keys, "the HMAC secret is never published")
+}
@@ -2,44 +2,188 @@ package auth
 
 import (
 	"context"
-	"github.com/assylzhan-a/company-task/config"
-	"github.com/golang-jwt/jwt/v4"
+	"fmt"
+	"net"
 	"net/http"
 	"strings"
+	"time"
 
+	"github.com/assylzhan-a/company-task/internal/domain/entity"
+	r "github.com/assylzhan-a/company-task/internal/ports/repository"
+	"github.com/assylzhan-a/company-task/internal/tenant"
 	"github.com/assylzhan-a/company-task/pkg/errors"
+	"github.com/google/uuid"
 )
 
-func JWTAuth(next http.Handler) http.Handler {
+// HeaderAPIKey carries the API key of machine clients.
+const HeaderAPIKey = "X-API-Key"
+
+// maxPendingDenials bounds the denial events recorded in the background.
+const maxPendingDenials = 64
+
+// denialTimeout bounds recording a denial event after the request is done.
+const denialTimeout = 5 * time.Second
+
+// Authenticator verifies access tokens and API keys on incoming requests.
+type Authenticator struct {
+	tokens   *TokenService
+	denylist *Denylist
+	apiKeys  r.APIKeyRepository
+	events   r.SecurityEventRepository
+	denials  chan struct{}
+}
+
+func NewAuthenticator(tokens *TokenService, denylist *Denylist, apiKeys r.APIKeyRepository, events r.SecurityEventRepository) *Authenticator {
+	return &Authenticator{
+		tokens:   tokens,
+		denylist: denylist,
+		apiKeys:  apiKeys,
+		events:   events,
+		denials:  make(chan struct{}, maxPendingDenials),
+	}
+}
+
+// Authenticate accepts either an API key in the X-API-Key header or a bearer
+// access token. Routes that act on a user session should use JWTAuth.
+func (a *Authenticator) Authenticate(next http.Handler) http.Handler {
+	jwtAuth := a.JWTAuth(next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rawKey := r.Header.Get(HeaderAPIKey)
+		if rawKey == "" {
+			jwtAuth.ServeHTTP(w, r)
+			return
+		}
+
+		principal, err := a.authenticateAPIKey(r.Context(), rawKey)
+		if err != nil {
+			errors.RespondWithError(w, r, errors.New(errors.CodeInvalidAPIKey, "Invalid API key"))
+			return
+		}
+
+		ctx := WithPrincipal(r.Context(), principal)
+		ctx = tenant.WithOrganization(ctx, principal.OrganizationID)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func (a *Authenticator) authenticateAPIKey(ctx context.Context, rawKey string) (*Principal, error) {
+	prefix, ok := entity.ParseAPIKeyPrefix(rawKey)
+	if !ok {
+		return nil, entity.ErrInvalidAPIKey
+	}
+
+	key, err := a.apiKeys.GetAPIKeyByPrefix(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if !key.Matches(rawKey) || !key.IsUsable(now) {
+		return nil, entity.ErrInvalidAPIKey
+	}
+
+	if key.TouchDue(now) {
+		if err := a.apiKeys.TouchAPIKey(ctx, key.ID, now); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Principal{
+		UserID:         key.ServiceAccountID,
+		APIKeyID:       key.ID,
+		OrganizationID: key.OrganizationID,
+		Permissions:    key.Permissions(),
+	}, nil
+}
+
+// JWTAuth only accepts bearer access tokens.
+func (a *Authenticator) JWTAuth(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		authHeader := r.Header.Get("Authorization")
 		if authHeader == "" {
-			errors.RespondWithError(w, errors.NewUnauthorizedError("Authorization header is required"))
+			errors.RespondWithError(w, r, errors.NewUnauthorizedError("Authorization header is required"))
 			return
 		}
 
 		bearerToken := strings.Split(authHeader, " ")
 		if len(bearerToken) != 2 || bearerToken[0] != "Bearer" {
-			errors.RespondWithError(w, errors.NewUnauthorizedError("Invalid authorization header format"))
+			errors.RespondWithError(w, r, errors.New(errors.CodeInvalidToken, "Invalid authorization header format"))
 			return
 		}
 
-		claims := jwt.MapClaims{}
-		token, err := jwt.ParseWithClaims(bearerToken[1], claims, func(token *jwt.Token) (interface{}, error) {
-			return []byte(config.Load().JWTSecret), nil
-		})
-
+		claims, err := a.tokens.ParseAccessToken(bearerToken[1])
 		if err != nil {
-			errors.RespondWithError(w, errors.NewUnauthorizedError("Invalid token"))
+			errors.RespondWithError(w, r, errors.New(errors.CodeInvalidToken, "Invalid token"))
 			return
 		}
 
-		if !token.Valid {
-			errors.RespondWithError(w, errors.NewUnauthorizedError("Invalid token"))
+		if a.denylist.IsRevoked(claims.TokenID(), claims.SessionID) {
+			errors.RespondWithError(w, r, errors.New(errors.CodeInvalidToken, "Token has been revoked"))
 			return
 		}
 
-		ctx := context.WithValue(r.Context(), "user", claims["user_id"])
+		principal := newPrincipal(claims)
+		ctx := WithPrincipal(r.Context(), principal)
+		if principal.OrganizationID != uuid.Nil {
+			ctx = tenant.WithOrganization(ctx, principal.OrganizationID)
+		}
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
+
+// RequirePermission only lets requests through whose token grants every given
+// permission, and records the requests it denies in the audit log. It must be
+// used after JWTAuth or Authenticate.
+func (a *Authenticator) RequirePermission(permissions ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if err := a.CheckPermissions(r, permissions...); err != nil {
+				errors.RespondWithError(w, r, err)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// CheckPermissions is RequirePermission for handlers whose permissions depend
+// on the request body. Denials are recorded like those of RequirePermission.
+func (a *Authenticator) CheckPermissions(r *http.Request, permissions ...string) error {
+	principal, ok := PrincipalFromContext(r.Context())
+	if !ok {
+		return errors.NewUnauthorizedError("Authentication required")
+	}
+
+	for _, permission := range permissions {
+		if !principal.HasPermission(permission) {
+			a.recordDenial(r, principal, permission)
+			return errors.Newf(errors.CodePermissionDenied, "Missing permission: {0}", permission)
+		}
+	}
+	return nil
+}
+
+// recordDenial records a permission_denied event in the background, so that
+// denials are answered without waiting for the database. When too many are
+// pending it records the event before returning rather than dropping it. The
+// request is denied even if the event cannot be recorded.
+func (a *Authenticator) recordDenial(r *http.Request, principal *Principal, permission string) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	reason := fmt.Sprintf("missing %s for %s %s", permission, r.Method, r.URL.Path)
+	event := entity.NewSecurityEvent(entity.SecurityEventPermissionDenied, "", host, &principal.UserID, reason)
+	select {
+	case a.denials <- struct{}{}:
+		go func() {
+			defer func() { <-a.denials }()
+			ctx, cancel := context.WithTimeout(context.Background(), denialTimeout)
+			defer cancel()
+			_ = a.events.Create(ctx, event)
+		}()
+	default:
+		_ = a.events.Create(r.Context(), event)
+	}
+}
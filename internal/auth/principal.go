@@ -0,0 +1,73 @@
+package auth
+
+import (
+	"context"
+
+	"github.com/assylzhan-a/company-task/internal/domain/entity"
+	"github.com/google/uuid"
+)
+
+type contextKey string
+
+const principalContextKey contextKey = "principal"
+
+// Principal is the authenticated caller of a request.
+type Principal struct {
+	UserID    uuid.UUID
+	SessionID uuid.UUID
+	TokenID   uuid.UUID
+	// APIKeyID is set instead of SessionID and TokenID when the caller is a
+	// service account authenticated with an API key.
+	APIKeyID uuid.UUID
+	// OrganizationID is the active tenant, or uuid.Nil if the user has none.
+	OrganizationID uuid.UUID
+	Roles          []string
+	Permissions    []string
+}
+
+func newPrincipal(claims *Claims) *Principal {
+	principal := &Principal{
+		UserID:      claims.UserID,
+		SessionID:   claims.SessionID,
+		TokenID:     claims.TokenID(),
+		Roles:       claims.Roles,
+		Permissions: claims.Permissions,
+	}
+	if claims.OrganizationID != nil {
+		principal.OrganizationID = *claims.OrganizationID
+	}
+	return principal
+}
+
+func (p *Principal) HasRole(role string) bool {
+	for _, r := range p.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Principal) HasPermission(permission string) bool {
+	for _, perm := range p.Permissions {
+		if perm == permission {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Principal) IsAdmin() bool {
+	return p.HasRole(entity.RoleAdmin)
+}
+
+// WithPrincipal returns a copy of ctx carrying the principal.
+func WithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalContextKey, principal)
+}
+
+// PrincipalFromContext returns the caller stored by JWTAuth or Authenticate, if any.
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	principal, ok := ctx.Value(principalContextKey).(*Principal)
+	return principal, ok && principal != nil
+}
@@ -0,0 +1,154 @@
+package auth
+
+import (
+	"errors"
+	"time"
+
+	"github.com/assylzhan-a/company-task/internal/domain/entity"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+)
+
+var ErrInvalidToken = errors.New("invalid token")
+
+// PurposeMFA marks the short-lived tokens that only prove a correct password
+// and can be exchanged for an access token with a second factor.
+const PurposeMFA = "mfa"
+
+// Claims are the claims carried by access tokens. The token ID (jti) identifies
+// a single access token and the session ID (sid) the login it was issued for,
+// so either can be put on the denylist.
+type Claims struct {
+	UserID         uuid.UUID  `json:"user_id"`
+	SessionID      uuid.UUID  `json:"sid"`
+	OrganizationID *uuid.UUID `json:"org,omitempty"`
+	Roles          []string   `json:"roles,omitempty"`
+	Permissions    []string   `json:"permissions,omitempty"`
+	// Purpose is empty for access tokens.
+	Purpose string `json:"purpose,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// TokenID returns the parsed jti claim.
+func (c *Claims) TokenID() uuid.UUID {
+	id, _ := uuid.Parse(c.ID)
+	return id
+}
+
+type TokenService struct {
+	keys      *KeySet
+	issuer    string
+	audience  string
+	accessTTL time.Duration
+}
+
+func NewTokenService(keys *KeySet, issuer, audience string, accessTTL time.Duration) *TokenService {
+	return &TokenService{
+		keys:      keys,
+		issuer:    issuer,
+		audience:  audience,
+		accessTTL: accessTTL,
+	}
+}
+
+func (s *TokenService) AccessTTL() time.Duration {
+	return s.accessTTL
+}
+
+// IssueAccessToken signs a short-lived access token for the user's session with
+// the currently active key, identified by the kid header. The user's roles and
+// permissions are embedded as claims, so changes to them take effect when the
+// token is next refreshed. The organization, if any, is the tenant the token
+// is scoped to.
+func (s *TokenService) IssueAccessToken(user *entity.User, sessionID uuid.UUID, organizationID *uuid.UUID) (string, time.Time, error) {
+	return s.sign(&Claims{
+		UserID:         user.ID,
+		SessionID:      sessionID,
+		OrganizationID: organizationID,
+		Roles:          user.Roles,
+		Permissions:    user.Permissions,
+	}, s.accessTTL)
+}
+
+// IssueMFAToken signs a challenge token for a user who passed the password
+// check but still has to present a second factor. It carries the requested
+// organization, if any, so that the login can be completed as asked.
+func (s *TokenService) IssueMFAToken(userID uuid.UUID, organizationID *uuid.UUID, ttl time.Duration) (string, time.Time, error) {
+	return s.sign(&Claims{
+		UserID:         userID,
+		OrganizationID: organizationID,
+		Purpose:        PurposeMFA,
+	}, ttl)
+}
+
+// ParseAccessToken verifies the signature against the key named by the kid
+// header and enforces the iss, aud, exp and nbf claims.
+func (s *TokenService) ParseAccessToken(tokenString string) (*Claims, error) {
+	claims, err := s.parse(tokenString)
+	if err != nil || claims.Purpose != "" {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+// ParseMFAToken verifies a token issued by IssueMFAToken.
+func (s *TokenService) ParseMFAToken(tokenString string) (*Claims, error) {
+	claims, err := s.parse(tokenString)
+	if err != nil || claims.Purpose != PurposeMFA {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+func (s *TokenService) sign(claims *Claims, ttl time.Duration) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(ttl)
+
+	key, err := s.keys.signingKey(now)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		ID:        uuid.NewString(),
+		Issuer:    s.issuer,
+		Audience:  jwt.ClaimStrings{s.audience},
+		Subject:   claims.UserID.String(),
+		IssuedAt:  jwt.NewNumericDate(now),
+		NotBefore: jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(expiresAt),
+	}
+	token := jwt.NewWithClaims(key.method, claims)
+	token.Header["kid"] = key.id
+
+	signed, err := token.SignedString(key.private)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return signed, expiresAt, nil
+}
+
+func (s *TokenService) parse(tokenString string) (*Claims, error) {
+	now := time.Now()
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, ok := s.keys.verificationKey(kid, now)
+		if !ok || token.Method.Alg() != key.method.Alg() {
+			return nil, ErrInvalidToken
+		}
+		return key.public, nil
+	})
+	if err != nil || !token.Valid {
+		return nil, ErrInvalidToken
+	}
+
+	if !claims.VerifyIssuer(s.issuer, true) ||
+		!claims.VerifyAudience(s.audience, true) ||
+		!claims.VerifyExpiresAt(now, true) ||
+		!claims.VerifyNotBefore(now, true) ||
+		claims.UserID == uuid.Nil {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
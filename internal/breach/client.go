@@ -0,0 +1,93 @@
+// Package breach checks whether passwords have appeared in public breaches,
+// using a k-anonymity range API such as the one of Have I Been Pwned.
+package breach
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/assylzhan-a/company-task/pkg/logger"
+)
+
+// DefaultRangeURL is the range API of Have I Been Pwned.
+const DefaultRangeURL = "https://api.pwnedpasswords.com/range/"
+
+// maxResponseBytes bounds a range response; real ones are well below 100 KiB.
+const maxResponseBytes = 1 << 20
+
+type Checker interface {
+	// IsBreached reports whether the password has appeared in a breach.
+	IsBreached(ctx context.Context, password string) bool
+}
+
+type Client struct {
+	rangeURL   string
+	httpClient *http.Client
+	logger     *logger.Logger
+}
+
+// NewClient returns a checker that queries the range API at rangeURL, to
+// which the first five hex digits of the password's SHA-1 hash are appended.
+// Neither the password nor its full hash leave the service.
+//
+// Lookups that fail are logged and the password is treated as not breached,
+// so that an outage of the API does not block registrations.
+func NewClient(rangeURL string, httpClient *http.Client, logger *logger.Logger) Checker {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 5 * time.Second}
+	}
+	return &Client{rangeURL: rangeURL, httpClient: httpClient, logger: logger}
+}
+
+func (c *Client) IsBreached(ctx context.Context, password string) bool {
+	breached, err := c.lookup(ctx, password)
+	if err != nil {
+		c.logger.Warn("Failed to check the password against breaches", "error", err)
+		return false
+	}
+	return breached
+}
+
+func (c *Client) lookup(ctx context.Context, password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := hash[:5], hash[5:]
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.rangeURL+prefix, nil)
+	if err != nil {
+		return false, err
+	}
+	// Padding hides the size of the response, which could otherwise hint at
+	// the prefix.
+	req.Header.Set("Add-Padding", "true")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("range API returned %s", resp.Status)
+	}
+
+	// Every line is a hash suffix and the number of times it was seen.
+	// Padding entries have a count of 0.
+	scanner := bufio.NewScanner(io.LimitReader(resp.Body, maxResponseBytes))
+	for scanner.Scan() {
+		candidate, count, ok := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if ok && strings.EqualFold(candidate, suffix) {
+			return count != "0", nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return false, fmt.Errorf("failed to read range response: %w", err)
+	}
+	return false, nil
+}
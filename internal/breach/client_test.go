@@ -0,0 +1,60 @@
+package breach
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/assylzhan-a/company-task/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// SHA-1 of "password123" is CBFDAC6008F9CAB4083784CBD1874F76618D2A97.
+const (
+	breachedPrefix = "CBFDA"
+	breachedSuffix = "C6008F9CAB4083784CBD1874F76618D2A97"
+)
+
+func newRangeServer(t *testing.T, status int, body string) (*httptest.Server, *[]string) {
+	t.Helper()
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		assert.Equal(t, "true", r.Header.Get("Add-Padding"))
+		w.WriteHeader(status)
+		fmt.Fprint(w, body)
+	}))
+	t.Cleanup(server.Close)
+	return server, &paths
+}
+
+func TestClientIsBreached(t *testing.T) {
+	server, paths := newRangeServer(t, http.StatusOK, "0018A45C4D1DEF81644B54AB7F969B88D65:1\r\n"+
+		breachedSuffix+":2254650\r\n"+
+		"00D4F6E8FA6EECAD2A3AA415EEC418D38EC:0\r\n")
+	client := NewClient(server.URL+"/range/", nil, logger.NewLogger("error"))
+
+	assert.True(t, client.IsBreached(context.Background(), "password123"))
+	assert.False(t, client.IsBreached(context.Background(), "correct horse battery"))
+	require.Len(t, *paths, 2)
+	assert.Equal(t, "/range/"+breachedPrefix, (*paths)[0], "only the hash prefix is sent")
+}
+
+func TestClientIgnoresPadding(t *testing.T) {
+	server, _ := newRangeServer(t, http.StatusOK, breachedSuffix+":0\r\n")
+	client := NewClient(server.URL+"/", nil, logger.NewLogger("error"))
+
+	assert.False(t, client.IsBreached(context.Background(), "password123"))
+}
+
+func TestClientFailsOpen(t *testing.T) {
+	server, _ := newRangeServer(t, http.StatusServiceUnavailable, breachedSuffix+":1\r\n")
+	client := NewClient(server.URL+"/", nil, logger.NewLogger("error")).(*Client)
+
+	_, err := client.lookup(context.Background(), "password123")
+	assert.Error(t, err)
+	assert.False(t, client.IsBreached(context.Background(), "password123"))
+}
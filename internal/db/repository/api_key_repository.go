@@ -0,0 +1,220 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/assylzhan-a/company-task/internal/domain/entity"
+	r "github.com/assylzhan-a/company-task/internal/ports/repository"
+	"github.com/go-errors/errors"
+	"github.com/google/uuid"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+const apiKeyColumns = `id, service_account_id, organization_id, prefix, key_hash, scopes,
+	expires_at, last_used_at, created_at, revoked_at`
+
+type apiKeyRepository struct {
+	db      *pgxpool.Pool
+	timeout time.Duration
+}
+
+func NewAPIKeyRepository(db *pgxpool.Pool) r.APIKeyRepository {
+	return &apiKeyRepository{
+		db:      db,
+		timeout: 30 * time.Second,
+	}
+}
+
+func (r *apiKeyRepository) CreateServiceAccount(ctx context.Context, account *entity.ServiceAccount) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// The backing user has an empty password hash, so it can never log in.
+	_, err = tx.Exec(ctx,
+		"INSERT INTO users (id, username, password) VALUES ($1, $2, '')",
+		account.ID, "service-account:"+account.ID.String())
+	if err != nil {
+		return fmt.Errorf("failed to insert service account user: %w", err)
+	}
+
+	_, err = tx.Exec(ctx,
+		"INSERT INTO organization_members (organization_id, user_id) VALUES ($1, $2)", account.OrganizationID, account.ID)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == foreignKeyViolationCode {
+			return entity.ErrOrganizationNotFound
+		}
+		return fmt.Errorf("failed to add service account to organization: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO service_accounts (id, organization_id, name, created_by, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`, account.ID, account.OrganizationID, account.Name, account.CreatedBy, account.CreatedAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == UniqueViolationCode {
+			return entity.ErrServiceAccountExists
+		}
+		return fmt.Errorf("failed to insert service account: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func (r *apiKeyRepository) GetServiceAccount(ctx context.Context, id uuid.UUID) (*entity.ServiceAccount, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	account := &entity.ServiceAccount{}
+	err := r.db.QueryRow(ctx,
+		"SELECT id, organization_id, name, created_by, created_at FROM service_accounts WHERE id = $1", id).
+		Scan(&account.ID, &account.OrganizationID, &account.Name, &account.CreatedBy, &account.CreatedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, entity.ErrServiceAccountNotFound
+		}
+		return nil, fmt.Errorf("failed to get service account: %w", err)
+	}
+	return account, nil
+}
+
+func (r *apiKeyRepository) ListServiceAccounts(ctx context.Context, organizationID uuid.UUID) ([]*entity.ServiceAccount, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	rows, err := r.db.Query(ctx, `
+		SELECT id, organization_id, name, created_by, created_at
+		FROM service_accounts
+		WHERE organization_id = $1
+		ORDER BY name
+	`, organizationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list service accounts: %w", err)
+	}
+	defer rows.Close()
+
+	var accounts []*entity.ServiceAccount
+	for rows.Next() {
+		account := &entity.ServiceAccount{}
+		if err := rows.Scan(&account.ID, &account.OrganizationID, &account.Name, &account.CreatedBy, &account.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan service account: %w", err)
+		}
+		accounts = append(accounts, account)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list service accounts: %w", err)
+	}
+	return accounts, nil
+}
+
+func (r *apiKeyRepository) CreateAPIKey(ctx context.Context, key *entity.APIKey) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	_, err := r.db.Exec(ctx, `
+		INSERT INTO api_keys (id, service_account_id, organization_id, prefix, key_hash, scopes, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`, key.ID, key.ServiceAccountID, key.OrganizationID, key.Prefix, key.KeyHash, key.Scopes, key.ExpiresAt, key.CreatedAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == foreignKeyViolationCode {
+			return entity.ErrServiceAccountNotFound
+		}
+		return fmt.Errorf("failed to insert api key: %w", err)
+	}
+	return nil
+}
+
+func (r *apiKeyRepository) GetAPIKeyByPrefix(ctx context.Context, prefix string) (*entity.APIKey, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	key, err := scanAPIKey(r.db.QueryRow(ctx, "SELECT "+apiKeyColumns+" FROM api_keys WHERE prefix = $1", prefix))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, entity.ErrAPIKeyNotFound
+		}
+		return nil, fmt.Errorf("failed to get api key: %w", err)
+	}
+	return key, nil
+}
+
+func (r *apiKeyRepository) ListAPIKeys(ctx context.Context, serviceAccountID uuid.UUID) ([]*entity.APIKey, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	rows, err := r.db.Query(ctx,
+		"SELECT "+apiKeyColumns+" FROM api_keys WHERE service_account_id = $1 ORDER BY created_at", serviceAccountID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list api keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []*entity.APIKey
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan api key: %w", err)
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list api keys: %w", err)
+	}
+	return keys, nil
+}
+
+func (r *apiKeyRepository) RevokeAPIKey(ctx context.Context, serviceAccountID, keyID uuid.UUID) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := r.db.Exec(ctx, `
+		UPDATE api_keys SET revoked_at = COALESCE(revoked_at, $3)
+		WHERE id = $1 AND service_account_id = $2
+	`, keyID, serviceAccountID, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to revoke api key: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return entity.ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+func (r *apiKeyRepository) TouchAPIKey(ctx context.Context, id uuid.UUID, usedAt time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	_, err := r.db.Exec(ctx, `
+		UPDATE api_keys SET last_used_at = $2
+		WHERE id = $1 AND (last_used_at IS NULL OR last_used_at <= $3)
+	`, id, usedAt, usedAt.Add(-entity.APIKeyTouchInterval))
+	if err != nil {
+		return fmt.Errorf("failed to record api key use: %w", err)
+	}
+	return nil
+}
+
+func scanAPIKey(row pgx.Row) (*entity.APIKey, error) {
+	key := &entity.APIKey{}
+	err := row.Scan(&key.ID, &key.ServiceAccountID, &key.OrganizationID, &key.Prefix, &key.KeyHash, &key.Scopes,
+		&key.ExpiresAt, &key.LastUsedAt, &key.CreatedAt, &key.RevokedAt)
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
@@ -2,11 +2,15 @@ package repository
 
 import (
 	"context"
+	"encoding/json"
 	"github.com/jackc/pgconn"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/assylzhan-a/company-task/internal/domain/entity"
 	r "github.com/assylzhan-a/company-task/internal/ports/repository"
+	"github.com/assylzhan-a/company-task/internal/tenant"
 	customError "github.com/assylzhan-a/company-task/pkg/errors"
 	"github.com/go-errors/errors"
 	"github.com/google/uuid"
@@ -16,6 +20,14 @@ import (
 
 const UniqueViolationCode = "23505"
 
+// companySlugConstraint is the unique constraint on the slugs of an
+// organization's companies.
+const companySlugConstraint = "companies_organization_id_slug_key"
+
+// insufficientPrivilegeCode is also returned when row-level security rejects
+// a row.
+const insufficientPrivilegeCode = "42501"
+
 type companyRepo struct {
 	pool    *pgxpool.Pool
 	timeout time.Duration
@@ -28,113 +40,534 @@ func NewCompanyRepository(pool *pgxpool.Pool) r.CompanyRepository {
 	}
 }
 
-func (r *companyRepo) CreateWithOutboxEvent(ctx context.Context, company *entity.Company, event *entity.OutboxEvent) error {
-	ctx, cancel := context.WithTimeout(ctx, r.timeout)
-	defer cancel()
+// inTenantTx runs fn in a transaction scoped to the organization of ctx. Besides
+// the explicit organization_id filters in every query, the transaction switches
+// to the company_tenant role so that Postgres row-level security rejects any
+// row of another tenant.
+func (r *companyRepo) inTenantTx(ctx context.Context, fn func(tx pgx.Tx, organizationID uuid.UUID) error) error {
+	return r.inTenantTxWithOptions(ctx, pgx.TxOptions{}, fn)
+}
 
-	tx, err := r.pool.Begin(ctx)
+// inTenantTxWithOptions is inTenantTx for transactions that need another
+// isolation level or access mode.
+func (r *companyRepo) inTenantTxWithOptions(ctx context.Context, options pgx.TxOptions, fn func(tx pgx.Tx, organizationID uuid.UUID) error) error {
+	organizationID, ok := tenant.OrganizationFromContext(ctx)
+	if !ok {
+		return customError.New(customError.CodeNoOrganization, "No active organization")
+	}
+
+	tx, err := r.pool.BeginTx(ctx, options)
 	if err != nil {
 		return customError.NewInternalServerError("Failed to begin transaction")
 	}
 	defer tx.Rollback(ctx)
 
-	_, err = tx.Exec(ctx, `
-		INSERT INTO companies (id, name, description, amount_of_employees, registered, type, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-	`, company.ID, company.Name, company.Description, company.AmountOfEmployees, company.Registered, company.Type, company.CreatedAt, company.UpdatedAt)
-	if err != nil {
-		var pgErr *pgconn.PgError
-		if errors.As(err, &pgErr) && pgErr.Code == UniqueViolationCode {
-			return customError.NewBadRequestError("Company with this name or ID already exists")
-		}
-		return customError.NewInternalServerError("Failed to create company")
+	if _, err := tx.Exec(ctx, "SET LOCAL ROLE company_tenant"); err != nil {
+		return customError.NewInternalServerError("Failed to set tenant role")
+	}
+	if _, err := tx.Exec(ctx, "SELECT set_config('app.current_organization', $1, true)", organizationID.String()); err != nil {
+		return customError.NewInternalServerError("Failed to set tenant")
 	}
 
-	_, err = tx.Exec(ctx, `
-		INSERT INTO outbox_events (id, event_type, payload, created_at)
-		VALUES ($1, $2, $3, $4)
-	`, event.ID, event.EventType, event.Payload, event.CreatedAt)
-	if err != nil {
-		return customError.NewInternalServerError("Failed to create outbox event")
+	if err := fn(tx, organizationID); err != nil {
+		return err
 	}
 
 	if err := tx.Commit(ctx); err != nil {
 		return customError.NewInternalServerError("Failed to commit transaction")
 	}
-
 	return nil
 }
 
-func (r *companyRepo) UpdateWithOutboxEvent(ctx context.Context, company *entity.Company, event *entity.OutboxEvent) error {
+func (r *companyRepo) CreateWithOutboxEvent(ctx context.Context, company *entity.Company, event *entity.OutboxEvent, revision *entity.CompanyRevision) error {
 	ctx, cancel := context.WithTimeout(ctx, r.timeout)
 	defer cancel()
 
-	tx, err := r.pool.Begin(ctx)
+	return r.inTenantTx(ctx, func(tx pgx.Tx, organizationID uuid.UUID) error {
+		return createCompany(ctx, tx, organizationID, &entity.CompanyChange{Company: company, Event: event, Revision: revision})
+	})
+}
+
+func (r *companyRepo) UpdateWithOutboxEvent(ctx context.Context, company *entity.Company, expectedVersion int64, event *entity.OutboxEvent, revision *entity.CompanyRevision) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	return r.inTenantTx(ctx, func(tx pgx.Tx, organizationID uuid.UUID) error {
+		return updateCompany(ctx, tx, organizationID, &entity.CompanyChange{Company: company, ExpectedVersion: expectedVersion, Event: event, Revision: revision})
+	})
+}
+
+// ApplyChanges stores the changes in order in one transaction. The kind of
+// each change is the operation of its revision.
+func (r *companyRepo) ApplyChanges(ctx context.Context, changes []*entity.CompanyChange) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	return r.inTenantTx(ctx, func(tx pgx.Tx, organizationID uuid.UUID) error {
+		for i, change := range changes {
+			var err error
+			switch change.Revision.Operation {
+			case entity.CompanyRevisionCreated:
+				err = createCompany(ctx, tx, organizationID, change)
+			case entity.CompanyRevisionUpdated:
+				err = updateCompany(ctx, tx, organizationID, change)
+			case entity.CompanyRevisionDeleted:
+				err = deleteCompany(ctx, tx, organizationID, change)
+			default:
+				err = customError.NewInternalServerError("Failed to save company")
+			}
+			if err != nil {
+				return &entity.CompanyChangeError{Index: i, Err: err}
+			}
+		}
+		return nil
+	})
+}
+
+func createCompany(ctx context.Context, tx pgx.Tx, organizationID uuid.UUID, change *entity.CompanyChange) error {
+	company := change.Company
+	company.OrganizationID = organizationID
+	slug, err := uniqueSlug(ctx, tx, organizationID, company.Slug)
 	if err != nil {
-		return customError.NewInternalServerError("Failed to begin transaction")
+		return err
 	}
-	defer tx.Rollback(ctx)
+	company.Slug = slug
 
-	_, err = tx.Exec(ctx, `
+	err = tx.QueryRow(ctx, `
+		INSERT INTO companies (id, organization_id, name, description, amount_of_employees, registered, type, owner_id, created_by, updated_by, created_at, updated_at, slug)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+		RETURNING version
+	`, company.ID, company.OrganizationID, company.Name, company.Description, company.AmountOfEmployees, company.Registered, company.Type,
+		company.OwnerID, company.CreatedBy, company.UpdatedBy, company.CreatedAt, company.UpdatedAt, company.Slug).Scan(&company.Version)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == UniqueViolationCode {
+			if pgErr.ConstraintName == companySlugConstraint {
+				return slugTakenError()
+			}
+			return customError.New(customError.CodeCompanyExists, "Company with this name or ID already exists")
+		}
+		return customError.NewInternalServerError("Failed to create company")
+	}
+
+	if err := insertCompanyRevision(ctx, tx, change.Revision, organizationID); err != nil {
+		return err
+	}
+	return insertOutboxEvent(ctx, tx, change.Event, organizationID)
+}
+
+func updateCompany(ctx context.Context, tx pgx.Tx, organizationID uuid.UUID, change *entity.CompanyChange) error {
+	company := change.Company
+	err := tx.QueryRow(ctx, `
 		UPDATE companies
-		SET name = $2, description = $3, amount_of_employees = $4, registered = $5, type = $6, updated_at = $7
-		WHERE id = $1
-	`, company.ID, company.Name, company.Description, company.AmountOfEmployees, company.Registered, company.Type, company.UpdatedAt)
+		SET name = $3, description = $4, amount_of_employees = $5, registered = $6, type = $7,
+		    owner_id = $8, updated_by = $9, updated_at = $10, version = version + 1
+		WHERE id = $1 AND organization_id = $2 AND ($11::BIGINT = 0 OR version = $11)
+		RETURNING version, slug
+	`, company.ID, organizationID, company.Name, company.Description, company.AmountOfEmployees, company.Registered, company.Type,
+		company.OwnerID, company.UpdatedBy, company.UpdatedAt, change.ExpectedVersion).Scan(&company.Version, &company.Slug)
 	if err != nil {
 		var pgErr *pgconn.PgError
 		if errors.As(err, &pgErr) && pgErr.Code == UniqueViolationCode {
-			return customError.NewBadRequestError("Company with this name already exists")
+			return customError.New(customError.CodeCompanyExists, "Company with this name already exists")
+		}
+		if errors.As(err, &pgErr) && pgErr.Code == foreignKeyViolationCode {
+			return customError.New(customError.CodeOwnerNotFound, "Owner not found")
+		}
+		if err == pgx.ErrNoRows {
+			return missingCompanyError(change.ExpectedVersion)
 		}
 		return customError.NewInternalServerError("Failed to update company")
 	}
 
-	_, err = tx.Exec(ctx, `
-		INSERT INTO outbox_events (id, event_type, payload, created_at)
-		VALUES ($1, $2, $3, $4)
-	`, event.ID, event.EventType, event.Payload, event.CreatedAt)
+	if err := insertCompanyRevision(ctx, tx, change.Revision, organizationID); err != nil {
+		return err
+	}
+	return insertOutboxEvent(ctx, tx, change.Event, organizationID)
+}
+
+func deleteCompany(ctx context.Context, tx pgx.Tx, organizationID uuid.UUID, change *entity.CompanyChange) error {
+	result, err := tx.Exec(ctx, `
+		DELETE FROM companies WHERE id = $1 AND organization_id = $2 AND ($3::BIGINT = 0 OR version = $3)
+	`, change.Company.ID, organizationID, change.ExpectedVersion)
 	if err != nil {
-		return customError.NewInternalServerError("Failed to create outbox event")
+		return customError.NewInternalServerError("Failed to delete company")
 	}
+	if result.RowsAffected() == 0 {
+		return missingCompanyError(change.ExpectedVersion)
+	}
+	if err := insertCompanyRevision(ctx, tx, change.Revision, organizationID); err != nil {
+		return err
+	}
+	return insertOutboxEvent(ctx, tx, change.Event, organizationID)
+}
 
-	if err := tx.Commit(ctx); err != nil {
-		return customError.NewInternalServerError("Failed to commit transaction")
+// missingCompanyError explains why a change matched no company: with an
+// expected version, the company was most likely changed since it was read.
+func missingCompanyError(expectedVersion int64) error {
+	if expectedVersion != 0 {
+		return customError.New(customError.CodeConflict, "Company was changed concurrently")
 	}
+	return customError.New(customError.CodeCompanyNotFound, "Company not found")
+}
 
-	return nil
+// UpsertWithOutboxEvent inserts the company, or replaces it if it exists with
+// the expected version. An expected version of 0 requires that the company
+// does not exist. Checking the version in the ON CONFLICT clause makes the
+// write fail rather than overwrite a change made since the caller read the
+// company.
+func (r *companyRepo) UpsertWithOutboxEvent(ctx context.Context, company *entity.Company, expectedVersion int64, event *entity.OutboxEvent, revision *entity.CompanyRevision) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	return r.inTenantTx(ctx, func(tx pgx.Tx, organizationID uuid.UUID) error {
+		company.OrganizationID = organizationID
+		if expectedVersion == 0 {
+			slug, err := uniqueSlug(ctx, tx, organizationID, company.Slug)
+			if err != nil {
+				return err
+			}
+			company.Slug = slug
+		}
+
+		// The slug of an existing company is kept.
+		err := tx.QueryRow(ctx, `
+			INSERT INTO companies (id, organization_id, name, description, amount_of_employees, registered, type, owner_id, created_by, updated_by, created_at, updated_at, slug)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $14)
+			ON CONFLICT (id) DO UPDATE
+			SET name = EXCLUDED.name, description = EXCLUDED.description, amount_of_employees = EXCLUDED.amount_of_employees,
+			    registered = EXCLUDED.registered, type = EXCLUDED.type, owner_id = EXCLUDED.owner_id,
+			    updated_by = EXCLUDED.updated_by, updated_at = EXCLUDED.updated_at, version = companies.version + 1
+			WHERE companies.organization_id = EXCLUDED.organization_id AND companies.version = $13
+			RETURNING version, slug
+		`, company.ID, company.OrganizationID, company.Name, company.Description, company.AmountOfEmployees, company.Registered, company.Type,
+			company.OwnerID, company.CreatedBy, company.UpdatedBy, company.CreatedAt, company.UpdatedAt, expectedVersion,
+			company.Slug).Scan(&company.Version, &company.Slug)
+		if err != nil {
+			var pgErr *pgconn.PgError
+			switch {
+			case err == pgx.ErrNoRows:
+				return customError.New(customError.CodeConflict, "Company was changed concurrently")
+			case errors.As(err, &pgErr) && pgErr.Code == UniqueViolationCode && pgErr.ConstraintName == companySlugConstraint:
+				return slugTakenError()
+			case errors.As(err, &pgErr) && pgErr.Code == UniqueViolationCode:
+				return customError.New(customError.CodeCompanyExists, "Company with this name already exists")
+			case errors.As(err, &pgErr) && pgErr.Code == insufficientPrivilegeCode:
+				// Row-level security rejects updating a company of another
+				// organization that has the same ID.
+				return customError.New(customError.CodeCompanyExists, "Company with this name or ID already exists")
+			case errors.As(err, &pgErr) && pgErr.Code == foreignKeyViolationCode:
+				return customError.New(customError.CodeOwnerNotFound, "Owner not found")
+			}
+			return customError.NewInternalServerError("Failed to save company")
+		}
+
+		if err := insertCompanyRevision(ctx, tx, revision, organizationID); err != nil {
+			return err
+		}
+		return insertOutboxEvent(ctx, tx, event, organizationID)
+	})
 }
 
-func (r *companyRepo) Delete(ctx context.Context, id uuid.UUID) error {
+func (r *companyRepo) DeleteWithOutboxEvent(ctx context.Context, id uuid.UUID, expectedVersion int64, event *entity.OutboxEvent, revision *entity.CompanyRevision) error {
 	ctx, cancel := context.WithTimeout(ctx, r.timeout)
 	defer cancel()
 
-	query := `DELETE FROM companies WHERE id = $1`
-	result, err := r.pool.Exec(ctx, query, id)
+	return r.inTenantTx(ctx, func(tx pgx.Tx, organizationID uuid.UUID) error {
+		return deleteCompany(ctx, tx, organizationID, &entity.CompanyChange{Company: &entity.Company{ID: id}, ExpectedVersion: expectedVersion, Event: event, Revision: revision})
+	})
+}
+
+func (r *companyRepo) GetByID(ctx context.Context, id uuid.UUID) (*entity.Company, error) {
+	return r.getCompany(ctx, "id = $1", id)
+}
+
+func (r *companyRepo) GetBySlug(ctx context.Context, slug string) (*entity.Company, error) {
+	return r.getCompany(ctx, "slug = $1", slug)
+}
+
+// getCompany returns the company of the organization that matches the
+// condition on its first query parameter.
+func (r *companyRepo) getCompany(ctx context.Context, condition string, arg interface{}) (*entity.Company, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var company *entity.Company
+	err := r.inTenantTx(ctx, func(tx pgx.Tx, organizationID uuid.UUID) error {
+		query := `SELECT ` + companyColumns + ` FROM companies WHERE ` + condition + ` AND organization_id = $2`
+		var err error
+		company, err = scanCompany(tx.QueryRow(ctx, query, arg, organizationID))
+		if err != nil {
+			if err == pgx.ErrNoRows {
+				return customError.New(customError.CodeCompanyNotFound, "Company not found")
+			}
+			return customError.NewInternalServerError("Failed to get company")
+		}
+		return nil
+	})
 	if err != nil {
-		return customError.NewInternalServerError("Failed to delete company")
-	}
-	if result.RowsAffected() == 0 {
-		return customError.NewNotFoundError("Company not found")
+		return nil, err
 	}
-	return nil
+	return company, nil
 }
 
-func (r *companyRepo) GetByID(ctx context.Context, id uuid.UUID) (*entity.Company, error) {
+// companyStreamBatch is the number of companies Stream fetches at a time.
+const companyStreamBatch = 500
+
+// Stream reads the companies through a server-side cursor, a batch at a time,
+// so that memory does not grow with the number of companies. The cursor runs
+// in a read-only REPEATABLE READ transaction, so the whole stream reads one
+// snapshot of the companies whatever changes while it runs. Like the stream
+// of security events it is not bounded by the repository timeout.
+func (r *companyRepo) Stream(ctx context.Context, filter entity.CompanyFilter, fn func(*entity.Company) error) error {
+	options := pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly}
+	return r.inTenantTxWithOptions(ctx, options, func(tx pgx.Tx, organizationID uuid.UUID) error {
+		condition, args := companyFilterCondition(filter, organizationID)
+		_, err := tx.Exec(ctx, `DECLARE company_stream NO SCROLL CURSOR FOR
+			SELECT `+companyColumns+` FROM companies WHERE `+condition+` ORDER BY created_at, id`, args...)
+		if err != nil {
+			return customError.NewInternalServerError("Failed to list companies")
+		}
+
+		batch := make([]*entity.Company, 0, companyStreamBatch)
+		for {
+			batch = batch[:0]
+			rows, err := tx.Query(ctx, "FETCH FORWARD "+strconv.Itoa(companyStreamBatch)+" FROM company_stream")
+			if err != nil {
+				return customError.NewInternalServerError("Failed to list companies")
+			}
+			for rows.Next() {
+				company, err := scanCompany(rows)
+				if err != nil {
+					rows.Close()
+					return customError.NewInternalServerError("Failed to list companies")
+				}
+				batch = append(batch, company)
+			}
+			rows.Close()
+			if rows.Err() != nil {
+				return customError.NewInternalServerError("Failed to list companies")
+			}
+
+			for _, company := range batch {
+				if err := fn(company); err != nil {
+					return err
+				}
+			}
+			if len(batch) < companyStreamBatch {
+				return nil
+			}
+		}
+	})
+}
+
+// Stats groups by the positions of the dimension columns, so that their
+// expressions and parameters are not repeated in the GROUP BY clause. Periods
+// are truncated in UTC; width_bucket numbers the employee ranges from 0.
+func (r *companyRepo) Stats(ctx context.Context, query entity.CompanyStatsQuery) ([]*entity.CompanyStatsGroup, error) {
 	ctx, cancel := context.WithTimeout(ctx, r.timeout)
 	defer cancel()
 
-	query := `SELECT id, name, description, amount_of_employees, registered, type, created_at, updated_at FROM companies WHERE id = $1`
-	var company entity.Company
-	err := r.pool.QueryRow(ctx, query, id).Scan(
-		&company.ID, &company.Name, &company.Description, &company.AmountOfEmployees,
-		&company.Registered, &company.Type, &company.CreatedAt, &company.UpdatedAt,
+	var groups []*entity.CompanyStatsGroup
+	err := r.inTenantTx(ctx, func(tx pgx.Tx, organizationID uuid.UUID) error {
+		condition, args := companyFilterCondition(query.Filter, organizationID)
+		columns := make([]string, len(query.GroupBy))
+		positions := make([]string, len(query.GroupBy))
+		for i, dimension := range query.GroupBy {
+			switch dimension {
+			case entity.CompanyStatsByType:
+				columns[i] = "type"
+			case entity.CompanyStatsByRegistered:
+				columns[i] = "registered"
+			case entity.CompanyStatsByEmployees:
+				args = append(args, query.EmployeeBounds)
+				columns[i] = "width_bucket(amount_of_employees, $" + strconv.Itoa(len(args)) + "::integer[])"
+			case entity.CompanyStatsByCreated:
+				args = append(args, string(query.Bucket))
+				columns[i] = "date_trunc($" + strconv.Itoa(len(args)) + ", created_at AT TIME ZONE 'UTC')"
+			default:
+				return customError.NewInternalServerError("Failed to compute company statistics")
+			}
+			positions[i] = strconv.Itoa(i + 1)
+		}
+
+		sql := `SELECT ` + strings.Join(append(columns, "count(*)", "COALESCE(sum(amount_of_employees), 0)"), ", ") +
+			` FROM companies WHERE ` + condition
+		if len(positions) > 0 {
+			sql += ` GROUP BY ` + strings.Join(positions, ", ") + ` ORDER BY ` + strings.Join(positions, ", ")
+		}
+		rows, err := tx.Query(ctx, sql, args...)
+		if err != nil {
+			return customError.NewInternalServerError("Failed to compute company statistics")
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			group, err := scanCompanyStatsGroup(rows, &query)
+			if err != nil {
+				return customError.NewInternalServerError("Failed to compute company statistics")
+			}
+			groups = append(groups, group)
+		}
+		if rows.Err() != nil {
+			return customError.NewInternalServerError("Failed to compute company statistics")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
+
+func scanCompanyStatsGroup(row pgx.Row, query *entity.CompanyStatsQuery) (*entity.CompanyStatsGroup, error) {
+	group := &entity.CompanyStatsGroup{}
+	var companyType string
+	var employeeRange int32
+	dest := make([]interface{}, 0, len(query.GroupBy)+2)
+	for _, dimension := range query.GroupBy {
+		switch dimension {
+		case entity.CompanyStatsByType:
+			dest = append(dest, &companyType)
+		case entity.CompanyStatsByRegistered:
+			dest = append(dest, &group.Registered)
+		case entity.CompanyStatsByEmployees:
+			dest = append(dest, &employeeRange)
+		case entity.CompanyStatsByCreated:
+			dest = append(dest, &group.Created)
+		}
+	}
+	if err := row.Scan(append(dest, &group.Count, &group.TotalEmployees)...); err != nil {
+		return nil, err
+	}
+
+	group.Type = entity.CompanyType(companyType)
+	for _, dimension := range query.GroupBy {
+		if dimension == entity.CompanyStatsByEmployees {
+			group.Employees = query.EmployeeRange(int(employeeRange))
+		}
+	}
+	return group, nil
+}
+
+// companyFilterCondition returns the condition selecting the companies of
+// the organization that match the filter, and its arguments from $1 on.
+func companyFilterCondition(filter entity.CompanyFilter, organizationID uuid.UUID) (string, []interface{}) {
+	types := make([]string, len(filter.Types))
+	for i, t := range filter.Types {
+		types[i] = string(t)
+	}
+	var from, to *time.Time
+	if !filter.CreatedFrom.IsZero() {
+		from = &filter.CreatedFrom
+	}
+	if !filter.CreatedTo.IsZero() {
+		to = &filter.CreatedTo
+	}
+
+	return `organization_id = $1
+		  AND (cardinality($2::text[]) = 0 OR type = ANY($2))
+		  AND ($3::boolean IS NULL OR registered = $3)
+		  AND ($4::integer IS NULL OR amount_of_employees >= $4)
+		  AND ($5::integer IS NULL OR amount_of_employees <= $5)
+		  AND ($6 = '' OR name ILIKE '%' || $6 || '%')
+		  AND ($7::uuid IS NULL OR owner_id = $7)
+		  AND ($8::timestamptz IS NULL OR created_at >= $8)
+		  AND ($9::timestamptz IS NULL OR created_at < $9)`,
+		[]interface{}{organizationID, types, filter.Registered, filter.MinEmployees, filter.MaxEmployees,
+			escapeLike(filter.Query), filter.OwnerID, from, to}
+}
+
+const companyColumns = "id, organization_id, name, description, amount_of_employees, registered, type, owner_id, created_by, updated_by, created_at, updated_at, version, slug"
+
+func scanCompany(row pgx.Row) (*entity.Company, error) {
+	company := &entity.Company{}
+	err := row.Scan(
+		&company.ID, &company.OrganizationID, &company.Name, &company.Description, &company.AmountOfEmployees,
+		&company.Registered, &company.Type, &company.OwnerID, &company.CreatedBy, &company.UpdatedBy,
+		&company.CreatedAt, &company.UpdatedAt, &company.Version, &company.Slug,
 	)
 	if err != nil {
-		if err == pgx.ErrNoRows {
-			return nil, customError.NewNotFoundError("Company not found")
+		return nil, err
+	}
+	return company, nil
+}
+
+func (r *companyRepo) ListRevisions(ctx context.Context, id uuid.UUID) ([]*entity.CompanyRevision, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var revisions []*entity.CompanyRevision
+	err := r.inTenantTx(ctx, func(tx pgx.Tx, organizationID uuid.UUID) error {
+		rows, err := tx.Query(ctx, `
+			SELECT `+companyRevisionColumns+` FROM company_revisions
+			WHERE company_id = $1 AND organization_id = $2
+			ORDER BY revision
+		`, id, organizationID)
+		if err != nil {
+			return customError.NewInternalServerError("Failed to list company revisions")
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			revision, err := scanCompanyRevision(rows)
+			if err != nil {
+				return customError.NewInternalServerError("Failed to scan company revision")
+			}
+			revisions = append(revisions, revision)
+		}
+		if rows.Err() != nil {
+			return customError.NewInternalServerError("Failed to list company revisions")
+		}
+		if len(revisions) == 0 {
+			return customError.New(customError.CodeCompanyNotFound, "Company not found")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return revisions, nil
+}
+
+func (r *companyRepo) GetRevision(ctx context.Context, id uuid.UUID, number int64) (*entity.CompanyRevision, error) {
+	return r.getRevision(ctx, `
+		SELECT `+companyRevisionColumns+` FROM company_revisions
+		WHERE company_id = $1 AND organization_id = $2 AND revision = $3
+	`, id, number)
+}
+
+func (r *companyRepo) GetRevisionAt(ctx context.Context, id uuid.UUID, asOf time.Time) (*entity.CompanyRevision, error) {
+	return r.getRevision(ctx, `
+		SELECT `+companyRevisionColumns+` FROM company_revisions
+		WHERE company_id = $1 AND organization_id = $2 AND created_at <= $3
+		ORDER BY revision DESC
+		LIMIT 1
+	`, id, asOf)
+}
+
+// getRevision runs a query for a single revision whose first two parameters
+// are the company and organization ID.
+func (r *companyRepo) getRevision(ctx context.Context, query string, id uuid.UUID, arg interface{}) (*entity.CompanyRevision, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var revision *entity.CompanyRevision
+	err := r.inTenantTx(ctx, func(tx pgx.Tx, organizationID uuid.UUID) error {
+		var err error
+		revision, err = scanCompanyRevision(tx.QueryRow(ctx, query, id, organizationID, arg))
+		if err != nil {
+			if err == pgx.ErrNoRows {
+				return customError.New(customError.CodeCompanyRevisionNotFound, "Company revision not found")
+			}
+			return customError.NewInternalServerError("Failed to get company revision")
 		}
-		return nil, customError.NewInternalServerError("Failed to get company")
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
-	return &company, nil
+	return revision, nil
 }
 
 func (r *companyRepo) GetOutboxEvents(ctx context.Context, limit int) ([]*entity.OutboxEvent, error) {
@@ -142,7 +575,7 @@ func (r *companyRepo) GetOutboxEvents(ctx context.Context, limit int) ([]*entity
 	defer cancel()
 
 	rows, err := r.pool.Query(ctx, `
-		SELECT id, event_type, payload, created_at
+		SELECT id, organization_id, event_type, payload, created_at
 		FROM outbox_events
 		ORDER BY created_at
 		LIMIT $1
@@ -155,7 +588,7 @@ func (r *companyRepo) GetOutboxEvents(ctx context.Context, limit int) ([]*entity
 	var events []*entity.OutboxEvent
 	for rows.Next() {
 		var event entity.OutboxEvent
-		if err := rows.Scan(&event.ID, &event.EventType, &event.Payload, &event.CreatedAt); err != nil {
+		if err := rows.Scan(&event.ID, &event.OrganizationID, &event.EventType, &event.Payload, &event.CreatedAt); err != nil {
 			return nil, customError.NewInternalServerError("Failed to scan outbox event")
 		}
 		events = append(events, &event)
@@ -174,3 +607,90 @@ func (r *companyRepo) DeleteOutboxEvent(ctx context.Context, id uuid.UUID) error
 	}
 	return nil
 }
+
+// slugTakenError is returned when another company took the slug between
+// choosing it and inserting the company.
+func slugTakenError() error {
+	return customError.New(customError.CodeConflict, "Another company with the same slug was created concurrently")
+}
+
+// uniqueSlug returns base, or base with the first numeric suffix, such as
+// "acme-2", that no company of the organization uses.
+func uniqueSlug(ctx context.Context, tx pgx.Tx, organizationID uuid.UUID, base string) (string, error) {
+	rows, err := tx.Query(ctx, `
+		SELECT slug FROM companies
+		WHERE organization_id = $1 AND (slug = $2 OR slug LIKE $3)
+	`, organizationID, base, base+"-%")
+	if err != nil {
+		return "", customError.NewInternalServerError("Failed to choose company slug")
+	}
+	defer rows.Close()
+
+	taken := make(map[string]bool)
+	for rows.Next() {
+		var slug string
+		if err := rows.Scan(&slug); err != nil {
+			return "", customError.NewInternalServerError("Failed to choose company slug")
+		}
+		taken[slug] = true
+	}
+	if rows.Err() != nil {
+		return "", customError.NewInternalServerError("Failed to choose company slug")
+	}
+
+	slug := base
+	for n := 2; taken[slug]; n++ {
+		slug = base + "-" + strconv.Itoa(n)
+	}
+	return slug, nil
+}
+
+func insertOutboxEvent(ctx context.Context, tx pgx.Tx, event *entity.OutboxEvent, organizationID uuid.UUID) error {
+	event.OrganizationID = &organizationID
+	_, err := tx.Exec(ctx, `
+		INSERT INTO outbox_events (id, organization_id, event_type, payload, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`, event.ID, event.OrganizationID, event.EventType, event.Payload, event.CreatedAt)
+	if err != nil {
+		return customError.NewInternalServerError("Failed to create outbox event")
+	}
+	return nil
+}
+
+const companyRevisionColumns = "company_id, revision, operation, snapshot, actor_id, COALESCE(request_id, ''), created_at"
+
+func scanCompanyRevision(row pgx.Row) (*entity.CompanyRevision, error) {
+	revision := &entity.CompanyRevision{}
+	var snapshot []byte
+	err := row.Scan(&revision.CompanyID, &revision.Revision, &revision.Operation, &snapshot, &revision.ActorID,
+		&revision.RequestID, &revision.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(snapshot, &revision.Snapshot); err != nil {
+		return nil, err
+	}
+	return revision, nil
+}
+
+// insertCompanyRevision numbers the revision after the company's latest one.
+// Concurrent changes of a company are serialized by the row lock of the
+// change itself, and the primary key rejects duplicates in any case.
+func insertCompanyRevision(ctx context.Context, tx pgx.Tx, revision *entity.CompanyRevision, organizationID uuid.UUID) error {
+	snapshot, err := json.Marshal(revision.Snapshot)
+	if err != nil {
+		return customError.NewInternalServerError("Failed to encode company revision")
+	}
+
+	err = tx.QueryRow(ctx, `
+		INSERT INTO company_revisions (company_id, revision, organization_id, operation, snapshot, actor_id, request_id, created_at)
+		SELECT $1, COALESCE(MAX(revision), 0) + 1, $2, $3, $4, $5, NULLIF($6, ''), $7
+		FROM company_revisions WHERE company_id = $1
+		RETURNING revision
+	`, revision.CompanyID, organizationID, revision.Operation, snapshot, revision.ActorID, revision.RequestID,
+		revision.CreatedAt).Scan(&revision.Revision)
+	if err != nil {
+		return customError.NewInternalServerError("Failed to create company revision")
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/assylzhan-a/company-task/internal/domain/entity"
+	r "github.com/assylzhan-a/company-task/internal/ports/repository"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+type idempotencyRepository struct {
+	db      *pgxpool.Pool
+	timeout time.Duration
+}
+
+func NewIdempotencyRepository(db *pgxpool.Pool) r.IdempotencyRepository {
+	return &idempotencyRepository{
+		db:      db,
+		timeout: 30 * time.Second,
+	}
+}
+
+// Claim inserts the record as a placeholder without a response. Expired
+// records of the key, including claims of requests that never finished, are
+// replaced. The claim is committed before the request runs, so that no
+// transaction stays open for the duration of a request.
+func (r *idempotencyRepository) Claim(ctx context.Context, record *entity.IdempotencyRecord) (*entity.IdempotencyRecord, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var claimed bool
+	err = tx.QueryRow(ctx, `
+		INSERT INTO idempotency_keys (scope, key, fingerprint, status_code, header, body, created_at, expires_at)
+		VALUES ($1, $2, $3, 0, '{}', '', $4, $5)
+		ON CONFLICT (scope, key) DO UPDATE SET
+			fingerprint = EXCLUDED.fingerprint,
+			status_code = 0,
+			header = '{}',
+			body = '',
+			created_at = EXCLUDED.created_at,
+			expires_at = EXCLUDED.expires_at
+		WHERE idempotency_keys.expires_at <= EXCLUDED.created_at
+		RETURNING true
+	`, record.Scope, record.Key, record.Fingerprint, record.CreatedAt, record.ExpiresAt).Scan(&claimed)
+	if err == pgx.ErrNoRows {
+		return r.get(ctx, tx, record.Scope, record.Key)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim idempotency key: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit idempotency key: %w", err)
+	}
+	return nil, nil
+}
+
+// Store, Extend and Release only match the claim they were given, identified by its
+// creation time, so that a request whose claim expired and was taken over by
+// a retry does not overwrite the retry's.
+func (r *idempotencyRepository) Store(ctx context.Context, record *entity.IdempotencyRecord) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	header, err := json.Marshal(record.Header)
+	if err != nil {
+		return fmt.Errorf("failed to encode response header: %w", err)
+	}
+	_, err = r.db.Exec(ctx, `
+		UPDATE idempotency_keys SET status_code = $4, header = $5, body = $6, expires_at = $7
+		WHERE scope = $1 AND key = $2 AND created_at = $3 AND status_code = 0
+	`, record.Scope, record.Key, record.CreatedAt, record.StatusCode, header, record.Body, record.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to store idempotent response: %w", err)
+	}
+	return nil
+}
+
+func (r *idempotencyRepository) Extend(ctx context.Context, record *entity.IdempotencyRecord) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	_, err := r.db.Exec(ctx, `
+		UPDATE idempotency_keys SET expires_at = $4
+		WHERE scope = $1 AND key = $2 AND created_at = $3 AND status_code = 0
+	`, record.Scope, record.Key, record.CreatedAt, record.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to extend idempotency key: %w", err)
+	}
+	return nil
+}
+
+func (r *idempotencyRepository) Release(ctx context.Context, record *entity.IdempotencyRecord) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	_, err := r.db.Exec(ctx, `
+		DELETE FROM idempotency_keys
+		WHERE scope = $1 AND key = $2 AND created_at = $3 AND status_code = 0
+	`, record.Scope, record.Key, record.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to release idempotency key: %w", err)
+	}
+	return nil
+}
+
+func (r *idempotencyRepository) get(ctx context.Context, tx pgx.Tx, scope, key string) (*entity.IdempotencyRecord, error) {
+	record := &entity.IdempotencyRecord{Scope: scope, Key: key}
+	var header []byte
+	err := tx.QueryRow(ctx, `
+		SELECT fingerprint, status_code, header, body, created_at, expires_at
+		FROM idempotency_keys WHERE scope = $1 AND key = $2
+	`, scope, key).Scan(&record.Fingerprint, &record.StatusCode, &header, &record.Body, &record.CreatedAt, &record.ExpiresAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get idempotency key: %w", err)
+	}
+	if err := json.Unmarshal(header, &record.Header); err != nil {
+		return nil, fmt.Errorf("failed to decode response header: %w", err)
+	}
+	return record, nil
+}
+
+func (r *idempotencyRepository) DeleteExpired(ctx context.Context, now time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	_, err := r.db.Exec(ctx, "DELETE FROM idempotency_keys WHERE expires_at <= $1", now)
+	if err != nil {
+		return fmt.Errorf("failed to delete expired idempotency keys: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,266 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/assylzhan-a/company-task/internal/domain/entity"
+	r "github.com/assylzhan-a/company-task/internal/ports/repository"
+	"github.com/assylzhan-a/company-task/internal/tenant"
+	customError "github.com/assylzhan-a/company-task/pkg/errors"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// importJobColumns are the columns scanned by scanImportJob, without the
+// uploaded file.
+const importJobColumns = `id, organization_id, created_by, created_by_api_key_id, format, columns, mapping, status, total_rows, processed_rows,
+	succeeded_rows, failed_rows, COALESCE(error, ''), lease_token, lease_until, created_at, updated_at, started_at, finished_at`
+
+type importRepository struct {
+	db      *pgxpool.Pool
+	timeout time.Duration
+}
+
+func NewImportRepository(db *pgxpool.Pool) r.ImportRepository {
+	return &importRepository{
+		db:      db,
+		timeout: 30 * time.Second,
+	}
+}
+
+func (r *importRepository) Create(ctx context.Context, job *entity.ImportJob) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	columns, err := json.Marshal(job.Columns)
+	if err != nil {
+		return customError.NewInternalServerError("Failed to create import")
+	}
+	mapping, err := json.Marshal(job.Mapping)
+	if err != nil {
+		return customError.NewInternalServerError("Failed to create import")
+	}
+
+	_, err = r.db.Exec(ctx, `
+		INSERT INTO import_jobs (id, organization_id, created_by, created_by_api_key_id, format, data, columns, mapping, status, total_rows,
+			created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+	`, job.ID, job.OrganizationID, job.CreatedBy, job.CreatedByAPIKey, job.Format, job.Data, columns, mapping, job.Status, job.TotalRows, job.CreatedAt, job.UpdatedAt)
+	if err != nil {
+		return customError.NewInternalServerError("Failed to create import")
+	}
+	return nil
+}
+
+func (r *importRepository) GetByID(ctx context.Context, id uuid.UUID) (*entity.ImportJob, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	organizationID, ok := tenant.OrganizationFromContext(ctx)
+	if !ok {
+		return nil, customError.New(customError.CodeNoOrganization, "No active organization")
+	}
+
+	job, err := scanImportJob(r.db.QueryRow(ctx, `SELECT `+importJobColumns+` FROM import_jobs WHERE id = $1 AND organization_id = $2`,
+		id, organizationID))
+	if err == pgx.ErrNoRows {
+		return nil, customError.New(customError.CodeImportNotFound, "Import not found")
+	}
+	if err != nil {
+		return nil, customError.NewInternalServerError("Failed to get import")
+	}
+	return job, nil
+}
+
+func (r *importRepository) GetData(ctx context.Context, id uuid.UUID) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	organizationID, ok := tenant.OrganizationFromContext(ctx)
+	if !ok {
+		return nil, customError.New(customError.CodeNoOrganization, "No active organization")
+	}
+
+	var data []byte
+	err := r.db.QueryRow(ctx, `SELECT data FROM import_jobs WHERE id = $1 AND organization_id = $2`, id, organizationID).Scan(&data)
+	if err == pgx.ErrNoRows {
+		return nil, customError.New(customError.CodeImportNotFound, "Import not found")
+	}
+	if err != nil {
+		return nil, customError.NewInternalServerError("Failed to get import")
+	}
+	return data, nil
+}
+
+// Queue only changes jobs that are uploaded or failed, so that a job is never
+// queued twice while it runs.
+func (r *importRepository) Queue(ctx context.Context, id uuid.UUID, mapping entity.ImportMapping) (*entity.ImportJob, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	organizationID, ok := tenant.OrganizationFromContext(ctx)
+	if !ok {
+		return nil, customError.New(customError.CodeNoOrganization, "No active organization")
+	}
+	encoded, err := json.Marshal(mapping)
+	if err != nil {
+		return nil, customError.NewInternalServerError("Failed to queue import")
+	}
+
+	job, err := scanImportJob(r.db.QueryRow(ctx, `
+		UPDATE import_jobs SET mapping = $3, status = $4, error = NULL, updated_at = $5
+		WHERE id = $1 AND organization_id = $2 AND status IN ($6, $7)
+		RETURNING `+importJobColumns,
+		id, organizationID, encoded, entity.ImportQueued, time.Now(), entity.ImportUploaded, entity.ImportFailed))
+	if err == pgx.ErrNoRows {
+		// The job does not exist or is not in a state to be queued.
+		current, err := r.GetByID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		return nil, customError.Newf(customError.CodeConflict, "An import that is {0} cannot be run", string(current.Status))
+	}
+	if err != nil {
+		return nil, customError.NewInternalServerError("Failed to queue import")
+	}
+	return job, nil
+}
+
+// Claim skips jobs locked by other workers claiming them at the same time.
+func (r *importRepository) Claim(ctx context.Context, now, leaseUntil time.Time) (*entity.ImportJob, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var data []byte
+	job, err := scanImportJob(r.db.QueryRow(ctx, `
+		UPDATE import_jobs SET status = $1, lease_token = $2, lease_until = $3, started_at = COALESCE(started_at, $4), updated_at = $4
+		WHERE id = (
+			SELECT id FROM import_jobs
+			WHERE status = $5 OR (status = $1 AND (lease_until IS NULL OR lease_until <= $4))
+			ORDER BY created_at
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING `+importJobColumns+`, data`,
+		entity.ImportRunning, uuid.New(), leaseUntil, now, entity.ImportQueued), &data)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, customError.NewInternalServerError("Failed to claim import")
+	}
+	job.Data = data
+	return job, nil
+}
+
+func (r *importRepository) SaveProgress(ctx context.Context, job *entity.ImportJob, rowErrors []*entity.ImportRowError, leaseUntil *time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return customError.NewInternalServerError("Failed to begin transaction")
+	}
+	defer tx.Rollback(ctx)
+
+	job.UpdatedAt = time.Now()
+	tag, err := tx.Exec(ctx, `
+		UPDATE import_jobs SET status = $3, processed_rows = $4, succeeded_rows = $5, failed_rows = $6, error = NULLIF($7, ''),
+			lease_until = $8, updated_at = $9, finished_at = $10
+		WHERE id = $1 AND lease_token = $2
+	`, job.ID, job.LeaseToken, job.Status, job.ProcessedRows, job.SucceededRows, job.FailedRows, job.Error,
+		leaseUntil, job.UpdatedAt, job.FinishedAt)
+	if err != nil {
+		return customError.NewInternalServerError("Failed to save import progress")
+	}
+	if tag.RowsAffected() == 0 {
+		return entity.ErrImportLeaseLost
+	}
+
+	for _, rowErr := range rowErrors {
+		_, err := tx.Exec(ctx, `
+			INSERT INTO import_row_errors (job_id, row_number, field, code, message)
+			VALUES ($1, $2, NULLIF($3, ''), $4, $5)
+		`, job.ID, rowErr.Row, rowErr.Field, rowErr.Code, rowErr.Message)
+		if err != nil {
+			return customError.NewInternalServerError("Failed to save import progress")
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return customError.NewInternalServerError("Failed to commit transaction")
+	}
+	job.LeaseUntil = leaseUntil
+	return nil
+}
+
+func (r *importRepository) ListRowErrors(ctx context.Context, id uuid.UUID, afterRow, limit int) ([]*entity.ImportRowError, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	organizationID, ok := tenant.OrganizationFromContext(ctx)
+	if !ok {
+		return nil, customError.New(customError.CodeNoOrganization, "No active organization")
+	}
+
+	rows, err := r.db.Query(ctx, `
+		SELECT e.row_number, COALESCE(e.field, ''), e.code, e.message
+		FROM import_row_errors e
+		JOIN import_jobs j ON j.id = e.job_id
+		WHERE e.job_id = $1 AND j.organization_id = $2 AND e.row_number IN (
+			SELECT DISTINCT row_number FROM import_row_errors
+			WHERE job_id = $1 AND row_number > $3
+			ORDER BY row_number
+			LIMIT $4
+		)
+		ORDER BY e.row_number, e.id
+	`, id, organizationID, afterRow, limit)
+	if err != nil {
+		return nil, customError.NewInternalServerError("Failed to list import errors")
+	}
+	defer rows.Close()
+
+	rowErrors := []*entity.ImportRowError{}
+	for rows.Next() {
+		var rowErr entity.ImportRowError
+		if err := rows.Scan(&rowErr.Row, &rowErr.Field, &rowErr.Code, &rowErr.Message); err != nil {
+			return nil, customError.NewInternalServerError("Failed to list import errors")
+		}
+		rowErrors = append(rowErrors, &rowErr)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, customError.NewInternalServerError("Failed to list import errors")
+	}
+	return rowErrors, nil
+}
+
+// scanImportJob scans the importJobColumns of a row followed by any extra
+// destinations.
+func scanImportJob(row pgx.Row, extra ...interface{}) (*entity.ImportJob, error) {
+	var job entity.ImportJob
+	var columns, mapping []byte
+	var leaseToken *uuid.UUID
+	dest := append([]interface{}{
+		&job.ID, &job.OrganizationID, &job.CreatedBy, &job.CreatedByAPIKey, &job.Format, &columns, &mapping, &job.Status, &job.TotalRows,
+		&job.ProcessedRows, &job.SucceededRows, &job.FailedRows, &job.Error, &leaseToken, &job.LeaseUntil,
+		&job.CreatedAt, &job.UpdatedAt, &job.StartedAt, &job.FinishedAt,
+	}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+	if leaseToken != nil {
+		job.LeaseToken = *leaseToken
+	}
+	if err := json.Unmarshal(columns, &job.Columns); err != nil {
+		return nil, err
+	}
+	if mapping != nil {
+		if err := json.Unmarshal(mapping, &job.Mapping); err != nil {
+			return nil, err
+		}
+	}
+	return &job, nil
+}
@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/assylzhan-a/company-task/internal/domain/entity"
+	r "github.com/assylzhan-a/company-task/internal/ports/repository"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+type loginThrottleRepository struct {
+	db      *pgxpool.Pool
+	timeout time.Duration
+}
+
+func NewLoginThrottleRepository(db *pgxpool.Pool) r.LoginThrottleRepository {
+	return &loginThrottleRepository{
+		db:      db,
+		timeout: 30 * time.Second,
+	}
+}
+
+// Acquire locks the throttle row for the decision, so that the next attempt
+// only decides once this one was counted.
+func (r *loginThrottleRepository) Acquire(ctx context.Context, kind, key string, now time.Time, policy entity.LockoutPolicy) (*entity.LoginThrottle, bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO login_throttles (kind, key, failures, last_failure_at)
+		VALUES ($1, $2, 0, $3)
+		ON CONFLICT (kind, key) DO NOTHING
+	`, kind, key, now)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to acquire login throttle: %w", err)
+	}
+
+	throttle := &entity.LoginThrottle{Kind: kind, Key: key}
+	err = tx.QueryRow(ctx, `
+		SELECT failures, last_failure_at, locked_until FROM login_throttles
+		WHERE kind = $1 AND key = $2
+		FOR UPDATE
+	`, kind, key).Scan(&throttle.Failures, &throttle.LastFailureAt, &throttle.LockedUntil)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to acquire login throttle: %w", err)
+	}
+	if throttle.RetryAfter(now, policy) > 0 {
+		return throttle, false, nil
+	}
+
+	throttle.AddFailure(now, policy)
+	_, err = tx.Exec(ctx, `
+		UPDATE login_throttles SET failures = $3, last_failure_at = $4
+		WHERE kind = $1 AND key = $2
+	`, kind, key, throttle.Failures, throttle.LastFailureAt)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to record login attempt: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return throttle, true, nil
+}
+
+// Release restores the time of the previous failure unless another failure
+// was counted since.
+func (r *loginThrottleRepository) Release(ctx context.Context, throttle *entity.LoginThrottle) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	_, err := r.db.Exec(ctx, `
+		UPDATE login_throttles SET
+			failures = GREATEST(failures - 1, 0),
+			last_failure_at = CASE WHEN last_failure_at = $3 THEN $4 ELSE last_failure_at END
+		WHERE kind = $1 AND key = $2
+	`, throttle.Kind, throttle.Key, throttle.LastFailureAt, throttle.PreviousFailureAt)
+	if err != nil {
+		return fmt.Errorf("failed to release login attempt: %w", err)
+	}
+	return nil
+}
+
+func (r *loginThrottleRepository) Lock(ctx context.Context, kind, key string, until time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	_, err := r.db.Exec(ctx,
+		"UPDATE login_throttles SET locked_until = $3 WHERE kind = $1 AND key = $2", kind, key, until)
+	if err != nil {
+		return fmt.Errorf("failed to lock login: %w", err)
+	}
+	return nil
+}
+
+func (r *loginThrottleRepository) Reset(ctx context.Context, kind, key string) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	_, err := r.db.Exec(ctx, "DELETE FROM login_throttles WHERE kind = $1 AND key = $2", kind, key)
+	if err != nil {
+		return fmt.Errorf("failed to reset login throttle: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,205 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/assylzhan-a/company-task/internal/domain/entity"
+	r "github.com/assylzhan-a/company-task/internal/ports/repository"
+	"github.com/assylzhan-a/company-task/pkg/secretbox"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+type mfaRepository struct {
+	db      *pgxpool.Pool
+	box     *secretbox.Box
+	timeout time.Duration
+}
+
+// NewMFARepository stores TOTP secrets sealed with box, bound to the user ID.
+func NewMFARepository(db *pgxpool.Pool, box *secretbox.Box) r.MFARepository {
+	return &mfaRepository{
+		db:      db,
+		box:     box,
+		timeout: 30 * time.Second,
+	}
+}
+
+func (r *mfaRepository) Get(ctx context.Context, userID uuid.UUID) (*entity.UserMFA, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	mfa := &entity.UserMFA{}
+	var secret []byte
+	err := r.db.QueryRow(ctx, `
+		SELECT user_id, secret, enabled, last_used_step, created_at, confirmed_at
+		FROM user_mfa WHERE user_id = $1
+	`, userID).Scan(&mfa.UserID, &secret, &mfa.Enabled, &mfa.LastUsedStep, &mfa.CreatedAt, &mfa.ConfirmedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, entity.ErrMFANotEnrolled
+		}
+		return nil, fmt.Errorf("failed to get mfa enrollment: %w", err)
+	}
+
+	if !secretbox.IsSealed(secret) {
+		// Secrets stored before encryption was introduced are sealed now.
+		mfa.Secret = string(secret)
+		if err := r.sealLegacySecret(ctx, mfa.UserID, secret); err != nil {
+			return nil, err
+		}
+		return mfa, nil
+	}
+	plaintext, err := r.box.Open(secret, mfa.UserID[:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to open mfa secret: %w", err)
+	}
+	mfa.Secret = string(plaintext)
+	return mfa, nil
+}
+
+func (r *mfaRepository) sealLegacySecret(ctx context.Context, userID uuid.UUID, secret []byte) error {
+	sealed, err := r.box.Seal(secret, userID[:])
+	if err != nil {
+		return fmt.Errorf("failed to seal mfa secret: %w", err)
+	}
+	_, err = r.db.Exec(ctx, "UPDATE user_mfa SET secret = $2 WHERE user_id = $1 AND secret = $3", userID, sealed, secret)
+	if err != nil {
+		return fmt.Errorf("failed to seal mfa secret: %w", err)
+	}
+	return nil
+}
+
+func (r *mfaRepository) SaveEnrollment(ctx context.Context, mfa *entity.UserMFA) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	secret, err := r.box.Seal([]byte(mfa.Secret), mfa.UserID[:])
+	if err != nil {
+		return fmt.Errorf("failed to seal mfa secret: %w", err)
+	}
+
+	result, err := r.db.Exec(ctx, `
+		INSERT INTO user_mfa (user_id, secret, enabled, last_used_step, created_at)
+		VALUES ($1, $2, FALSE, 0, $3)
+		ON CONFLICT (user_id) DO UPDATE SET secret = EXCLUDED.secret, created_at = EXCLUDED.created_at
+		WHERE NOT user_mfa.enabled
+	`, mfa.UserID, secret, mfa.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save mfa enrollment: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return entity.ErrMFAAlreadyEnabled
+	}
+	return nil
+}
+
+func (r *mfaRepository) Enable(ctx context.Context, userID uuid.UUID, confirmedAt time.Time, step int64, codes []*entity.RecoveryCode) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	result, err := tx.Exec(ctx, `
+		UPDATE user_mfa SET enabled = TRUE, confirmed_at = $2, last_used_step = $3
+		WHERE user_id = $1 AND NOT enabled
+	`, userID, confirmedAt, step)
+	if err != nil {
+		return fmt.Errorf("failed to enable mfa: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return entity.ErrMFAAlreadyEnabled
+	}
+
+	if err := replaceRecoveryCodes(ctx, tx, userID, codes); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func (r *mfaRepository) Delete(ctx context.Context, userID uuid.UUID) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := r.db.Exec(ctx, "DELETE FROM user_mfa WHERE user_id = $1", userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete mfa enrollment: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return entity.ErrMFANotEnrolled
+	}
+	return nil
+}
+
+func (r *mfaRepository) UseStep(ctx context.Context, userID uuid.UUID, step int64) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := r.db.Exec(ctx,
+		"UPDATE user_mfa SET last_used_step = $2 WHERE user_id = $1 AND last_used_step < $2", userID, step)
+	if err != nil {
+		return false, fmt.Errorf("failed to record mfa step: %w", err)
+	}
+	return result.RowsAffected() == 1, nil
+}
+
+func (r *mfaRepository) UseRecoveryCode(ctx context.Context, userID uuid.UUID, codeHash string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := r.db.Exec(ctx, `
+		UPDATE mfa_recovery_codes SET used_at = $3
+		WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+	`, userID, codeHash, time.Now())
+	if err != nil {
+		return false, fmt.Errorf("failed to use recovery code: %w", err)
+	}
+	return result.RowsAffected() == 1, nil
+}
+
+func (r *mfaRepository) ReplaceRecoveryCodes(ctx context.Context, userID uuid.UUID, codes []*entity.RecoveryCode) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := replaceRecoveryCodes(ctx, tx, userID, codes); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func replaceRecoveryCodes(ctx context.Context, tx pgx.Tx, userID uuid.UUID, codes []*entity.RecoveryCode) error {
+	if _, err := tx.Exec(ctx, "DELETE FROM mfa_recovery_codes WHERE user_id = $1", userID); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+
+	for _, code := range codes {
+		_, err := tx.Exec(ctx,
+			"INSERT INTO mfa_recovery_codes (id, user_id, code_hash) VALUES ($1, $2, $3)",
+			code.ID, userID, code.CodeHash)
+		if err != nil {
+			return fmt.Errorf("failed to insert recovery code: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/assylzhan-a/company-task/internal/domain/entity"
+	r "github.com/assylzhan-a/company-task/internal/ports/repository"
+	"github.com/go-errors/errors"
+	"github.com/google/uuid"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+type organizationRepository struct {
+	db      *pgxpool.Pool
+	timeout time.Duration
+}
+
+func NewOrganizationRepository(db *pgxpool.Pool) r.OrganizationRepository {
+	return &organizationRepository{
+		db:      db,
+		timeout: 30 * time.Second,
+	}
+}
+
+func (r *organizationRepository) Create(ctx context.Context, organization *entity.Organization) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	_, err := r.db.Exec(ctx,
+		"INSERT INTO organizations (id, name, created_at) VALUES ($1, $2, $3)",
+		organization.ID, organization.Name, organization.CreatedAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == UniqueViolationCode {
+			return entity.ErrOrganizationExists
+		}
+		return fmt.Errorf("failed to insert organization: %w", err)
+	}
+	return nil
+}
+
+func (r *organizationRepository) GetByID(ctx context.Context, id uuid.UUID) (*entity.Organization, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	organization := &entity.Organization{}
+	err := r.db.QueryRow(ctx, "SELECT id, name, created_at FROM organizations WHERE id = $1", id).
+		Scan(&organization.ID, &organization.Name, &organization.CreatedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, entity.ErrOrganizationNotFound
+		}
+		return nil, fmt.Errorf("failed to get organization: %w", err)
+	}
+	return organization, nil
+}
+
+func (r *organizationRepository) List(ctx context.Context) ([]*entity.Organization, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	rows, err := r.db.Query(ctx, "SELECT id, name, created_at FROM organizations ORDER BY name")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list organizations: %w", err)
+	}
+	return scanOrganizations(rows)
+}
+
+func (r *organizationRepository) ListByUser(ctx context.Context, userID uuid.UUID) ([]*entity.Organization, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	rows, err := r.db.Query(ctx, `
+		SELECT o.id, o.name, o.created_at
+		FROM organizations o
+		JOIN organization_members m ON m.organization_id = o.id
+		WHERE m.user_id = $1
+		ORDER BY m.joined_at, o.name
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list user organizations: %w", err)
+	}
+	return scanOrganizations(rows)
+}
+
+func (r *organizationRepository) AddMember(ctx context.Context, organizationID, userID uuid.UUID) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	_, err := r.db.Exec(ctx, `
+		INSERT INTO organization_members (organization_id, user_id) VALUES ($1, $2)
+		ON CONFLICT (organization_id, user_id) DO NOTHING
+	`, organizationID, userID)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == foreignKeyViolationCode {
+			if pgErr.ConstraintName == "organization_members_user_id_fkey" {
+				return entity.ErrUserNotFound
+			}
+			return entity.ErrOrganizationNotFound
+		}
+		return fmt.Errorf("failed to add organization member: %w", err)
+	}
+	return nil
+}
+
+func (r *organizationRepository) RemoveMember(ctx context.Context, organizationID, userID uuid.UUID) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := r.db.Exec(ctx,
+		"DELETE FROM organization_members WHERE organization_id = $1 AND user_id = $2", organizationID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to remove organization member: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return entity.ErrNotOrganizationMember
+	}
+	return nil
+}
+
+func (r *organizationRepository) IsMember(ctx context.Context, organizationID, userID uuid.UUID) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var member bool
+	err := r.db.QueryRow(ctx, `
+		SELECT EXISTS(SELECT 1 FROM organization_members WHERE organization_id = $1 AND user_id = $2)
+	`, organizationID, userID).Scan(&member)
+	if err != nil {
+		return false, fmt.Errorf("failed to check organization membership: %w", err)
+	}
+	return member, nil
+}
+
+func scanOrganizations(rows pgx.Rows) ([]*entity.Organization, error) {
+	defer rows.Close()
+
+	var organizations []*entity.Organization
+	for rows.Next() {
+		organization := &entity.Organization{}
+		if err := rows.Scan(&organization.ID, &organization.Name, &organization.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan organization: %w", err)
+		}
+		organizations = append(organizations, organization)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list organizations: %w", err)
+	}
+	return organizations, nil
+}
@@ -0,0 +1,204 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/assylzhan-a/company-task/internal/domain/entity"
+	r "github.com/assylzhan-a/company-task/internal/ports/repository"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// securityEventChainLock is the advisory lock held by the appender, so that
+// two instances never extend the chain from the same predecessor.
+const securityEventChainLock = 7263501
+
+type securityEventRepository struct {
+	db      *pgxpool.Pool
+	timeout time.Duration
+}
+
+func NewSecurityEventRepository(db *pgxpool.Pool) r.SecurityEventRepository {
+	return &securityEventRepository{
+		db:      db,
+		timeout: 30 * time.Second,
+	}
+}
+
+func (r *securityEventRepository) Create(ctx context.Context, event *entity.SecurityEvent) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	_, err := r.db.Exec(ctx, `
+		INSERT INTO security_event_queue (id, type, user_id, username, ip_address, reason, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`, event.ID, event.Type, event.UserID, event.Username, event.IPAddress, event.Reason, event.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to queue security event: %w", err)
+	}
+	return nil
+}
+
+func (r *securityEventRepository) AppendQueued(ctx context.Context, limit int) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var locked bool
+	if err := tx.QueryRow(ctx, "SELECT pg_try_advisory_xact_lock($1)", securityEventChainLock).Scan(&locked); err != nil {
+		return 0, fmt.Errorf("failed to lock security events: %w", err)
+	}
+	if !locked {
+		// Another instance is appending.
+		return 0, nil
+	}
+
+	prev, err := scanSecurityEvent(tx.QueryRow(ctx, `
+		SELECT `+securityEventColumns+` FROM security_events
+		WHERE sequence IS NOT NULL
+		ORDER BY sequence DESC
+		LIMIT 1
+	`))
+	if errors.Is(err, pgx.ErrNoRows) {
+		prev = nil
+	} else if err != nil {
+		return 0, fmt.Errorf("failed to get last security event: %w", err)
+	}
+
+	rows, err := tx.Query(ctx, `
+		SELECT position, id, type, user_id, username, ip_address, COALESCE(reason, ''), created_at
+		FROM security_event_queue
+		ORDER BY position
+		LIMIT $1
+	`, limit)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list queued security events: %w", err)
+	}
+	var events []*entity.SecurityEvent
+	var last int64
+	for rows.Next() {
+		event := &entity.SecurityEvent{}
+		err := rows.Scan(&last, &event.ID, &event.Type, &event.UserID, &event.Username, &event.IPAddress,
+			&event.Reason, &event.CreatedAt)
+		if err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan queued security event: %w", err)
+		}
+		events = append(events, event)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to list queued security events: %w", err)
+	}
+	if len(events) == 0 {
+		return 0, nil
+	}
+
+	for _, event := range events {
+		event.Chain(prev)
+		_, err = tx.Exec(ctx, `
+			INSERT INTO security_events (id, type, user_id, username, ip_address, reason, created_at, sequence, prev_hash, hash)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		`, event.ID, event.Type, event.UserID, event.Username, event.IPAddress, event.Reason, event.CreatedAt,
+			event.Sequence, event.PrevHash, event.Hash)
+		if err != nil {
+			return 0, fmt.Errorf("failed to insert security event: %w", err)
+		}
+		prev = event
+	}
+
+	if _, err := tx.Exec(ctx, "DELETE FROM security_event_queue WHERE position <= $1", last); err != nil {
+		return 0, fmt.Errorf("failed to delete queued security events: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return len(events), nil
+}
+
+func (r *securityEventRepository) List(ctx context.Context, filter entity.SecurityEventFilter) ([]*entity.SecurityEvent, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	filter.Normalize()
+	events := []*entity.SecurityEvent{}
+	err := r.query(ctx, filter, filter.Limit, func(event *entity.SecurityEvent) error {
+		events = append(events, event)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// Stream is not bounded by the repository timeout because exports of the
+// whole log can take a while; the caller's context still applies.
+func (r *securityEventRepository) Stream(ctx context.Context, filter entity.SecurityEventFilter, fn func(*entity.SecurityEvent) error) error {
+	return r.query(ctx, filter, 0, fn)
+}
+
+// query selects the events matching the filter; a limit of 0 selects all.
+func (r *securityEventRepository) query(ctx context.Context, filter entity.SecurityEventFilter, limit int, fn func(*entity.SecurityEvent) error) error {
+	var from, to *time.Time
+	if !filter.From.IsZero() {
+		from = &filter.From
+	}
+	if !filter.To.IsZero() {
+		to = &filter.To
+	}
+	var limitArg *int
+	if limit > 0 {
+		limitArg = &limit
+	}
+
+	rows, err := r.db.Query(ctx, `
+		SELECT `+securityEventColumns+` FROM security_events
+		WHERE ($1::uuid IS NULL OR user_id = $1)
+		  AND ($2 = '' OR type = $2)
+		  AND ($3::timestamptz IS NULL OR created_at >= $3)
+		  AND ($4::timestamptz IS NULL OR created_at < $4)
+		  AND ($5 = 0 OR sequence > $5)
+		ORDER BY sequence NULLS FIRST, created_at, id
+		LIMIT $6
+	`, filter.UserID, filter.Type, from, to, filter.AfterSequence, limitArg)
+	if err != nil {
+		return fmt.Errorf("failed to list security events: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		event, err := scanSecurityEvent(rows)
+		if err != nil {
+			return fmt.Errorf("failed to scan security event: %w", err)
+		}
+		if err := fn(event); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to list security events: %w", err)
+	}
+	return nil
+}
+
+const securityEventColumns = "id, type, user_id, username, ip_address, COALESCE(reason, ''), created_at, sequence, COALESCE(prev_hash, ''), COALESCE(hash, '')"
+
+func scanSecurityEvent(row pgx.Row) (*entity.SecurityEvent, error) {
+	event := &entity.SecurityEvent{}
+	err := row.Scan(&event.ID, &event.Type, &event.UserID, &event.Username, &event.IPAddress, &event.Reason,
+		&event.CreatedAt, &event.Sequence, &event.PrevHash, &event.Hash)
+	if err != nil {
+		return nil, err
+	}
+	return event, nil
+}
@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/assylzhan-a/company-task/internal/domain/entity"
+	r "github.com/assylzhan-a/company-task/internal/ports/repository"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+type signingKeyRepository struct {
+	db      *pgxpool.Pool
+	timeout time.Duration
+}
+
+func NewSigningKeyRepository(db *pgxpool.Pool) r.SigningKeyRepository {
+	return &signingKeyRepository{
+		db:      db,
+		timeout: 30 * time.Second,
+	}
+}
+
+func (r *signingKeyRepository) Create(ctx context.Context, key *entity.SigningKey) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	_, err := r.db.Exec(ctx, `
+		INSERT INTO signing_keys (id, algorithm, private_key, not_before, retires_at, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`, key.ID, key.Algorithm, key.PrivateKey, key.NotBefore, key.RetiresAt, key.ExpiresAt, key.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert signing key: %w", err)
+	}
+	return nil
+}
+
+func (r *signingKeyRepository) List(ctx context.Context, algorithm string, now time.Time) ([]*entity.SigningKey, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	rows, err := r.db.Query(ctx, `
+		SELECT id, algorithm, private_key, not_before, retires_at, expires_at, created_at
+		FROM signing_keys
+		WHERE algorithm = $1 AND expires_at > $2
+		ORDER BY not_before
+	`, algorithm, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list signing keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []*entity.SigningKey
+	for rows.Next() {
+		key := &entity.SigningKey{}
+		if err := rows.Scan(&key.ID, &key.Algorithm, &key.PrivateKey, &key.NotBefore,
+			&key.RetiresAt, &key.ExpiresAt, &key.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan signing key: %w", err)
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list signing keys: %w", err)
+	}
+	return keys, nil
+}
+
+func (r *signingKeyRepository) UpdatePrivateKey(ctx context.Context, id string, privateKey []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	if _, err := r.db.Exec(ctx, "UPDATE signing_keys SET private_key = $2 WHERE id = $1", id, privateKey); err != nil {
+		return fmt.Errorf("failed to update signing key: %w", err)
+	}
+	return nil
+}
+
+func (r *signingKeyRepository) DeleteExpired(ctx context.Context, now time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	if _, err := r.db.Exec(ctx, "DELETE FROM signing_keys WHERE expires_at <= $1", now); err != nil {
+		return fmt.Errorf("failed to delete expired signing keys: %w", err)
+	}
+	return nil
+}
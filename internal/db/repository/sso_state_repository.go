@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/assylzhan-a/company-task/internal/domain/entity"
+	r "github.com/assylzhan-a/company-task/internal/ports/repository"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+type ssoStateRepository struct {
+	db      *pgxpool.Pool
+	timeout time.Duration
+}
+
+func NewSSOStateRepository(db *pgxpool.Pool) r.SSOStateRepository {
+	return &ssoStateRepository{
+		db:      db,
+		timeout: 30 * time.Second,
+	}
+}
+
+func (r *ssoStateRepository) Create(ctx context.Context, state *entity.SSOLoginState) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	// Abandoned logins are cleaned up as new ones start.
+	_, err := r.db.Exec(ctx, "DELETE FROM sso_login_states WHERE expires_at < $1", state.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to delete expired sso login states: %w", err)
+	}
+
+	_, err = r.db.Exec(ctx, `
+		INSERT INTO sso_login_states (state_hash, nonce, code_verifier, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`, entity.HashOneTimeToken(state.State), state.Nonce, state.CodeVerifier, state.ExpiresAt, state.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create sso login state: %w", err)
+	}
+	return nil
+}
+
+func (r *ssoStateRepository) Consume(ctx context.Context, state string, now time.Time) (*entity.SSOLoginState, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	// Deleting the row makes each state usable once, even with concurrent callbacks.
+	loginState := &entity.SSOLoginState{State: state}
+	err := r.db.QueryRow(ctx, `
+		DELETE FROM sso_login_states
+		WHERE state_hash = $1
+		RETURNING nonce, code_verifier, expires_at, created_at
+	`, entity.HashOneTimeToken(state)).Scan(&loginState.Nonce, &loginState.CodeVerifier, &loginState.ExpiresAt, &loginState.CreatedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, entity.ErrInvalidSSOState
+		}
+		return nil, fmt.Errorf("failed to consume sso login state: %w", err)
+	}
+	if !loginState.ExpiresAt.After(now) {
+		return nil, entity.ErrInvalidSSOState
+	}
+	return loginState, nil
+}
@@ -0,0 +1,250 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/assylzhan-a/company-task/internal/domain/entity"
+	r "github.com/assylzhan-a/company-task/internal/ports/repository"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+type tokenRepository struct {
+	db      *pgxpool.Pool
+	timeout time.Duration
+}
+
+func NewTokenRepository(db *pgxpool.Pool) r.TokenRepository {
+	return &tokenRepository{
+		db:      db,
+		timeout: 30 * time.Second,
+	}
+}
+
+func (r *tokenRepository) CreateRefreshToken(ctx context.Context, token *entity.RefreshToken) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	_, err := r.db.Exec(ctx, `
+		INSERT INTO refresh_tokens (id, user_id, family_id, organization_id, token_hash, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`, token.ID, token.UserID, token.FamilyID, token.OrganizationID, token.TokenHash, token.ExpiresAt, token.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert refresh token: %w", err)
+	}
+	return nil
+}
+
+func (r *tokenRepository) GetRefreshTokenByHash(ctx context.Context, hash string) (*entity.RefreshToken, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	token := &entity.RefreshToken{}
+	err := r.db.QueryRow(ctx, `
+		SELECT id, user_id, family_id, organization_id, token_hash, expires_at, created_at, revoked_at, replaced_by
+		FROM refresh_tokens WHERE token_hash = $1
+	`, hash).Scan(&token.ID, &token.UserID, &token.FamilyID, &token.OrganizationID, &token.TokenHash,
+		&token.ExpiresAt, &token.CreatedAt, &token.RevokedAt, &token.ReplacedBy)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, entity.ErrInvalidRefreshToken
+		}
+		return nil, fmt.Errorf("failed to get refresh token: %w", err)
+	}
+	return token, nil
+}
+
+func (r *tokenRepository) RotateRefreshToken(ctx context.Context, oldID uuid.UUID, replacement *entity.RefreshToken) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	result, err := tx.Exec(ctx, `
+		UPDATE refresh_tokens SET revoked_at = $2, replaced_by = $3
+		WHERE id = $1 AND revoked_at IS NULL
+	`, oldID, replacement.CreatedAt, replacement.ID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke refresh token: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return entity.ErrRefreshTokenReused
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO refresh_tokens (id, user_id, family_id, organization_id, token_hash, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`, replacement.ID, replacement.UserID, replacement.FamilyID, replacement.OrganizationID, replacement.TokenHash, replacement.ExpiresAt, replacement.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert refresh token: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func (r *tokenRepository) RevokeRefreshTokenFamily(ctx context.Context, familyID uuid.UUID) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	_, err := r.db.Exec(ctx,
+		"UPDATE refresh_tokens SET revoked_at = NOW() WHERE family_id = $1 AND revoked_at IS NULL", familyID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke refresh token family: %w", err)
+	}
+	return nil
+}
+
+func (r *tokenRepository) RevokeUserRefreshTokens(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	rows, err := r.db.Query(ctx, `
+		UPDATE refresh_tokens SET revoked_at = NOW()
+		WHERE user_id = $1 AND revoked_at IS NULL
+		RETURNING family_id
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to revoke user refresh tokens: %w", err)
+	}
+	defer rows.Close()
+
+	seen := make(map[uuid.UUID]struct{})
+	var families []uuid.UUID
+	for rows.Next() {
+		var familyID uuid.UUID
+		if err := rows.Scan(&familyID); err != nil {
+			return nil, fmt.Errorf("failed to scan family id: %w", err)
+		}
+		if _, ok := seen[familyID]; ok {
+			continue
+		}
+		seen[familyID] = struct{}{}
+		families = append(families, familyID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to revoke user refresh tokens: %w", err)
+	}
+	return families, nil
+}
+
+func (r *tokenRepository) RevokeTokenIDs(ctx context.Context, ids []uuid.UUID, expiresAt time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	_, err := r.db.Exec(ctx, `
+		INSERT INTO revoked_tokens (token_id, expires_at)
+		SELECT unnest($1::uuid[]), $2
+		ON CONFLICT (token_id) DO UPDATE SET expires_at = GREATEST(revoked_tokens.expires_at, EXCLUDED.expires_at)
+	`, ids, expiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to revoke token ids: %w", err)
+	}
+	return nil
+}
+
+func (r *tokenRepository) GetRevokedTokenIDs(ctx context.Context) (map[uuid.UUID]time.Time, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	rows, err := r.db.Query(ctx, "SELECT token_id, expires_at FROM revoked_tokens WHERE expires_at > NOW()")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get revoked token ids: %w", err)
+	}
+	defer rows.Close()
+
+	revoked := make(map[uuid.UUID]time.Time)
+	for rows.Next() {
+		var id uuid.UUID
+		var expiresAt time.Time
+		if err := rows.Scan(&id, &expiresAt); err != nil {
+			return nil, fmt.Errorf("failed to scan revoked token id: %w", err)
+		}
+		revoked[id] = expiresAt
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get revoked token ids: %w", err)
+	}
+	return revoked, nil
+}
+
+func (r *tokenRepository) DeleteExpiredTokens(ctx context.Context, now time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	if _, err := r.db.Exec(ctx, "DELETE FROM revoked_tokens WHERE expires_at <= $1", now); err != nil {
+		return fmt.Errorf("failed to delete expired revoked tokens: %w", err)
+	}
+	if _, err := r.db.Exec(ctx, "DELETE FROM refresh_tokens WHERE expires_at <= $1", now); err != nil {
+		return fmt.Errorf("failed to delete expired refresh tokens: %w", err)
+	}
+	if _, err := r.db.Exec(ctx, "DELETE FROM one_time_tokens WHERE expires_at <= $1", now); err != nil {
+		return fmt.Errorf("failed to delete expired one-time tokens: %w", err)
+	}
+	return nil
+}
+
+func (r *tokenRepository) CreateOneTimeToken(ctx context.Context, token *entity.OneTimeToken) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `
+		UPDATE one_time_tokens SET used_at = $3
+		WHERE user_id = $1 AND purpose = $2 AND used_at IS NULL
+	`, token.UserID, token.Purpose, token.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to invalidate one-time tokens: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO one_time_tokens (id, user_id, purpose, token_hash, email, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`, token.ID, token.UserID, token.Purpose, token.TokenHash, token.Email, token.ExpiresAt, token.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert one-time token: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func (r *tokenRepository) ConsumeOneTimeToken(ctx context.Context, purpose, hash string, now time.Time) (*entity.OneTimeToken, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	token := &entity.OneTimeToken{}
+	var email *string
+	err := r.db.QueryRow(ctx, `
+		UPDATE one_time_tokens SET used_at = $3
+		WHERE token_hash = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > $3
+		RETURNING id, user_id, purpose, token_hash, email, expires_at, created_at, used_at
+	`, hash, purpose, now).Scan(&token.ID, &token.UserID, &token.Purpose, &token.TokenHash, &email,
+		&token.ExpiresAt, &token.CreatedAt, &token.UsedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, entity.ErrInvalidOneTimeToken
+		}
+		return nil, fmt.Errorf("failed to consume one-time token: %w", err)
+	}
+	if email != nil {
+		token.Email = *email
+	}
+	return token, nil
+}
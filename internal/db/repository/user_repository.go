@@ -3,10 +3,15 @@ package repository
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/assylzhan-a/company-task/internal/domain/entity"
 	r "github.com/assylzhan-a/company-task/internal/ports/repository"
+	"github.com/go-errors/errors"
+	"github.com/google/uuid"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
 )
 
@@ -36,25 +41,215 @@ func (r *userRepository) Create(ctx context.Context, user *entity.User) error {
 		return entity.ErrUsernameTaken
 	}
 
-	_, err = r.db.Exec(ctx,
-		"INSERT INTO users (id, username, password) VALUES ($1, $2, $3)",
-		user.ID, user.Username, user.Password)
+	tx, err := r.db.Begin(ctx)
 	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if user.CreatedAt.IsZero() {
+		user.CreatedAt = time.Now()
+	}
+	_, err = tx.Exec(ctx, `
+		INSERT INTO users (id, username, password, email, email_verified, created_at)
+		VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6)
+	`, user.ID, user.Username, user.Password, user.Email, user.EmailVerified, user.CreatedAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == UniqueViolationCode && pgErr.ConstraintName == "idx_users_email" {
+			return entity.ErrEmailTaken
+		}
 		return fmt.Errorf("failed to insert user: %w", err)
 	}
+
+	for _, organizationID := range user.OrganizationIDs {
+		_, err = tx.Exec(ctx,
+			"INSERT INTO organization_members (organization_id, user_id) VALUES ($1, $2)", organizationID, user.ID)
+		if err != nil {
+			return fmt.Errorf("failed to add user to organization: %w", err)
+		}
+	}
+
+	for _, role := range user.Roles {
+		_, err = tx.Exec(ctx, "INSERT INTO user_roles (user_id, role) VALUES ($1, $2)", user.ID, role)
+		if err != nil {
+			return fmt.Errorf("failed to assign role %q: %w", role, err)
+		}
+	}
+
+	for _, identity := range user.Identities {
+		_, err = tx.Exec(ctx,
+			"INSERT INTO user_identities (issuer, subject, user_id, created_at) VALUES ($1, $2, $3, $4)",
+			identity.Issuer, identity.Subject, user.ID, identity.CreatedAt)
+		if err != nil {
+			return fmt.Errorf("failed to link identity: %w", err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
 	return nil
 }
 
+func (r *userRepository) GetByID(ctx context.Context, id uuid.UUID) (*entity.User, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	user, err := scanUser(r.db.QueryRow(ctx, "SELECT "+userColumns+" FROM users WHERE id = $1", id))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, entity.ErrUserNotFound
+		}
+		return nil, err
+	}
+	return user, nil
+}
+
 func (r *userRepository) GetByUsername(ctx context.Context, username string) (*entity.User, error) {
 	ctx, cancel := context.WithTimeout(ctx, r.timeout)
 	defer cancel()
 
+	return scanUser(r.db.QueryRow(ctx, "SELECT "+userColumns+" FROM users WHERE username = $1", username))
+}
+
+func (r *userRepository) GetByIdentity(ctx context.Context, issuer, subject string) (*entity.User, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	user, err := scanUser(r.db.QueryRow(ctx, `
+		SELECT `+userColumns+` FROM users
+		WHERE id = (SELECT user_id FROM user_identities WHERE issuer = $1 AND subject = $2)
+	`, issuer, subject))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, entity.ErrUserNotFound
+		}
+		return nil, err
+	}
+	return user, nil
+}
+
+func (r *userRepository) GetByEmail(ctx context.Context, email string) (*entity.User, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	user, err := scanUser(r.db.QueryRow(ctx, "SELECT "+userColumns+" FROM users WHERE LOWER(email) = LOWER($1)", email))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, entity.ErrUserNotFound
+		}
+		return nil, err
+	}
+	return user, nil
+}
+
+func (r *userRepository) UpdatePassword(ctx context.Context, userID uuid.UUID, passwordHash string) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := r.db.Exec(ctx, "UPDATE users SET password = $2 WHERE id = $1", userID, passwordHash)
+	if err != nil {
+		return fmt.Errorf("failed to update password: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return entity.ErrUserNotFound
+	}
+	return nil
+}
+
+func (r *userRepository) MarkEmailVerified(ctx context.Context, userID uuid.UUID, email string) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := r.db.Exec(ctx,
+		"UPDATE users SET email_verified = TRUE WHERE id = $1 AND LOWER(email) = LOWER($2)", userID, email)
+	if err != nil {
+		return fmt.Errorf("failed to verify email: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return entity.ErrInvalidOneTimeToken
+	}
+	return nil
+}
+
+func (r *userRepository) List(ctx context.Context, filter entity.UserFilter) ([]*entity.User, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	filter.Normalize()
+	rows, err := r.db.Query(ctx, `
+		SELECT `+userColumns+` FROM users
+		WHERE id NOT IN (SELECT id FROM service_accounts)
+		  AND ($1 = '' OR username ILIKE '%' || $1 || '%' OR email ILIKE '%' || $1 || '%')
+		  AND ($2::boolean IS NULL OR (disabled_at IS NOT NULL) = $2)
+		ORDER BY created_at, id
+		LIMIT $3 OFFSET $4
+	`, escapeLike(filter.Query), filter.Disabled, filter.Limit, filter.Offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+	defer rows.Close()
+
+	users := []*entity.User{}
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan user: %w", err)
+		}
+		users = append(users, user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+	return users, nil
+}
+
+func (r *userRepository) SetDisabled(ctx context.Context, userID uuid.UUID, disabledAt *time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := r.db.Exec(ctx, `
+		UPDATE users SET disabled_at = $2
+		WHERE id = $1 AND id NOT IN (SELECT id FROM service_accounts)
+	`, userID, disabledAt)
+	if err != nil {
+		return fmt.Errorf("failed to update user: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return entity.ErrUserNotFound
+	}
+	return nil
+}
+
+func (r *userRepository) Delete(ctx context.Context, userID uuid.UUID) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	// Sessions, roles, memberships and MFA are deleted by cascade; companies
+	// the user owned are left without an owner.
+	result, err := r.db.Exec(ctx, "DELETE FROM users WHERE id = $1", userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete user: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return entity.ErrUserNotFound
+	}
+	return nil
+}
+
+const userColumns = "id, username, password, COALESCE(email, ''), email_verified, created_at, disabled_at"
+
+func scanUser(row pgx.Row) (*entity.User, error) {
 	user := &entity.User{}
-	err := r.db.QueryRow(ctx,
-		"SELECT id, username, password FROM users WHERE username = $1", username).
-		Scan(&user.ID, &user.Username, &user.Password)
+	err := row.Scan(&user.ID, &user.Username, &user.Password, &user.Email, &user.EmailVerified, &user.CreatedAt, &user.DisabledAt)
 	if err != nil {
 		return nil, err
 	}
 	return user, nil
 }
+
+// escapeLike escapes the LIKE wildcards so that the query matches literally.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
@@ -0,0 +1,106 @@
+package http
+
+import (
+	"encoding/json"
+	"github.com/assylzhan-a/company-task/internal/auth"
+	"github.com/assylzhan-a/company-task/internal/domain/entity"
+	uc "github.com/assylzhan-a/company-task/internal/ports/usecase"
+	customError "github.com/assylzhan-a/company-task/pkg/errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+type auditHandler struct {
+	auditUseCase uc.AuditUseCase
+}
+
+func NewAuditHandler(r *chi.Mux, auditUseCase uc.AuditUseCase, authenticator *auth.Authenticator) {
+	handler := &auditHandler{
+		auditUseCase: auditUseCase,
+	}
+
+	r.Route("/v1/admin/audit-events", func(r chi.Router) {
+		r.Use(authenticator.JWTAuth)
+		r.Use(authenticator.RequirePermission(entity.PermissionAuditRead))
+		r.Get("/", handler.ListEvents)
+		r.Get("/verify", handler.VerifyChain)
+	})
+}
+
+// ListEvents is paginated by sequence: the response's next_after is passed
+// as after to get the following page.
+func (h *auditHandler) ListEvents(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseSecurityEventFilter(r.URL.Query())
+	if err != nil {
+		customError.RespondWithError(w, r, err)
+		return
+	}
+	filter.Normalize()
+
+	events, err := h.auditUseCase.ListEvents(r.Context(), filter)
+	if err != nil {
+		customError.RespondWithError(w, r, customError.NewInternalServerError("Failed to list audit events"))
+		return
+	}
+
+	response := map[string]interface{}{"events": events}
+	if len(events) == filter.Limit {
+		if last := events[len(events)-1]; last.Sequence != nil {
+			response["next_after"] = *last.Sequence
+		}
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+func (h *auditHandler) VerifyChain(w http.ResponseWriter, r *http.Request) {
+	result, err := h.auditUseCase.VerifyChain(r.Context())
+	if err != nil {
+		customError.RespondWithError(w, r, customError.NewInternalServerError("Failed to verify audit log"))
+		return
+	}
+
+	json.NewEncoder(w).Encode(result)
+}
+
+// parseSecurityEventFilter reads user_id, action, from and to (RFC 3339),
+// after and limit.
+func parseSecurityEventFilter(query url.Values) (entity.SecurityEventFilter, error) {
+	filter := entity.SecurityEventFilter{Type: query.Get("action")}
+	if value := query.Get("user_id"); value != "" {
+		userID, err := uuid.Parse(value)
+		if err != nil {
+			return filter, customError.Newf(customError.CodeInvalidParameter, "Invalid {0}", "user_id")
+		}
+		filter.UserID = &userID
+	}
+	for name, target := range map[string]*time.Time{"from": &filter.From, "to": &filter.To} {
+		value := query.Get(name)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return filter, customError.Newf(customError.CodeInvalidParameter, "Invalid {0}, expected an RFC 3339 timestamp", name)
+		}
+		*target = t
+	}
+	if value := query.Get("after"); value != "" {
+		after, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || after < 0 {
+			return filter, customError.Newf(customError.CodeInvalidParameter, "Invalid {0}", "after")
+		}
+		filter.AfterSequence = after
+	}
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 0 {
+			return filter, customError.Newf(customError.CodeInvalidParameter, "Invalid {0}", "limit")
+		}
+		filter.Limit = limit
+	}
+	return filter, nil
+}
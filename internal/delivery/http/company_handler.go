@@ -5,29 +5,53 @@ import (
 	"github.com/assylzhan-a/company-task/internal/auth"
 	"github.com/assylzhan-a/company-task/internal/domain/entity"
 	uc "github.com/assylzhan-a/company-task/internal/ports/usecase"
+	"github.com/assylzhan-a/company-task/internal/requestid"
 	"github.com/assylzhan-a/company-task/pkg/errors"
+	"github.com/assylzhan-a/company-task/pkg/logger"
+	"github.com/assylzhan-a/company-task/pkg/tabular"
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type companyHandler struct {
 	companyUseCase uc.CompanyUseCase
+	authenticator  *auth.Authenticator
+	logger         *logger.Logger
 }
 
-func NewCompanyHandler(r *chi.Mux, useCase uc.CompanyUseCase) {
+// NewCompanyHandler registers the company routes. idempotency makes the
+// changes of companies safe to retry; it runs after authentication because
+// keys are scoped to the caller.
+func NewCompanyHandler(r *chi.Mux, useCase uc.CompanyUseCase, authenticator *auth.Authenticator, idempotency func(http.Handler) http.Handler, logger *logger.Logger) {
 	handler := &companyHandler{
 		companyUseCase: useCase,
+		authenticator:  authenticator,
+		logger:         logger,
 	}
-	// Public route (getter)
-	r.Get("/v1/companies/{id}", handler.Get)
-
-	// Protected routes
+	// Every route is scoped to the organization of the caller's token.
+	// The permissions of a batch depend on its operations, which Batch checks.
+	r.With(authenticator.Authenticate, idempotency).Post("/v1/companies:batch", handler.Batch)
+	// Registered outside the /v1/companies routes so that it takes precedence
+	// over the getter.
+	r.With(authenticator.Authenticate, authenticator.RequirePermission(entity.PermissionCompaniesRead)).Get("/v1/companies/export", handler.Export)
 	r.Route("/v1/companies", func(r chi.Router) {
-		r.Use(auth.JWTAuth)
-		r.Post("/", handler.Create)
-		r.Patch("/{id}", handler.Patch)
-		r.Delete("/{id}", handler.Delete)
+		r.Use(authenticator.Authenticate)
+		// Only applies to POST, PUT, PATCH and DELETE.
+		r.Use(idempotency)
+		r.With(authenticator.RequirePermission(entity.PermissionCompaniesRead)).Get("/{id}", handler.Get)
+		r.With(authenticator.RequirePermission(entity.PermissionCompaniesRead)).Get("/by-slug/{slug}", handler.GetBySlug)
+		r.With(authenticator.RequirePermission(entity.PermissionCompaniesCreate)).Post("/", handler.Create)
+		r.With(authenticator.RequirePermission(entity.PermissionCompaniesCreate), authenticator.RequirePermission(entity.PermissionCompaniesUpdate)).Put("/{id}", handler.Put)
+		r.With(authenticator.RequirePermission(entity.PermissionCompaniesUpdate)).Patch("/{id}", handler.Patch)
+		r.With(authenticator.RequirePermission(entity.PermissionCompaniesUpdate)).Post("/{id}/transfer", handler.TransferOwnership)
+		r.With(authenticator.RequirePermission(entity.PermissionCompaniesDelete)).Delete("/{id}", handler.Delete)
+		r.With(authenticator.RequirePermission(entity.PermissionCompaniesRead)).Get("/{id}/history", handler.History)
+		r.With(authenticator.RequirePermission(entity.PermissionCompaniesRead)).Get("/{id}/history/diff", handler.Diff)
 	})
 }
 
@@ -35,47 +59,206 @@ func (h *companyHandler) Create(w http.ResponseWriter, r *http.Request) {
 	var company entity.Company
 	ctx := r.Context()
 
-	if err := json.NewDecoder(r.Body).Decode(&company); err != nil {
-		errors.RespondWithError(w, errors.NewBadRequestError("Invalid request payload"))
+	if err := decodeJSON(r, &company, disallowUnknownFields()); err != nil {
+		errors.RespondWithError(w, r, err)
 		return
 	}
 
 	if err := company.Validate(); err != nil {
-		errors.RespondWithError(w, errors.NewBadRequestError(err.Error()))
+		errors.RespondWithError(w, r, errors.NewValidationError(err))
 		return
 	}
 
 	if err := h.companyUseCase.Create(ctx, &company); err != nil {
-		errors.RespondWithError(w, err)
+		errors.RespondWithError(w, r, err)
 		return
 	}
 
+	w.Header().Set("ETag", company.ETag())
+	w.Header().Set("Location", companyLocation(&company))
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(company)
 }
 
-func (h *companyHandler) Patch(w http.ResponseWriter, r *http.Request) {
+func companyLocation(company *entity.Company) string {
+	return "/v1/companies/" + company.ID.String()
+}
+
+// Put creates the company with the ID of the URL or replaces it. Clients make
+// it conditional with If-Match, to replace only the version they read, or
+// with "If-None-Match: *", to only create it.
+func (h *companyHandler) Put(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
 	id, err := uuid.Parse(chi.URLParam(r, "id"))
 	if err != nil {
-		errors.RespondWithError(w, errors.NewBadRequestError("Invalid company ID"))
+		errors.RespondWithError(w, r, errors.New(errors.CodeInvalidParameter, "Invalid company ID"))
+		return
+	}
+
+	var company entity.Company
+	if err := decodeJSON(r, &company, disallowUnknownFields()); err != nil {
+		errors.RespondWithError(w, r, err)
+		return
+	}
+	if company.ID == uuid.Nil {
+		company.ID = id
+	}
+	if company.ID != id {
+		errors.RespondWithError(w, r, errors.New(errors.CodeInvalidPayload, "The company ID does not match the URL"))
+		return
+	}
+
+	if err := company.Validate(); err != nil {
+		errors.RespondWithError(w, r, errors.NewValidationError(err))
+		return
+	}
+
+	precondition := entity.CompanyPrecondition{
+		IfMatch:     entityTags(r.Header.Get("If-Match")),
+		IfNoneMatch: entityTags(r.Header.Get("If-None-Match")),
+	}
+	created, err := h.companyUseCase.Put(ctx, &company, precondition)
+	if err != nil {
+		errors.RespondWithError(w, r, err)
+		return
+	}
+
+	w.Header().Set("ETag", company.ETag())
+	if created {
+		w.Header().Set("Location", companyLocation(&company))
+		w.WriteHeader(http.StatusCreated)
+	} else {
+		w.WriteHeader(http.StatusOK)
+	}
+	json.NewEncoder(w).Encode(company)
+}
+
+// companyBatchResponse reports the outcome of every operation of a batch, in
+// the order of the operations.
+type companyBatchResponse struct {
+	Mode    entity.CompanyBatchMode `json:"mode"`
+	Results []companyBatchItem      `json:"results"`
+}
+
+// companyBatchItem is the outcome of an operation with the status the single
+// endpoint would have responded with. Operations of a failed atomic batch that
+// did not fail themselves have status 424 Failed Dependency.
+type companyBatchItem struct {
+	Index   int                   `json:"index"`
+	Op      entity.CompanyBatchOp `json:"op"`
+	ID      *uuid.UUID            `json:"id,omitempty"`
+	Status  int                   `json:"status"`
+	Company *entity.Company       `json:"company,omitempty"`
+	Error   *errors.Problem       `json:"error,omitempty"`
+}
+
+// Batch runs a list of create, patch and delete operations. The response is
+// 200 unless an atomic batch failed, in which case it has the status of the
+// first failed operation and nothing was changed.
+func (h *companyHandler) Batch(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var batch entity.CompanyBatch
+	if err := decodeJSON(r, &batch, disallowUnknownFields()); err != nil {
+		errors.RespondWithError(w, r, err)
+		return
+	}
+	if err := batch.Validate(); err != nil {
+		errors.RespondWithError(w, r, errors.NewValidationError(err))
+		return
+	}
+	if err := h.authenticator.CheckPermissions(r, batchPermissions(batch.Operations)...); err != nil {
+		errors.RespondWithError(w, r, err)
+		return
+	}
+
+	results, err := h.companyUseCase.Batch(ctx, &batch)
+	if err != nil {
+		errors.RespondWithError(w, r, err)
 		return
 	}
 
-	var patchCompany entity.PatchCompany
-	if err := json.NewDecoder(r.Body).Decode(&patchCompany); err != nil {
-		errors.RespondWithError(w, errors.NewBadRequestError("Invalid request payload"))
+	response := companyBatchResponse{Mode: entity.CompanyBatchBestEffort, Results: make([]companyBatchItem, len(results))}
+	if batch.IsAtomic() {
+		response.Mode = entity.CompanyBatchAtomic
+	}
+	status := http.StatusOK
+	for i, result := range results {
+		item := companyBatchItem{Index: i, Op: result.Op, Company: result.Company}
+		if result.ID != uuid.Nil {
+			id := result.ID
+			item.ID = &id
+		}
+		switch {
+		case result.Err != nil:
+			item.Error = errors.NewProblem(r, result.Err)
+			item.Status = item.Error.Status
+			if batch.IsAtomic() && status == http.StatusOK {
+				status = item.Status
+			}
+		case !result.Applied:
+			item.Status = http.StatusFailedDependency
+		case result.Op == entity.CompanyBatchCreate:
+			item.Status = http.StatusCreated
+		case result.Op == entity.CompanyBatchDelete:
+			item.Status = http.StatusNoContent
+		default:
+			item.Status = http.StatusOK
+		}
+		response.Results[i] = item
+	}
+
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
+
+// batchPermissions returns the permissions the operations need, each once.
+func batchPermissions(operations []entity.CompanyBatchOperation) []string {
+	required := map[entity.CompanyBatchOp]string{
+		entity.CompanyBatchCreate: entity.PermissionCompaniesCreate,
+		entity.CompanyBatchPatch:  entity.PermissionCompaniesUpdate,
+		entity.CompanyBatchDelete: entity.PermissionCompaniesDelete,
+	}
+	var permissions []string
+	for _, op := range operations {
+		if permission, ok := required[op.Op]; ok {
+			permissions = append(permissions, permission)
+			delete(required, op.Op)
+		}
+	}
+	return permissions
+}
+
+// entityTags splits the value of an If-Match or If-None-Match header.
+func entityTags(header string) []string {
+	var tags []string
+	for _, tag := range strings.Split(header, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+func (h *companyHandler) Patch(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		errors.RespondWithError(w, r, errors.New(errors.CodeInvalidParameter, "Invalid company ID"))
 		return
 	}
 
-	if err := patchCompany.Validate(); err != nil {
-		errors.RespondWithError(w, errors.NewBadRequestError(err.Error()))
+	w.Header().Set("Accept-Patch", strings.Join(patchContentTypes, ", "))
+	patch, err := decodeCompanyPatch(r)
+	if err != nil {
+		errors.RespondWithError(w, r, err)
 		return
 	}
 
-	if err := h.companyUseCase.Patch(ctx, id, &patchCompany); err != nil {
-		errors.RespondWithError(w, err)
+	if err := h.companyUseCase.Patch(ctx, id, patch); err != nil {
+		errors.RespondWithError(w, r, err)
 		return
 	}
 
@@ -83,16 +266,75 @@ func (h *companyHandler) Patch(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]string{"message": "Company updated successfully"})
 }
 
+// patchContentTypes are the media types of company patches: the fields to
+// set as plain JSON, a JSON Merge Patch (RFC 7396) or a JSON Patch (RFC 6902).
+var patchContentTypes = []string{contentTypeJSON, contentTypeMergePatch, contentTypeJSONPatch}
+
+func decodeCompanyPatch(r *http.Request) (entity.CompanyPatch, error) {
+	switch mediaType(r) {
+	case contentTypeMergePatch:
+		var patch json.RawMessage
+		if err := decodeJSON(r, &patch, acceptContentTypes(contentTypeMergePatch)); err != nil {
+			return nil, err
+		}
+		return entity.CompanyMergePatch(patch), nil
+	case contentTypeJSONPatch:
+		var patch entity.CompanyJSONPatch
+		if err := decodeJSON(r, &patch, acceptContentTypes(contentTypeJSONPatch)); err != nil {
+			return nil, err
+		}
+		return patch, nil
+	default:
+		var patch entity.PatchCompany
+		if err := decodeJSON(r, &patch, disallowUnknownFields(), acceptContentTypes(patchContentTypes...)); err != nil {
+			return nil, err
+		}
+		if err := patch.Validate(); err != nil {
+			return nil, errors.NewValidationError(err)
+		}
+		return &patch, nil
+	}
+}
+
+func (h *companyHandler) TransferOwnership(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		errors.RespondWithError(w, r, errors.New(errors.CodeInvalidParameter, "Invalid company ID"))
+		return
+	}
+
+	var transfer entity.TransferOwnership
+	if err := decodeJSON(r, &transfer, disallowUnknownFields()); err != nil {
+		errors.RespondWithError(w, r, err)
+		return
+	}
+
+	if err := transfer.Validate(); err != nil {
+		errors.RespondWithError(w, r, errors.NewValidationError(err))
+		return
+	}
+
+	if err := h.companyUseCase.TransferOwnership(ctx, id, transfer.OwnerID); err != nil {
+		errors.RespondWithError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Company ownership transferred successfully"})
+}
+
 func (h *companyHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	id, err := uuid.Parse(chi.URLParam(r, "id"))
 	if err != nil {
-		errors.RespondWithError(w, errors.NewBadRequestError("Invalid company ID"))
+		errors.RespondWithError(w, r, errors.New(errors.CodeInvalidParameter, "Invalid company ID"))
 		return
 	}
 
 	if err := h.companyUseCase.Delete(ctx, id); err != nil {
-		errors.RespondWithError(w, err)
+		errors.RespondWithError(w, r, err)
 		return
 	}
 
@@ -103,15 +345,170 @@ func (h *companyHandler) Get(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	id, err := uuid.Parse(chi.URLParam(r, "id"))
 	if err != nil {
-		errors.RespondWithError(w, errors.NewBadRequestError("Invalid company ID"))
+		errors.RespondWithError(w, r, errors.New(errors.CodeInvalidParameter, "Invalid company ID"))
 		return
 	}
 
-	company, err := h.companyUseCase.GetByID(ctx, id)
+	var company *entity.Company
+	if value := r.URL.Query().Get("as_of"); value != "" {
+		asOf, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			errors.RespondWithError(w, r, errors.Newf(errors.CodeInvalidParameter, "Invalid {0}, expected an RFC 3339 timestamp", "as_of"))
+			return
+		}
+		company, err = h.companyUseCase.GetAsOf(ctx, id, asOf)
+	} else {
+		company, err = h.companyUseCase.GetByID(ctx, id)
+		if err == nil {
+			w.Header().Set("ETag", company.ETag())
+		}
+	}
 	if err != nil {
-		errors.RespondWithError(w, err)
+		errors.RespondWithError(w, r, err)
 		return
 	}
 
 	json.NewEncoder(w).Encode(company)
 }
+
+// GetBySlug returns the current state of the company with the slug, for
+// pages that link to companies by name.
+func (h *companyHandler) GetBySlug(w http.ResponseWriter, r *http.Request) {
+	company, err := h.companyUseCase.GetBySlug(r.Context(), chi.URLParam(r, "slug"))
+	if err != nil {
+		errors.RespondWithError(w, r, err)
+		return
+	}
+
+	w.Header().Set("ETag", company.ETag())
+	json.NewEncoder(w).Encode(company)
+}
+
+func (h *companyHandler) History(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		errors.RespondWithError(w, r, errors.New(errors.CodeInvalidParameter, "Invalid company ID"))
+		return
+	}
+
+	revisions, err := h.companyUseCase.History(ctx, id)
+	if err != nil {
+		errors.RespondWithError(w, r, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{"revisions": revisions})
+}
+
+// Diff compares the revisions given by the from and to query parameters.
+func (h *companyHandler) Diff(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		errors.RespondWithError(w, r, errors.New(errors.CodeInvalidParameter, "Invalid company ID"))
+		return
+	}
+
+	from, errFrom := strconv.ParseInt(r.URL.Query().Get("from"), 10, 64)
+	to, errTo := strconv.ParseInt(r.URL.Query().Get("to"), 10, 64)
+	if errFrom != nil || errTo != nil || from < 1 || to < 1 {
+		errors.RespondWithError(w, r, errors.New(errors.CodeInvalidParameter, "The from and to revisions are required"))
+		return
+	}
+
+	diff, err := h.companyUseCase.Diff(ctx, id, from, to)
+	if err != nil {
+		errors.RespondWithError(w, r, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(diff)
+}
+
+// Export streams the companies matching the filter parameters as CSV, NDJSON
+// or XLSX, given by the format parameter or the Accept header. CSV and NDJSON
+// are compressed with gzip for clients that accept it.
+func (h *companyHandler) Export(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseCompanyFilter(r.URL.Query())
+	if err != nil {
+		errors.RespondWithError(w, r, err)
+		return
+	}
+	format, err := exportFormat(r)
+	if err != nil {
+		errors.RespondWithError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Vary", "Accept, Accept-Encoding")
+	response := &exportResponse{w: w, format: format, compress: format != tabular.XLSX && acceptsGzip(r)}
+	err = h.companyUseCase.Export(r.Context(), filter, format, response)
+	if err == nil {
+		err = response.Close()
+	}
+	if err == nil {
+		return
+	}
+	if !response.started {
+		errors.RespondWithError(w, r, err)
+		return
+	}
+	// The status was already sent. Aborting the response tells the client
+	// that the export is incomplete rather than letting it end normally.
+	if r.Context().Err() == nil {
+		h.logger.Error("Export failed", "error", err, "path", r.URL.Path, "requestID", requestid.FromContext(r.Context()))
+	}
+	panic(http.ErrAbortHandler)
+}
+
+// parseCompanyFilter reads type (repeated for several types), registered,
+// min_employees, max_employees, q, owner_id, and created_from and created_to
+// (RFC 3339).
+func parseCompanyFilter(query url.Values) (entity.CompanyFilter, error) {
+	filter := entity.CompanyFilter{Query: query.Get("q")}
+	for _, value := range query["type"] {
+		companyType := entity.CompanyType(value)
+		if !companyType.IsValid() {
+			return filter, errors.Newf(errors.CodeInvalidParameter, "Invalid {0}", "type")
+		}
+		filter.Types = append(filter.Types, companyType)
+	}
+	if value := query.Get("registered"); value != "" {
+		registered, err := strconv.ParseBool(value)
+		if err != nil {
+			return filter, errors.Newf(errors.CodeInvalidParameter, "Invalid {0}", "registered")
+		}
+		filter.Registered = &registered
+	}
+	for name, target := range map[string]**int{"min_employees": &filter.MinEmployees, "max_employees": &filter.MaxEmployees} {
+		value := query.Get(name)
+		if value == "" {
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return filter, errors.Newf(errors.CodeInvalidParameter, "Invalid {0}", name)
+		}
+		*target = &n
+	}
+	if value := query.Get("owner_id"); value != "" {
+		ownerID, err := uuid.Parse(value)
+		if err != nil {
+			return filter, errors.Newf(errors.CodeInvalidParameter, "Invalid {0}", "owner_id")
+		}
+		filter.OwnerID = &ownerID
+	}
+	for name, target := range map[string]*time.Time{"created_from": &filter.CreatedFrom, "created_to": &filter.CreatedTo} {
+		value := query.Get(name)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return filter, errors.Newf(errors.CodeInvalidParameter, "Invalid {0}, expected an RFC 3339 timestamp", name)
+		}
+		*target = t
+	}
+	return filter, nil
+}
@@ -0,0 +1,163 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/assylzhan-a/company-task/internal/auth"
+	"github.com/assylzhan-a/company-task/internal/domain/entity"
+	uc "github.com/assylzhan-a/company-task/internal/ports/usecase"
+	customError "github.com/assylzhan-a/company-task/pkg/errors"
+	"github.com/assylzhan-a/company-task/pkg/tabular"
+	"github.com/go-chi/chi/v5"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// statsMediaTypes are the media types of statistics, the default first.
+var statsMediaTypes = []string{"application/json", tabular.CSV.MediaType()}
+
+type companyStatsHandler struct {
+	statsUseCase uc.CompanyStatsUseCase
+}
+
+func NewCompanyStatsHandler(r *chi.Mux, statsUseCase uc.CompanyStatsUseCase, authenticator *auth.Authenticator) {
+	handler := &companyStatsHandler{
+		statsUseCase: statsUseCase,
+	}
+
+	// Registered outside the /v1/companies routes so that it takes precedence
+	// over the getter.
+	r.With(authenticator.Authenticate, authenticator.RequirePermission(entity.PermissionCompaniesRead)).Get("/v1/companies/stats", handler.Stats)
+}
+
+// Stats counts the companies matching the filter parameters in the groups
+// given by group_by, as JSON or, by the format parameter or the Accept
+// header, CSV.
+func (h *companyStatsHandler) Stats(w http.ResponseWriter, r *http.Request) {
+	query, err := parseCompanyStatsQuery(r.URL.Query())
+	if err != nil {
+		customError.RespondWithError(w, r, err)
+		return
+	}
+	mediaType, err := statsMediaType(r)
+	if err != nil {
+		customError.RespondWithError(w, r, err)
+		return
+	}
+
+	stats, err := h.statsUseCase.Stats(r.Context(), query)
+	if err != nil {
+		customError.RespondWithError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Vary", "Accept")
+	if mediaType == statsMediaTypes[0] {
+		w.Header().Set("Content-Type", mediaType)
+		json.NewEncoder(w).Encode(stats)
+		return
+	}
+
+	// Statistics are small, so the file is built before responding to be
+	// able to respond with an error.
+	var body bytes.Buffer
+	writer, err := tabular.NewWriter(tabular.CSV, &body, stats.Columns())
+	if err == nil {
+		for _, group := range stats.Groups {
+			if err = writer.Write(stats.Values(group)); err != nil {
+				break
+			}
+		}
+	}
+	if err == nil {
+		err = writer.Close()
+	}
+	if err != nil {
+		customError.RespondWithError(w, r, customError.NewInternalServerError("Failed to write company statistics"))
+		return
+	}
+	w.Header().Set("Content-Type", mediaType)
+	w.Header().Set("Content-Disposition", `attachment; filename="company-stats.csv"`)
+	w.Write(body.Bytes())
+}
+
+// statsMediaType returns the media type named by the format parameter, json
+// or csv, or else the one the Accept header prefers.
+func statsMediaType(r *http.Request) (string, error) {
+	switch value := r.URL.Query().Get("format"); value {
+	case "":
+		return negotiateMediaType(r.Header.Get("Accept"), statsMediaTypes)
+	case "json":
+		return statsMediaTypes[0], nil
+	case string(tabular.CSV):
+		return statsMediaTypes[1], nil
+	default:
+		return "", customError.Newf(customError.CodeInvalidParameter, "Invalid {0}", "format")
+	}
+}
+
+// parseCompanyStatsQuery reads the filter parameters of parseCompanyFilter,
+// group_by (comma-separated or repeated), and bucket and employee_bounds for
+// grouping by created and employees.
+func parseCompanyStatsQuery(values url.Values) (entity.CompanyStatsQuery, error) {
+	filter, err := parseCompanyFilter(values)
+	if err != nil {
+		return entity.CompanyStatsQuery{}, err
+	}
+	query := entity.CompanyStatsQuery{Filter: filter}
+
+	seen := make(map[entity.CompanyStatsDimension]bool)
+	for _, value := range values["group_by"] {
+		for _, name := range strings.Split(value, ",") {
+			dimension := entity.CompanyStatsDimension(strings.TrimSpace(name))
+			if !dimension.IsValid() || seen[dimension] {
+				return query, customError.Newf(customError.CodeInvalidParameter, "Invalid {0}", "group_by")
+			}
+			seen[dimension] = true
+			query.GroupBy = append(query.GroupBy, dimension)
+		}
+	}
+
+	// The bucket and bounds are only kept when they are used, so that they do
+	// not tell apart queries with the same results.
+	if seen[entity.CompanyStatsByCreated] {
+		query.Bucket = entity.BucketWeek
+		if value := values.Get("bucket"); value != "" {
+			query.Bucket = entity.TimeBucket(value)
+		}
+		if !query.Bucket.IsValid() {
+			return query, customError.Newf(customError.CodeInvalidParameter, "Invalid {0}", "bucket")
+		}
+	}
+	if seen[entity.CompanyStatsByEmployees] {
+		query.EmployeeBounds = entity.DefaultEmployeeBounds
+		if value := values.Get("employee_bounds"); value != "" {
+			bounds, err := parseEmployeeBounds(value)
+			if err != nil {
+				return query, err
+			}
+			query.EmployeeBounds = bounds
+		}
+	}
+	return query, nil
+}
+
+// parseEmployeeBounds reads up to entity.MaxEmployeeBounds comma-separated,
+// ascending, positive numbers.
+func parseEmployeeBounds(value string) ([]int, error) {
+	parts := strings.Split(value, ",")
+	if len(parts) > entity.MaxEmployeeBounds {
+		return nil, customError.Newf(customError.CodeInvalidParameter, "Invalid {0}", "employee_bounds")
+	}
+	bounds := make([]int, len(parts))
+	for i, part := range parts {
+		n, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || n < 1 || (i > 0 && n <= bounds[i-1]) {
+			return nil, customError.Newf(customError.CodeInvalidParameter, "Invalid {0}", "employee_bounds")
+		}
+		bounds[i] = n
+	}
+	return bounds, nil
+}
@@ -0,0 +1,226 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	customError "github.com/assylzhan-a/company-task/pkg/errors"
+)
+
+// DefaultMaxBodyBytes limits request bodies unless BodyLimit sets another
+// limit.
+const DefaultMaxBodyBytes = 1 << 20
+
+const (
+	contentTypeJSON       = "application/json"
+	contentTypeMergePatch = "application/merge-patch+json"
+	contentTypeJSONPatch  = "application/json-patch+json"
+)
+
+type bodyLimitKey struct{}
+
+// BodyLimit sets the largest request body decodeJSON accepts for the routes
+// it wraps.
+func BodyLimit(maxBytes int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), bodyLimitKey{}, maxBytes)))
+		})
+	}
+}
+
+func maxBodyBytes(ctx context.Context) int64 {
+	if maxBytes, ok := ctx.Value(bodyLimitKey{}).(int64); ok && maxBytes > 0 {
+		return maxBytes
+	}
+	return DefaultMaxBodyBytes
+}
+
+type decodeOptions struct {
+	contentTypes          []string
+	disallowUnknownFields bool
+}
+
+type decodeOption func(*decodeOptions)
+
+// disallowUnknownFields rejects fields the target does not have, so that
+// misspelt fields are reported instead of ignored.
+func disallowUnknownFields() decodeOption {
+	return func(o *decodeOptions) {
+		o.disallowUnknownFields = true
+	}
+}
+
+// acceptContentTypes replaces application/json as the accepted media types.
+func acceptContentTypes(contentTypes ...string) decodeOption {
+	return func(o *decodeOptions) {
+		o.contentTypes = contentTypes
+	}
+}
+
+// decodeJSON decodes the request body, which must be a single JSON value of
+// an accepted media type within the body limit, into v. The error is an
+// *customError.AppError that names the offending field or offset.
+func decodeJSON(r *http.Request, v interface{}, opts ...decodeOption) error {
+	options := decodeOptions{contentTypes: []string{contentTypeJSON}}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	if err := checkContentType(r, options.contentTypes); err != nil {
+		return err
+	}
+
+	body, err := readBody(r)
+	if err != nil {
+		return err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	if options.disallowUnknownFields {
+		decoder.DisallowUnknownFields()
+	}
+	if err := decoder.Decode(v); err != nil {
+		return decodeError(err)
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return customError.Newf(customError.CodeInvalidPayload, "Request body must contain a single JSON value, found more at offset {0}",
+			strconv.FormatInt(decoder.InputOffset(), 10))
+	}
+	return nil
+}
+
+// readBody reads the request body, which must not be empty or exceed the body
+// limit.
+func readBody(r *http.Request) ([]byte, error) {
+	limit := maxBodyBytes(r.Context())
+	body, err := io.ReadAll(io.LimitReader(r.Body, limit+1))
+	if err != nil {
+		return nil, customError.New(customError.CodeInvalidPayload, "Invalid request payload")
+	}
+	if int64(len(body)) > limit {
+		return nil, customError.Newf(customError.CodePayloadTooLarge, "Request body must not exceed {0} bytes",
+			strconv.FormatInt(limit, 10))
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		return nil, customError.New(customError.CodeInvalidPayload, "Request body is empty")
+	}
+	return body, nil
+}
+
+// requiredField is a field of a request body and whether it is missing.
+type requiredField struct {
+	name    string
+	missing bool
+}
+
+// requireFields reports the missing fields as a validation error, or returns
+// nil if none is missing.
+func requireFields(fields ...requiredField) error {
+	var fieldErrors []customError.FieldError
+	for _, field := range fields {
+		if field.missing {
+			fieldErrors = append(fieldErrors, customError.NewFieldError(field.name, "required", "", "{0} is a required field", field.name))
+		}
+	}
+	if fieldErrors == nil {
+		return nil
+	}
+	appErr := customError.New(customError.CodeValidationFailed, "One or more fields are invalid")
+	appErr.Errors = fieldErrors
+	return appErr
+}
+
+// mediaType returns the media type of the request body without parameters,
+// or "" if the Content-Type header is missing or malformed.
+func mediaType(r *http.Request) string {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return ""
+	}
+	return mediaType
+}
+
+func checkContentType(r *http.Request, accepted []string) error {
+	mediaType, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err == nil {
+		if charset, ok := params["charset"]; ok && !strings.EqualFold(charset, "utf-8") {
+			err = errors.New("unsupported charset")
+		}
+	}
+	if err == nil {
+		for _, contentType := range accepted {
+			if mediaType == contentType {
+				return nil
+			}
+		}
+	}
+	return customError.Newf(customError.CodeUnsupportedMedia, "Content-Type must be {0}", strings.Join(accepted, ", "))
+}
+
+// decodeError describes why the body could not be decoded.
+func decodeError(err error) error {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		return customError.Newf(customError.CodeInvalidPayload, "Malformed JSON at offset {0}",
+			strconv.FormatInt(syntaxErr.Offset, 10))
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return customError.New(customError.CodeInvalidPayload, "Request body ends unexpectedly")
+	case errors.As(err, &typeErr):
+		expected := jsonType(typeErr.Type)
+		if typeErr.Field == "" {
+			return customError.Newf(customError.CodeInvalidPayload, "Request body must be a JSON {0}", expected)
+		}
+		appErr := customError.New(customError.CodeInvalidPayload, "Invalid request payload")
+		fieldErr := customError.NewFieldError(typeErr.Field, "type", expected, "{0} must be of type {1}", typeErr.Field, expected)
+		fieldErr.Offset = typeErr.Offset
+		appErr.Errors = []customError.FieldError{fieldErr}
+		return appErr
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		field, _ := strconv.Unquote(strings.TrimPrefix(err.Error(), "json: unknown field "))
+		appErr := customError.New(customError.CodeInvalidPayload, "Invalid request payload")
+		appErr.Errors = []customError.FieldError{
+			customError.NewFieldError(field, "unknown", "", "{0} is not a known field", field),
+		}
+		return appErr
+	default:
+		return customError.New(customError.CodeInvalidPayload, "Invalid request payload")
+	}
+}
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// jsonType names the JSON type a Go type is decoded from.
+func jsonType(t reflect.Type) string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if reflect.PointerTo(t).Implements(textUnmarshalerType) {
+		return "string"
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.Map, reflect.Struct:
+		return "object"
+	default:
+		return "string"
+	}
+}
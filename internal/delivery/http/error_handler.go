@@ -0,0 +1,37 @@
+package http
+
+import (
+	"encoding/json"
+	customError "github.com/assylzhan-a/company-task/pkg/errors"
+	"github.com/go-chi/chi/v5"
+	"net/http"
+)
+
+type errorHandler struct{}
+
+// NewErrorHandler answers unknown routes and methods with problem responses
+// and publishes the catalogue of error codes. It must be registered before
+// the other handlers so that their subrouters inherit it.
+func NewErrorHandler(r *chi.Mux) {
+	handler := &errorHandler{}
+	r.NotFound(handler.NotFound)
+	r.MethodNotAllowed(handler.MethodNotAllowed)
+	r.Get("/v1/errors", handler.Catalogue)
+}
+
+func (h *errorHandler) NotFound(w http.ResponseWriter, r *http.Request) {
+	customError.RespondWithError(w, r, customError.NewNotFoundError("No route matches the request"))
+}
+
+func (h *errorHandler) MethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	customError.RespondWithError(w, r, customError.Newf(customError.CodeMethodNotAllowed, "The route does not support the {0} method", r.Method))
+}
+
+// Catalogue lists every error code with its usual status and meaning.
+func (h *errorHandler) Catalogue(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"type_prefix": customError.TypeURIPrefix,
+		"errors":      customError.Catalogue,
+	})
+}
@@ -0,0 +1,99 @@
+package http
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/assylzhan-a/company-task/internal/domain/entity"
+	customError "github.com/assylzhan-a/company-task/pkg/errors"
+)
+
+// domainErrorCodes are the codes of the domain errors whose messages are
+// returned to clients.
+var domainErrorCodes = []struct {
+	err  error
+	code customError.Code
+}{
+	{entity.ErrInvalidCredentials, customError.CodeInvalidCredentials},
+	{entity.ErrWeakPassword, customError.CodeWeakPassword},
+	{entity.ErrPasswordNotSet, customError.CodePasswordNotSet},
+	{entity.ErrUsernameTaken, customError.CodeUsernameTaken},
+	{entity.ErrEmailTaken, customError.CodeEmailTaken},
+	{entity.ErrInvalidEmail, customError.CodeInvalidEmail},
+	{entity.ErrEmailRequired, customError.CodeEmailRequired},
+	{entity.ErrEmailNotVerified, customError.CodeEmailNotVerified},
+	{entity.ErrEmailAlreadyVerified, customError.CodeEmailVerified},
+	{entity.ErrInvalidRefreshToken, customError.CodeInvalidRefreshToken},
+	{entity.ErrRefreshTokenReused, customError.CodeRefreshTokenReused},
+	{entity.ErrInvalidOneTimeToken, customError.CodeInvalidOneTimeToken},
+	{entity.ErrUserNotFound, customError.CodeUserNotFound},
+	{entity.ErrUserDisabled, customError.CodeUserDisabled},
+	{entity.ErrCannotModifySelf, customError.CodeCannotModifySelf},
+	{entity.ErrRoleNotFound, customError.CodeRoleNotFound},
+	{entity.ErrLocalLoginDisabled, customError.CodeLocalLoginDisabled},
+	{entity.ErrSSODisabled, customError.CodeSSODisabled},
+	{entity.ErrInvalidSSOState, customError.CodeInvalidSSOState},
+	{entity.ErrSSOLoginFailed, customError.CodeSSOLoginFailed},
+	{entity.ErrMFANotEnrolled, customError.CodeMFANotEnrolled},
+	{entity.ErrMFAAlreadyEnabled, customError.CodeMFAAlreadyEnabled},
+	{entity.ErrInvalidMFACode, customError.CodeInvalidMFACode},
+	{entity.ErrInvalidMFAToken, customError.CodeInvalidMFAToken},
+	{entity.ErrOrganizationNotFound, customError.CodeOrganizationNotFound},
+	{entity.ErrOrganizationExists, customError.CodeOrganizationExists},
+	{entity.ErrNotOrganizationMember, customError.CodeNotOrganizationMember},
+	{entity.ErrServiceAccountNotFound, customError.CodeServiceAccountNotFound},
+	{entity.ErrServiceAccountExists, customError.CodeServiceAccountExists},
+	{entity.ErrAPIKeyNotFound, customError.CodeAPIKeyNotFound},
+	{entity.ErrInvalidAPIKey, customError.CodeInvalidAPIKey},
+	{entity.ErrInvalidScope, customError.CodeInvalidScope},
+}
+
+// passwordRuleMessages are the messages of the password policy rules, which
+// are translated like the messages of customError.Newf.
+var passwordRuleMessages = map[string]string{
+	entity.PasswordRuleMinLength: "password must be at least {0} characters long",
+	entity.PasswordRuleUpper:     "password must contain an uppercase letter",
+	entity.PasswordRuleLower:     "password must contain a lowercase letter",
+	entity.PasswordRuleDigit:     "password must contain a digit",
+	entity.PasswordRuleSymbol:    "password must contain a symbol",
+	entity.PasswordRuleBreached:  "password appears in a list of breached passwords",
+}
+
+// domainError reports a domain error with its message and code under the
+// status chosen by the handler. Errors without a code of their own get the
+// generic code of the status.
+func domainError(status int, err error) *customError.AppError {
+	code := customError.CodeForStatus(status)
+	for _, domainErr := range domainErrorCodes {
+		if errors.Is(err, domainErr.err) {
+			code = domainErr.code
+			break
+		}
+	}
+
+	appErr := customError.New(code, err.Error())
+	var throttled *entity.LoginThrottledError
+	var resetThrottled *entity.ResetThrottledError
+	var policyErr *entity.PasswordPolicyError
+	switch {
+	case errors.As(err, &throttled):
+		appErr = customError.Newf(customError.CodeRateLimited, "too many failed login attempts, retry after {0}",
+			throttled.RetryAfter.Round(time.Second).String())
+	case errors.As(err, &resetThrottled):
+		appErr = customError.Newf(customError.CodeRateLimited, "too many password reset requests, retry after {0}",
+			resetThrottled.RetryAfter.Round(time.Second).String())
+	case errors.As(err, &policyErr):
+		appErr = customError.New(code, entity.ErrWeakPassword.Error())
+		for _, rule := range policyErr.Rules {
+			if rule == entity.PasswordRuleMinLength {
+				minLength := strconv.Itoa(policyErr.MinLength)
+				appErr.Errors = append(appErr.Errors, customError.NewFieldError("password", rule, minLength, passwordRuleMessages[rule], minLength))
+				continue
+			}
+			appErr.Errors = append(appErr.Errors, customError.NewFieldError("password", rule, "", passwordRuleMessages[rule]))
+		}
+	}
+	appErr.StatusCode = status
+	return appErr
+}
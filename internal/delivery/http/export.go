@@ -0,0 +1,153 @@
+package http
+
+import (
+	"compress/gzip"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	customError "github.com/assylzhan-a/company-task/pkg/errors"
+	"github.com/assylzhan-a/company-task/pkg/tabular"
+)
+
+// acceptedValue is an entry of an Accept or Accept-Encoding header.
+type acceptedValue struct {
+	value string
+	q     float64
+}
+
+// parseAccept returns the values of an Accept or Accept-Encoding header, most
+// preferred first. Values refused with q=0 come last.
+func parseAccept(header string) []acceptedValue {
+	var values []acceptedValue
+	for _, part := range strings.Split(header, ",") {
+		params := strings.Split(part, ";")
+		accepted := acceptedValue{value: strings.ToLower(strings.TrimSpace(params[0])), q: 1}
+		for _, param := range params[1:] {
+			name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if strings.EqualFold(name, "q") {
+				q, err := strconv.ParseFloat(value, 64)
+				if err != nil {
+					q = 0
+				}
+				accepted.q = q
+			}
+		}
+		if accepted.value != "" {
+			values = append(values, accepted)
+		}
+	}
+	sort.SliceStable(values, func(i, j int) bool { return values[i].q > values[j].q })
+	return values
+}
+
+// exportFormat returns the format named by the format parameter or else the
+// format the Accept header prefers. Wildcards and requests without an Accept
+// header get the first matching format of tabular.Formats, i.e. CSV.
+func exportFormat(r *http.Request) (tabular.Format, error) {
+	if value := r.URL.Query().Get("format"); value != "" {
+		format, ok := tabular.ParseFormat(value)
+		if !ok {
+			return "", customError.Newf(customError.CodeInvalidParameter, "Invalid {0}", "format")
+		}
+		return format, nil
+	}
+
+	mediaTypes := make([]string, len(tabular.Formats))
+	for i, format := range tabular.Formats {
+		mediaTypes[i] = format.MediaType()
+	}
+	mediaType, err := negotiateMediaType(r.Header.Get("Accept"), mediaTypes)
+	if err != nil {
+		return "", err
+	}
+	for _, format := range tabular.Formats {
+		if format.MediaType() == mediaType {
+			return format, nil
+		}
+	}
+	return tabular.Formats[0], nil
+}
+
+// negotiateMediaType returns the media type the Accept header prefers among
+// the offered ones. Wildcards and a missing header get the first offered
+// media type they allow, and media types refused with q=0 are never chosen.
+func negotiateMediaType(header string, offered []string) (string, error) {
+	if header == "" {
+		return offered[0], nil
+	}
+	accepted := parseAccept(header)
+	refused := make(map[string]bool)
+	for _, value := range accepted {
+		if value.q == 0 {
+			refused[value.value] = true
+		}
+	}
+	for _, value := range accepted {
+		if value.q == 0 {
+			break
+		}
+		for _, mediaType := range offered {
+			if refused[mediaType] {
+				continue
+			}
+			if value.value == "*/*" || value.value == mediaType ||
+				(strings.HasSuffix(value.value, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(value.value, "*"))) {
+				return mediaType, nil
+			}
+		}
+	}
+	return "", customError.Newf(customError.CodeNotAcceptable, "Accept must allow {0}", strings.Join(offered, ", "))
+}
+
+// acceptsGzip reports whether the Accept-Encoding header allows gzip.
+func acceptsGzip(r *http.Request) bool {
+	wildcard := false
+	for _, accepted := range parseAccept(r.Header.Get("Accept-Encoding")) {
+		switch accepted.value {
+		case "gzip":
+			return accepted.q > 0
+		case "*":
+			wildcard = accepted.q > 0
+		}
+	}
+	return wildcard
+}
+
+// exportResponse sends the headers of an export with its first bytes, so
+// that an export that fails before writing anything can still respond with
+// an error.
+type exportResponse struct {
+	w        http.ResponseWriter
+	format   tabular.Format
+	compress bool
+	gzip     *gzip.Writer
+	started  bool
+}
+
+func (e *exportResponse) Write(p []byte) (int, error) {
+	if !e.started {
+		e.started = true
+		header := e.w.Header()
+		header.Set("Content-Type", e.format.MediaType())
+		header.Set("Content-Disposition", `attachment; filename="companies.`+string(e.format)+`"`)
+		if e.compress {
+			header.Set("Content-Encoding", "gzip")
+			e.gzip = gzip.NewWriter(e.w)
+		}
+		e.w.WriteHeader(http.StatusOK)
+	}
+	if e.gzip != nil {
+		return e.gzip.Write(p)
+	}
+	return e.w.Write(p)
+}
+
+// Close completes the compressed stream.
+func (e *exportResponse) Close() error {
+	if e.gzip != nil {
+		return e.gzip.Close()
+	}
+	return nil
+}
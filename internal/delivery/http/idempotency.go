@@ -0,0 +1,230 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/assylzhan-a/company-task/internal/auth"
+	"github.com/assylzhan-a/company-task/internal/domain/entity"
+	ports "github.com/assylzhan-a/company-task/internal/ports/repository"
+	"github.com/assylzhan-a/company-task/internal/requestid"
+	customError "github.com/assylzhan-a/company-task/pkg/errors"
+	"github.com/assylzhan-a/company-task/pkg/logger"
+)
+
+const (
+	HeaderIdempotencyKey = "Idempotency-Key"
+	// HeaderIdempotentReplayed marks responses that were stored for an
+	// earlier request with the same Idempotency-Key.
+	HeaderIdempotentReplayed = "Idempotent-Replayed"
+
+	maxIdempotencyKeyLength = 255
+	// idempotencyClaimTTL bounds how long a key stays claimed by a request
+	// that never responds, e.g. because its instance stopped. A request that
+	// is still running extends its claim every idempotencyClaimRefresh, so
+	// that a retry does not run it a second time.
+	idempotencyClaimTTL     = time.Minute
+	idempotencyClaimRefresh = idempotencyClaimTTL / 3
+)
+
+// Idempotency makes POST, PUT, PATCH and DELETE requests with an
+// Idempotency-Key header safe to retry: the response to the first request is
+// stored for ttl and replayed for retries instead of running the request
+// again. Reusing a key for a different request is rejected, and a retry that
+// arrives while the first request is still running is answered with a
+// conflict.
+//
+// It must run after authentication: keys are scoped to the principal and the
+// organization, so that callers cannot see each other's responses, and
+// requests without a principal are not made idempotent.
+func Idempotency(repo ports.IdempotencyRepository, ttl time.Duration, logger *logger.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			key := req.Header.Get(HeaderIdempotencyKey)
+			principal, ok := auth.PrincipalFromContext(req.Context())
+			if key == "" || !isMutating(req.Method) || !ok {
+				next.ServeHTTP(w, req)
+				return
+			}
+			if len(key) > maxIdempotencyKeyLength {
+				customError.RespondWithError(w, req, customError.Newf(customError.CodeInvalidParameter,
+					"{0} must be at most {1} characters", HeaderIdempotencyKey, strconv.Itoa(maxIdempotencyKeyLength)))
+				return
+			}
+
+			// The body is read within the limit of decodeJSON, which still
+			// rejects bodies that exceed it.
+			body, err := io.ReadAll(io.LimitReader(req.Body, maxBodyBytes(req.Context())+1))
+			if err != nil {
+				customError.RespondWithError(w, req, customError.New(customError.CodeInvalidPayload, "Invalid request payload"))
+				return
+			}
+			req.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), req.Body))
+
+			// Postgres keeps microseconds; the claim is identified by its
+			// creation time.
+			now := time.Now().Truncate(time.Microsecond)
+			record := &entity.IdempotencyRecord{
+				Scope:       idempotencyScope(principal),
+				Key:         key,
+				Fingerprint: requestFingerprint(req, body),
+				CreatedAt:   now,
+				ExpiresAt:   now.Add(idempotencyClaimTTL),
+			}
+
+			stored, err := repo.Claim(req.Context(), record)
+			if err != nil {
+				customError.RespondWithError(w, req, err)
+				return
+			}
+			if stored != nil {
+				replay(w, req, record, stored)
+				return
+			}
+
+			// The response of a request whose client gave up is still stored
+			// for its retry.
+			ctx := context.WithoutCancel(req.Context())
+			recorder := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+			stop := keepClaimed(ctx, repo, *record, logger, req.URL.Path)
+			next.ServeHTTP(recorder, req)
+			stop()
+
+			if !isReplayable(recorder.status) || isNoStore(w.Header()) {
+				if err := repo.Release(ctx, record); err != nil {
+					logger.Error("Failed to release idempotency key", "error", err, "path", req.URL.Path, "requestID", requestid.FromContext(ctx))
+				}
+				return
+			}
+			record.StatusCode = recorder.status
+			// The replay keeps the ID of the request it answers.
+			record.Header = w.Header().Clone()
+			delete(record.Header, requestid.Header)
+			record.Body = recorder.body.Bytes()
+			record.ExpiresAt = time.Now().Add(ttl)
+			if err := repo.Store(ctx, record); err != nil {
+				// The response has been sent; the retry runs the request again
+				// once the claim expires.
+				logger.Error("Failed to store idempotent response", "error", err, "path", req.URL.Path, "requestID", requestid.FromContext(ctx))
+			}
+		})
+	}
+}
+
+// keepClaimed extends the claim of record until the returned function is
+// called, which waits for the last extension to finish.
+func keepClaimed(ctx context.Context, repo ports.IdempotencyRepository, record entity.IdempotencyRecord, logger *logger.Logger, path string) func() {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(idempotencyClaimRefresh)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				record.ExpiresAt = time.Now().Add(idempotencyClaimTTL)
+				if err := repo.Extend(ctx, &record); err != nil {
+					logger.Error("Failed to extend idempotency key", "error", err, "path", path, "requestID", requestid.FromContext(ctx))
+				}
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// replay answers a request with the record found for its key.
+func replay(w http.ResponseWriter, req *http.Request, record, stored *entity.IdempotencyRecord) {
+	if stored.Fingerprint != record.Fingerprint {
+		customError.RespondWithError(w, req, customError.New(customError.CodeIdempotencyKeyReused,
+			"The Idempotency-Key was already used for a different request"))
+		return
+	}
+	if stored.InProgress() {
+		w.Header().Set("Retry-After", "1")
+		customError.RespondWithError(w, req, customError.New(customError.CodeIdempotencyInProgress,
+			"A request with the Idempotency-Key is still in progress"))
+		return
+	}
+	for name, values := range stored.Header {
+		w.Header()[name] = values
+	}
+	w.Header().Set(HeaderIdempotentReplayed, "true")
+	w.WriteHeader(stored.StatusCode)
+	w.Write(stored.Body)
+}
+
+// noStore marks a response that carries secrets, such as tokens or keys, so
+// that it is neither cached nor kept in the database for retries. Retries of
+// its request run again.
+func noStore(w http.ResponseWriter) {
+	w.Header().Set("Cache-Control", "no-store")
+}
+
+func isNoStore(header http.Header) bool {
+	return header.Get("Cache-Control") == "no-store"
+}
+
+func isMutating(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	default:
+		return false
+	}
+}
+
+// isReplayable reports whether a response is stored for retries. Server
+// errors and rate limiting are transient, so their retries run again.
+func isReplayable(status int) bool {
+	return status < http.StatusInternalServerError && status != http.StatusTooManyRequests
+}
+
+// idempotencyScope identifies the caller by the user or service account and
+// the organization it acts in, so that keys survive token refreshes and key
+// rotation but are not shared between organizations.
+func idempotencyScope(principal *auth.Principal) string {
+	hash := sha256.New()
+	io.WriteString(hash, principal.UserID.String()+"\n"+principal.OrganizationID.String())
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func requestFingerprint(req *http.Request, body []byte) string {
+	hash := sha256.New()
+	io.WriteString(hash, req.Method+"\n"+req.URL.RequestURI()+"\n")
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// responseRecorder passes a response through while keeping a copy of it.
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (rec *responseRecorder) WriteHeader(status int) {
+	if !rec.wroteHeader {
+		rec.status = status
+		rec.wroteHeader = true
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	rec.wroteHeader = true
+	rec.body.Write(b)
+	return rec.ResponseWriter.Write(b)
+}
@@ -0,0 +1,205 @@
+package http
+
+import (
+	"encoding/json"
+	"github.com/assylzhan-a/company-task/internal/auth"
+	"github.com/assylzhan-a/company-task/internal/domain/entity"
+	uc "github.com/assylzhan-a/company-task/internal/ports/usecase"
+	customError "github.com/assylzhan-a/company-task/pkg/errors"
+	"github.com/assylzhan-a/company-task/pkg/tabular"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type importHandler struct {
+	importUseCase uc.ImportUseCase
+}
+
+// NewImportHandler registers the company import routes. Uploaded files may
+// be up to maxFileBytes, unlike JSON bodies.
+func NewImportHandler(r *chi.Mux, importUseCase uc.ImportUseCase, authenticator *auth.Authenticator, idempotency func(http.Handler) http.Handler, maxFileBytes int64) {
+	handler := &importHandler{
+		importUseCase: importUseCase,
+	}
+
+	r.Route("/v1/companies/imports", func(r chi.Router) {
+		r.Use(authenticator.Authenticate)
+		r.Use(authenticator.RequirePermission(entity.PermissionCompaniesCreate))
+		// The file is fingerprinted for its key within its own limit.
+		r.With(BodyLimit(maxFileBytes), idempotency).Post("/", handler.Upload)
+		r.Get("/{id}", handler.Get)
+		r.With(idempotency).Post("/{id}/dry-run", handler.DryRun)
+		r.With(idempotency).Post("/{id}/run", handler.Run)
+		r.Get("/{id}/errors", handler.ListErrors)
+	})
+}
+
+// importRequest optionally replaces the mapping of a job.
+type importRequest struct {
+	Mapping entity.ImportMapping `json:"mapping"`
+}
+
+// Upload stores the file of the request body. Its format is given by the
+// Content-Type or, for clients that cannot set it, the format parameter.
+func (h *importHandler) Upload(w http.ResponseWriter, r *http.Request) {
+	format, ok := tabular.FormatForMediaType(mediaType(r))
+	if value := r.URL.Query().Get("format"); value != "" {
+		if format, ok = tabular.ParseFormat(value); !ok {
+			customError.RespondWithError(w, r, customError.Newf(customError.CodeInvalidParameter, "Invalid {0}", "format"))
+			return
+		}
+	}
+	if !ok {
+		mediaTypes := make([]string, len(tabular.Formats))
+		for i, format := range tabular.Formats {
+			mediaTypes[i] = format.MediaType()
+		}
+		customError.RespondWithError(w, r, customError.Newf(customError.CodeUnsupportedMedia, "Content-Type must be {0}", strings.Join(mediaTypes, ", ")))
+		return
+	}
+
+	data, err := readBody(r)
+	if err != nil {
+		customError.RespondWithError(w, r, err)
+		return
+	}
+
+	job, err := h.importUseCase.Upload(r.Context(), format, data)
+	if err != nil {
+		customError.RespondWithError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Location", importLocation(job))
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(job)
+}
+
+func importLocation(job *entity.ImportJob) string {
+	return "/v1/companies/imports/" + job.ID.String()
+}
+
+// Get reports the status and progress of the job.
+func (h *importHandler) Get(w http.ResponseWriter, r *http.Request) {
+	id, err := importID(r)
+	if err != nil {
+		customError.RespondWithError(w, r, err)
+		return
+	}
+
+	job, err := h.importUseCase.Get(r.Context(), id)
+	if err != nil {
+		customError.RespondWithError(w, r, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(job)
+}
+
+func (h *importHandler) DryRun(w http.ResponseWriter, r *http.Request) {
+	id, request, err := decodeImportRequest(r)
+	if err != nil {
+		customError.RespondWithError(w, r, err)
+		return
+	}
+
+	report, err := h.importUseCase.DryRun(r.Context(), id, request.Mapping)
+	if err != nil {
+		customError.RespondWithError(w, r, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(report)
+}
+
+// Run queues the job and responds before it is processed; clients poll the
+// job for its progress.
+func (h *importHandler) Run(w http.ResponseWriter, r *http.Request) {
+	id, request, err := decodeImportRequest(r)
+	if err != nil {
+		customError.RespondWithError(w, r, err)
+		return
+	}
+
+	job, err := h.importUseCase.Run(r.Context(), id, request.Mapping)
+	if err != nil {
+		customError.RespondWithError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Location", importLocation(job))
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(job)
+}
+
+// ListErrors is paginated by row, so that the errors of a row are on the same
+// page: the response's next_after is passed as after to get the following
+// page.
+func (h *importHandler) ListErrors(w http.ResponseWriter, r *http.Request) {
+	id, err := importID(r)
+	if err != nil {
+		customError.RespondWithError(w, r, err)
+		return
+	}
+
+	query := r.URL.Query()
+	afterRow, limit := 0, entity.DefaultImportErrorLimit
+	if value := query.Get("after"); value != "" {
+		if afterRow, err = strconv.Atoi(value); err != nil || afterRow < 0 {
+			customError.RespondWithError(w, r, customError.Newf(customError.CodeInvalidParameter, "Invalid {0}", "after"))
+			return
+		}
+	}
+	if value := query.Get("limit"); value != "" {
+		if limit, err = strconv.Atoi(value); err != nil || limit <= 0 {
+			customError.RespondWithError(w, r, customError.Newf(customError.CodeInvalidParameter, "Invalid {0}", "limit"))
+			return
+		}
+	}
+	if limit > entity.MaxImportErrorLimit {
+		limit = entity.MaxImportErrorLimit
+	}
+
+	rowErrors, err := h.importUseCase.ListErrors(r.Context(), id, afterRow, limit)
+	if err != nil {
+		customError.RespondWithError(w, r, err)
+		return
+	}
+
+	response := map[string]interface{}{"errors": rowErrors}
+	rows := 0
+	for i, rowErr := range rowErrors {
+		if i == 0 || rowErr.Row != rowErrors[i-1].Row {
+			rows++
+		}
+	}
+	if rows == limit {
+		response["next_after"] = rowErrors[len(rowErrors)-1].Row
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// decodeImportRequest reads the ID of the job and the optional body of a dry
+// run or run.
+func decodeImportRequest(r *http.Request) (uuid.UUID, importRequest, error) {
+	var request importRequest
+	id, err := importID(r)
+	if err != nil {
+		return id, request, err
+	}
+	if r.ContentLength == 0 {
+		return id, request, nil
+	}
+	return id, request, decodeJSON(r, &request, disallowUnknownFields())
+}
+
+func importID(r *http.Request) (uuid.UUID, error) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		return id, customError.New(customError.CodeInvalidParameter, "Invalid import ID")
+	}
+	return id, nil
+}
@@ -0,0 +1,25 @@
+package http
+
+import (
+	"encoding/json"
+	"github.com/assylzhan-a/company-task/internal/auth"
+	"github.com/go-chi/chi/v5"
+	"net/http"
+)
+
+type jwksHandler struct {
+	keys *auth.KeySet
+}
+
+func NewJWKSHandler(r *chi.Mux, keys *auth.KeySet) {
+	handler := &jwksHandler{
+		keys: keys,
+	}
+	r.Get("/.well-known/jwks.json", handler.Get)
+}
+
+func (h *jwksHandler) Get(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	json.NewEncoder(w).Encode(h.keys.JWKS())
+}
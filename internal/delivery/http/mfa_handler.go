@@ -0,0 +1,130 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/assylzhan-a/company-task/internal/auth"
+	"github.com/assylzhan-a/company-task/internal/domain/entity"
+	uc "github.com/assylzhan-a/company-task/internal/ports/usecase"
+	customError "github.com/assylzhan-a/company-task/pkg/errors"
+	"github.com/go-chi/chi/v5"
+	"net/http"
+)
+
+type mfaCodeRequest struct {
+	Code string `json:"code"`
+}
+
+type mfaHandler struct {
+	mfaUseCase uc.MFAUseCase
+}
+
+func NewMFAHandler(r *chi.Mux, mfaUseCase uc.MFAUseCase, authenticator *auth.Authenticator, idempotency func(http.Handler) http.Handler) {
+	handler := &mfaHandler{
+		mfaUseCase: mfaUseCase,
+	}
+
+	r.Route("/v1/users/mfa", func(r chi.Router) {
+		r.Use(authenticator.JWTAuth)
+		r.Use(idempotency)
+		r.Post("/enroll", handler.Enroll)
+		r.Post("/confirm", handler.Confirm)
+		r.Post("/disable", handler.Disable)
+		r.Post("/recovery-codes", handler.RegenerateRecoveryCodes)
+	})
+}
+
+func (h *mfaHandler) Enroll(w http.ResponseWriter, r *http.Request) {
+	principal, ok := auth.PrincipalFromContext(r.Context())
+	if !ok {
+		customError.RespondWithError(w, r, customError.New(customError.CodeInvalidToken, "Invalid token"))
+		return
+	}
+
+	enrollment, err := h.mfaUseCase.Enroll(r.Context(), principal.UserID)
+	if err != nil {
+		respondWithMFAError(w, r, err, "Failed to enroll MFA")
+		return
+	}
+
+	noStore(w)
+	json.NewEncoder(w).Encode(enrollment)
+}
+
+func (h *mfaHandler) Confirm(w http.ResponseWriter, r *http.Request) {
+	principal, code, ok := parseMFACodeRequest(w, r)
+	if !ok {
+		return
+	}
+
+	recoveryCodes, err := h.mfaUseCase.Confirm(r.Context(), principal.UserID, code)
+	if err != nil {
+		respondWithMFAError(w, r, err, "Failed to confirm MFA")
+		return
+	}
+
+	noStore(w)
+	json.NewEncoder(w).Encode(map[string]interface{}{"recovery_codes": recoveryCodes})
+}
+
+func (h *mfaHandler) Disable(w http.ResponseWriter, r *http.Request) {
+	principal, code, ok := parseMFACodeRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.mfaUseCase.Disable(r.Context(), principal.UserID, code); err != nil {
+		respondWithMFAError(w, r, err, "Failed to disable MFA")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *mfaHandler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	principal, code, ok := parseMFACodeRequest(w, r)
+	if !ok {
+		return
+	}
+
+	recoveryCodes, err := h.mfaUseCase.RegenerateRecoveryCodes(r.Context(), principal.UserID, code)
+	if err != nil {
+		respondWithMFAError(w, r, err, "Failed to regenerate recovery codes")
+		return
+	}
+
+	noStore(w)
+	json.NewEncoder(w).Encode(map[string]interface{}{"recovery_codes": recoveryCodes})
+}
+
+func parseMFACodeRequest(w http.ResponseWriter, r *http.Request) (*auth.Principal, string, bool) {
+	principal, ok := auth.PrincipalFromContext(r.Context())
+	if !ok {
+		customError.RespondWithError(w, r, customError.New(customError.CodeInvalidToken, "Invalid token"))
+		return nil, "", false
+	}
+
+	var req mfaCodeRequest
+	if err := decodeJSON(r, &req); err != nil {
+		customError.RespondWithError(w, r, err)
+		return nil, "", false
+	}
+	if err := requireFields(requiredField{"code", req.Code == ""}); err != nil {
+		customError.RespondWithError(w, r, err)
+		return nil, "", false
+	}
+	return principal, req.Code, true
+}
+
+func respondWithMFAError(w http.ResponseWriter, r *http.Request, err error, fallback string) {
+	switch {
+	case errors.Is(err, entity.ErrInvalidMFACode):
+		customError.RespondWithError(w, r, domainError(http.StatusBadRequest, err))
+	case errors.Is(err, entity.ErrMFAAlreadyEnabled):
+		customError.RespondWithError(w, r, customError.New(customError.CodeMFAAlreadyEnabled, "MFA is already enabled"))
+	case errors.Is(err, entity.ErrMFANotEnrolled):
+		customError.RespondWithError(w, r, customError.New(customError.CodeMFANotEnrolled, "MFA is not enrolled"))
+	default:
+		customError.RespondWithError(w, r, customError.NewInternalServerError(fallback))
+	}
+}
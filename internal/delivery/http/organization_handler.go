@@ -0,0 +1,146 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/assylzhan-a/company-task/internal/auth"
+	"github.com/assylzhan-a/company-task/internal/domain/entity"
+	uc "github.com/assylzhan-a/company-task/internal/ports/usecase"
+	customError "github.com/assylzhan-a/company-task/pkg/errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"net/http"
+)
+
+type organizationRequest struct {
+	Name string `json:"name"`
+}
+
+type organizationHandler struct {
+	organizationUseCase uc.OrganizationUseCase
+}
+
+func NewOrganizationHandler(r *chi.Mux, organizationUseCase uc.OrganizationUseCase, authenticator *auth.Authenticator, idempotency func(http.Handler) http.Handler) {
+	handler := &organizationHandler{
+		organizationUseCase: organizationUseCase,
+	}
+
+	r.With(authenticator.JWTAuth).Get("/v1/organizations", handler.ListMine)
+
+	r.Route("/v1/admin/organizations", func(r chi.Router) {
+		r.Use(authenticator.JWTAuth)
+		r.Use(authenticator.RequirePermission(entity.PermissionOrganizationsManage))
+		r.Use(idempotency)
+		r.Get("/", handler.List)
+		r.Post("/", handler.Create)
+		r.Put("/{id}/members/{user_id}", handler.AddMember)
+		r.Delete("/{id}/members/{user_id}", handler.RemoveMember)
+	})
+}
+
+func (h *organizationHandler) ListMine(w http.ResponseWriter, r *http.Request) {
+	principal, ok := auth.PrincipalFromContext(r.Context())
+	if !ok {
+		customError.RespondWithError(w, r, customError.New(customError.CodeInvalidToken, "Invalid token"))
+		return
+	}
+
+	organizations, err := h.organizationUseCase.ListForUser(r.Context(), principal.UserID)
+	if err != nil {
+		customError.RespondWithError(w, r, customError.NewInternalServerError("Failed to list organizations"))
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"organizations":          organizations,
+		"active_organization_id": principal.OrganizationID,
+	})
+}
+
+func (h *organizationHandler) List(w http.ResponseWriter, r *http.Request) {
+	organizations, err := h.organizationUseCase.List(r.Context())
+	if err != nil {
+		customError.RespondWithError(w, r, customError.NewInternalServerError("Failed to list organizations"))
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{"organizations": organizations})
+}
+
+func (h *organizationHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var req organizationRequest
+	if err := decodeJSON(r, &req); err != nil {
+		customError.RespondWithError(w, r, err)
+		return
+	}
+
+	organization, err := h.organizationUseCase.Create(r.Context(), req.Name)
+	if err != nil {
+		respondWithOrganizationError(w, r, err, "Failed to create organization")
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(organization)
+}
+
+func (h *organizationHandler) AddMember(w http.ResponseWriter, r *http.Request) {
+	organizationID, userID, ok := parseMembershipParams(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.organizationUseCase.AddMember(r.Context(), organizationID, userID); err != nil {
+		respondWithOrganizationError(w, r, err, "Failed to add organization member")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *organizationHandler) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	organizationID, userID, ok := parseMembershipParams(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.organizationUseCase.RemoveMember(r.Context(), organizationID, userID); err != nil {
+		respondWithOrganizationError(w, r, err, "Failed to remove organization member")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func parseMembershipParams(w http.ResponseWriter, r *http.Request) (uuid.UUID, uuid.UUID, bool) {
+	organizationID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		customError.RespondWithError(w, r, customError.New(customError.CodeInvalidParameter, "Invalid organization ID"))
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	userID, err := uuid.Parse(chi.URLParam(r, "user_id"))
+	if err != nil {
+		customError.RespondWithError(w, r, customError.New(customError.CodeInvalidParameter, "Invalid user ID"))
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	return organizationID, userID, true
+}
+
+func respondWithOrganizationError(w http.ResponseWriter, r *http.Request, err error, fallback string) {
+	switch {
+	case errors.Is(err, entity.ErrEmptyOrganizationName):
+		customError.RespondWithError(w, r, domainError(http.StatusBadRequest, err))
+	case errors.Is(err, entity.ErrOrganizationExists):
+		customError.RespondWithError(w, r, customError.New(customError.CodeOrganizationExists, "Organization already exists"))
+	case errors.Is(err, entity.ErrOrganizationNotFound):
+		customError.RespondWithError(w, r, customError.New(customError.CodeOrganizationNotFound, "Organization not found"))
+	case errors.Is(err, entity.ErrUserNotFound):
+		customError.RespondWithError(w, r, customError.New(customError.CodeUserNotFound, "User not found"))
+	case errors.Is(err, entity.ErrNotOrganizationMember):
+		customError.RespondWithError(w, r, customError.New(customError.CodeNotOrganizationMember, "User is not a member of the organization"))
+	default:
+		customError.RespondWithError(w, r, customError.NewInternalServerError(fallback))
+	}
+}
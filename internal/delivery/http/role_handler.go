@@ -0,0 +1,109 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/assylzhan-a/company-task/internal/auth"
+	"github.com/assylzhan-a/company-task/internal/domain/entity"
+	uc "github.com/assylzhan-a/company-task/internal/ports/usecase"
+	customError "github.com/assylzhan-a/company-task/pkg/errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"net/http"
+)
+
+type roleHandler struct {
+	roleUseCase uc.RoleUseCase
+}
+
+func NewRoleHandler(r *chi.Mux, roleUseCase uc.RoleUseCase, authenticator *auth.Authenticator, idempotency func(http.Handler) http.Handler) {
+	handler := &roleHandler{
+		roleUseCase: roleUseCase,
+	}
+	r.Route("/v1/admin", func(r chi.Router) {
+		r.Use(authenticator.JWTAuth)
+		r.Use(authenticator.RequirePermission(entity.PermissionRolesManage))
+		r.Use(idempotency)
+		r.Get("/roles", handler.ListRoles)
+		r.Get("/users/{id}/roles", handler.GetUserRoles)
+		r.Put("/users/{id}/roles/{role}", handler.AssignRole)
+		r.Delete("/users/{id}/roles/{role}", handler.RevokeRole)
+	})
+}
+
+func (h *roleHandler) ListRoles(w http.ResponseWriter, r *http.Request) {
+	roles, err := h.roleUseCase.ListRoles(r.Context())
+	if err != nil {
+		customError.RespondWithError(w, r, customError.NewInternalServerError("Failed to list roles"))
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{"roles": roles})
+}
+
+func (h *roleHandler) GetUserRoles(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		customError.RespondWithError(w, r, customError.New(customError.CodeInvalidParameter, "Invalid user ID"))
+		return
+	}
+
+	roles, err := h.roleUseCase.GetUserRoles(r.Context(), userID)
+	if err != nil {
+		respondWithRoleError(w, r, err, "Failed to get user roles")
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{"user_id": userID, "roles": roles})
+}
+
+func (h *roleHandler) AssignRole(w http.ResponseWriter, r *http.Request) {
+	principal, ok := auth.PrincipalFromContext(r.Context())
+	if !ok {
+		customError.RespondWithError(w, r, customError.New(customError.CodeInvalidToken, "Invalid token"))
+		return
+	}
+	userID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		customError.RespondWithError(w, r, customError.New(customError.CodeInvalidParameter, "Invalid user ID"))
+		return
+	}
+
+	if err := h.roleUseCase.AssignRole(r.Context(), principal.UserID, userID, chi.URLParam(r, "role")); err != nil {
+		respondWithRoleError(w, r, err, "Failed to assign role")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *roleHandler) RevokeRole(w http.ResponseWriter, r *http.Request) {
+	principal, ok := auth.PrincipalFromContext(r.Context())
+	if !ok {
+		customError.RespondWithError(w, r, customError.New(customError.CodeInvalidToken, "Invalid token"))
+		return
+	}
+	userID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		customError.RespondWithError(w, r, customError.New(customError.CodeInvalidParameter, "Invalid user ID"))
+		return
+	}
+
+	if err := h.roleUseCase.RevokeRole(r.Context(), principal.UserID, userID, chi.URLParam(r, "role")); err != nil {
+		respondWithRoleError(w, r, err, "Failed to revoke role")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func respondWithRoleError(w http.ResponseWriter, r *http.Request, err error, fallback string) {
+	switch {
+	case errors.Is(err, entity.ErrUserNotFound):
+		customError.RespondWithError(w, r, customError.New(customError.CodeUserNotFound, "User not found"))
+	case errors.Is(err, entity.ErrRoleNotFound):
+		customError.RespondWithError(w, r, customError.New(customError.CodeRoleNotFound, "Role not found"))
+	default:
+		customError.RespondWithError(w, r, customError.NewInternalServerError(fallback))
+	}
+}
@@ -0,0 +1,158 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/assylzhan-a/company-task/internal/auth"
+	"github.com/assylzhan-a/company-task/internal/domain/entity"
+	uc "github.com/assylzhan-a/company-task/internal/ports/usecase"
+	customError "github.com/assylzhan-a/company-task/pkg/errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"net/http"
+	"time"
+)
+
+type serviceAccountRequest struct {
+	Name string `json:"name"`
+}
+
+type apiKeyRequest struct {
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+type apiKeyResponse struct {
+	*entity.APIKey
+	// Key is only returned when the key is created.
+	Key string `json:"key"`
+}
+
+type serviceAccountHandler struct {
+	serviceAccountUseCase uc.ServiceAccountUseCase
+}
+
+func NewServiceAccountHandler(r *chi.Mux, serviceAccountUseCase uc.ServiceAccountUseCase, authenticator *auth.Authenticator, idempotency func(http.Handler) http.Handler) {
+	handler := &serviceAccountHandler{
+		serviceAccountUseCase: serviceAccountUseCase,
+	}
+
+	r.Route("/v1/admin/service-accounts", func(r chi.Router) {
+		r.Use(authenticator.JWTAuth)
+		r.Use(authenticator.RequirePermission(entity.PermissionServiceAccountsManage))
+		r.Use(idempotency)
+		r.Get("/", handler.List)
+		r.Post("/", handler.Create)
+		r.Get("/{id}/keys", handler.ListKeys)
+		r.Post("/{id}/keys", handler.CreateKey)
+		r.Delete("/{id}/keys/{key_id}", handler.RevokeKey)
+	})
+}
+
+func (h *serviceAccountHandler) List(w http.ResponseWriter, r *http.Request) {
+	accounts, err := h.serviceAccountUseCase.List(r.Context())
+	if err != nil {
+		respondWithServiceAccountError(w, r, err, "Failed to list service accounts")
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{"service_accounts": accounts})
+}
+
+func (h *serviceAccountHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var req serviceAccountRequest
+	if err := decodeJSON(r, &req); err != nil {
+		customError.RespondWithError(w, r, err)
+		return
+	}
+
+	account, err := h.serviceAccountUseCase.Create(r.Context(), req.Name)
+	if err != nil {
+		respondWithServiceAccountError(w, r, err, "Failed to create service account")
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(account)
+}
+
+func (h *serviceAccountHandler) ListKeys(w http.ResponseWriter, r *http.Request) {
+	serviceAccountID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		customError.RespondWithError(w, r, customError.New(customError.CodeInvalidParameter, "Invalid service account ID"))
+		return
+	}
+
+	keys, err := h.serviceAccountUseCase.ListAPIKeys(r.Context(), serviceAccountID)
+	if err != nil {
+		respondWithServiceAccountError(w, r, err, "Failed to list API keys")
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{"keys": keys})
+}
+
+func (h *serviceAccountHandler) CreateKey(w http.ResponseWriter, r *http.Request) {
+	serviceAccountID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		customError.RespondWithError(w, r, customError.New(customError.CodeInvalidParameter, "Invalid service account ID"))
+		return
+	}
+
+	var req apiKeyRequest
+	if err := decodeJSON(r, &req); err != nil {
+		customError.RespondWithError(w, r, err)
+		return
+	}
+
+	key, raw, err := h.serviceAccountUseCase.CreateAPIKey(r.Context(), serviceAccountID, req.Scopes, req.ExpiresAt)
+	if err != nil {
+		respondWithServiceAccountError(w, r, err, "Failed to create API key")
+		return
+	}
+
+	noStore(w)
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(apiKeyResponse{APIKey: key, Key: raw})
+}
+
+func (h *serviceAccountHandler) RevokeKey(w http.ResponseWriter, r *http.Request) {
+	serviceAccountID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		customError.RespondWithError(w, r, customError.New(customError.CodeInvalidParameter, "Invalid service account ID"))
+		return
+	}
+
+	keyID, err := uuid.Parse(chi.URLParam(r, "key_id"))
+	if err != nil {
+		customError.RespondWithError(w, r, customError.New(customError.CodeInvalidParameter, "Invalid API key ID"))
+		return
+	}
+
+	if err := h.serviceAccountUseCase.RevokeAPIKey(r.Context(), serviceAccountID, keyID); err != nil {
+		respondWithServiceAccountError(w, r, err, "Failed to revoke API key")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func respondWithServiceAccountError(w http.ResponseWriter, r *http.Request, err error, fallback string) {
+	var appErr *customError.AppError
+	switch {
+	case errors.As(err, &appErr):
+		customError.RespondWithError(w, r, appErr)
+	case errors.Is(err, entity.ErrEmptyServiceAccountName),
+		errors.Is(err, entity.ErrInvalidScope),
+		errors.Is(err, entity.ErrInvalidAPIKeyExpiry):
+		customError.RespondWithError(w, r, domainError(http.StatusBadRequest, err))
+	case errors.Is(err, entity.ErrServiceAccountExists):
+		customError.RespondWithError(w, r, customError.New(customError.CodeServiceAccountExists, "Service account already exists"))
+	case errors.Is(err, entity.ErrServiceAccountNotFound):
+		customError.RespondWithError(w, r, customError.New(customError.CodeServiceAccountNotFound, "Service account not found"))
+	case errors.Is(err, entity.ErrAPIKeyNotFound):
+		customError.RespondWithError(w, r, customError.New(customError.CodeAPIKeyNotFound, "API key not found"))
+	default:
+		customError.RespondWithError(w, r, customError.NewInternalServerError(fallback))
+	}
+}
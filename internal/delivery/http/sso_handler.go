@@ -0,0 +1,108 @@
+package http
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"github.com/assylzhan-a/company-task/internal/domain/entity"
+	uc "github.com/assylzhan-a/company-task/internal/ports/usecase"
+	customError "github.com/assylzhan-a/company-task/pkg/errors"
+	"github.com/go-chi/chi/v5"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ssoStateCookie binds the callback to the browser that started the login, so
+// that an attacker cannot log a victim into the attacker's account.
+const ssoStateCookie = "sso_state"
+
+type ssoHandler struct {
+	UserUseCase uc.UserUseCase
+}
+
+func NewSSOHandler(r *chi.Mux, userUseCase uc.UserUseCase) {
+	handler := &ssoHandler{
+		UserUseCase: userUseCase,
+	}
+
+	r.Route("/v1/users/sso", func(r chi.Router) {
+		r.Get("/login", handler.Login)
+		r.Get("/callback", handler.Callback)
+	})
+}
+
+// Login redirects to the identity provider. Clients that accept JSON get the
+// URL in the body instead.
+func (h *ssoHandler) Login(w http.ResponseWriter, r *http.Request) {
+	redirect, err := h.UserUseCase.StartSSOLogin(r.Context())
+	if err != nil {
+		respondWithSSOError(w, r, err)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     ssoStateCookie,
+		Value:    redirect.State,
+		Path:     "/v1/users/sso",
+		Expires:  redirect.ExpiresAt,
+		MaxAge:   int(time.Until(redirect.ExpiresAt).Seconds()),
+		Secure:   true,
+		HttpOnly: true,
+		// Lax lets the cookie through on the top-level redirect back from
+		// the provider.
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	if strings.Contains(r.Header.Get("Accept"), "application/json") {
+		json.NewEncoder(w).Encode(redirect)
+		return
+	}
+	http.Redirect(w, r, redirect.URL, http.StatusFound)
+}
+
+func (h *ssoHandler) Callback(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if providerError := query.Get("error"); providerError != "" {
+		customError.RespondWithError(w, r, customError.Newf(customError.CodeSSOLoginFailed, "Identity provider returned {0}", providerError))
+		return
+	}
+
+	state, code := query.Get("state"), query.Get("code")
+	cookie, err := r.Cookie(ssoStateCookie)
+	if err != nil || state == "" || code == "" || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(state)) != 1 {
+		customError.RespondWithError(w, r, customError.New(customError.CodeInvalidSSOState, entity.ErrInvalidSSOState.Error()))
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: ssoStateCookie, Path: "/v1/users/sso", MaxAge: -1, Secure: true, HttpOnly: true})
+
+	result, err := h.UserUseCase.CompleteSSOLogin(r.Context(), state, code, clientIP(r))
+	if err != nil {
+		respondWithSSOError(w, r, err)
+		return
+	}
+
+	if result.Challenge != nil {
+		json.NewEncoder(w).Encode(result.Challenge)
+		return
+	}
+	json.NewEncoder(w).Encode(result.Tokens)
+}
+
+func respondWithSSOError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, entity.ErrSSODisabled):
+		customError.RespondWithError(w, r, domainError(http.StatusNotFound, err))
+	case errors.Is(err, entity.ErrInvalidSSOState):
+		customError.RespondWithError(w, r, domainError(http.StatusBadRequest, err))
+	case errors.Is(err, entity.ErrSSOLoginFailed):
+		customError.RespondWithError(w, r, customError.New(customError.CodeSSOLoginFailed, entity.ErrSSOLoginFailed.Error()))
+	case errors.Is(err, entity.ErrUsernameTaken), errors.Is(err, entity.ErrEmailTaken):
+		customError.RespondWithError(w, r, customError.New(customError.CodeAccountNotLinked,
+			"An account with the same username or email already exists and is not linked to this identity"))
+	case errors.Is(err, entity.ErrUserDisabled), errors.Is(err, entity.ErrNotOrganizationMember):
+		customError.RespondWithError(w, r, domainError(http.StatusForbidden, err))
+	default:
+		customError.RespondWithError(w, r, customError.NewInternalServerError("Failed to log in"))
+	}
+}
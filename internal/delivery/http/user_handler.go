@@ -2,48 +2,127 @@ package http
 
 import (
 	"encoding/json"
+	"errors"
+	"github.com/assylzhan-a/company-task/internal/auth"
 	"github.com/assylzhan-a/company-task/internal/domain/entity"
 	uc "github.com/assylzhan-a/company-task/internal/ports/usecase"
 	customError "github.com/assylzhan-a/company-task/pkg/errors"
 	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"math"
+	"net"
 	"net/http"
+	"strconv"
 )
 
 type userRequest struct {
-	Username string `json:"username"`
+	Username       string     `json:"username"`
+	Password       string     `json:"password"`
+	Email          string     `json:"email,omitempty"`
+	OrganizationID *uuid.UUID `json:"organization_id,omitempty"`
+}
+
+type switchOrganizationRequest struct {
+	OrganizationID uuid.UUID `json:"organization_id"`
+}
+
+type mfaLoginRequest struct {
+	MFAToken string `json:"mfa_token"`
+	Code     string `json:"code"`
+}
+
+type passwordResetRequest struct {
+	Email string `json:"email"`
+}
+
+type passwordResetConfirmRequest struct {
+	Token    string `json:"token"`
 	Password string `json:"password"`
 }
 
+type verifyEmailRequest struct {
+	Token string `json:"token"`
+}
+
+type changePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+type deleteAccountRequest struct {
+	Password string `json:"password"`
+}
+
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
 type userHandler struct {
 	UserUseCase uc.UserUseCase
 }
 
-func NewUserHandler(r *chi.Mux, userUseCase uc.UserUseCase) {
+func NewUserHandler(r *chi.Mux, userUseCase uc.UserUseCase, authenticator *auth.Authenticator, idempotency func(http.Handler) http.Handler) {
 	handler := &userHandler{
 		UserUseCase: userUseCase,
 	}
 	r.Route("/v1/users", func(r chi.Router) {
 		r.Post("/register", handler.Register)
 		r.Post("/login", handler.Login)
+		r.Post("/login/mfa", handler.LoginMFA)
+		r.Post("/refresh", handler.Refresh)
+		r.Post("/password-reset/request", handler.RequestPasswordReset)
+		r.Post("/password-reset/confirm", handler.ResetPassword)
+		r.Post("/verify-email", handler.VerifyEmail)
+
+		r.Group(func(r chi.Router) {
+			r.Use(authenticator.JWTAuth)
+			r.Use(idempotency)
+			r.Post("/verify-email/resend", handler.ResendVerificationEmail)
+			r.Post("/logout", handler.Logout)
+			r.Post("/logout-all", handler.LogoutAll)
+			r.Post("/switch-organization", handler.SwitchOrganization)
+			r.Get("/me", handler.GetProfile)
+			r.Post("/me/password", handler.ChangePassword)
+			r.Delete("/me", handler.DeleteAccount)
+		})
+	})
+
+	r.Group(func(r chi.Router) {
+		r.Use(authenticator.JWTAuth)
+		r.Use(authenticator.RequirePermission(entity.PermissionUsersManage))
+		r.Use(idempotency)
+		r.Get("/v1/admin/users", handler.ListUsers)
+		r.Post("/v1/admin/users/{id}/disable", handler.DisableUser)
+		r.Post("/v1/admin/users/{id}/enable", handler.EnableUser)
 	})
 }
 
 func (h *userHandler) Register(w http.ResponseWriter, r *http.Request) {
 	var req userRequest
 	ctx := r.Context()
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		customError.RespondWithError(w, customError.NewBadRequestError("Invalid request payload"))
+	if err := decodeJSON(r, &req); err != nil {
+		customError.RespondWithError(w, r, err)
 		return
 	}
 
-	if err := h.UserUseCase.Register(ctx, req.Username, req.Password); err != nil {
-		switch err {
-		case entity.ErrEmptyUsername, entity.ErrEmptyPassword:
-			customError.RespondWithError(w, customError.NewBadRequestError(err.Error()))
-		case entity.ErrUsernameTaken:
-			customError.RespondWithError(w, customError.NewBadRequestError("Username is already taken"))
+	if err := h.UserUseCase.Register(ctx, req.Username, req.Password, req.Email); err != nil {
+		switch {
+		case errors.Is(err, entity.ErrVerificationEmailNotSent):
+			// The account exists; the user can ask for another email.
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(map[string]string{
+				"message": "User registered successfully, but the verification email could not be sent. Please request a new one",
+			})
+		case errors.Is(err, entity.ErrEmptyUsername), errors.Is(err, entity.ErrEmptyPassword),
+			errors.Is(err, entity.ErrWeakPassword), errors.Is(err, entity.ErrInvalidEmail),
+			errors.Is(err, entity.ErrEmailRequired):
+			customError.RespondWithError(w, r, domainError(http.StatusBadRequest, err))
+		case errors.Is(err, entity.ErrUsernameTaken):
+			customError.RespondWithError(w, r, customError.New(customError.CodeUsernameTaken, "Username is already taken"))
+		case errors.Is(err, entity.ErrLocalLoginDisabled):
+			customError.RespondWithError(w, r, domainError(http.StatusForbidden, err))
 		default:
-			customError.RespondWithError(w, customError.NewInternalServerError("Failed to register user"))
+			customError.RespondWithError(w, r, customError.NewInternalServerError("Failed to register user"))
 		}
 		return
 	}
@@ -55,21 +134,443 @@ func (h *userHandler) Register(w http.ResponseWriter, r *http.Request) {
 func (h *userHandler) Login(w http.ResponseWriter, r *http.Request) {
 	var req userRequest
 	ctx := r.Context()
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		customError.RespondWithError(w, customError.NewBadRequestError("Invalid request payload"))
+	if err := decodeJSON(r, &req); err != nil {
+		customError.RespondWithError(w, r, err)
+		return
+	}
+
+	result, err := h.UserUseCase.Login(ctx, req.Username, req.Password, clientIP(r), req.OrganizationID)
+	if err != nil {
+		respondWithLoginError(w, r, err)
+		return
+	}
+
+	if result.Challenge != nil {
+		json.NewEncoder(w).Encode(result.Challenge)
+		return
+	}
+	json.NewEncoder(w).Encode(result.Tokens)
+}
+
+func (h *userHandler) LoginMFA(w http.ResponseWriter, r *http.Request) {
+	var req mfaLoginRequest
+	ctx := r.Context()
+	if err := decodeJSON(r, &req); err != nil {
+		customError.RespondWithError(w, r, err)
+		return
+	}
+	if err := requireFields(requiredField{"mfa_token", req.MFAToken == ""}, requiredField{"code", req.Code == ""}); err != nil {
+		customError.RespondWithError(w, r, err)
 		return
 	}
 
-	token, err := h.UserUseCase.Login(ctx, req.Username, req.Password)
+	tokens, err := h.UserUseCase.VerifyMFA(ctx, req.MFAToken, req.Code, clientIP(r))
 	if err != nil {
-		switch err {
-		case entity.ErrEmptyUsername, entity.ErrEmptyPassword, entity.ErrInvalidCredentials:
-			customError.RespondWithError(w, customError.NewUnauthorizedError(err.Error()))
+		respondWithLoginError(w, r, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(tokens)
+}
+
+func (h *userHandler) Refresh(w http.ResponseWriter, r *http.Request) {
+	var req refreshRequest
+	ctx := r.Context()
+	if err := decodeJSON(r, &req); err != nil {
+		customError.RespondWithError(w, r, err)
+		return
+	}
+	if err := requireFields(requiredField{"refresh_token", req.RefreshToken == ""}); err != nil {
+		customError.RespondWithError(w, r, err)
+		return
+	}
+
+	tokens, err := h.UserUseCase.Refresh(ctx, req.RefreshToken, clientIP(r))
+	if err != nil {
+		switch {
+		case errors.Is(err, entity.ErrInvalidRefreshToken), errors.Is(err, entity.ErrRefreshTokenReused),
+			errors.Is(err, entity.ErrNotOrganizationMember):
+			customError.RespondWithError(w, r, domainError(http.StatusUnauthorized, err))
+		default:
+			customError.RespondWithError(w, r, customError.NewInternalServerError("Failed to refresh token"))
+		}
+		return
+	}
+
+	json.NewEncoder(w).Encode(tokens)
+}
+
+func (h *userHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	principal, ok := auth.PrincipalFromContext(ctx)
+	if !ok {
+		customError.RespondWithError(w, r, customError.New(customError.CodeInvalidToken, "Invalid token"))
+		return
+	}
+
+	if err := h.UserUseCase.Logout(ctx, principal.SessionID); err != nil {
+		customError.RespondWithError(w, r, customError.NewInternalServerError("Failed to log out"))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *userHandler) RequestPasswordReset(w http.ResponseWriter, r *http.Request) {
+	var req passwordResetRequest
+	ctx := r.Context()
+	if err := decodeJSON(r, &req); err != nil {
+		customError.RespondWithError(w, r, err)
+		return
+	}
+	if err := requireFields(requiredField{"email", req.Email == ""}); err != nil {
+		customError.RespondWithError(w, r, err)
+		return
+	}
+
+	if err := h.UserUseCase.RequestPasswordReset(ctx, req.Email, clientIP(r)); err != nil {
+		var throttled *entity.ResetThrottledError
+		switch {
+		case errors.As(err, &throttled):
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(throttled.RetryAfter.Seconds()))))
+			customError.RespondWithError(w, r, domainError(http.StatusTooManyRequests, err))
+		case errors.Is(err, entity.ErrLocalLoginDisabled):
+			customError.RespondWithError(w, r, domainError(http.StatusForbidden, err))
+		default:
+			customError.RespondWithError(w, r, customError.NewInternalServerError("Failed to request password reset"))
+		}
+		return
+	}
+
+	// The response is the same whether or not the email is registered.
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "If the email belongs to a verified account, a reset link has been sent",
+	})
+}
+
+func (h *userHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var req passwordResetConfirmRequest
+	ctx := r.Context()
+	if err := decodeJSON(r, &req); err != nil {
+		customError.RespondWithError(w, r, err)
+		return
+	}
+	if err := requireFields(requiredField{"token", req.Token == ""}); err != nil {
+		customError.RespondWithError(w, r, err)
+		return
+	}
+
+	if err := h.UserUseCase.ResetPassword(ctx, req.Token, req.Password, clientIP(r)); err != nil {
+		switch {
+		case errors.Is(err, entity.ErrEmptyPassword), errors.Is(err, entity.ErrWeakPassword),
+			errors.Is(err, entity.ErrInvalidOneTimeToken):
+			customError.RespondWithError(w, r, domainError(http.StatusBadRequest, err))
+		case errors.Is(err, entity.ErrLocalLoginDisabled):
+			customError.RespondWithError(w, r, domainError(http.StatusForbidden, err))
+		default:
+			customError.RespondWithError(w, r, customError.NewInternalServerError("Failed to reset password"))
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *userHandler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	var req verifyEmailRequest
+	ctx := r.Context()
+	if err := decodeJSON(r, &req); err != nil {
+		customError.RespondWithError(w, r, err)
+		return
+	}
+	if err := requireFields(requiredField{"token", req.Token == ""}); err != nil {
+		customError.RespondWithError(w, r, err)
+		return
+	}
+
+	if err := h.UserUseCase.VerifyEmail(ctx, req.Token); err != nil {
+		switch {
+		case errors.Is(err, entity.ErrInvalidOneTimeToken):
+			customError.RespondWithError(w, r, domainError(http.StatusBadRequest, err))
 		default:
-			customError.RespondWithError(w, customError.NewInternalServerError("Failed to log in"))
+			customError.RespondWithError(w, r, customError.NewInternalServerError("Failed to verify email"))
 		}
 		return
 	}
 
-	json.NewEncoder(w).Encode(map[string]string{"token": token})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *userHandler) ResendVerificationEmail(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	principal, ok := auth.PrincipalFromContext(ctx)
+	if !ok {
+		customError.RespondWithError(w, r, customError.New(customError.CodeInvalidToken, "Invalid token"))
+		return
+	}
+
+	if err := h.UserUseCase.SendVerificationEmail(ctx, principal.UserID); err != nil {
+		switch {
+		case errors.Is(err, entity.ErrEmailRequired), errors.Is(err, entity.ErrEmailAlreadyVerified):
+			customError.RespondWithError(w, r, domainError(http.StatusBadRequest, err))
+		default:
+			customError.RespondWithError(w, r, customError.NewInternalServerError("Failed to send verification email"))
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Verification email sent"})
+}
+
+func (h *userHandler) SwitchOrganization(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	principal, ok := auth.PrincipalFromContext(ctx)
+	if !ok {
+		customError.RespondWithError(w, r, customError.New(customError.CodeInvalidToken, "Invalid token"))
+		return
+	}
+
+	var req switchOrganizationRequest
+	if err := decodeJSON(r, &req); err != nil {
+		customError.RespondWithError(w, r, err)
+		return
+	}
+	if err := requireFields(requiredField{"organization_id", req.OrganizationID == uuid.Nil}); err != nil {
+		customError.RespondWithError(w, r, err)
+		return
+	}
+
+	tokens, err := h.UserUseCase.SwitchOrganization(ctx, principal.UserID, principal.SessionID, req.OrganizationID)
+	if err != nil {
+		switch {
+		case errors.Is(err, entity.ErrNotOrganizationMember):
+			customError.RespondWithError(w, r, domainError(http.StatusForbidden, err))
+		default:
+			customError.RespondWithError(w, r, customError.NewInternalServerError("Failed to switch organization"))
+		}
+		return
+	}
+
+	noStore(w)
+	json.NewEncoder(w).Encode(tokens)
+}
+
+func (h *userHandler) LogoutAll(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	principal, ok := auth.PrincipalFromContext(ctx)
+	if !ok {
+		customError.RespondWithError(w, r, customError.New(customError.CodeInvalidToken, "Invalid token"))
+		return
+	}
+
+	if err := h.UserUseCase.LogoutAll(ctx, principal.UserID); err != nil {
+		customError.RespondWithError(w, r, customError.NewInternalServerError("Failed to log out"))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *userHandler) GetProfile(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	principal, ok := auth.PrincipalFromContext(ctx)
+	if !ok {
+		customError.RespondWithError(w, r, customError.New(customError.CodeInvalidToken, "Invalid token"))
+		return
+	}
+
+	profile, err := h.UserUseCase.GetProfile(ctx, principal.UserID)
+	if err != nil {
+		switch {
+		case errors.Is(err, entity.ErrUserNotFound):
+			customError.RespondWithError(w, r, domainError(http.StatusNotFound, err))
+		default:
+			customError.RespondWithError(w, r, customError.NewInternalServerError("Failed to get profile"))
+		}
+		return
+	}
+
+	json.NewEncoder(w).Encode(profile)
+}
+
+func (h *userHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	principal, ok := auth.PrincipalFromContext(ctx)
+	if !ok {
+		customError.RespondWithError(w, r, customError.New(customError.CodeInvalidToken, "Invalid token"))
+		return
+	}
+
+	var req changePasswordRequest
+	if err := decodeJSON(r, &req); err != nil {
+		customError.RespondWithError(w, r, err)
+		return
+	}
+
+	var organizationID *uuid.UUID
+	if principal.OrganizationID != uuid.Nil {
+		organizationID = &principal.OrganizationID
+	}
+	tokens, err := h.UserUseCase.ChangePassword(ctx, principal.UserID, organizationID, req.CurrentPassword, req.NewPassword, clientIP(r))
+	if err != nil {
+		switch {
+		case errors.Is(err, entity.ErrEmptyPassword), errors.Is(err, entity.ErrWeakPassword):
+			customError.RespondWithError(w, r, domainError(http.StatusBadRequest, err))
+		default:
+			respondWithReauthenticationError(w, r, err, "Failed to change password")
+		}
+		return
+	}
+
+	noStore(w)
+	json.NewEncoder(w).Encode(tokens)
+}
+
+func (h *userHandler) DeleteAccount(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	principal, ok := auth.PrincipalFromContext(ctx)
+	if !ok {
+		customError.RespondWithError(w, r, customError.New(customError.CodeInvalidToken, "Invalid token"))
+		return
+	}
+
+	var req deleteAccountRequest
+	if err := decodeJSON(r, &req); err != nil {
+		customError.RespondWithError(w, r, err)
+		return
+	}
+
+	if err := h.UserUseCase.DeleteAccount(ctx, principal.UserID, req.Password, clientIP(r)); err != nil {
+		respondWithReauthenticationError(w, r, err, "Failed to delete account")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *userHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := entity.UserFilter{Query: query.Get("q")}
+	if value := query.Get("disabled"); value != "" {
+		disabled, err := strconv.ParseBool(value)
+		if err != nil {
+			customError.RespondWithError(w, r, customError.New(customError.CodeInvalidParameter, "Invalid disabled filter"))
+			return
+		}
+		filter.Disabled = &disabled
+	}
+	for name, target := range map[string]*int{"limit": &filter.Limit, "offset": &filter.Offset} {
+		value := query.Get(name)
+		if value == "" {
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			customError.RespondWithError(w, r, customError.Newf(customError.CodeInvalidParameter, "Invalid {0}", name))
+			return
+		}
+		*target = n
+	}
+	filter.Normalize()
+
+	users, err := h.UserUseCase.ListUsers(r.Context(), filter)
+	if err != nil {
+		customError.RespondWithError(w, r, customError.NewInternalServerError("Failed to list users"))
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"users":  users,
+		"limit":  filter.Limit,
+		"offset": filter.Offset,
+	})
+}
+
+func (h *userHandler) DisableUser(w http.ResponseWriter, r *http.Request) {
+	h.setUserDisabled(w, r, true)
+}
+
+func (h *userHandler) EnableUser(w http.ResponseWriter, r *http.Request) {
+	h.setUserDisabled(w, r, false)
+}
+
+func (h *userHandler) setUserDisabled(w http.ResponseWriter, r *http.Request, disabled bool) {
+	ctx := r.Context()
+	principal, ok := auth.PrincipalFromContext(ctx)
+	if !ok {
+		customError.RespondWithError(w, r, customError.New(customError.CodeInvalidToken, "Invalid token"))
+		return
+	}
+	userID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		customError.RespondWithError(w, r, customError.New(customError.CodeInvalidParameter, "Invalid user ID"))
+		return
+	}
+
+	if disabled {
+		err = h.UserUseCase.DisableUser(ctx, principal.UserID, userID)
+	} else {
+		err = h.UserUseCase.EnableUser(ctx, principal.UserID, userID)
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, entity.ErrUserNotFound):
+			customError.RespondWithError(w, r, domainError(http.StatusNotFound, err))
+		case errors.Is(err, entity.ErrCannotModifySelf):
+			customError.RespondWithError(w, r, domainError(http.StatusBadRequest, err))
+		default:
+			customError.RespondWithError(w, r, customError.NewInternalServerError("Failed to update user"))
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// respondWithReauthenticationError maps the errors of confirming the password
+// for a sensitive change. A wrong password is 403 rather than 401, because
+// the access token itself is valid.
+func respondWithReauthenticationError(w http.ResponseWriter, r *http.Request, err error, message string) {
+	var throttled *entity.LoginThrottledError
+	switch {
+	case errors.As(err, &throttled):
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(throttled.RetryAfter.Seconds()))))
+		customError.RespondWithError(w, r, domainError(http.StatusTooManyRequests, err))
+	case errors.Is(err, entity.ErrInvalidCredentials), errors.Is(err, entity.ErrPasswordNotSet):
+		customError.RespondWithError(w, r, domainError(http.StatusForbidden, err))
+	case errors.Is(err, entity.ErrUserNotFound):
+		customError.RespondWithError(w, r, domainError(http.StatusNotFound, err))
+	default:
+		customError.RespondWithError(w, r, customError.NewInternalServerError(message))
+	}
+}
+
+func respondWithLoginError(w http.ResponseWriter, r *http.Request, err error) {
+	var throttled *entity.LoginThrottledError
+	switch {
+	case errors.As(err, &throttled):
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(throttled.RetryAfter.Seconds()))))
+		customError.RespondWithError(w, r, domainError(http.StatusTooManyRequests, err))
+	case errors.Is(err, entity.ErrEmptyUsername), errors.Is(err, entity.ErrEmptyPassword),
+		errors.Is(err, entity.ErrInvalidCredentials), errors.Is(err, entity.ErrInvalidMFAToken),
+		errors.Is(err, entity.ErrInvalidMFACode):
+		customError.RespondWithError(w, r, domainError(http.StatusUnauthorized, err))
+	case errors.Is(err, entity.ErrNotOrganizationMember), errors.Is(err, entity.ErrEmailNotVerified),
+		errors.Is(err, entity.ErrLocalLoginDisabled), errors.Is(err, entity.ErrUserDisabled):
+		customError.RespondWithError(w, r, domainError(http.StatusForbidden, err))
+	default:
+		customError.RespondWithError(w, r, customError.NewInternalServerError("Failed to log in"))
+	}
+}
+
+// clientIP returns the address of the peer. Forwarding headers are ignored
+// because they are set by the client and would let it evade login throttling.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
 }
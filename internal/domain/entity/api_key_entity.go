@@ -0,0 +1,169 @@
+package entity
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// APIKeyPrefix starts every API key so that leaked keys can be recognised,
+// e.g. by secret scanners.
+const APIKeyPrefix = "csk"
+
+// APIKeyTouchInterval is how often the last use of a key is recorded at most,
+// so that busy keys do not write on every request.
+const APIKeyTouchInterval = time.Minute
+
+const (
+	ScopeCompaniesRead  = "companies:read"
+	ScopeCompaniesWrite = "companies:write"
+	// ScopeCompaniesDelete is separate from ScopeCompaniesWrite, since keys
+	// cannot step up with MFA like users must to delete companies.
+	ScopeCompaniesDelete = "companies:delete"
+)
+
+// ScopePermissions maps API key scopes to the permissions they grant.
+var ScopePermissions = map[string][]string{
+	ScopeCompaniesRead:   {PermissionCompaniesRead},
+	ScopeCompaniesWrite:  {PermissionCompaniesRead, PermissionCompaniesCreate, PermissionCompaniesUpdate},
+	ScopeCompaniesDelete: {PermissionCompaniesRead, PermissionCompaniesDelete},
+}
+
+type ServiceAccount struct {
+	ID             uuid.UUID  `json:"id"`
+	OrganizationID uuid.UUID  `json:"organization_id"`
+	Name           string     `json:"name"`
+	CreatedBy      *uuid.UUID `json:"created_by,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// APIKey is a long-lived credential of a service account. Keys have the form
+// csk_<prefix>_<secret>; the prefix identifies the key and only a hash of the
+// whole key is stored.
+type APIKey struct {
+	ID               uuid.UUID  `json:"id"`
+	ServiceAccountID uuid.UUID  `json:"service_account_id"`
+	OrganizationID   uuid.UUID  `json:"organization_id"`
+	Prefix           string     `json:"prefix"`
+	KeyHash          string     `json:"-"`
+	Scopes           []string   `json:"scopes"`
+	ExpiresAt        *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt       *time.Time `json:"last_used_at,omitempty"`
+	CreatedAt        time.Time  `json:"created_at"`
+	RevokedAt        *time.Time `json:"revoked_at,omitempty"`
+}
+
+func NewServiceAccount(organizationID uuid.UUID, name string, createdBy *uuid.UUID) (*ServiceAccount, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, ErrEmptyServiceAccountName
+	}
+
+	return &ServiceAccount{
+		ID:             uuid.New(),
+		OrganizationID: organizationID,
+		Name:           name,
+		CreatedBy:      createdBy,
+		CreatedAt:      time.Now(),
+	}, nil
+}
+
+// NewAPIKey generates a key for the service account and returns the entity
+// together with the raw key, which is shown to the caller only once.
+func NewAPIKey(account *ServiceAccount, scopes []string, expiresAt *time.Time) (*APIKey, string, error) {
+	if len(scopes) == 0 {
+		return nil, "", ErrInvalidScope
+	}
+	for _, scope := range scopes {
+		if _, ok := ScopePermissions[scope]; !ok {
+			return nil, "", ErrInvalidScope
+		}
+	}
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return nil, "", ErrInvalidAPIKeyExpiry
+	}
+
+	prefix, err := randomToken(9)
+	if err != nil {
+		return nil, "", err
+	}
+	secret, err := randomToken(32)
+	if err != nil {
+		return nil, "", err
+	}
+	raw := APIKeyPrefix + "_" + prefix + "_" + secret
+
+	return &APIKey{
+		ID:               uuid.New(),
+		ServiceAccountID: account.ID,
+		OrganizationID:   account.OrganizationID,
+		Prefix:           prefix,
+		KeyHash:          hashAPIKey(raw),
+		Scopes:           scopes,
+		ExpiresAt:        expiresAt,
+		CreatedAt:        time.Now(),
+	}, raw, nil
+}
+
+// ParseAPIKeyPrefix extracts the lookup prefix from a raw API key.
+func ParseAPIKeyPrefix(raw string) (string, bool) {
+	parts := strings.Split(raw, "_")
+	if len(parts) != 3 || parts[0] != APIKeyPrefix || parts[1] == "" || parts[2] == "" {
+		return "", false
+	}
+	return parts[1], true
+}
+
+// Matches reports whether raw is this key, in constant time.
+func (k *APIKey) Matches(raw string) bool {
+	return subtle.ConstantTimeCompare([]byte(hashAPIKey(raw)), []byte(k.KeyHash)) == 1
+}
+
+// TouchDue reports whether the use at now should be recorded.
+func (k *APIKey) TouchDue(now time.Time) bool {
+	return k.LastUsedAt == nil || now.Sub(*k.LastUsedAt) >= APIKeyTouchInterval
+}
+
+// IsUsable reports whether the key is neither revoked nor expired.
+func (k *APIKey) IsUsable(now time.Time) bool {
+	if k.RevokedAt != nil {
+		return false
+	}
+	return k.ExpiresAt == nil || now.Before(*k.ExpiresAt)
+}
+
+// Permissions returns the permissions granted by the key's scopes.
+func (k *APIKey) Permissions() []string {
+	seen := make(map[string]struct{})
+	var permissions []string
+	for _, scope := range k.Scopes {
+		for _, permission := range ScopePermissions[scope] {
+			if _, ok := seen[permission]; ok {
+				continue
+			}
+			seen[permission] = struct{}{}
+			permissions = append(permissions, permission)
+		}
+	}
+	return permissions
+}
+
+func hashAPIKey(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomToken(size int) (string, error) {
+	buf := make([]byte, size)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	// The raw URL alphabet contains "_", which separates the key parts.
+	return strings.ReplaceAll(base64.RawURLEncoding.EncodeToString(buf), "_", "-"), nil
+}
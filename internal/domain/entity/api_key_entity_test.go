@@ -0,0 +1,27 @@
+package entity
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPIKeyTouchDue(t *testing.T) {
+	now := time.Now()
+	key := &APIKey{}
+	assert.True(t, key.TouchDue(now), "a key that was never used")
+
+	lastUsed := now.Add(-30 * time.Second)
+	key.LastUsedAt = &lastUsed
+	assert.False(t, key.TouchDue(now))
+	assert.True(t, key.TouchDue(lastUsed.Add(APIKeyTouchInterval)))
+}
+
+func TestAPIKeyPermissions(t *testing.T) {
+	key := &APIKey{Scopes: []string{ScopeCompaniesWrite}}
+	assert.ElementsMatch(t, []string{PermissionCompaniesRead, PermissionCompaniesCreate, PermissionCompaniesUpdate}, key.Permissions())
+
+	key.Scopes = append(key.Scopes, ScopeCompaniesDelete)
+	assert.ElementsMatch(t, []string{PermissionCompaniesRead, PermissionCompaniesCreate, PermissionCompaniesUpdate, PermissionCompaniesDelete}, key.Permissions())
+}
@@ -0,0 +1,88 @@
+package entity
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+type CompanyBatchMode string
+
+const (
+	// CompanyBatchAtomic applies every operation of the batch in one
+	// transaction, or none of them if any fails.
+	CompanyBatchAtomic CompanyBatchMode = "atomic"
+	// CompanyBatchBestEffort applies each operation on its own, so that a
+	// failed operation does not affect the others.
+	CompanyBatchBestEffort CompanyBatchMode = "best_effort"
+)
+
+type CompanyBatchOp string
+
+const (
+	CompanyBatchCreate CompanyBatchOp = "create"
+	CompanyBatchPatch  CompanyBatchOp = "patch"
+	CompanyBatchDelete CompanyBatchOp = "delete"
+)
+
+// CompanyBatch is a list of company operations sent in one request. The
+// operations are applied in order, so that later operations see the changes
+// of earlier ones.
+type CompanyBatch struct {
+	Mode       CompanyBatchMode        `json:"mode" validate:"omitempty,oneof=atomic best_effort"`
+	Operations []CompanyBatchOperation `json:"operations" validate:"required,min=1"`
+}
+
+// CompanyBatchOperation creates Company, applies Patch, a JSON Merge Patch,
+// to the company with ID or deletes it.
+type CompanyBatchOperation struct {
+	Op      CompanyBatchOp  `json:"op"`
+	ID      uuid.UUID       `json:"id,omitempty"`
+	Company *Company        `json:"company,omitempty"`
+	Patch   json.RawMessage `json:"patch,omitempty"`
+}
+
+// CompanyBatchResult is the outcome of an operation. Company is the company
+// as created or patched. An operation that did not fail itself is not
+// applied either if another operation of an atomic batch failed.
+type CompanyBatchResult struct {
+	Op      CompanyBatchOp
+	ID      uuid.UUID
+	Company *Company
+	Applied bool
+	Err     error
+}
+
+// IsAtomic reports whether the batch is all-or-nothing, which is the default.
+func (b *CompanyBatch) IsAtomic() bool {
+	return b.Mode != CompanyBatchBestEffort
+}
+
+func (b *CompanyBatch) Validate() error {
+	return validate.Struct(b)
+}
+
+// CompanyChange is a change of a company that is stored together with its
+// revision and, for creates and updates, its outbox event. ExpectedVersion,
+// if not 0, is the version the company must have for the change to apply.
+type CompanyChange struct {
+	Company         *Company
+	ExpectedVersion int64
+	Event           *OutboxEvent
+	Revision        *CompanyRevision
+}
+
+// CompanyChangeError reports which of a list of changes failed.
+type CompanyChangeError struct {
+	Index int
+	Err   error
+}
+
+func (e *CompanyChangeError) Error() string {
+	return fmt.Sprintf("change %d: %v", e.Index, e.Err)
+}
+
+func (e *CompanyChangeError) Unwrap() error {
+	return e.Err
+}
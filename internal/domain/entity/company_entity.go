@@ -3,6 +3,9 @@ package entity
 import (
 	"github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
+	"reflect"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -16,14 +19,108 @@ var ValidCompanyTypes = []CompanyType{
 }
 
 type Company struct {
-	ID                uuid.UUID   `json:"id" validate:"required"`
+	// ID is generated by the server when the client omits it.
+	ID             uuid.UUID `json:"id"`
+	OrganizationID uuid.UUID `json:"organization_id"`
+	// Slug names the company in URLs. It is derived from the name when the
+	// company is created and never changes.
+	Slug              string      `json:"slug"`
 	Name              string      `json:"name" validate:"required,max=15"`
 	Description       *string     `json:"description,omitempty" validate:"omitempty,max=3000"`
 	AmountOfEmployees int         `json:"amount_of_employees" validate:"required,min=1"`
-	Registered        bool        `json:"registered" validate:"required"`
+	Registered        bool        `json:"registered"`
 	Type              CompanyType `json:"type" validate:"required,companyType"`
+	OwnerID           *uuid.UUID  `json:"owner_id,omitempty"`
+	CreatedBy         *uuid.UUID  `json:"created_by,omitempty"`
+	UpdatedBy         *uuid.UUID  `json:"updated_by,omitempty"`
 	CreatedAt         time.Time   `json:"created_at"`
 	UpdatedAt         time.Time   `json:"updated_at"`
+	// Version counts the changes of the company. It is only exposed as the
+	// ETag of the company.
+	Version int64 `json:"-"`
+}
+
+// IsOwnedBy reports whether the user is the current owner of the company.
+func (c *Company) IsOwnedBy(userID uuid.UUID) bool {
+	return c.OwnerID != nil && *c.OwnerID == userID
+}
+
+// CompanyFilter selects companies of an organization. Zero values do not
+// filter.
+type CompanyFilter struct {
+	// Types matches companies of any of the types.
+	Types      []CompanyType
+	Registered *bool
+	// MinEmployees and MaxEmployees bound the amount of employees, inclusive.
+	MinEmployees *int
+	MaxEmployees *int
+	// Query matches a part of the name, ignoring case.
+	Query   string
+	OwnerID *uuid.UUID
+	// CreatedFrom and CreatedTo bound the creation time; CreatedTo is
+	// exclusive.
+	CreatedFrom time.Time
+	CreatedTo   time.Time
+}
+
+// defaultSlug is the slug of companies whose name has no letters or digits
+// that can be used in a URL.
+const defaultSlug = "company"
+
+// Slugify derives a URL-friendly slug from a company name: ASCII letters and
+// digits in lower case, with every other run of characters replaced by a
+// single hyphen.
+func Slugify(name string) string {
+	var b strings.Builder
+	hyphen := false
+	for _, c := range strings.ToLower(name) {
+		if ('a' <= c && c <= 'z') || ('0' <= c && c <= '9') {
+			if hyphen && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(c)
+			hyphen = false
+		} else {
+			hyphen = true
+		}
+	}
+	if b.Len() == 0 {
+		return defaultSlug
+	}
+	return b.String()
+}
+
+// ETag returns the strong entity tag of the current version of the company.
+func (c *Company) ETag() string {
+	return `"` + strconv.FormatInt(c.Version, 10) + `"`
+}
+
+// CompanyPrecondition holds the entity tags of the If-Match and If-None-Match
+// headers of a request; "*" matches any version of an existing company.
+type CompanyPrecondition struct {
+	IfMatch     []string
+	IfNoneMatch []string
+}
+
+// Check reports whether the company, nil if it does not exist, meets the
+// precondition.
+func (p CompanyPrecondition) Check(company *Company) bool {
+	if len(p.IfMatch) > 0 && (company == nil || !matchesETag(p.IfMatch, company.ETag())) {
+		return false
+	}
+	if len(p.IfNoneMatch) > 0 && company != nil && matchesETag(p.IfNoneMatch, company.ETag()) {
+		return false
+	}
+	return true
+}
+
+func matchesETag(tags []string, etag string) bool {
+	for _, tag := range tags {
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+	return false
 }
 
 type PatchCompany struct {
@@ -34,24 +131,56 @@ type PatchCompany struct {
 	Type              *CompanyType `json:"type,omitempty" validate:"omitempty,companyType"`
 }
 
+type TransferOwnership struct {
+	OwnerID uuid.UUID `json:"owner_id" validate:"required"`
+}
+
+// Company events are published to the Kafka topic named after their type,
+// with the company as JSON in the payload. A deleted company is the company
+// as it was before it was deleted.
+const (
+	CompanyCreatedEvent = "company_created"
+	CompanyUpdatedEvent = "company_updated"
+	CompanyDeletedEvent = "company_deleted"
+)
+
+// CompanyEventTypes lists the types of company events.
+var CompanyEventTypes = []string{CompanyCreatedEvent, CompanyUpdatedEvent, CompanyDeletedEvent}
+
 type OutboxEvent struct {
-	ID        uuid.UUID `json:"id"`
-	EventType string    `json:"event_type"`
-	Payload   []byte    `json:"payload"`
-	CreatedAt time.Time `json:"created_at"`
+	ID             uuid.UUID  `json:"id"`
+	OrganizationID *uuid.UUID `json:"organization_id,omitempty"`
+	EventType      string     `json:"event_type"`
+	Payload        []byte     `json:"payload"`
+	CreatedAt      time.Time  `json:"created_at"`
 }
 
 var validate *validator.Validate
 
 func init() {
 	validate = validator.New()
+	// Report fields by their JSON names, which are the names clients know.
+	validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
 	validate.RegisterValidation("companyType", validateCompanyType)
+	if err := registerTranslations(validate); err != nil {
+		panic(err)
+	}
 }
 
 func validateCompanyType(fl validator.FieldLevel) bool {
-	value := CompanyType(fl.Field().String())
-	for _, t := range ValidCompanyTypes {
-		if value == t {
+	return CompanyType(fl.Field().String()).IsValid()
+}
+
+// IsValid reports whether t is one of ValidCompanyTypes.
+func (t CompanyType) IsValid() bool {
+	for _, valid := range ValidCompanyTypes {
+		if t == valid {
 			return true
 		}
 	}
@@ -65,3 +194,7 @@ func (c *Company) Validate() error {
 func (pc *PatchCompany) Validate() error {
 	return validate.Struct(pc)
 }
+
+func (t *TransferOwnership) Validate() error {
+	return validate.Struct(t)
+}
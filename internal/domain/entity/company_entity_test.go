@@ -0,0 +1,118 @@
+package entity
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompanyPrecondition(t *testing.T) {
+	company := &Company{Version: 3}
+	assert.Equal(t, `"3"`, company.ETag())
+
+	cases := []struct {
+		name         string
+		precondition CompanyPrecondition
+		existing     bool
+		missing      bool
+	}{
+		{"none", CompanyPrecondition{}, true, true},
+		{"if-match any", CompanyPrecondition{IfMatch: []string{"*"}}, true, false},
+		{"if-match version", CompanyPrecondition{IfMatch: []string{`"2"`, `"3"`}}, true, false},
+		{"if-match stale version", CompanyPrecondition{IfMatch: []string{`"2"`}}, false, false},
+		{"if-match weak tag", CompanyPrecondition{IfMatch: []string{`W/"3"`}}, false, false},
+		{"if-none-match any", CompanyPrecondition{IfNoneMatch: []string{"*"}}, false, true},
+		{"if-none-match version", CompanyPrecondition{IfNoneMatch: []string{`"3"`}}, false, true},
+		{"if-none-match other version", CompanyPrecondition{IfNoneMatch: []string{`"2"`}}, true, true},
+	}
+	for _, tc := range cases {
+		assert.Equal(t, tc.existing, tc.precondition.Check(company), tc.name)
+		assert.Equal(t, tc.missing, tc.precondition.Check(nil), tc.name)
+	}
+}
+
+func TestCompanyValidateAcceptsUnregistered(t *testing.T) {
+	company := &Company{Name: "Acme", AmountOfEmployees: 3, Registered: false, Type: "Cooperative"}
+	assert.NoError(t, company.Validate())
+
+	company.Name = ""
+	assert.Error(t, company.Validate())
+}
+
+func TestSlugify(t *testing.T) {
+	cases := map[string]string{
+		"Tech Corp":         "tech-corp",
+		"  ACME, Inc.  ":    "acme-inc",
+		"R2-D2":             "r2-d2",
+		"Café Noir":         "caf-noir",
+		"Рога и копыта":     "company",
+		"--":                "company",
+		"already-a-slug-42": "already-a-slug-42",
+	}
+	for name, slug := range cases {
+		assert.Equal(t, slug, Slugify(name), name)
+	}
+}
+
+func TestCompanyExportValues(t *testing.T) {
+	ownerID := uuid.New()
+	description := "Makes things"
+	company := &Company{
+		ID:                uuid.New(),
+		Slug:              "acme",
+		Name:              "Acme",
+		Description:       &description,
+		AmountOfEmployees: 10,
+		Registered:        true,
+		Type:              "Corporations",
+		OwnerID:           &ownerID,
+		CreatedAt:         time.Date(2024, 10, 13, 9, 0, 0, 0, time.UTC),
+		UpdatedAt:         time.Date(2024, 10, 14, 9, 0, 0, 0, time.UTC),
+	}
+
+	values := company.ExportValues()
+	require.Len(t, values, len(CompanyExportColumns))
+	assert.Equal(t, []interface{}{
+		company.ID.String(), "acme", "Acme", "Makes things", 10, true, "Corporations",
+		ownerID.String(), nil, nil, company.CreatedAt, company.UpdatedAt,
+	}, values)
+
+	// An export maps back to the company by default.
+	mapping := DefaultImportMapping(CompanyExportColumns)
+	assert.Len(t, mapping, len(ImportFields))
+}
+
+func TestCompanyStatsEmployeeRange(t *testing.T) {
+	query := &CompanyStatsQuery{EmployeeBounds: DefaultEmployeeBounds}
+	assert.Equal(t, "0-9", query.EmployeeRange(0))
+	assert.Equal(t, "10-49", query.EmployeeRange(1))
+	assert.Equal(t, "50-249", query.EmployeeRange(2))
+	assert.Equal(t, "250+", query.EmployeeRange(3))
+
+	assert.Equal(t, "0+", (&CompanyStatsQuery{}).EmployeeRange(0))
+}
+
+func TestCompanyStatsValues(t *testing.T) {
+	registered := true
+	created := time.Date(2024, 10, 7, 0, 0, 0, 0, time.UTC)
+	stats := &CompanyStats{GroupBy: []CompanyStatsDimension{CompanyStatsByCreated, CompanyStatsByType, CompanyStatsByRegistered}}
+	group := &CompanyStatsGroup{Type: "Cooperative", Registered: &registered, Created: &created, Count: 2, TotalEmployees: 30}
+
+	assert.Equal(t, []string{"created", "type", "registered", "count", "total_employees"}, stats.Columns())
+	assert.Equal(t, []interface{}{created, "Cooperative", true, int64(2), int64(30)}, stats.Values(group))
+
+	assert.Equal(t, []string{"count", "total_employees"}, (&CompanyStats{}).Columns())
+}
+
+func TestCompanyStatsQueryKey(t *testing.T) {
+	registered := true
+	query := CompanyStatsQuery{GroupBy: []CompanyStatsDimension{CompanyStatsByType}, Bucket: BucketWeek}
+	other := query
+	other.Filter.Registered = &registered
+
+	assert.Equal(t, query.Key(), (&CompanyStatsQuery{GroupBy: []CompanyStatsDimension{CompanyStatsByType}, Bucket: BucketWeek}).Key())
+	assert.NotEqual(t, query.Key(), other.Key())
+}
@@ -0,0 +1,34 @@
+package entity
+
+import "github.com/google/uuid"
+
+// CompanyExportColumns are the columns of an export of companies. They are
+// named like the JSON fields of a company, so that an export can be imported
+// again.
+var CompanyExportColumns = []string{
+	"id", "slug", "name", "description", "amount_of_employees", "registered", "type",
+	"owner_id", "created_by", "updated_by", "created_at", "updated_at",
+}
+
+// ExportValues returns the values of the company for CompanyExportColumns,
+// with nil for the fields it does not have.
+func (c *Company) ExportValues() []interface{} {
+	return []interface{}{
+		c.ID.String(), c.Slug, c.Name, optionalString(c.Description), c.AmountOfEmployees, c.Registered, string(c.Type),
+		optionalID(c.OwnerID), optionalID(c.CreatedBy), optionalID(c.UpdatedBy), c.CreatedAt, c.UpdatedAt,
+	}
+}
+
+func optionalString(s *string) interface{} {
+	if s == nil {
+		return nil
+	}
+	return *s
+}
+
+func optionalID(id *uuid.UUID) interface{} {
+	if id == nil {
+		return nil
+	}
+	return id.String()
+}
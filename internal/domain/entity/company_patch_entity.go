@@ -0,0 +1,108 @@
+package entity
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/assylzhan-a/company-task/pkg/jsonpatch"
+)
+
+// ErrInvalidCompanyPatch is returned when a patch leaves a company with
+// fields that do not exist or cannot be changed, or with values of the wrong
+// type.
+var ErrInvalidCompanyPatch = errors.New("patched company has unknown fields or values of the wrong type")
+
+// CompanyPatch changes the editable fields of a company. The result is not
+// validated; callers validate the company before storing it.
+type CompanyPatch interface {
+	Apply(company *Company) error
+}
+
+// Apply sets the fields present in the patch. A nil field is left unchanged,
+// so fields cannot be cleared; use a CompanyMergePatch to clear them.
+func (pc *PatchCompany) Apply(company *Company) error {
+	if pc.Name != nil {
+		company.Name = *pc.Name
+	}
+	if pc.Description != nil {
+		company.Description = pc.Description
+	}
+	if pc.AmountOfEmployees != nil {
+		company.AmountOfEmployees = *pc.AmountOfEmployees
+	}
+	if pc.Registered != nil {
+		company.Registered = *pc.Registered
+	}
+	if pc.Type != nil {
+		company.Type = *pc.Type
+	}
+	return nil
+}
+
+// CompanyMergePatch is a JSON Merge Patch (RFC 7396) of a company: members
+// set the fields and null members clear them.
+type CompanyMergePatch json.RawMessage
+
+func (p CompanyMergePatch) Apply(company *Company) error {
+	return patchDocument(company, func(doc []byte) ([]byte, error) {
+		return jsonpatch.MergePatch(doc, p)
+	})
+}
+
+// CompanyJSONPatch is a JSON Patch (RFC 6902) of a company. Its test
+// operations make the patch conditional on the current field values.
+type CompanyJSONPatch []jsonpatch.Operation
+
+func (p CompanyJSONPatch) Apply(company *Company) error {
+	return patchDocument(company, func(doc []byte) ([]byte, error) {
+		return jsonpatch.Apply(doc, p)
+	})
+}
+
+// companyDocument is the JSON document that merge and JSON patches apply to.
+// It only holds the editable fields, so that patches cannot change the ID,
+// the owner or the timestamps of a company.
+type companyDocument struct {
+	Name              string      `json:"name"`
+	Description       *string     `json:"description"`
+	AmountOfEmployees int         `json:"amount_of_employees"`
+	Registered        bool        `json:"registered"`
+	Type              CompanyType `json:"type"`
+}
+
+func patchDocument(company *Company, patch func(doc []byte) ([]byte, error)) error {
+	doc, err := json.Marshal(companyDocument{
+		Name:              company.Name,
+		Description:       company.Description,
+		AmountOfEmployees: company.AmountOfEmployees,
+		Registered:        company.Registered,
+		Type:              company.Type,
+	})
+	if err != nil {
+		return err
+	}
+
+	patched, err := patch(doc)
+	if err != nil {
+		return err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(patched))
+	decoder.DisallowUnknownFields()
+	var result *companyDocument
+	if err := decoder.Decode(&result); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidCompanyPatch, err)
+	}
+	if result == nil {
+		return ErrInvalidCompanyPatch
+	}
+
+	company.Name = result.Name
+	company.Description = result.Description
+	company.AmountOfEmployees = result.AmountOfEmployees
+	company.Registered = result.Registered
+	company.Type = result.Type
+	return nil
+}
@@ -0,0 +1,77 @@
+package entity
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/assylzhan-a/company-task/pkg/jsonpatch"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newPatchTestCompany() *Company {
+	description := "A company"
+	ownerID := uuid.New()
+	return &Company{
+		ID:                uuid.New(),
+		Name:              "Acme",
+		Description:       &description,
+		AmountOfEmployees: 10,
+		Registered:        true,
+		Type:              "Corporations",
+		OwnerID:           &ownerID,
+	}
+}
+
+func TestCompanyMergePatch(t *testing.T) {
+	company := newPatchTestCompany()
+	id, ownerID := company.ID, *company.OwnerID
+
+	require.NoError(t, CompanyMergePatch(`{"description":null,"amount_of_employees":20}`).Apply(company))
+	assert.Nil(t, company.Description)
+	assert.Equal(t, 20, company.AmountOfEmployees)
+	assert.Equal(t, "Acme", company.Name)
+	assert.Equal(t, id, company.ID)
+	assert.Equal(t, ownerID, *company.OwnerID)
+
+	// Removing a required field leaves it empty for Validate to reject.
+	require.NoError(t, CompanyMergePatch(`{"name":null}`).Apply(company))
+	assert.Error(t, company.Validate())
+
+	for _, patch := range []string{`{"id":"` + uuid.NewString() + `"}`, `{"amount_of_employees":"many"}`, `null`, `[]`} {
+		assert.ErrorIs(t, CompanyMergePatch(patch).Apply(newPatchTestCompany()), ErrInvalidCompanyPatch, patch)
+	}
+}
+
+func TestCompanyJSONPatch(t *testing.T) {
+	var patch CompanyJSONPatch
+	require.NoError(t, json.Unmarshal([]byte(`[
+		{"op":"test","path":"/name","value":"Acme"},
+		{"op":"replace","path":"/name","value":"Acme 2"},
+		{"op":"remove","path":"/description"}
+	]`), &patch))
+
+	company := newPatchTestCompany()
+	require.NoError(t, patch.Apply(company))
+	assert.Equal(t, "Acme 2", company.Name)
+	assert.Nil(t, company.Description)
+	require.NoError(t, company.Validate())
+
+	// The test operation fails now that the name has changed, and the
+	// company is left as it was.
+	err := patch.Apply(company)
+	assert.ErrorIs(t, err, jsonpatch.ErrTestFailed)
+	assert.Equal(t, "Acme 2", company.Name)
+
+	require.NoError(t, json.Unmarshal([]byte(`[{"op":"add","path":"/owner_id","value":"`+uuid.NewString()+`"}]`), &patch))
+	assert.ErrorIs(t, patch.Apply(newPatchTestCompany()), ErrInvalidCompanyPatch)
+}
+
+func TestPatchCompanyKeepsMissingFields(t *testing.T) {
+	name := "Acme 2"
+	company := newPatchTestCompany()
+	require.NoError(t, (&PatchCompany{Name: &name}).Apply(company))
+	assert.Equal(t, "Acme 2", company.Name)
+	assert.Equal(t, "A company", *company.Description)
+}
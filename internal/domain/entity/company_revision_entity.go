@@ -0,0 +1,114 @@
+package entity
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	CompanyRevisionCreated = "created"
+	CompanyRevisionUpdated = "updated"
+	CompanyRevisionDeleted = "deleted"
+	// CompanyRevisionSnapshot is the first revision of companies that existed
+	// before history was recorded.
+	CompanyRevisionSnapshot = "snapshot"
+)
+
+// CompanyRevision is the state of a company after a change, together with who
+// made the change and in which request.
+type CompanyRevision struct {
+	CompanyID uuid.UUID `json:"company_id"`
+	// Revision numbers the changes of a company starting at 1. It is assigned
+	// when the revision is stored.
+	Revision  int64      `json:"revision"`
+	Operation string     `json:"operation"`
+	Snapshot  *Company   `json:"snapshot"`
+	ActorID   *uuid.UUID `json:"actor_id,omitempty"`
+	RequestID string     `json:"request_id,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+func NewCompanyRevision(company *Company, operation string, actorID *uuid.UUID, requestID string) *CompanyRevision {
+	snapshot := *company
+	return &CompanyRevision{
+		CompanyID: company.ID,
+		Operation: operation,
+		Snapshot:  &snapshot,
+		ActorID:   actorID,
+		RequestID: requestID,
+		CreatedAt: time.Now(),
+	}
+}
+
+// FieldChange is a field that differs between two revisions, named by its
+// JSON name. From or To is nil if the field is unset on that side.
+type FieldChange struct {
+	Field string      `json:"field"`
+	From  interface{} `json:"from"`
+	To    interface{} `json:"to"`
+}
+
+// CompanyDiff lists the changes from one revision of a company to another.
+type CompanyDiff struct {
+	CompanyID    uuid.UUID     `json:"company_id"`
+	FromRevision int64         `json:"from_revision"`
+	ToRevision   int64         `json:"to_revision"`
+	Changes      []FieldChange `json:"changes"`
+}
+
+// DiffCompanyRevisions compares the snapshots field by field, in the order of
+// the JSON field names.
+func DiffCompanyRevisions(from, to *CompanyRevision) (*CompanyDiff, error) {
+	before, err := companyFields(from.Snapshot)
+	if err != nil {
+		return nil, err
+	}
+	after, err := companyFields(to.Snapshot)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]struct{}, len(before)+len(after))
+	for name := range before {
+		names[name] = struct{}{}
+	}
+	for name := range after {
+		names[name] = struct{}{}
+	}
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	diff := &CompanyDiff{
+		CompanyID:    to.CompanyID,
+		FromRevision: from.Revision,
+		ToRevision:   to.Revision,
+		Changes:      []FieldChange{},
+	}
+	for _, name := range sorted {
+		if !reflect.DeepEqual(before[name], after[name]) {
+			diff.Changes = append(diff.Changes, FieldChange{Field: name, From: before[name], To: after[name]})
+		}
+	}
+	return diff, nil
+}
+
+// companyFields returns the company as its JSON object, so that the diff uses
+// the same names and representations as the API.
+func companyFields(company *Company) (map[string]interface{}, error) {
+	data, err := json.Marshal(company)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
@@ -0,0 +1,41 @@
+package entity
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffCompanyRevisions(t *testing.T) {
+	description := "A company"
+	company := &Company{
+		ID:                uuid.New(),
+		Name:              "Before",
+		AmountOfEmployees: 10,
+		Registered:        true,
+		Type:              "Corporations",
+	}
+	from := NewCompanyRevision(company, CompanyRevisionCreated, nil, "")
+	from.Revision = 1
+
+	company.Name = "After"
+	company.Description = &description
+	to := NewCompanyRevision(company, CompanyRevisionUpdated, nil, "")
+	to.Revision = 2
+
+	diff, err := DiffCompanyRevisions(from, to)
+	require.NoError(t, err)
+	assert.Equal(t, company.ID, diff.CompanyID)
+	assert.Equal(t, int64(1), diff.FromRevision)
+	assert.Equal(t, int64(2), diff.ToRevision)
+	assert.Equal(t, []FieldChange{
+		{Field: "description", From: nil, To: "A company"},
+		{Field: "name", From: "Before", To: "After"},
+	}, diff.Changes)
+
+	same, err := DiffCompanyRevisions(to, to)
+	require.NoError(t, err)
+	assert.Empty(t, same.Changes)
+}
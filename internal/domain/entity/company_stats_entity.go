@@ -0,0 +1,165 @@
+package entity
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+)
+
+// CompanyStatsDimension is a property companies are grouped by.
+type CompanyStatsDimension string
+
+const (
+	CompanyStatsByType       CompanyStatsDimension = "type"
+	CompanyStatsByRegistered CompanyStatsDimension = "registered"
+	// CompanyStatsByEmployees groups companies by ranges of their amount of
+	// employees, bounded by CompanyStatsQuery.EmployeeBounds.
+	CompanyStatsByEmployees CompanyStatsDimension = "employees"
+	// CompanyStatsByCreated groups companies by the start of the period of
+	// CompanyStatsQuery.Bucket they were created in.
+	CompanyStatsByCreated CompanyStatsDimension = "created"
+)
+
+var CompanyStatsDimensions = []CompanyStatsDimension{
+	CompanyStatsByType, CompanyStatsByRegistered, CompanyStatsByEmployees, CompanyStatsByCreated,
+}
+
+func (d CompanyStatsDimension) IsValid() bool {
+	for _, dimension := range CompanyStatsDimensions {
+		if d == dimension {
+			return true
+		}
+	}
+	return false
+}
+
+// TimeBucket is the length of the periods of CompanyStatsByCreated, in UTC.
+// Weeks start on Monday.
+type TimeBucket string
+
+const (
+	BucketDay     TimeBucket = "day"
+	BucketWeek    TimeBucket = "week"
+	BucketMonth   TimeBucket = "month"
+	BucketQuarter TimeBucket = "quarter"
+	BucketYear    TimeBucket = "year"
+)
+
+var TimeBuckets = []TimeBucket{BucketDay, BucketWeek, BucketMonth, BucketQuarter, BucketYear}
+
+func (b TimeBucket) IsValid() bool {
+	for _, bucket := range TimeBuckets {
+		if b == bucket {
+			return true
+		}
+	}
+	return false
+}
+
+// DefaultEmployeeBounds split companies into micro, small, medium and large
+// ones.
+var DefaultEmployeeBounds = []int{10, 50, 250}
+
+// MaxEmployeeBounds limits the bounds of a query, and so its employee ranges.
+const MaxEmployeeBounds = 20
+
+// CompanyStatsQuery counts the companies that match Filter in groups of
+// equal values of the GroupBy dimensions. Without dimensions, all companies
+// form one group.
+type CompanyStatsQuery struct {
+	Filter  CompanyFilter
+	GroupBy []CompanyStatsDimension
+	Bucket  TimeBucket
+	// EmployeeBounds are the ascending, positive lower bounds of the employee
+	// ranges after the first; 10, 50 and 250 give the ranges 0-9, 10-49,
+	// 50-249 and 250+.
+	EmployeeBounds []int
+}
+
+// Key identifies the results of the query within an organization.
+func (q *CompanyStatsQuery) Key() string {
+	key, _ := json.Marshal(q)
+	return string(key)
+}
+
+// EmployeeRange returns the label of the range of EmployeeBounds with the
+// index, where 0 is the range below the first bound.
+func (q *CompanyStatsQuery) EmployeeRange(index int) string {
+	bounds := q.EmployeeBounds
+	switch {
+	case len(bounds) == 0:
+		return "0+"
+	case index <= 0:
+		return "0-" + strconv.Itoa(bounds[0]-1)
+	case index >= len(bounds):
+		return strconv.Itoa(bounds[len(bounds)-1]) + "+"
+	default:
+		return strconv.Itoa(bounds[index-1]) + "-" + strconv.Itoa(bounds[index]-1)
+	}
+}
+
+// CompanyStatsGroup counts the companies with the same values of the
+// dimensions of a query. Dimensions the query does not group by are empty.
+type CompanyStatsGroup struct {
+	Type       CompanyType `json:"type,omitempty"`
+	Registered *bool       `json:"registered,omitempty"`
+	// Employees is the label of an employee range, such as "10-49".
+	Employees string `json:"employees,omitempty"`
+	// Created is the start of a period.
+	Created        *time.Time `json:"created,omitempty"`
+	Count          int64      `json:"count"`
+	TotalEmployees int64      `json:"total_employees"`
+}
+
+// CompanyStats are the results of a query. Groups are ordered by their
+// dimensions; groups without companies are left out.
+type CompanyStats struct {
+	GroupBy        []CompanyStatsDimension `json:"group_by"`
+	Bucket         TimeBucket              `json:"bucket,omitempty"`
+	Count          int64                   `json:"count"`
+	TotalEmployees int64                   `json:"total_employees"`
+	Groups         []*CompanyStatsGroup    `json:"groups"`
+	ComputedAt     time.Time               `json:"computed_at"`
+}
+
+// Columns returns the columns of a table of the groups: a column per
+// dimension, followed by count and total_employees.
+func (s *CompanyStats) Columns() []string {
+	columns := make([]string, 0, len(s.GroupBy)+2)
+	for _, dimension := range s.GroupBy {
+		columns = append(columns, string(dimension))
+	}
+	return append(columns, "count", "total_employees")
+}
+
+// Values returns the values of the group for the Columns of the stats.
+func (s *CompanyStats) Values(group *CompanyStatsGroup) []interface{} {
+	values := make([]interface{}, 0, len(s.GroupBy)+2)
+	for _, dimension := range s.GroupBy {
+		switch dimension {
+		case CompanyStatsByType:
+			values = append(values, string(group.Type))
+		case CompanyStatsByRegistered:
+			values = append(values, optionalBool(group.Registered))
+		case CompanyStatsByEmployees:
+			values = append(values, group.Employees)
+		case CompanyStatsByCreated:
+			values = append(values, optionalTime(group.Created))
+		}
+	}
+	return append(values, group.Count, group.TotalEmployees)
+}
+
+func optionalBool(b *bool) interface{} {
+	if b == nil {
+		return nil
+	}
+	return *b
+}
+
+func optionalTime(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return *t
+}
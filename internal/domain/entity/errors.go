@@ -3,8 +3,49 @@ package entity
 import "errors"
 
 var (
-	ErrEmptyUsername      = errors.New("username cannot be empty")
-	ErrEmptyPassword      = errors.New("password cannot be empty")
-	ErrInvalidCredentials = errors.New("invalid username or password")
-	ErrUsernameTaken      = errors.New("username is already taken")
+	ErrEmptyUsername        = errors.New("username cannot be empty")
+	ErrEmptyPassword        = errors.New("password cannot be empty")
+	ErrInvalidCredentials   = errors.New("invalid username or password")
+	ErrUsernameTaken        = errors.New("username is already taken")
+	ErrWeakPassword         = errors.New("password does not meet the policy")
+	ErrTooManyLoginAttempts = errors.New("too many failed login attempts")
+	ErrInvalidRefreshToken  = errors.New("invalid or expired refresh token")
+	ErrRefreshTokenReused   = errors.New("refresh token has already been used")
+	ErrUserNotFound         = errors.New("user not found")
+	ErrRoleNotFound         = errors.New("role not found")
+	ErrUserDisabled         = errors.New("user is disabled")
+	ErrCannotModifySelf     = errors.New("administrators cannot disable themselves")
+	ErrPasswordNotSet       = errors.New("user has no password and signs in with single sign-on")
+
+	ErrInvalidOneTimeToken      = errors.New("invalid or expired token")
+	ErrEmailTaken               = errors.New("email is already registered")
+	ErrInvalidEmail             = errors.New("invalid email address")
+	ErrEmailRequired            = errors.New("email is required")
+	ErrEmailNotVerified         = errors.New("email address is not verified")
+	ErrEmailAlreadyVerified     = errors.New("email address is already verified")
+	ErrVerificationEmailNotSent = errors.New("verification email could not be sent")
+	ErrTooManyResetRequests     = errors.New("too many password reset requests")
+
+	ErrLocalLoginDisabled = errors.New("password login is disabled, use single sign-on")
+	ErrSSODisabled        = errors.New("single sign-on is not configured")
+	ErrInvalidSSOState    = errors.New("invalid or expired sso login")
+	ErrSSOLoginFailed     = errors.New("single sign-on login failed")
+
+	ErrEmptyOrganizationName = errors.New("organization name cannot be empty")
+	ErrOrganizationNotFound  = errors.New("organization not found")
+	ErrOrganizationExists    = errors.New("organization already exists")
+	ErrNotOrganizationMember = errors.New("user is not a member of the organization")
+
+	ErrMFANotEnrolled    = errors.New("mfa is not enrolled")
+	ErrMFAAlreadyEnabled = errors.New("mfa is already enabled")
+	ErrInvalidMFACode    = errors.New("invalid mfa code")
+	ErrInvalidMFAToken   = errors.New("invalid or expired mfa token")
+
+	ErrEmptyServiceAccountName = errors.New("service account name cannot be empty")
+	ErrServiceAccountNotFound  = errors.New("service account not found")
+	ErrServiceAccountExists    = errors.New("service account already exists")
+	ErrAPIKeyNotFound          = errors.New("api key not found")
+	ErrInvalidAPIKey           = errors.New("invalid or expired api key")
+	ErrInvalidScope            = errors.New("unknown or missing api key scope")
+	ErrInvalidAPIKeyExpiry     = errors.New("api key expiry must be in the future")
 )
@@ -0,0 +1,25 @@
+package entity
+
+import "time"
+
+// IdempotencyRecord is the response to a request made with an
+// Idempotency-Key. Scope identifies the caller and Fingerprint the request,
+// so that a retry is only answered with the stored response if it is the
+// same request from the same caller.
+type IdempotencyRecord struct {
+	Scope       string
+	Key         string
+	Fingerprint string
+	// StatusCode is 0 while the request that claimed the key is running.
+	StatusCode int
+	Header     map[string][]string
+	Body       []byte
+	CreatedAt  time.Time
+	ExpiresAt  time.Time
+}
+
+// InProgress reports whether the record is the claim of a request that has
+// not responded yet.
+func (r *IdempotencyRecord) InProgress() bool {
+	return r.StatusCode == 0
+}
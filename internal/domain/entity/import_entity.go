@@ -0,0 +1,195 @@
+package entity
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/assylzhan-a/company-task/pkg/tabular"
+	"github.com/google/uuid"
+)
+
+type ImportStatus string
+
+const (
+	// ImportUploaded jobs wait for their mapping to be checked and run.
+	ImportUploaded  ImportStatus = "uploaded"
+	ImportQueued    ImportStatus = "queued"
+	ImportRunning   ImportStatus = "running"
+	ImportCompleted ImportStatus = "completed"
+	// ImportFailed jobs stopped on an error of the service rather than of a
+	// row. Running them again resumes them after the last processed row.
+	ImportFailed ImportStatus = "failed"
+)
+
+// ImportFields are the fields of a company that can be imported, by their
+// JSON names.
+var ImportFields = []string{"id", "name", "description", "amount_of_employees", "registered", "type"}
+
+// RequiredImportFields must be mapped to a column.
+var RequiredImportFields = []string{"name", "amount_of_employees", "registered", "type"}
+
+// ErrImportLeaseLost is returned to a worker whose lease on an import job
+// expired and was taken by another worker.
+var ErrImportLeaseLost = errors.New("import job is leased by another worker")
+
+const (
+	// MaxImportReportErrors limits the row errors of a dry run report.
+	MaxImportReportErrors = 1000
+	// DefaultImportErrorLimit and MaxImportErrorLimit apply to pages of the
+	// row errors of a job.
+	DefaultImportErrorLimit = 100
+	MaxImportErrorLimit     = 1000
+)
+
+// ImportJob imports the companies of an uploaded file. Rows are processed in
+// order and ProcessedRows counts those that were imported or rejected.
+type ImportJob struct {
+	ID             uuid.UUID  `json:"id"`
+	OrganizationID uuid.UUID  `json:"organization_id"`
+	CreatedBy      *uuid.UUID `json:"created_by,omitempty"`
+	// CreatedByAPIKey is the API key the file was uploaded with, if any.
+	CreatedByAPIKey *uuid.UUID     `json:"-"`
+	Format          tabular.Format `json:"format"`
+	Columns         []string       `json:"columns"`
+	Mapping         ImportMapping  `json:"mapping"`
+	Status          ImportStatus   `json:"status"`
+	TotalRows       int            `json:"total_rows"`
+	ProcessedRows   int            `json:"processed_rows"`
+	SucceededRows   int            `json:"succeeded_rows"`
+	FailedRows      int            `json:"failed_rows"`
+	// Error is why a failed job stopped.
+	Error      string     `json:"error,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	// Data is the uploaded file. It is only loaded to process the job.
+	Data []byte `json:"-"`
+	// LeaseToken identifies the worker holding the job until LeaseUntil.
+	LeaseToken uuid.UUID  `json:"-"`
+	LeaseUntil *time.Time `json:"-"`
+}
+
+// CompanyID returns the ID of the company imported from the row if the file
+// does not give one. The ID is the same every time the row is processed, so
+// that a job that resumes after a crash finds the companies it created before.
+func (j *ImportJob) CompanyID(line int) uuid.UUID {
+	return uuid.NewSHA1(j.ID, []byte(strconv.Itoa(line)))
+}
+
+// ImportRowError is why a row was not imported. Row is the line or row
+// number of the row in the file and Code an error code of the API.
+type ImportRowError struct {
+	Row     int    `json:"row"`
+	Field   string `json:"field,omitempty"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// ImportReport is the result of checking every row of a file without
+// importing it. Errors holds at most MaxImportReportErrors errors.
+type ImportReport struct {
+	TotalRows   int               `json:"total_rows"`
+	ValidRows   int               `json:"valid_rows"`
+	InvalidRows int               `json:"invalid_rows"`
+	Errors      []*ImportRowError `json:"errors"`
+}
+
+// ImportMapping maps fields of a company to the columns of a file.
+type ImportMapping map[string]string
+
+// DefaultImportMapping maps every field to the column with the same name,
+// ignoring case and treating spaces and hyphens like underscores, e.g. the
+// "Amount of employees" column to amount_of_employees.
+func DefaultImportMapping(columns []string) ImportMapping {
+	mapping := make(ImportMapping)
+	for _, column := range columns {
+		name := strings.NewReplacer(" ", "_", "-", "_").Replace(strings.ToLower(column))
+		for _, field := range ImportFields {
+			if _, ok := mapping[field]; !ok && name == field {
+				mapping[field] = column
+			}
+		}
+	}
+	return mapping
+}
+
+// ImportValueError reports a value that cannot be converted to the type of
+// its field.
+type ImportValueError struct {
+	Field string
+	// Expected describes the values the field accepts, e.g. "a whole number".
+	Expected string
+}
+
+// Company converts a row of a file with the columns to a company. Values are
+// trimmed, and empty values leave their field unset. The company still has to
+// be validated.
+func (m ImportMapping) Company(columns []string, values []string) (*Company, []ImportValueError) {
+	indexes := make(map[string]int, len(columns))
+	for i, column := range columns {
+		indexes[column] = i
+	}
+	value := func(field string) string {
+		i, ok := indexes[m[field]]
+		if !ok || i >= len(values) {
+			return ""
+		}
+		return strings.TrimSpace(values[i])
+	}
+
+	company := &Company{Name: value("name"), Type: CompanyType(value("type"))}
+	var errs []ImportValueError
+	if v := value("id"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			errs = append(errs, ImportValueError{Field: "id", Expected: "a UUID"})
+		}
+		company.ID = id
+	}
+	if v := value("description"); v != "" {
+		company.Description = &v
+	}
+	if v := value("amount_of_employees"); v != "" {
+		amount, ok := parseWholeNumber(v)
+		if !ok {
+			errs = append(errs, ImportValueError{Field: "amount_of_employees", Expected: "a whole number"})
+		}
+		company.AmountOfEmployees = amount
+	}
+	if v := value("registered"); v != "" {
+		registered, ok := parseImportBool(v)
+		if !ok {
+			errs = append(errs, ImportValueError{Field: "registered", Expected: "true or false"})
+		}
+		company.Registered = registered
+	}
+	return company, errs
+}
+
+// parseWholeNumber also accepts numbers such as "12.0", which spreadsheet
+// applications write for whole numbers.
+func parseWholeNumber(s string) (int, bool) {
+	if n, err := strconv.Atoi(s); err == nil {
+		return n, true
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || f != math.Trunc(f) || math.Abs(f) > math.MaxInt32 {
+		return 0, false
+	}
+	return int(f), true
+}
+
+func parseImportBool(s string) (bool, bool) {
+	switch strings.ToLower(s) {
+	case "yes", "y":
+		return true, true
+	case "no", "n":
+		return false, true
+	}
+	b, err := strconv.ParseBool(s)
+	return b, err == nil
+}
@@ -0,0 +1,52 @@
+package entity
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDefaultImportMapping(t *testing.T) {
+	mapping := DefaultImportMapping([]string{"Name", "Amount of employees", "registered", "Company-Type", "Type", "notes"})
+
+	assert.Equal(t, ImportMapping{
+		"name":                "Name",
+		"amount_of_employees": "Amount of employees",
+		"registered":          "registered",
+		"type":                "Type",
+	}, mapping)
+}
+
+func TestImportMappingCompany(t *testing.T) {
+	columns := []string{"ID", "Company", "Employees", "Registered", "Type", "Notes"}
+	mapping := ImportMapping{
+		"id":                  "ID",
+		"name":                "Company",
+		"description":         "Notes",
+		"amount_of_employees": "Employees",
+		"registered":          "Registered",
+		"type":                "Type",
+	}
+	id := uuid.New()
+
+	company, errs := mapping.Company(columns, []string{id.String(), " Acme ", "12.0", "Yes", "NonProfit", ""})
+	assert.Empty(t, errs)
+	assert.Equal(t, &Company{ID: id, Name: "Acme", AmountOfEmployees: 12, Registered: true, Type: "NonProfit"}, company)
+	assert.NoError(t, company.Validate())
+
+	_, errs = mapping.Company(columns, []string{"42", "Acme", "twelve", "maybe", "NonProfit", "notes"})
+	assert.Equal(t, []ImportValueError{
+		{Field: "id", Expected: "a UUID"},
+		{Field: "amount_of_employees", Expected: "a whole number"},
+		{Field: "registered", Expected: "true or false"},
+	}, errs)
+}
+
+func TestImportJobCompanyID(t *testing.T) {
+	job := &ImportJob{ID: uuid.New()}
+
+	assert.Equal(t, job.CompanyID(2), job.CompanyID(2))
+	assert.NotEqual(t, job.CompanyID(2), job.CompanyID(3))
+	assert.NotEqual(t, job.CompanyID(2), (&ImportJob{ID: uuid.New()}).CompanyID(2))
+}
@@ -0,0 +1,164 @@
+package entity
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// LockoutPolicy controls how failed logins slow down and lock out further
+// attempts. Accounts and client IPs are tracked separately.
+type LockoutPolicy struct {
+	// FreeAttempts is the number of failures allowed before delays apply.
+	FreeAttempts int
+	// BaseDelay is the delay after the first failure beyond FreeAttempts;
+	// it doubles with every further failure up to MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// MaxAccountFailures and MaxIPFailures lock the account or IP for
+	// LockoutDuration once reached. Zero disables the lockout.
+	MaxAccountFailures int
+	MaxIPFailures      int
+	LockoutDuration    time.Duration
+	// Window is how long failures are remembered after the last one.
+	Window time.Duration
+}
+
+func DefaultLockoutPolicy() LockoutPolicy {
+	return LockoutPolicy{
+		FreeAttempts:       3,
+		BaseDelay:          time.Second,
+		MaxDelay:           30 * time.Second,
+		MaxAccountFailures: 10,
+		MaxIPFailures:      50,
+		LockoutDuration:    15 * time.Minute,
+		Window:             15 * time.Minute,
+	}
+}
+
+// Delay returns how long to wait after the given number of consecutive failures.
+func (p LockoutPolicy) Delay(failures int) time.Duration {
+	excess := failures - p.FreeAttempts
+	if excess <= 0 || p.BaseDelay <= 0 {
+		return 0
+	}
+	// Cap the exponent to avoid overflowing time.Duration.
+	if excess > 32 {
+		excess = 32
+	}
+	delay := time.Duration(float64(p.BaseDelay) * math.Pow(2, float64(excess-1)))
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		return p.MaxDelay
+	}
+	return delay
+}
+
+// DefaultPasswordResetPolicy limits password reset requests per email
+// address and per client IP. Requests are never failures, so every request
+// counts and none lock out.
+func DefaultPasswordResetPolicy() LockoutPolicy {
+	return LockoutPolicy{
+		FreeAttempts: 5,
+		BaseDelay:    time.Minute,
+		MaxDelay:     time.Hour,
+		Window:       time.Hour,
+	}
+}
+
+const (
+	LoginThrottleAccount = "account"
+	LoginThrottleIP      = "ip"
+
+	PasswordResetThrottleEmail = "reset_email"
+	PasswordResetThrottleIP    = "reset_ip"
+)
+
+// LoginThrottle tracks the recent failed logins of one account or client IP.
+type LoginThrottle struct {
+	Kind          string
+	Key           string
+	Failures      int
+	LastFailureAt time.Time
+	LockedUntil   *time.Time
+	// PreviousFailureAt is the last failure before the one counted by
+	// AddFailure, so that the failure can be taken back.
+	PreviousFailureAt time.Time
+}
+
+// AccountThrottleKey normalises a username so that case variants share one
+// counter.
+func AccountThrottleKey(username string) string {
+	return strings.ToLower(strings.TrimSpace(username))
+}
+
+// MaxFailures returns the lockout threshold that applies to this throttle.
+func (t *LoginThrottle) MaxFailures(policy LockoutPolicy) int {
+	switch t.Kind {
+	case LoginThrottleIP, PasswordResetThrottleIP:
+		return policy.MaxIPFailures
+	default:
+		return policy.MaxAccountFailures
+	}
+}
+
+// RetryAfter returns how long the next attempt has to wait, or zero if it
+// may proceed now.
+func (t *LoginThrottle) RetryAfter(now time.Time, policy LockoutPolicy) time.Duration {
+	if t.LockedUntil != nil && now.Before(*t.LockedUntil) {
+		return t.LockedUntil.Sub(now)
+	}
+	if policy.Window > 0 && now.Sub(t.LastFailureAt) >= policy.Window {
+		return 0
+	}
+	next := t.LastFailureAt.Add(policy.Delay(t.Failures))
+	if now.Before(next) {
+		return next.Sub(now)
+	}
+	return 0
+}
+
+// AddFailure counts a failure at now. Failures outside the window are
+// forgotten first.
+func (t *LoginThrottle) AddFailure(now time.Time, policy LockoutPolicy) {
+	t.PreviousFailureAt = t.LastFailureAt
+	if policy.Window > 0 && now.Sub(t.LastFailureAt) >= policy.Window {
+		t.Failures = 0
+	}
+	t.Failures++
+	t.LastFailureAt = now
+}
+
+// ShouldLock reports whether the throttle just reached its lockout threshold.
+func (t *LoginThrottle) ShouldLock(policy LockoutPolicy) bool {
+	max := t.MaxFailures(policy)
+	return max > 0 && t.Failures >= max && policy.LockoutDuration > 0
+}
+
+// LoginThrottledError is returned while an account or IP has to wait before
+// the next login attempt.
+type LoginThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LoginThrottledError) Error() string {
+	return fmt.Sprintf("%s, retry after %s", ErrTooManyLoginAttempts, e.RetryAfter.Round(time.Second))
+}
+
+func (e *LoginThrottledError) Is(target error) bool {
+	return target == ErrTooManyLoginAttempts
+}
+
+// ResetThrottledError is returned while an email address or client IP has to
+// wait before requesting another password reset.
+type ResetThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *ResetThrottledError) Error() string {
+	return fmt.Sprintf("%s, retry after %s", ErrTooManyResetRequests, e.RetryAfter.Round(time.Second))
+}
+
+func (e *ResetThrottledError) Is(target error) bool {
+	return target == ErrTooManyResetRequests
+}
@@ -0,0 +1,80 @@
+package entity
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLockoutPolicyDelay(t *testing.T) {
+	policy := LockoutPolicy{FreeAttempts: 2, BaseDelay: time.Second, MaxDelay: 10 * time.Second}
+
+	assert.Equal(t, time.Duration(0), policy.Delay(0))
+	assert.Equal(t, time.Duration(0), policy.Delay(2))
+	assert.Equal(t, time.Second, policy.Delay(3))
+	assert.Equal(t, 2*time.Second, policy.Delay(4))
+	assert.Equal(t, 8*time.Second, policy.Delay(6))
+	assert.Equal(t, 10*time.Second, policy.Delay(7))
+	assert.Equal(t, 10*time.Second, policy.Delay(1000))
+}
+
+func TestLoginThrottleRetryAfter(t *testing.T) {
+	policy := LockoutPolicy{FreeAttempts: 1, BaseDelay: time.Second, MaxDelay: time.Minute, Window: 15 * time.Minute}
+	now := time.Now()
+
+	throttle := &LoginThrottle{Kind: LoginThrottleAccount, Failures: 1, LastFailureAt: now}
+	assert.Equal(t, time.Duration(0), throttle.RetryAfter(now, policy), "free attempts are not delayed")
+
+	throttle.Failures = 3
+	assert.Equal(t, 2*time.Second, throttle.RetryAfter(now, policy))
+	assert.Equal(t, time.Second, throttle.RetryAfter(now.Add(time.Second), policy))
+	assert.Equal(t, time.Duration(0), throttle.RetryAfter(now.Add(2*time.Second), policy))
+
+	lockedUntil := now.Add(10 * time.Minute)
+	throttle.LockedUntil = &lockedUntil
+	assert.Equal(t, 5*time.Minute, throttle.RetryAfter(now.Add(5*time.Minute), policy))
+	assert.Equal(t, time.Duration(0), throttle.RetryAfter(lockedUntil, policy))
+}
+
+func TestLoginThrottleForgetsFailuresOutsideWindow(t *testing.T) {
+	policy := LockoutPolicy{FreeAttempts: 0, BaseDelay: time.Hour, Window: time.Minute}
+	now := time.Now()
+
+	throttle := &LoginThrottle{Kind: LoginThrottleIP, Failures: 5, LastFailureAt: now.Add(-2 * time.Minute)}
+	assert.Equal(t, time.Duration(0), throttle.RetryAfter(now, policy))
+}
+
+func TestLoginThrottleAddFailure(t *testing.T) {
+	policy := LockoutPolicy{Window: time.Minute}
+	now := time.Now()
+
+	throttle := &LoginThrottle{Kind: LoginThrottleAccount, Failures: 2, LastFailureAt: now.Add(-time.Second)}
+	throttle.AddFailure(now, policy)
+	assert.Equal(t, 3, throttle.Failures)
+	assert.Equal(t, now, throttle.LastFailureAt)
+	assert.Equal(t, now.Add(-time.Second), throttle.PreviousFailureAt)
+
+	throttle.AddFailure(now.Add(2*time.Minute), policy)
+	assert.Equal(t, 1, throttle.Failures, "failures outside the window are forgotten")
+}
+
+func TestLoginThrottleShouldLock(t *testing.T) {
+	policy := LockoutPolicy{MaxAccountFailures: 3, MaxIPFailures: 10, LockoutDuration: time.Minute}
+
+	account := &LoginThrottle{Kind: LoginThrottleAccount, Failures: 3}
+	assert.True(t, account.ShouldLock(policy))
+
+	ip := &LoginThrottle{Kind: LoginThrottleIP, Failures: 3}
+	assert.False(t, ip.ShouldLock(policy))
+
+	policy.LockoutDuration = 0
+	assert.False(t, account.ShouldLock(policy), "a zero lockout duration disables locking")
+}
+
+func TestLoginThrottledErrorIsTooManyLoginAttempts(t *testing.T) {
+	var err error = &LoginThrottledError{RetryAfter: 3 * time.Second}
+	assert.True(t, errors.Is(err, ErrTooManyLoginAttempts))
+	assert.Contains(t, err.Error(), "3s")
+}
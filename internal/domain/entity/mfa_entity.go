@@ -0,0 +1,120 @@
+package entity
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/assylzhan-a/company-task/pkg/totp"
+	"github.com/google/uuid"
+)
+
+// RecoveryCodeCount is the number of recovery codes issued at a time.
+const RecoveryCodeCount = 10
+
+// totpSkew accepts codes from one period before or after the current one.
+const totpSkew = 1
+
+// UserMFA is a user's TOTP enrollment. It only protects logins once Enabled,
+// which happens when the user confirms it with a first code.
+type UserMFA struct {
+	UserID       uuid.UUID
+	Secret       string
+	Enabled      bool
+	LastUsedStep int64
+	CreatedAt    time.Time
+	ConfirmedAt  *time.Time
+}
+
+// MFAEnrollment is returned when a user starts enrolling an authenticator.
+type MFAEnrollment struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+}
+
+// MFAChallenge is returned by a login that still needs a second factor.
+type MFAChallenge struct {
+	MFARequired bool      `json:"mfa_required"`
+	MFAToken    string    `json:"mfa_token"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// LoginResult holds either a token pair or, for users with MFA enabled, the
+// challenge to complete first.
+type LoginResult struct {
+	Tokens    *TokenPair
+	Challenge *MFAChallenge
+}
+
+type RecoveryCode struct {
+	ID       uuid.UUID
+	UserID   uuid.UUID
+	CodeHash string
+	UsedAt   *time.Time
+}
+
+func NewUserMFA(userID uuid.UUID) (*UserMFA, error) {
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	return &UserMFA{
+		UserID:    userID,
+		Secret:    secret,
+		CreatedAt: time.Now(),
+	}, nil
+}
+
+// Enrollment returns the secret in the form authenticator apps import.
+func (m *UserMFA) Enrollment(issuer, username string) *MFAEnrollment {
+	return &MFAEnrollment{
+		Secret:     m.Secret,
+		OTPAuthURI: totp.URI(issuer, username, m.Secret),
+	}
+}
+
+// VerifyCode checks a TOTP code and returns its time step. Codes of a step
+// that was already used are rejected so that an observed code cannot be
+// replayed.
+func (m *UserMFA) VerifyCode(code string, now time.Time) (int64, bool) {
+	step, ok := totp.Validate(m.Secret, code, now, totpSkew)
+	if !ok || step <= m.LastUsedStep {
+		return 0, false
+	}
+	return step, true
+}
+
+// NewRecoveryCodes generates a set of single-use recovery codes and returns
+// them together with their raw values, which are shown to the user once.
+func NewRecoveryCodes(userID uuid.UUID) ([]*RecoveryCode, []string, error) {
+	codes := make([]*RecoveryCode, 0, RecoveryCodeCount)
+	raws := make([]string, 0, RecoveryCodeCount)
+	for i := 0; i < RecoveryCodeCount; i++ {
+		buf := make([]byte, 5)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, nil, err
+		}
+		encoded := strings.ToLower(base32.StdEncoding.EncodeToString(buf))
+		raw := encoded[:4] + "-" + encoded[4:]
+
+		codes = append(codes, &RecoveryCode{
+			ID:       uuid.New(),
+			UserID:   userID,
+			CodeHash: HashRecoveryCode(raw),
+		})
+		raws = append(raws, raw)
+	}
+	return codes, raws, nil
+}
+
+// HashRecoveryCode hashes a recovery code, ignoring case, surrounding spaces
+// and the dash.
+func HashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,47 @@
+package entity
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/assylzhan-a/company-task/pkg/totp"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserMFAVerifyCodeRejectsReplays(t *testing.T) {
+	mfa, err := NewUserMFA(uuid.New())
+	require.NoError(t, err)
+
+	now := time.Now()
+	code, err := totp.Code(mfa.Secret, totp.Step(now))
+	require.NoError(t, err)
+
+	step, ok := mfa.VerifyCode(code, now)
+	require.True(t, ok)
+	assert.Equal(t, totp.Step(now), step)
+
+	mfa.LastUsedStep = step
+	_, ok = mfa.VerifyCode(code, now)
+	assert.False(t, ok)
+}
+
+func TestNewRecoveryCodes(t *testing.T) {
+	userID := uuid.New()
+	codes, raws, err := NewRecoveryCodes(userID)
+	require.NoError(t, err)
+	require.Len(t, codes, RecoveryCodeCount)
+	require.Len(t, raws, RecoveryCodeCount)
+
+	seen := make(map[string]bool)
+	for i, raw := range raws {
+		assert.Len(t, raw, 9)
+		assert.False(t, seen[raw])
+		seen[raw] = true
+		assert.Equal(t, userID, codes[i].UserID)
+		assert.Equal(t, codes[i].CodeHash, HashRecoveryCode(" "+strings.ToUpper(raw)+" "))
+		assert.Equal(t, codes[i].CodeHash, HashRecoveryCode(strings.ReplaceAll(raw, "-", "")))
+	}
+}
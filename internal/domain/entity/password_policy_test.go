@@ -0,0 +1,73 @@
+package entity
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPasswordPolicyValidate(t *testing.T) {
+	strict := PasswordPolicy{
+		MinLength:     10,
+		RequireUpper:  true,
+		RequireLower:  true,
+		RequireDigit:  true,
+		RequireSymbol: true,
+	}
+
+	tests := []struct {
+		name       string
+		policy     PasswordPolicy
+		password   string
+		violations []string
+	}{
+		{"default accepts passphrase", DefaultPasswordPolicy(), "correct horse battery", nil},
+		{"default rejects short", DefaultPasswordPolicy(), "a1b2c3", []string{"must be at least 8 characters long"}},
+		{"strict accepts all classes", strict, "Tr0ub4dor&3x", nil},
+		{"strict reports every violation", strict, "abcdef", []string{
+			"must be at least 10 characters long",
+			"must contain an uppercase letter",
+			"must contain a digit",
+			"must contain a symbol",
+		}},
+		{"length counts runes", PasswordPolicy{MinLength: 4}, "äöüß", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.Validate(tt.password)
+			if tt.violations == nil {
+				assert.NoError(t, err)
+				return
+			}
+
+			require.True(t, errors.Is(err, ErrWeakPassword))
+			var policyErr *PasswordPolicyError
+			require.True(t, errors.As(err, &policyErr))
+			assert.Equal(t, tt.violations, policyErr.Violations)
+			assert.Len(t, policyErr.Rules, len(tt.violations))
+		})
+	}
+}
+
+func TestBreachedPasswordError(t *testing.T) {
+	err := DefaultPasswordPolicy().BreachedPasswordError()
+	require.True(t, errors.Is(err, ErrWeakPassword))
+	var policyErr *PasswordPolicyError
+	require.True(t, errors.As(err, &policyErr))
+	assert.Equal(t, []string{PasswordRuleBreached}, policyErr.Rules)
+}
+
+func TestNewUserAppliesPasswordPolicy(t *testing.T) {
+	_, err := NewUser("alice", "short", DefaultPasswordPolicy())
+	assert.True(t, errors.Is(err, ErrWeakPassword))
+
+	_, err = NewUser("alice", " ", DefaultPasswordPolicy())
+	assert.Equal(t, ErrEmptyPassword, err)
+
+	user, err := NewUser("alice", "correct horse battery", DefaultPasswordPolicy())
+	require.NoError(t, err)
+	assert.NoError(t, user.ComparePassword("correct horse battery"))
+}
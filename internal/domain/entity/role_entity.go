@@ -0,0 +1,26 @@
+package entity
+
+const (
+	RoleAdmin  = "admin"
+	RoleEditor = "editor"
+	RoleViewer = "viewer"
+)
+
+const (
+	PermissionCompaniesRead   = "companies:read"
+	PermissionCompaniesCreate = "companies:create"
+	PermissionCompaniesUpdate = "companies:update"
+	PermissionCompaniesDelete = "companies:delete"
+	PermissionRolesManage     = "roles:manage"
+
+	PermissionOrganizationsManage   = "organizations:manage"
+	PermissionServiceAccountsManage = "service_accounts:manage"
+	PermissionUsersManage           = "users:manage"
+	PermissionAuditRead             = "audit:read"
+)
+
+type Role struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
@@ -0,0 +1,80 @@
+package entity
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newChain(n int) []*SecurityEvent {
+	userID := uuid.New()
+	var events []*SecurityEvent
+	var prev *SecurityEvent
+	for i := 0; i < n; i++ {
+		event := NewSecurityEvent(SecurityEventLoginSucceeded, "alice", "192.0.2.1", &userID, "")
+		event.Chain(prev)
+		events = append(events, event)
+		prev = event
+	}
+	return events
+}
+
+func verify(events []*SecurityEvent) *ChainVerification {
+	var verifier ChainVerifier
+	for _, event := range events {
+		verifier.Add(event)
+	}
+	return verifier.Result()
+}
+
+func TestSecurityEventChain(t *testing.T) {
+	events := newChain(3)
+	assert.Equal(t, int64(1), *events[0].Sequence)
+	assert.Empty(t, events[0].PrevHash)
+	assert.Equal(t, events[1].Hash, events[2].PrevHash)
+
+	result := verify(events)
+	assert.True(t, result.Valid)
+	assert.Equal(t, int64(3), result.Events)
+}
+
+func TestSecurityEventHashIgnoresTimeZone(t *testing.T) {
+	event := newChain(1)[0]
+	stored := *event
+	stored.CreatedAt = event.CreatedAt.In(time.FixedZone("UTC+5", 5*60*60))
+	assert.Equal(t, event.Hash, stored.ComputeHash())
+}
+
+func TestChainVerifierDetectsTampering(t *testing.T) {
+	tests := map[string]struct {
+		tamper   func(events []*SecurityEvent) []*SecurityEvent
+		brokenAt int64
+	}{
+		"changed": {func(events []*SecurityEvent) []*SecurityEvent {
+			events[1].Reason = "tampered"
+			return events
+		}, 2},
+		"removed": {func(events []*SecurityEvent) []*SecurityEvent {
+			return append(events[:1], events[2:]...)
+		}, 2},
+		"rehashed": {func(events []*SecurityEvent) []*SecurityEvent {
+			// Recomputing the changed event's hash still breaks the link
+			// from the next event.
+			events[1].Username = "mallory"
+			events[1].Hash = events[1].ComputeHash()
+			return events
+		}, 3},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			result := verify(tt.tamper(newChain(3)))
+			assert.False(t, result.Valid)
+			require.NotNil(t, result.BrokenAt)
+			assert.Equal(t, tt.brokenAt, *result.BrokenAt)
+		})
+	}
+}
@@ -0,0 +1,17 @@
+package entity
+
+import "time"
+
+// SigningKey is a key used to sign access tokens. A key is published in the
+// JWKS from creation, used for signing between NotBefore and RetiresAt, and
+// accepted for verification until ExpiresAt. PrivateKey is sealed with the
+// key encryption key.
+type SigningKey struct {
+	ID         string    `json:"kid"`
+	Algorithm  string    `json:"alg"`
+	PrivateKey []byte    `json:"-"`
+	NotBefore  time.Time `json:"not_before"`
+	RetiresAt  time.Time `json:"retires_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	CreatedAt  time.Time `json:"created_at"`
+}
@@ -0,0 +1,38 @@
+package entity
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRoleMapping(t *testing.T) {
+	mapping, err := ParseRoleMapping(" engineering=editor, it=admin,it=editor,")
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"editor"}, mapping.Roles([]string{"engineering", "sales"}))
+	assert.Equal(t, []string{"admin", "editor"}, mapping.Roles([]string{"it", "engineering"}))
+	assert.Empty(t, mapping.Roles(nil))
+	assert.Equal(t, []string{"admin", "editor"}, mapping.ManagedRoles())
+
+	empty, err := ParseRoleMapping("")
+	require.NoError(t, err)
+	assert.Empty(t, empty)
+
+	_, err = ParseRoleMapping("engineering")
+	assert.Error(t, err)
+	_, err = ParseRoleMapping("=admin")
+	assert.Error(t, err)
+}
+
+func TestNewSSOLoginStateIsRandom(t *testing.T) {
+	first, err := NewSSOLoginState(0)
+	require.NoError(t, err)
+	second, err := NewSSOLoginState(0)
+	require.NoError(t, err)
+
+	assert.NotEqual(t, first.State, second.State)
+	assert.NotEqual(t, first.Nonce, first.State)
+	assert.Len(t, first.CodeVerifier, 64)
+}
@@ -0,0 +1,115 @@
+package entity
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RefreshToken is a long-lived, single-use credential exchanged for a new
+// access token. All tokens produced by rotating the same login share a
+// FamilyID, which is also the session ID carried by access tokens.
+type RefreshToken struct {
+	ID             uuid.UUID  `json:"id"`
+	UserID         uuid.UUID  `json:"user_id"`
+	FamilyID       uuid.UUID  `json:"family_id"`
+	OrganizationID *uuid.UUID `json:"organization_id,omitempty"`
+	TokenHash      string     `json:"-"`
+	ExpiresAt      time.Time  `json:"expires_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+	RevokedAt      *time.Time `json:"revoked_at,omitempty"`
+	ReplacedBy     *uuid.UUID `json:"replaced_by,omitempty"`
+}
+
+// TokenPair is returned to clients on login and refresh.
+type TokenPair struct {
+	AccessToken  string    `json:"token"`
+	RefreshToken string    `json:"refresh_token"`
+	TokenType    string    `json:"token_type"`
+	ExpiresIn    int64     `json:"expires_in"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+// NewRefreshToken generates a random refresh token and returns the entity,
+// which only stores its hash, together with the raw value for the client.
+// The organization is the active tenant of the session and survives rotation.
+func NewRefreshToken(userID, familyID uuid.UUID, organizationID *uuid.UUID, ttl time.Duration) (*RefreshToken, string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, "", err
+	}
+	raw := base64.RawURLEncoding.EncodeToString(buf)
+	now := time.Now()
+
+	return &RefreshToken{
+		ID:             uuid.New(),
+		UserID:         userID,
+		FamilyID:       familyID,
+		OrganizationID: organizationID,
+		TokenHash:      HashRefreshToken(raw),
+		ExpiresAt:      now.Add(ttl),
+		CreatedAt:      now,
+	}, raw, nil
+}
+
+func HashRefreshToken(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+func (t *RefreshToken) IsRevoked() bool {
+	return t.RevokedAt != nil
+}
+
+func (t *RefreshToken) IsExpired(now time.Time) bool {
+	return !now.Before(t.ExpiresAt)
+}
+
+const (
+	TokenPurposePasswordReset     = "password_reset"
+	TokenPurposeEmailVerification = "email_verification"
+)
+
+// OneTimeToken is a single-use, expiring token sent to a user by email, for
+// example to reset their password. Only its hash is stored.
+type OneTimeToken struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	Purpose   string
+	TokenHash string
+	Email     string
+	ExpiresAt time.Time
+	CreatedAt time.Time
+	UsedAt    *time.Time
+}
+
+// NewOneTimeToken generates a token for the purpose and returns the entity
+// together with the raw value to send. Email is the address the token was
+// sent to, so that a verification only applies to that address.
+func NewOneTimeToken(userID uuid.UUID, purpose, email string, ttl time.Duration) (*OneTimeToken, string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, "", err
+	}
+	raw := base64.RawURLEncoding.EncodeToString(buf)
+	now := time.Now()
+
+	return &OneTimeToken{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: HashOneTimeToken(raw),
+		Email:     email,
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	}, raw, nil
+}
+
+func HashOneTimeToken(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
@@ -3,30 +3,106 @@ package entity
 import (
 	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
+	"net/mail"
 	"strings"
+	"time"
 )
 
 type User struct {
 	ID       uuid.UUID `json:"id"`
 	Username string    `json:"username"`
 	Password string    `json:"-"`
+	// Email is optional; EmailVerified reports whether the user proved they
+	// own it.
+	Email         string   `json:"email,omitempty"`
+	EmailVerified bool     `json:"email_verified"`
+	Roles         []string `json:"roles,omitempty"`
+	Permissions   []string `json:"permissions,omitempty"`
+	// OrganizationIDs are the organizations the user joins on creation.
+	OrganizationIDs []uuid.UUID `json:"organization_ids,omitempty"`
+	// Identities are the external identities linked to the user on creation.
+	Identities []UserIdentity `json:"-"`
+	CreatedAt  time.Time      `json:"created_at"`
+	// DisabledAt is set while an administrator has disabled the user.
+	DisabledAt *time.Time `json:"disabled_at,omitempty"`
 }
 
-func NewUser(username, password string) (*User, error) {
+// UserFilter selects users to list. Zero values do not filter.
+type UserFilter struct {
+	// Query matches a part of the username or email.
+	Query    string
+	Disabled *bool
+	Limit    int
+	Offset   int
+}
+
+const (
+	DefaultUserListLimit = 50
+	MaxUserListLimit     = 200
+)
+
+// Normalize applies the default limit and caps it.
+func (f *UserFilter) Normalize() {
+	if f.Limit <= 0 {
+		f.Limit = DefaultUserListLimit
+	}
+	if f.Limit > MaxUserListLimit {
+		f.Limit = MaxUserListLimit
+	}
+	if f.Offset < 0 {
+		f.Offset = 0
+	}
+}
+
+// NewUser validates the credentials against the password policy and hashes
+// the password.
+func NewUser(username, password string, policy PasswordPolicy) (*User, error) {
 	if err := validateCredentials(username, password); err != nil {
 		return nil, err
 	}
 
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	if err != nil {
+	user := &User{
+		ID:        uuid.New(),
+		Username:  username,
+		CreatedAt: time.Now(),
+	}
+	if err := user.SetPassword(password, policy); err != nil {
 		return nil, err
 	}
+	return user, nil
+}
+
+// SetEmail validates and normalises the address. A changed address has to be
+// verified again.
+func (u *User) SetEmail(email string) error {
+	address, err := mail.ParseAddress(strings.TrimSpace(email))
+	if err != nil || address.Name != "" {
+		return ErrInvalidEmail
+	}
 
-	return &User{
-		ID:       uuid.New(),
-		Username: username,
-		Password: string(hashedPassword),
-	}, nil
+	normalized := strings.ToLower(address.Address)
+	if normalized != u.Email {
+		u.Email = normalized
+		u.EmailVerified = false
+	}
+	return nil
+}
+
+// SetPassword validates the password against the policy and stores its hash.
+func (u *User) SetPassword(password string, policy PasswordPolicy) error {
+	if strings.TrimSpace(password) == "" {
+		return ErrEmptyPassword
+	}
+	if err := policy.Validate(password); err != nil {
+		return err
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	u.Password = string(hashedPassword)
+	return nil
 }
 
 func (u *User) ComparePassword(password string) error {
@@ -47,3 +123,13 @@ func validateCredentials(username, password string) error {
 
 	return nil
 }
+
+func (u *User) IsDisabled() bool {
+	return u.DisabledAt != nil
+}
+
+// UserProfile is the user together with the current access and memberships.
+type UserProfile struct {
+	*User
+	Organizations []*Organization `json:"organizations"`
+}
@@ -0,0 +1,56 @@
+package entity
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/assylzhan-a/company-task/pkg/i18n"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	entranslations "github.com/go-playground/validator/v10/translations/en"
+	rutranslations "github.com/go-playground/validator/v10/translations/ru"
+)
+
+// defaultTranslations register the messages of the built-in validation rules
+// for a locale.
+var defaultTranslations = map[string]func(*validator.Validate, ut.Translator) error{
+	"en": entranslations.RegisterDefaultTranslations,
+	"ru": rutranslations.RegisterDefaultTranslations,
+}
+
+// companyTypeMessages are the messages of the companyType rule.
+var companyTypeMessages = map[string]string{
+	"en": "{0} must be one of {1}",
+	"ru": "{0} должен быть одним из: {1}",
+}
+
+// registerTranslations registers the messages of the validation rules for
+// every supported locale, so that validation errors can be reported in the
+// language of the client.
+func registerTranslations(v *validator.Validate) error {
+	for _, trans := range i18n.Translators() {
+		register, ok := defaultTranslations[trans.Locale()]
+		if !ok {
+			return fmt.Errorf("no validation messages for locale %s", trans.Locale())
+		}
+		if err := register(v, trans); err != nil {
+			return err
+		}
+
+		message := companyTypeMessages[trans.Locale()]
+		err := v.RegisterTranslation("companyType", trans, func(trans ut.Translator) error {
+			return trans.Add("companyType", message, false)
+		}, func(trans ut.Translator, fe validator.FieldError) string {
+			types := make([]string, len(ValidCompanyTypes))
+			for i, t := range ValidCompanyTypes {
+				types[i] = string(t)
+			}
+			translated, _ := trans.T("companyType", fe.Field(), strings.Join(types, ", "))
+			return translated
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
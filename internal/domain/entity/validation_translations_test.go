@@ -0,0 +1,36 @@
+package entity
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/assylzhan-a/company-task/pkg/i18n"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidationMessagesAreTranslated(t *testing.T) {
+	company := &Company{
+		ID:                uuid.New(),
+		Name:              "A name that is far too long",
+		AmountOfEmployees: 5,
+		Registered:        true,
+		Type:              "Partnership",
+	}
+	var validationErrors validator.ValidationErrors
+	require.True(t, errors.As(company.Validate(), &validationErrors))
+	require.Len(t, validationErrors, 2)
+
+	en := i18n.Translator("en")
+	assert.Equal(t, "name", validationErrors[0].Field())
+	assert.Equal(t, "name must be a maximum of 15 characters in length", validationErrors[0].Translate(en))
+	assert.Equal(t, "type must be one of Corporations, NonProfit, Cooperative, Sole Proprietorship",
+		validationErrors[1].Translate(en))
+
+	ru := i18n.Translator("ru")
+	assert.Equal(t, "name должен содержать максимум 15 символов", validationErrors[0].Translate(ru))
+	assert.Equal(t, "type должен быть одним из: Corporations, NonProfit, Cooperative, Sole Proprietorship",
+		validationErrors[1].Translate(ru))
+}
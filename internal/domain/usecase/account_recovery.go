@@ -0,0 +1,177 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/assylzhan-a/company-task/internal/domain/entity"
+	"github.com/assylzhan-a/company-task/internal/mailer"
+	"github.com/google/uuid"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// SendVerificationEmail sends a new verification link to the user's email,
+// invalidating earlier ones.
+func (u *userUseCase) SendVerificationEmail(ctx context.Context, userID uuid.UUID) error {
+	user, err := u.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user.Email == "" {
+		return entity.ErrEmailRequired
+	}
+	if user.EmailVerified {
+		return entity.ErrEmailAlreadyVerified
+	}
+	return u.sendVerificationEmail(ctx, user)
+}
+
+// VerifyEmail consumes a verification token. It only verifies the address
+// the token was sent to.
+func (u *userUseCase) VerifyEmail(ctx context.Context, token string) error {
+	verification, err := u.tokenRepo.ConsumeOneTimeToken(ctx, entity.TokenPurposeEmailVerification,
+		entity.HashOneTimeToken(token), time.Now())
+	if err != nil {
+		return err
+	}
+	return u.userRepo.MarkEmailVerified(ctx, verification.UserID, verification.Email)
+}
+
+// RequestPasswordReset mails a reset link if a user with the verified email
+// exists. It reports success either way so that it cannot be used to find
+// out which addresses are registered; the mail is sent in the background by
+// the mailer. Requests are limited per email address and client IP whether or
+// not the address is registered.
+func (u *userUseCase) RequestPasswordReset(ctx context.Context, email, clientIP string) error {
+	if u.cfg.LocalLoginDisabled {
+		return entity.ErrLocalLoginDisabled
+	}
+
+	email = strings.TrimSpace(email)
+	keys := map[string]string{entity.PasswordResetThrottleEmail: strings.ToLower(email)}
+	if clientIP != "" {
+		keys[entity.PasswordResetThrottleIP] = clientIP
+	}
+	_, retryAfter, err := u.acquire(ctx, keys, u.cfg.PasswordResetThrottle, time.Now())
+	if err != nil {
+		return err
+	}
+	if retryAfter > 0 {
+		return &entity.ResetThrottledError{RetryAfter: retryAfter}
+	}
+
+	user, err := u.userRepo.GetByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, entity.ErrUserNotFound) {
+			return nil
+		}
+		return err
+	}
+	// Unverified addresses may belong to someone else. Users without a
+	// password log in through single sign-on and must not get one this way.
+	if !user.EmailVerified || user.Password == "" {
+		return nil
+	}
+
+	reset, raw, err := entity.NewOneTimeToken(user.ID, entity.TokenPurposePasswordReset, user.Email, u.cfg.PasswordResetTTL)
+	if err != nil {
+		return fmt.Errorf("failed to generate reset token: %w", err)
+	}
+	if err := u.tokenRepo.CreateOneTimeToken(ctx, reset); err != nil {
+		return err
+	}
+	if err := u.recordEvent(ctx, entity.SecurityEventPasswordResetRequested, user.Username, clientIP, &user.ID, ""); err != nil {
+		return err
+	}
+
+	// The mailer logs failures. Returning them would tell the caller that the
+	// address is registered.
+	_ = u.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hello %s,\n\nUse the link below to choose a new password. It expires in %s.\n\n%s\n\n"+
+			"If you did not ask to reset your password, you can ignore this email.\n",
+			user.Username, u.cfg.PasswordResetTTL, u.link("/reset-password", raw)),
+	})
+	return nil
+}
+
+// ResetPassword sets a new password with a reset token and ends all sessions
+// of the user.
+func (u *userUseCase) ResetPassword(ctx context.Context, token, password, clientIP string) error {
+	if u.cfg.LocalLoginDisabled {
+		return entity.ErrLocalLoginDisabled
+	}
+
+	// Check the password first so that a rejected one does not use up the token.
+	if strings.TrimSpace(password) == "" {
+		return entity.ErrEmptyPassword
+	}
+	if err := u.cfg.PasswordPolicy.Validate(password); err != nil {
+		return err
+	}
+	if err := u.checkBreached(ctx, password); err != nil {
+		return err
+	}
+
+	reset, err := u.tokenRepo.ConsumeOneTimeToken(ctx, entity.TokenPurposePasswordReset,
+		entity.HashOneTimeToken(token), time.Now())
+	if err != nil {
+		return err
+	}
+
+	user, err := u.userRepo.GetByID(ctx, reset.UserID)
+	if err != nil {
+		return err
+	}
+	if err := user.SetPassword(password, u.cfg.PasswordPolicy); err != nil {
+		return err
+	}
+	if err := u.userRepo.UpdatePassword(ctx, user.ID, user.Password); err != nil {
+		return err
+	}
+
+	if err := u.LogoutAll(ctx, user.ID); err != nil {
+		return err
+	}
+	if err := u.throttleRepo.Reset(ctx, entity.LoginThrottleAccount, entity.AccountThrottleKey(user.Username)); err != nil {
+		return err
+	}
+	return u.recordEvent(ctx, entity.SecurityEventPasswordReset, user.Username, clientIP, &user.ID, "")
+}
+
+// sendEmailTakenNotice tells the owner of an address that someone tried to
+// register it again.
+func (u *userUseCase) sendEmailTakenNotice(ctx context.Context, user *entity.User) error {
+	return u.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Your email address is already registered",
+		Body: fmt.Sprintf("Hello,\n\nSomeone tried to register the username %s with this email address, which already belongs to an account. "+
+			"If it was you, log in to your account at %s instead, or request a password reset there.\n\n"+
+			"If it was not you, you can ignore this email.\n",
+			user.Username, u.cfg.BaseURL),
+	})
+}
+
+func (u *userUseCase) sendVerificationEmail(ctx context.Context, user *entity.User) error {
+	verification, raw, err := entity.NewOneTimeToken(user.ID, entity.TokenPurposeEmailVerification, user.Email, u.cfg.EmailVerificationTTL)
+	if err != nil {
+		return fmt.Errorf("failed to generate verification token: %w", err)
+	}
+	if err := u.tokenRepo.CreateOneTimeToken(ctx, verification); err != nil {
+		return err
+	}
+
+	return u.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Hello %s,\n\nUse the link below to verify your email address. It expires in %s.\n\n%s\n",
+			user.Username, u.cfg.EmailVerificationTTL, u.link("/verify-email", raw)),
+	})
+}
+
+func (u *userUseCase) link(path, token string) string {
+	return strings.TrimRight(u.cfg.BaseURL, "/") + path + "?token=" + url.QueryEscape(token)
+}
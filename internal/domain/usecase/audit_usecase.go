@@ -0,0 +1,46 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"github.com/assylzhan-a/company-task/internal/domain/entity"
+	r "github.com/assylzhan-a/company-task/internal/ports/repository"
+	uc "github.com/assylzhan-a/company-task/internal/ports/usecase"
+)
+
+// errChainBroken stops streaming once verification has failed.
+var errChainBroken = errors.New("chain broken")
+
+type auditUseCase struct {
+	eventRepo r.SecurityEventRepository
+}
+
+func NewAuditUseCase(eventRepo r.SecurityEventRepository) uc.AuditUseCase {
+	return &auditUseCase{eventRepo: eventRepo}
+}
+
+func (u *auditUseCase) ListEvents(ctx context.Context, filter entity.SecurityEventFilter) ([]*entity.SecurityEvent, error) {
+	return u.eventRepo.List(ctx, filter)
+}
+
+func (u *auditUseCase) ExportEvents(ctx context.Context, filter entity.SecurityEventFilter, fn func(*entity.SecurityEvent) error) error {
+	return u.eventRepo.Stream(ctx, filter, fn)
+}
+
+// VerifyChain skips events recorded before the log was chained.
+func (u *auditUseCase) VerifyChain(ctx context.Context) (*entity.ChainVerification, error) {
+	var verifier entity.ChainVerifier
+	err := u.eventRepo.Stream(ctx, entity.SecurityEventFilter{}, func(event *entity.SecurityEvent) error {
+		if event.Sequence == nil {
+			return nil
+		}
+		if !verifier.Add(event) {
+			return errChainBroken
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errChainBroken) {
+		return nil, err
+	}
+	return verifier.Result(), nil
+}
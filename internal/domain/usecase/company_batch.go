@@ -0,0 +1,166 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"github.com/assylzhan-a/company-task/internal/domain/entity"
+	customError "github.com/assylzhan-a/company-task/pkg/errors"
+	"github.com/google/uuid"
+)
+
+// getCompanyFunc looks up the company an operation of a batch applies to.
+type getCompanyFunc func(ctx context.Context, id uuid.UUID) (*entity.Company, error)
+
+// Batch applies the operations of the batch in order and reports the outcome
+// of each. An error is only returned if the batch as a whole cannot be run.
+func (uc *companyUseCase) Batch(ctx context.Context, batch *entity.CompanyBatch) ([]*entity.CompanyBatchResult, error) {
+	if len(batch.Operations) > uc.cfg.MaxBatchOperations {
+		return nil, customError.Newf(customError.CodePayloadTooLarge, "A batch holds at most {0} operations",
+			strconv.Itoa(uc.cfg.MaxBatchOperations))
+	}
+
+	if batch.IsAtomic() {
+		return uc.batchAtomic(ctx, batch.Operations)
+	}
+
+	results := make([]*entity.CompanyBatchResult, len(batch.Operations))
+	for i := range batch.Operations {
+		op := &batch.Operations[i]
+		results[i] = newBatchResult(op)
+		change, err := prepareBatchOperation(ctx, op, uc.repo.GetByID)
+		if err == nil {
+			err = uc.store(ctx, change)
+		}
+		completeBatchResult(results[i], change, err)
+	}
+	return results, nil
+}
+
+// batchAtomic prepares every operation before storing any of them, so that
+// invalid operations fail the batch without a write. Operations see the
+// changes of earlier operations of the batch, and the companies they change
+// must still be at the version read when the batch is stored.
+func (uc *companyUseCase) batchAtomic(ctx context.Context, operations []entity.CompanyBatchOperation) ([]*entity.CompanyBatchResult, error) {
+	// pending holds the companies changed by the batch so far; deleted
+	// companies are nil.
+	pending := make(map[uuid.UUID]*entity.Company)
+	get := func(ctx context.Context, id uuid.UUID) (*entity.Company, error) {
+		company, ok := pending[id]
+		if !ok {
+			return uc.repo.GetByID(ctx, id)
+		}
+		if company == nil {
+			return nil, customError.New(customError.CodeCompanyNotFound, "Company not found")
+		}
+		current := *company
+		return &current, nil
+	}
+
+	results := make([]*entity.CompanyBatchResult, len(operations))
+	changes := make([]*entity.CompanyChange, 0, len(operations))
+	indexes := make([]int, 0, len(operations))
+	failed := false
+	for i := range operations {
+		op := &operations[i]
+		results[i] = newBatchResult(op)
+		change, err := prepareBatchOperation(ctx, op, get)
+		if err != nil {
+			results[i].Err = err
+			failed = true
+			continue
+		}
+		changes = append(changes, change)
+		indexes = append(indexes, i)
+
+		company := *change.Company
+		switch change.Revision.Operation {
+		case entity.CompanyRevisionCreated:
+			company.Version = 1
+			pending[company.ID] = &company
+		case entity.CompanyRevisionUpdated:
+			change.ExpectedVersion = company.Version
+			company.Version++
+			pending[company.ID] = &company
+		case entity.CompanyRevisionDeleted:
+			change.ExpectedVersion = company.Version
+			pending[company.ID] = nil
+		}
+	}
+	if failed {
+		return results, nil
+	}
+
+	if err := uc.repo.ApplyChanges(ctx, changes); err != nil {
+		var changeErr *entity.CompanyChangeError
+		if !errors.As(err, &changeErr) {
+			uc.logger.Error("Failed to apply company batch", "error", err)
+			return nil, err
+		}
+		results[indexes[changeErr.Index]].Err = changeErr.Err
+		return results, nil
+	}
+
+	for i, change := range changes {
+		completeBatchResult(results[indexes[i]], change, nil)
+	}
+	return results, nil
+}
+
+// prepareBatchOperation checks the operation and prepares its change like the
+// endpoint for the single operation does.
+func prepareBatchOperation(ctx context.Context, op *entity.CompanyBatchOperation, get getCompanyFunc) (*entity.CompanyChange, error) {
+	switch op.Op {
+	case entity.CompanyBatchCreate:
+		if op.Company == nil {
+			return nil, customError.Newf(customError.CodeInvalidPayload, "The {0} operation needs {1}", string(op.Op), "company")
+		}
+		company := *op.Company
+		if err := company.Validate(); err != nil {
+			return nil, customError.NewValidationError(err)
+		}
+		return prepareCreate(ctx, &company)
+	case entity.CompanyBatchPatch:
+		if op.ID == uuid.Nil || len(op.Patch) == 0 {
+			return nil, customError.Newf(customError.CodeInvalidPayload, "The {0} operation needs {1}", string(op.Op), "id, patch")
+		}
+		company, err := get(ctx, op.ID)
+		if err != nil {
+			return nil, err
+		}
+		return preparePatch(ctx, company, entity.CompanyMergePatch(op.Patch))
+	case entity.CompanyBatchDelete:
+		if op.ID == uuid.Nil {
+			return nil, customError.Newf(customError.CodeInvalidPayload, "The {0} operation needs {1}", string(op.Op), "id")
+		}
+		company, err := get(ctx, op.ID)
+		if err != nil {
+			return nil, err
+		}
+		return prepareDelete(ctx, company)
+	default:
+		return nil, customError.Newf(customError.CodeInvalidPayload, "Unknown operation {0}", string(op.Op))
+	}
+}
+
+func newBatchResult(op *entity.CompanyBatchOperation) *entity.CompanyBatchResult {
+	result := &entity.CompanyBatchResult{Op: op.Op, ID: op.ID}
+	if op.Op == entity.CompanyBatchCreate && op.Company != nil {
+		result.ID = op.Company.ID
+	}
+	return result
+}
+
+// completeBatchResult records the outcome of storing the change.
+func completeBatchResult(result *entity.CompanyBatchResult, change *entity.CompanyChange, err error) {
+	if err != nil {
+		result.Err = err
+		return
+	}
+	result.ID = change.Company.ID
+	result.Applied = true
+	if change.Revision.Operation != entity.CompanyRevisionDeleted {
+		result.Company = change.Company
+	}
+}
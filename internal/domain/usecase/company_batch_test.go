@@ -0,0 +1,173 @@
+package usecase
+
+import (
+	"context"
+	"testing"
+
+	"github.com/assylzhan-a/company-task/internal/auth"
+	"github.com/assylzhan-a/company-task/internal/domain/entity"
+	r "github.com/assylzhan-a/company-task/internal/ports/repository"
+	"github.com/assylzhan-a/company-task/internal/tenant"
+	customError "github.com/assylzhan-a/company-task/pkg/errors"
+	"github.com/assylzhan-a/company-task/pkg/logger"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeCompanyRepo struct {
+	r.CompanyRepository
+	companies map[uuid.UUID]*entity.Company
+	// applied holds the changes of every ApplyChanges call.
+	applied [][]*entity.CompanyChange
+	stored  []*entity.CompanyChange
+}
+
+func (f *fakeCompanyRepo) GetByID(ctx context.Context, id uuid.UUID) (*entity.Company, error) {
+	company, ok := f.companies[id]
+	if !ok {
+		return nil, customError.New(customError.CodeCompanyNotFound, "Company not found")
+	}
+	current := *company
+	return &current, nil
+}
+
+func (f *fakeCompanyRepo) CreateWithOutboxEvent(ctx context.Context, company *entity.Company, event *entity.OutboxEvent, revision *entity.CompanyRevision) error {
+	f.stored = append(f.stored, &entity.CompanyChange{Company: company, Event: event, Revision: revision})
+	company.Version = 1
+	f.companies[company.ID] = company
+	return nil
+}
+
+func (f *fakeCompanyRepo) UpdateWithOutboxEvent(ctx context.Context, company *entity.Company, expectedVersion int64, event *entity.OutboxEvent, revision *entity.CompanyRevision) error {
+	f.stored = append(f.stored, &entity.CompanyChange{Company: company, ExpectedVersion: expectedVersion, Event: event, Revision: revision})
+	company.Version++
+	f.companies[company.ID] = company
+	return nil
+}
+
+func (f *fakeCompanyRepo) DeleteWithOutboxEvent(ctx context.Context, id uuid.UUID, expectedVersion int64, event *entity.OutboxEvent, revision *entity.CompanyRevision) error {
+	f.stored = append(f.stored, &entity.CompanyChange{Company: &entity.Company{ID: id}, ExpectedVersion: expectedVersion, Event: event, Revision: revision})
+	delete(f.companies, id)
+	return nil
+}
+
+func (f *fakeCompanyRepo) ApplyChanges(ctx context.Context, changes []*entity.CompanyChange) error {
+	f.applied = append(f.applied, changes)
+	return nil
+}
+
+func newBatchFixture(t *testing.T) (*fakeCompanyRepo, *companyUseCase, context.Context, *entity.Company) {
+	t.Helper()
+	userID := uuid.New()
+	existing := &entity.Company{
+		ID:                uuid.New(),
+		Name:              "Existing",
+		AmountOfEmployees: 3,
+		Registered:        true,
+		Type:              "Cooperative",
+		OwnerID:           &userID,
+		Version:           4,
+	}
+	repo := &fakeCompanyRepo{companies: map[uuid.UUID]*entity.Company{existing.ID: existing}}
+	useCase := NewCompanyUseCase(repo, logger.NewLogger("error"), CompanyUseCaseConfig{MaxBatchOperations: 3}).(*companyUseCase)
+
+	ctx := tenant.WithOrganization(context.Background(), entity.DefaultOrganizationID)
+	ctx = auth.WithPrincipal(ctx, &auth.Principal{UserID: userID})
+	return repo, useCase, ctx, existing
+}
+
+func newBatchCompany(name string) *entity.Company {
+	return &entity.Company{Name: name, AmountOfEmployees: 5, Registered: true, Type: "Corporations"}
+}
+
+func TestAtomicBatchSeesEarlierOperations(t *testing.T) {
+	repo, useCase, ctx, existing := newBatchFixture(t)
+	id := uuid.New()
+	created := newBatchCompany("Batch")
+	created.ID = id
+
+	results, err := useCase.Batch(ctx, &entity.CompanyBatch{Operations: []entity.CompanyBatchOperation{
+		{Op: entity.CompanyBatchCreate, Company: created},
+		{Op: entity.CompanyBatchPatch, ID: id, Patch: []byte(`{"amount_of_employees":7}`)},
+		{Op: entity.CompanyBatchDelete, ID: existing.ID},
+	}})
+	require.NoError(t, err)
+	require.Len(t, repo.applied, 1)
+
+	changes := repo.applied[0]
+	require.Len(t, changes, 3)
+	assert.Equal(t, entity.CompanyRevisionCreated, changes[0].Revision.Operation)
+	assert.Equal(t, entity.CompanyRevisionUpdated, changes[1].Revision.Operation)
+	assert.Equal(t, 7, changes[1].Company.AmountOfEmployees)
+	assert.Equal(t, "Batch", changes[1].Company.Name)
+	// The patch applies to the company as created by the batch.
+	assert.Equal(t, int64(1), changes[1].ExpectedVersion)
+	assert.Equal(t, entity.CompanyRevisionDeleted, changes[2].Revision.Operation)
+	assert.Equal(t, int64(4), changes[2].ExpectedVersion)
+	require.NotNil(t, changes[2].Event)
+	assert.Equal(t, "company_deleted", changes[2].Event.EventType)
+
+	for _, result := range results {
+		assert.True(t, result.Applied)
+		assert.NoError(t, result.Err)
+	}
+	assert.Equal(t, 7, results[1].Company.AmountOfEmployees)
+	assert.Nil(t, results[2].Company)
+}
+
+func TestAtomicBatchFailsWithoutWriting(t *testing.T) {
+	repo, useCase, ctx, existing := newBatchFixture(t)
+
+	results, err := useCase.Batch(ctx, &entity.CompanyBatch{Operations: []entity.CompanyBatchOperation{
+		{Op: entity.CompanyBatchDelete, ID: existing.ID},
+		{Op: entity.CompanyBatchCreate, Company: newBatchCompany("This name is far too long")},
+		{Op: entity.CompanyBatchPatch, ID: existing.ID, Patch: []byte(`{"name":"Deleted"}`)},
+	}})
+	require.NoError(t, err)
+	assert.Empty(t, repo.applied)
+
+	assert.False(t, results[0].Applied)
+	assert.NoError(t, results[0].Err)
+	assert.True(t, hasCode(results[1].Err, customError.CodeValidationFailed))
+	// The company was deleted by the first operation.
+	assert.True(t, hasCode(results[2].Err, customError.CodeCompanyNotFound))
+}
+
+func TestBestEffortBatch(t *testing.T) {
+	repo, useCase, ctx, existing := newBatchFixture(t)
+
+	results, err := useCase.Batch(ctx, &entity.CompanyBatch{Mode: entity.CompanyBatchBestEffort, Operations: []entity.CompanyBatchOperation{
+		{Op: entity.CompanyBatchCreate, Company: newBatchCompany("First")},
+		{Op: "rename", ID: existing.ID},
+		{Op: entity.CompanyBatchDelete, ID: existing.ID},
+	}})
+	require.NoError(t, err)
+	assert.Empty(t, repo.applied)
+	require.Len(t, repo.stored, 2)
+
+	assert.True(t, results[0].Applied)
+	assert.NotEqual(t, uuid.Nil, results[0].ID)
+	assert.Equal(t, "first", results[0].Company.Slug)
+	assert.True(t, hasCode(results[1].Err, customError.CodeInvalidPayload))
+	assert.True(t, results[2].Applied)
+	assert.NotContains(t, repo.companies, existing.ID)
+	// The delete only applies to the version it was prepared from.
+	assert.Equal(t, int64(4), repo.stored[1].ExpectedVersion)
+}
+
+func TestPatchAppliesToTheVersionRead(t *testing.T) {
+	repo, useCase, ctx, existing := newBatchFixture(t)
+
+	require.NoError(t, useCase.Patch(ctx, existing.ID, entity.CompanyMergePatch(`{"amount_of_employees":9}`)))
+	require.Len(t, repo.stored, 1)
+	assert.Equal(t, int64(4), repo.stored[0].ExpectedVersion)
+	assert.Equal(t, entity.CompanyRevisionUpdated, repo.stored[0].Revision.Operation)
+}
+
+func TestBatchSizeIsLimited(t *testing.T) {
+	_, useCase, ctx, _ := newBatchFixture(t)
+
+	_, err := useCase.Batch(ctx, &entity.CompanyBatch{Operations: make([]entity.CompanyBatchOperation, 4)})
+	assert.True(t, hasCode(err, customError.CodePayloadTooLarge))
+}
@@ -0,0 +1,75 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/assylzhan-a/company-task/internal/domain/entity"
+	r "github.com/assylzhan-a/company-task/internal/ports/repository"
+	customError "github.com/assylzhan-a/company-task/pkg/errors"
+	"github.com/assylzhan-a/company-task/pkg/logger"
+	"github.com/assylzhan-a/company-task/pkg/tabular"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (f *fakeCompanyRepo) Stream(ctx context.Context, filter entity.CompanyFilter, fn func(*entity.Company) error) error {
+	companies := make([]*entity.Company, 0, len(f.companies))
+	for _, company := range f.companies {
+		companies = append(companies, company)
+	}
+	sort.Slice(companies, func(i, j int) bool { return companies[i].CreatedAt.Before(companies[j].CreatedAt) })
+	for _, company := range companies {
+		if err := fn(company); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type failingStreamRepo struct {
+	r.CompanyRepository
+}
+
+func (failingStreamRepo) Stream(ctx context.Context, filter entity.CompanyFilter, fn func(*entity.Company) error) error {
+	return customError.NewInternalServerError("Failed to list companies")
+}
+
+func TestExport(t *testing.T) {
+	created := time.Date(2024, 10, 13, 9, 0, 0, 0, time.UTC)
+	repo := &fakeCompanyRepo{companies: map[uuid.UUID]*entity.Company{}}
+	for i, name := range []string{"Second", "First"} {
+		company := &entity.Company{ID: uuid.New(), Name: name, Slug: strings.ToLower(name), AmountOfEmployees: 5, Type: "Cooperative",
+			CreatedAt: created.Add(-time.Duration(i) * time.Hour), UpdatedAt: created}
+		repo.companies[company.ID] = company
+	}
+	useCase := NewCompanyUseCase(repo, logger.NewLogger("error"), CompanyUseCaseConfig{})
+
+	var buf bytes.Buffer
+	require.NoError(t, useCase.Export(context.Background(), entity.CompanyFilter{}, tabular.CSV, &buf))
+	table, err := tabular.Read(tabular.CSV, buf.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, entity.CompanyExportColumns, table.Columns)
+	require.Len(t, table.Rows, 2)
+	assert.Equal(t, "First", table.Rows[0].Values[2])
+	assert.Equal(t, "Second", table.Rows[1].Values[2])
+
+	// An export without companies still has its header.
+	buf.Reset()
+	empty := NewCompanyUseCase(&fakeCompanyRepo{companies: map[uuid.UUID]*entity.Company{}}, logger.NewLogger("error"), CompanyUseCaseConfig{})
+	require.NoError(t, empty.Export(context.Background(), entity.CompanyFilter{}, tabular.NDJSON, &buf))
+	assert.Empty(t, buf.String())
+	require.NoError(t, empty.Export(context.Background(), entity.CompanyFilter{}, tabular.CSV, &buf))
+	assert.Equal(t, strings.Join(entity.CompanyExportColumns, ",")+"\n", buf.String())
+
+	// Nothing is written when the companies cannot be read.
+	buf.Reset()
+	failing := NewCompanyUseCase(failingStreamRepo{}, logger.NewLogger("error"), CompanyUseCaseConfig{})
+	assert.Error(t, failing.Export(context.Background(), entity.CompanyFilter{}, tabular.XLSX, &buf))
+	assert.Zero(t, buf.Len())
+}
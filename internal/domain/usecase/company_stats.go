@@ -0,0 +1,206 @@
+package usecase
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/assylzhan-a/company-task/internal/domain/entity"
+	r "github.com/assylzhan-a/company-task/internal/ports/repository"
+	uc "github.com/assylzhan-a/company-task/internal/ports/usecase"
+	"github.com/assylzhan-a/company-task/internal/tenant"
+	customError "github.com/assylzhan-a/company-task/pkg/errors"
+	"github.com/google/uuid"
+)
+
+type CompanyStatsUseCaseConfig struct {
+	// CacheTTL bounds how long results are cached; 0 disables the cache.
+	// Results are dropped sooner when the outbox events of their
+	// organization are published.
+	CacheTTL time.Duration
+	// CacheSize limits the number of cached results.
+	CacheSize int
+}
+
+type companyStatsUseCase struct {
+	repo  r.CompanyRepository
+	cfg   CompanyStatsUseCaseConfig
+	cache *statsCache
+}
+
+// NewCompanyStatsUseCase returns a use case that caches results in memory.
+// HandleOutboxEvent must be called with the company events of every instance,
+// i.e. as they are consumed from Kafka; results are otherwise served up to
+// CacheTTL old.
+func NewCompanyStatsUseCase(repo r.CompanyRepository, cfg CompanyStatsUseCaseConfig) uc.CompanyStatsUseCase {
+	return &companyStatsUseCase{repo: repo, cfg: cfg, cache: newStatsCache(cfg.CacheSize)}
+}
+
+func (u *companyStatsUseCase) Stats(ctx context.Context, query entity.CompanyStatsQuery) (*entity.CompanyStats, error) {
+	organizationID, ok := tenant.OrganizationFromContext(ctx)
+	if !ok {
+		return nil, customError.New(customError.CodeNoOrganization, "No active organization")
+	}
+
+	key := query.Key()
+	now := time.Now()
+	if stats, ok := u.cache.get(organizationID, key, now); ok {
+		return stats, nil
+	}
+
+	generation := u.cache.generation()
+	groups, err := u.repo.Stats(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &entity.CompanyStats{GroupBy: query.GroupBy, Groups: groups, ComputedAt: now}
+	if stats.GroupBy == nil {
+		stats.GroupBy = []entity.CompanyStatsDimension{}
+	}
+	if stats.Groups == nil {
+		stats.Groups = []*entity.CompanyStatsGroup{}
+	}
+	for _, dimension := range query.GroupBy {
+		if dimension == entity.CompanyStatsByCreated {
+			stats.Bucket = query.Bucket
+		}
+	}
+	for _, group := range groups {
+		stats.Count += group.Count
+		stats.TotalEmployees += group.TotalEmployees
+	}
+
+	if u.cfg.CacheTTL > 0 {
+		u.cache.put(organizationID, key, stats, now.Add(u.cfg.CacheTTL), generation)
+	}
+	return stats, nil
+}
+
+// HandleOutboxEvent drops every result of the organization, since a single
+// company can change the counts of any query.
+func (u *companyStatsUseCase) HandleOutboxEvent(event *entity.OutboxEvent) {
+	if !slices.Contains(entity.CompanyEventTypes, event.EventType) {
+		return
+	}
+	u.cache.invalidate(event.OrganizationID)
+}
+
+// statsCache holds results by organization and query key. Invalidations are
+// numbered, so that results computed while their organization was
+// invalidated are not cached.
+type statsCache struct {
+	mu      sync.Mutex
+	size    int
+	entries map[uuid.UUID]map[string]*statsCacheEntry
+	count   int
+	// invalidations counts every invalidation; invalidated holds the number
+	// of the last invalidation of each organization, and invalidatedAll that
+	// of the last invalidation of all of them.
+	invalidations  uint64
+	invalidated    map[uuid.UUID]uint64
+	invalidatedAll uint64
+}
+
+type statsCacheEntry struct {
+	stats     *entity.CompanyStats
+	expiresAt time.Time
+}
+
+func newStatsCache(size int) *statsCache {
+	return &statsCache{
+		size:        size,
+		entries:     make(map[uuid.UUID]map[string]*statsCacheEntry),
+		invalidated: make(map[uuid.UUID]uint64),
+	}
+}
+
+func (c *statsCache) get(organizationID uuid.UUID, key string, now time.Time) (*entity.CompanyStats, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[organizationID][key]
+	if !ok || !now.Before(entry.expiresAt) {
+		return nil, false
+	}
+	return entry.stats, true
+}
+
+// generation returns the number of the last invalidation, to be passed to put
+// with results computed afterwards.
+func (c *statsCache) generation() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.invalidations
+}
+
+// put caches the results unless their organization was invalidated after
+// generation. A full cache makes room by dropping expired results, or else
+// the result that expires first.
+func (c *statsCache) put(organizationID uuid.UUID, key string, stats *entity.CompanyStats, expiresAt time.Time, generation uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.size <= 0 || c.invalidated[organizationID] > generation || c.invalidatedAll > generation {
+		return
+	}
+	entries, ok := c.entries[organizationID]
+	if !ok {
+		entries = make(map[string]*statsCacheEntry)
+		c.entries[organizationID] = entries
+	}
+	if _, ok := entries[key]; !ok {
+		if c.count >= c.size {
+			c.evict(stats.ComputedAt)
+		}
+		c.count++
+	}
+	entries[key] = &statsCacheEntry{stats: stats, expiresAt: expiresAt}
+}
+
+func (c *statsCache) evict(now time.Time) {
+	var oldestOrganization uuid.UUID
+	var oldestKey string
+	var oldest *statsCacheEntry
+	for organizationID, entries := range c.entries {
+		for key, entry := range entries {
+			if !now.Before(entry.expiresAt) {
+				c.remove(organizationID, key)
+				continue
+			}
+			if oldest == nil || entry.expiresAt.Before(oldest.expiresAt) {
+				oldestOrganization, oldestKey, oldest = organizationID, key, entry
+			}
+		}
+	}
+	if c.count >= c.size && oldest != nil {
+		c.remove(oldestOrganization, oldestKey)
+	}
+}
+
+func (c *statsCache) remove(organizationID uuid.UUID, key string) {
+	delete(c.entries[organizationID], key)
+	if len(c.entries[organizationID]) == 0 {
+		delete(c.entries, organizationID)
+	}
+	c.count--
+}
+
+// invalidate drops the results of the organization, or of all organizations
+// if nil.
+func (c *statsCache) invalidate(organizationID *uuid.UUID) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.invalidations++
+	if organizationID == nil {
+		c.invalidatedAll = c.invalidations
+		c.entries = make(map[uuid.UUID]map[string]*statsCacheEntry)
+		c.count = 0
+		return
+	}
+	c.invalidated[*organizationID] = c.invalidations
+	c.count -= len(c.entries[*organizationID])
+	delete(c.entries, *organizationID)
+}
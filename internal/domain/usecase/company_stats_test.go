@@ -0,0 +1,143 @@
+package usecase
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/assylzhan-a/company-task/internal/domain/entity"
+	r "github.com/assylzhan-a/company-task/internal/ports/repository"
+	"github.com/assylzhan-a/company-task/internal/tenant"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// statsRepo counts the queries it computes and runs during, if set, while
+// computing one.
+type statsRepo struct {
+	r.CompanyRepository
+	queries int
+	during  func()
+}
+
+func (f *statsRepo) Stats(ctx context.Context, query entity.CompanyStatsQuery) ([]*entity.CompanyStatsGroup, error) {
+	f.queries++
+	if f.during != nil {
+		f.during()
+	}
+	return []*entity.CompanyStatsGroup{
+		{Type: "Cooperative", Count: 2, TotalEmployees: 30},
+		{Type: "Corporations", Count: 1, TotalEmployees: 500},
+	}, nil
+}
+
+func newStatsFixture(cfg CompanyStatsUseCaseConfig) (*statsRepo, *companyStatsUseCase) {
+	repo := &statsRepo{}
+	return repo, NewCompanyStatsUseCase(repo, cfg).(*companyStatsUseCase)
+}
+
+func TestCompanyStatsTotals(t *testing.T) {
+	_, useCase := newStatsFixture(CompanyStatsUseCaseConfig{})
+	ctx := tenant.WithOrganization(context.Background(), entity.DefaultOrganizationID)
+
+	stats, err := useCase.Stats(ctx, entity.CompanyStatsQuery{GroupBy: []entity.CompanyStatsDimension{entity.CompanyStatsByType}})
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), stats.Count)
+	assert.Equal(t, int64(530), stats.TotalEmployees)
+	assert.Len(t, stats.Groups, 2)
+	assert.Empty(t, stats.Bucket)
+
+	stats, err = useCase.Stats(ctx, entity.CompanyStatsQuery{
+		GroupBy: []entity.CompanyStatsDimension{entity.CompanyStatsByCreated},
+		Bucket:  entity.BucketMonth,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, entity.BucketMonth, stats.Bucket)
+
+	_, err = useCase.Stats(context.Background(), entity.CompanyStatsQuery{})
+	assert.Error(t, err)
+}
+
+func TestCompanyStatsCacheInvalidatedByOutboxEvents(t *testing.T) {
+	repo, useCase := newStatsFixture(CompanyStatsUseCaseConfig{CacheTTL: time.Minute, CacheSize: 10})
+	organizationID, otherID := entity.DefaultOrganizationID, uuid.New()
+	ctx := tenant.WithOrganization(context.Background(), organizationID)
+	otherCtx := tenant.WithOrganization(context.Background(), otherID)
+	query := entity.CompanyStatsQuery{GroupBy: []entity.CompanyStatsDimension{entity.CompanyStatsByType}}
+
+	first, err := useCase.Stats(ctx, query)
+	require.NoError(t, err)
+	_, err = useCase.Stats(otherCtx, query)
+	require.NoError(t, err)
+	cached, err := useCase.Stats(ctx, query)
+	require.NoError(t, err)
+	assert.Same(t, first, cached)
+	assert.Equal(t, 2, repo.queries)
+
+	// Other events and events of other organizations keep the results.
+	useCase.HandleOutboxEvent(&entity.OutboxEvent{EventType: "user_created", OrganizationID: &organizationID})
+	useCase.HandleOutboxEvent(&entity.OutboxEvent{EventType: "company_created", OrganizationID: &otherID})
+	_, err = useCase.Stats(ctx, query)
+	require.NoError(t, err)
+	assert.Equal(t, 2, repo.queries)
+
+	useCase.HandleOutboxEvent(&entity.OutboxEvent{EventType: "company_deleted", OrganizationID: &organizationID})
+	_, err = useCase.Stats(ctx, query)
+	require.NoError(t, err)
+	assert.Equal(t, 3, repo.queries)
+
+	// Events without an organization drop every result.
+	useCase.HandleOutboxEvent(&entity.OutboxEvent{EventType: "company_updated"})
+	_, err = useCase.Stats(ctx, query)
+	require.NoError(t, err)
+	_, err = useCase.Stats(otherCtx, query)
+	require.NoError(t, err)
+	assert.Equal(t, 5, repo.queries)
+}
+
+func TestCompanyStatsNotCachedWhenInvalidatedWhileComputed(t *testing.T) {
+	repo, useCase := newStatsFixture(CompanyStatsUseCaseConfig{CacheTTL: time.Minute, CacheSize: 10})
+	organizationID := entity.DefaultOrganizationID
+	ctx := tenant.WithOrganization(context.Background(), organizationID)
+
+	repo.during = func() {
+		useCase.HandleOutboxEvent(&entity.OutboxEvent{EventType: "company_created", OrganizationID: &organizationID})
+	}
+	_, err := useCase.Stats(ctx, entity.CompanyStatsQuery{})
+	require.NoError(t, err)
+
+	repo.during = nil
+	_, err = useCase.Stats(ctx, entity.CompanyStatsQuery{})
+	require.NoError(t, err)
+	assert.Equal(t, 2, repo.queries)
+}
+
+func TestCompanyStatsCacheLimits(t *testing.T) {
+	repo, useCase := newStatsFixture(CompanyStatsUseCaseConfig{})
+	ctx := tenant.WithOrganization(context.Background(), entity.DefaultOrganizationID)
+	for i := 0; i < 2; i++ {
+		_, err := useCase.Stats(ctx, entity.CompanyStatsQuery{})
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 2, repo.queries, "a CacheTTL of 0 disables the cache")
+
+	repo, useCase = newStatsFixture(CompanyStatsUseCaseConfig{CacheTTL: time.Minute, CacheSize: 2})
+	queries := []entity.CompanyStatsQuery{
+		{GroupBy: []entity.CompanyStatsDimension{entity.CompanyStatsByType}},
+		{GroupBy: []entity.CompanyStatsDimension{entity.CompanyStatsByRegistered}},
+		{GroupBy: []entity.CompanyStatsDimension{entity.CompanyStatsByEmployees}},
+	}
+	for _, query := range queries {
+		_, err := useCase.Stats(ctx, query)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 2, useCase.cache.count)
+
+	// The first result expired first and made room for the third.
+	_, err := useCase.Stats(ctx, queries[2])
+	require.NoError(t, err)
+	_, err = useCase.Stats(ctx, queries[0])
+	require.NoError(t, err)
+	assert.Equal(t, 4, repo.queries)
+}
@@ -3,95 +3,396 @@ package usecase
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"github.com/assylzhan-a/company-task/internal/auth"
 	"github.com/assylzhan-a/company-task/internal/domain/entity"
 	r "github.com/assylzhan-a/company-task/internal/ports/repository"
 	uc "github.com/assylzhan-a/company-task/internal/ports/usecase"
+	"github.com/assylzhan-a/company-task/internal/requestid"
+	"github.com/assylzhan-a/company-task/internal/tenant"
+	customError "github.com/assylzhan-a/company-task/pkg/errors"
+	"github.com/assylzhan-a/company-task/pkg/jsonpatch"
 	"github.com/assylzhan-a/company-task/pkg/logger"
+	"github.com/assylzhan-a/company-task/pkg/tabular"
 	"github.com/google/uuid"
+	"io"
+	"strconv"
 	"time"
 )
 
+type CompanyUseCaseConfig struct {
+	// MaxBatchOperations limits the number of operations of a batch.
+	MaxBatchOperations int
+}
+
 type companyUseCase struct {
 	repo   r.CompanyRepository
 	logger *logger.Logger
+	cfg    CompanyUseCaseConfig
 }
 
-func NewCompanyUseCase(repo r.CompanyRepository, logger *logger.Logger) uc.CompanyUseCase {
-	return &companyUseCase{repo: repo, logger: logger}
+func NewCompanyUseCase(repo r.CompanyRepository, logger *logger.Logger, cfg CompanyUseCaseConfig) uc.CompanyUseCase {
+	return &companyUseCase{repo: repo, logger: logger, cfg: cfg}
 }
 
 func (uc *companyUseCase) Create(ctx context.Context, company *entity.Company) error {
-	company.CreatedAt = time.Now()
-	company.UpdatedAt = time.Now()
-
-	payload, err := json.Marshal(company)
+	change, err := prepareCreate(ctx, company)
 	if err != nil {
 		return err
 	}
+	return uc.store(ctx, change)
+}
 
-	event := &entity.OutboxEvent{
-		ID:        uuid.New(),
-		EventType: "company_created",
-		Payload:   payload,
-		CreatedAt: time.Now(),
+// maxPutAttempts bounds how often Put retries when the company changes
+// between reading and writing it.
+const maxPutAttempts = 3
+
+// Put creates the company or replaces the editable fields of the existing
+// one. The precondition is checked against the company as stored; if the
+// company changes before it is written, the precondition is checked again
+// against the new state.
+func (uc *companyUseCase) Put(ctx context.Context, company *entity.Company, precondition entity.CompanyPrecondition) (bool, error) {
+	replacement := *company
+	for attempt := 1; ; attempt++ {
+		current, err := uc.repo.GetByID(ctx, company.ID)
+		if err != nil && !hasCode(err, customError.CodeCompanyNotFound) {
+			return false, err
+		}
+		if !precondition.Check(current) {
+			return false, customError.New(customError.CodePreconditionFailed, "The company does not match the If-Match or If-None-Match header")
+		}
+
+		*company = replacement
+		var event *entity.OutboxEvent
+		var revision *entity.CompanyRevision
+		var expectedVersion int64
+		if current == nil {
+			if err := initCompany(ctx, company); err != nil {
+				return false, err
+			}
+			event, err = newCompanyEvent(entity.CompanyCreatedEvent, company)
+			revision = entity.NewCompanyRevision(company, entity.CompanyRevisionCreated, company.CreatedBy, requestid.FromContext(ctx))
+		} else {
+			var principal *auth.Principal
+			if principal, err = authorizeOwner(ctx, current); err != nil {
+				return false, err
+			}
+			userID := principal.UserID
+			company.OrganizationID, company.Slug = current.OrganizationID, current.Slug
+			company.OwnerID, company.CreatedBy, company.UpdatedBy = current.OwnerID, current.CreatedBy, &userID
+			company.CreatedAt, company.UpdatedAt = current.CreatedAt, time.Now()
+			expectedVersion = current.Version
+			event, err = newCompanyEvent(entity.CompanyUpdatedEvent, company)
+			revision = entity.NewCompanyRevision(company, entity.CompanyRevisionUpdated, &userID, requestid.FromContext(ctx))
+		}
+		if err != nil {
+			return false, err
+		}
+
+		err = uc.repo.UpsertWithOutboxEvent(ctx, company, expectedVersion, event, revision)
+		if hasCode(err, customError.CodeConflict) && attempt < maxPutAttempts {
+			continue
+		}
+		if err != nil {
+			uc.logger.Error("Failed to put company with outbox event", "error", err, "companyID", company.ID)
+			return false, err
+		}
+		return current == nil, nil
 	}
+}
 
-	if err := uc.repo.CreateWithOutboxEvent(ctx, company, event); err != nil {
-		uc.logger.Error("Failed to create company with outbox event", "error", err, "companyID", company.ID)
+// Patch applies the patch to the company and stores it if the result is a
+// valid company.
+func (uc *companyUseCase) Patch(ctx context.Context, id uuid.UUID, patch entity.CompanyPatch) error {
+	company, err := uc.repo.GetByID(ctx, id)
+	if err != nil {
 		return err
 	}
 
-	return nil
+	change, err := preparePatch(ctx, company, patch)
+	if err != nil {
+		return err
+	}
+	return uc.store(ctx, change)
 }
 
-func (uc *companyUseCase) Patch(ctx context.Context, id uuid.UUID, patch *entity.PatchCompany) error {
+// TransferOwnership hands the company over to another user. Only the current
+// owner or an admin may transfer it.
+func (uc *companyUseCase) TransferOwnership(ctx context.Context, id uuid.UUID, newOwnerID uuid.UUID) error {
 	company, err := uc.repo.GetByID(ctx, id)
 	if err != nil {
 		return err
 	}
 
-	if patch.Name != nil {
-		company.Name = *patch.Name
+	principal, err := authorizeOwner(ctx, company)
+	if err != nil {
+		return err
+	}
+
+	company.OwnerID = &newOwnerID
+	change, err := prepareUpdate(ctx, company, principal)
+	if err != nil {
+		return err
+	}
+	return uc.store(ctx, change)
+}
+
+// store saves a change prepared for a single company in its own transaction.
+// Updates and deletes only apply to the version the change was prepared from,
+// so that a concurrent change is not overwritten.
+func (uc *companyUseCase) store(ctx context.Context, change *entity.CompanyChange) error {
+	company := change.Company
+	if change.Revision.Operation != entity.CompanyRevisionCreated {
+		change.ExpectedVersion = company.Version
 	}
-	if patch.Description != nil {
-		company.Description = patch.Description
+	switch change.Revision.Operation {
+	case entity.CompanyRevisionCreated:
+		if err := uc.repo.CreateWithOutboxEvent(ctx, company, change.Event, change.Revision); err != nil {
+			uc.logger.Error("Failed to create company with outbox event", "error", err, "companyID", company.ID)
+			return err
+		}
+	case entity.CompanyRevisionUpdated:
+		if err := uc.repo.UpdateWithOutboxEvent(ctx, company, change.ExpectedVersion, change.Event, change.Revision); err != nil {
+			uc.logger.Error("Failed to update company with outbox event", "error", err)
+			return err
+		}
+	default:
+		if err := uc.repo.DeleteWithOutboxEvent(ctx, company.ID, change.ExpectedVersion, change.Event, change.Revision); err != nil {
+			uc.logger.Error("Failed to delete company with outbox event", "error", err, "companyID", company.ID)
+			return err
+		}
 	}
-	if patch.AmountOfEmployees != nil {
-		company.AmountOfEmployees = *patch.AmountOfEmployees
+	return nil
+}
+
+// prepareCreate readies a new company for storing.
+func prepareCreate(ctx context.Context, company *entity.Company) (*entity.CompanyChange, error) {
+	if err := initCompany(ctx, company); err != nil {
+		return nil, err
+	}
+
+	event, err := newCompanyEvent(entity.CompanyCreatedEvent, company)
+	if err != nil {
+		return nil, err
+	}
+
+	revision := entity.NewCompanyRevision(company, entity.CompanyRevisionCreated, company.CreatedBy, requestid.FromContext(ctx))
+	return &entity.CompanyChange{Company: company, Event: event, Revision: revision}, nil
+}
+
+// preparePatch applies the patch to the company if the caller may change it
+// and the result is a valid company.
+func preparePatch(ctx context.Context, company *entity.Company, patch entity.CompanyPatch) (*entity.CompanyChange, error) {
+	principal, err := authorizeOwner(ctx, company)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := patch.Apply(company); err != nil {
+		return nil, patchError(err)
+	}
+	if err := company.Validate(); err != nil {
+		return nil, customError.NewValidationError(err)
+	}
+
+	return prepareUpdate(ctx, company, principal)
+}
+
+func prepareUpdate(ctx context.Context, company *entity.Company, principal *auth.Principal) (*entity.CompanyChange, error) {
+	userID := principal.UserID
+	company.UpdatedBy = &userID
+	company.UpdatedAt = time.Now()
+
+	event, err := newCompanyEvent(entity.CompanyUpdatedEvent, company)
+	if err != nil {
+		return nil, err
+	}
+
+	revision := entity.NewCompanyRevision(company, entity.CompanyRevisionUpdated, &userID, requestid.FromContext(ctx))
+	return &entity.CompanyChange{Company: company, Event: event, Revision: revision}, nil
+}
+
+func prepareDelete(ctx context.Context, company *entity.Company) (*entity.CompanyChange, error) {
+	principal, err := authorizeOwner(ctx, company)
+	if err != nil {
+		return nil, err
+	}
+
+	// The event carries the company as it was before it was deleted.
+	event, err := newCompanyEvent(entity.CompanyDeletedEvent, company)
+	if err != nil {
+		return nil, err
 	}
-	if patch.Registered != nil {
-		company.Registered = *patch.Registered
+
+	userID := principal.UserID
+	revision := entity.NewCompanyRevision(company, entity.CompanyRevisionDeleted, &userID, requestid.FromContext(ctx))
+	return &entity.CompanyChange{Company: company, Event: event, Revision: revision}, nil
+}
+
+// initCompany places a new company in the active organization and makes the
+// caller its owner. Companies without an ID get a time-ordered UUIDv7, which
+// keeps the primary key index compact.
+func initCompany(ctx context.Context, company *entity.Company) error {
+	organizationID, ok := tenant.OrganizationFromContext(ctx)
+	if !ok {
+		return customError.New(customError.CodeNoOrganization, "No active organization")
 	}
-	if patch.Type != nil {
-		company.Type = *patch.Type
+	company.OrganizationID = organizationID
+
+	if company.ID == uuid.Nil {
+		id, err := uuid.NewV7()
+		if err != nil {
+			return customError.NewInternalServerError("Failed to generate company ID")
+		}
+		company.ID = id
 	}
+	// The repository makes the slug unique.
+	company.Slug = entity.Slugify(company.Name)
 
+	company.CreatedAt = time.Now()
 	company.UpdatedAt = time.Now()
 
+	// Ownership is always derived from the caller, never from the payload.
+	company.OwnerID, company.CreatedBy, company.UpdatedBy = nil, nil, nil
+	if principal, ok := auth.PrincipalFromContext(ctx); ok {
+		userID := principal.UserID
+		company.OwnerID = &userID
+		company.CreatedBy = &userID
+		company.UpdatedBy = &userID
+	}
+	return nil
+}
+
+func newCompanyEvent(eventType string, company *entity.Company) (*entity.OutboxEvent, error) {
 	payload, err := json.Marshal(company)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	event := &entity.OutboxEvent{
+	return &entity.OutboxEvent{
 		ID:        uuid.New(),
-		EventType: "company_updated",
+		EventType: eventType,
 		Payload:   payload,
 		CreatedAt: time.Now(),
+	}, nil
+}
+
+func (uc *companyUseCase) Delete(ctx context.Context, id uuid.UUID) error {
+	company, err := uc.repo.GetByID(ctx, id)
+	if err != nil {
+		return err
 	}
 
-	if err := uc.repo.UpdateWithOutboxEvent(ctx, company, event); err != nil {
-		uc.logger.Error("Failed to update company with outbox event", "error", err)
+	change, err := prepareDelete(ctx, company)
+	if err != nil {
 		return err
 	}
+	return uc.store(ctx, change)
+}
 
-	return nil
+func (uc *companyUseCase) GetByID(ctx context.Context, id uuid.UUID) (*entity.Company, error) {
+	return uc.repo.GetByID(ctx, id)
 }
 
-func (uc *companyUseCase) Delete(ctx context.Context, id uuid.UUID) error {
-	return uc.repo.Delete(ctx, id)
+func (uc *companyUseCase) GetBySlug(ctx context.Context, slug string) (*entity.Company, error) {
+	return uc.repo.GetBySlug(ctx, slug)
 }
 
-func (uc *companyUseCase) GetByID(ctx context.Context, id uuid.UUID) (*entity.Company, error) {
-	return uc.repo.GetByID(ctx, id)
+// Export starts the file when the first company has been read, so that
+// nothing is written to w if the companies cannot be read at all.
+func (uc *companyUseCase) Export(ctx context.Context, filter entity.CompanyFilter, format tabular.Format, w io.Writer) error {
+	var writer tabular.Writer
+	start := func() (err error) {
+		writer, err = tabular.NewWriter(format, w, entity.CompanyExportColumns)
+		return err
+	}
+	err := uc.repo.Stream(ctx, filter, func(company *entity.Company) error {
+		if writer == nil {
+			if err := start(); err != nil {
+				return err
+			}
+		}
+		return writer.Write(company.ExportValues())
+	})
+	if err != nil {
+		return err
+	}
+	if writer == nil {
+		if err := start(); err != nil {
+			return err
+		}
+	}
+	return writer.Close()
+}
+
+// GetAsOf returns the company as it was at the given time. Companies that did
+// not exist yet or were already deleted are not found.
+func (uc *companyUseCase) GetAsOf(ctx context.Context, id uuid.UUID, asOf time.Time) (*entity.Company, error) {
+	revision, err := uc.repo.GetRevisionAt(ctx, id, asOf)
+	if err != nil {
+		return nil, err
+	}
+	if revision.Operation == entity.CompanyRevisionDeleted {
+		return nil, customError.New(customError.CodeCompanyNotFound, "Company not found")
+	}
+	return revision.Snapshot, nil
+}
+
+// History lists every revision of the company, including after it was
+// deleted.
+func (uc *companyUseCase) History(ctx context.Context, id uuid.UUID) ([]*entity.CompanyRevision, error) {
+	return uc.repo.ListRevisions(ctx, id)
+}
+
+func (uc *companyUseCase) Diff(ctx context.Context, id uuid.UUID, fromRevision, toRevision int64) (*entity.CompanyDiff, error) {
+	from, err := uc.repo.GetRevision(ctx, id, fromRevision)
+	if err != nil {
+		return nil, err
+	}
+	to, err := uc.repo.GetRevision(ctx, id, toRevision)
+	if err != nil {
+		return nil, err
+	}
+
+	diff, err := entity.DiffCompanyRevisions(from, to)
+	if err != nil {
+		return nil, customError.NewInternalServerError("Failed to compare revisions")
+	}
+	return diff, nil
+}
+
+// patchError reports why a patch could not be applied. A failed test
+// operation means the company changed since the client read it.
+func patchError(err error) error {
+	var opErr *jsonpatch.OperationError
+	switch {
+	case errors.Is(err, jsonpatch.ErrTestFailed) && errors.As(err, &opErr):
+		return customError.Newf(customError.CodePatchTestFailed, "Test operation {0} failed at {1}",
+			strconv.Itoa(opErr.Index), opErr.Path)
+	case errors.As(err, &opErr):
+		return customError.Newf(customError.CodeInvalidPatch, "Operation {0} cannot be applied at {1}",
+			strconv.Itoa(opErr.Index), opErr.Path)
+	case errors.Is(err, entity.ErrInvalidCompanyPatch):
+		return customError.New(customError.CodeInvalidPatch, "The patch sets a field that does not exist or a value of the wrong type")
+	default:
+		return err
+	}
+}
+
+// hasCode reports whether err is an *customError.AppError with the code.
+func hasCode(err error, code customError.Code) bool {
+	var appErr *customError.AppError
+	return errors.As(err, &appErr) && appErr.Code == code
+}
+
+// authorizeOwner returns the caller if they own the company or are an admin.
+func authorizeOwner(ctx context.Context, company *entity.Company) (*auth.Principal, error) {
+	principal, ok := auth.PrincipalFromContext(ctx)
+	if !ok {
+		return nil, customError.NewUnauthorizedError("Authentication required")
+	}
+	if principal.IsAdmin() || company.IsOwnedBy(principal.UserID) {
+		return principal, nil
+	}
+	return nil, customError.New(customError.CodeNotCompanyOwner, "Only the owner or an admin may modify this company")
 }
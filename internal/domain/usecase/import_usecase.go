@@ -0,0 +1,496 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/assylzhan-a/company-task/internal/auth"
+	"github.com/assylzhan-a/company-task/internal/domain/entity"
+	r "github.com/assylzhan-a/company-task/internal/ports/repository"
+	uc "github.com/assylzhan-a/company-task/internal/ports/usecase"
+	"github.com/assylzhan-a/company-task/internal/requestid"
+	"github.com/assylzhan-a/company-task/internal/tenant"
+	customError "github.com/assylzhan-a/company-task/pkg/errors"
+	"github.com/assylzhan-a/company-task/pkg/logger"
+	"github.com/assylzhan-a/company-task/pkg/tabular"
+	"github.com/google/uuid"
+)
+
+type ImportUseCaseConfig struct {
+	// Lease is how long a worker holds a job without saving progress before
+	// another worker may resume it.
+	Lease time.Duration
+}
+
+type importUseCase struct {
+	repo          r.ImportRepository
+	companies     uc.CompanyUseCase
+	users         r.UserRepository
+	roles         r.RoleRepository
+	organizations r.OrganizationRepository
+	apiKeys       r.APIKeyRepository
+	logger        *logger.Logger
+	cfg           ImportUseCaseConfig
+}
+
+// NewImportUseCase returns an import use case that creates companies with the
+// company use case, so that imported companies get revisions and outbox
+// events like any other. The other repositories load the current access of
+// the uploader whenever a job is processed.
+func NewImportUseCase(repo r.ImportRepository, companies uc.CompanyUseCase, users r.UserRepository, roles r.RoleRepository,
+	organizations r.OrganizationRepository, apiKeys r.APIKeyRepository, logger *logger.Logger, cfg ImportUseCaseConfig) uc.ImportUseCase {
+	return &importUseCase{
+		repo:          repo,
+		companies:     companies,
+		users:         users,
+		roles:         roles,
+		organizations: organizations,
+		apiKeys:       apiKeys,
+		logger:        logger,
+		cfg:           cfg,
+	}
+}
+
+func (u *importUseCase) Upload(ctx context.Context, format tabular.Format, data []byte) (*entity.ImportJob, error) {
+	organizationID, ok := tenant.OrganizationFromContext(ctx)
+	if !ok {
+		return nil, customError.New(customError.CodeNoOrganization, "No active organization")
+	}
+
+	// The rows are read to count them and to reject files that cannot be
+	// read, but are not held.
+	reader, err := tabular.NewReader(format, data)
+	totalRows := 0
+	for err == nil {
+		if _, err = reader.Read(); err == nil {
+			totalRows++
+		}
+	}
+	if err != io.EOF {
+		return nil, customError.Newf(customError.CodeInvalidImportFile, "The {0} file cannot be read: {1}",
+			string(format), strings.TrimPrefix(err.Error(), tabular.ErrInvalidFile.Error()+": "))
+	}
+	if totalRows == 0 {
+		return nil, customError.Newf(customError.CodeInvalidImportFile, "The {0} file has no rows", string(format))
+	}
+
+	id, err := uuid.NewV7()
+	if err != nil {
+		return nil, customError.NewInternalServerError("Failed to create import")
+	}
+	now := time.Now()
+	job := &entity.ImportJob{
+		ID:             id,
+		OrganizationID: organizationID,
+		Format:         format,
+		Data:           data,
+		Columns:        reader.Columns,
+		Mapping:        entity.DefaultImportMapping(reader.Columns),
+		Status:         entity.ImportUploaded,
+		TotalRows:      totalRows,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+	if principal, ok := auth.PrincipalFromContext(ctx); ok {
+		userID := principal.UserID
+		job.CreatedBy = &userID
+		if principal.APIKeyID != uuid.Nil {
+			keyID := principal.APIKeyID
+			job.CreatedByAPIKey = &keyID
+		}
+	}
+
+	if err := u.repo.Create(ctx, job); err != nil {
+		u.logger.Error("Failed to create import", "error", err)
+		return nil, err
+	}
+	return job, nil
+}
+
+func (u *importUseCase) Get(ctx context.Context, id uuid.UUID) (*entity.ImportJob, error) {
+	return u.repo.GetByID(ctx, id)
+}
+
+// DryRun does not find rows that conflict with companies that already exist,
+// only rows that conflict with each other.
+func (u *importUseCase) DryRun(ctx context.Context, id uuid.UUID, mapping entity.ImportMapping) (*entity.ImportReport, error) {
+	job, err := u.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if mapping == nil {
+		mapping = job.Mapping
+	}
+	if err := checkImportMapping(mapping, job.Columns); err != nil {
+		return nil, err
+	}
+
+	data, err := u.repo.GetData(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := tabular.NewReader(job.Format, data)
+	if err != nil {
+		u.logger.Error("Failed to read uploaded import", "error", err, "importID", id)
+		return nil, customError.NewInternalServerError("Failed to read import")
+	}
+
+	report := &entity.ImportReport{Errors: []*entity.ImportRowError{}}
+	// names and ids map the companies of the file to their rows.
+	names := make(map[string]int)
+	ids := make(map[uuid.UUID]int)
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			u.logger.Error("Failed to read uploaded import", "error", err, "importID", id)
+			return nil, customError.NewInternalServerError("Failed to read import")
+		}
+		report.TotalRows++
+
+		company, rowErrors := importCompany(job, mapping, reader.Columns, row)
+		if len(rowErrors) == 0 {
+			if other, ok := names[company.Name]; ok {
+				rowErrors = append(rowErrors, newImportRowError(row.Line, customError.NewFieldError("name", "unique", "",
+					"Row {0} has a company with the same {1}", strconv.Itoa(other), "name")))
+			}
+			if other, ok := ids[company.ID]; ok {
+				rowErrors = append(rowErrors, newImportRowError(row.Line, customError.NewFieldError("id", "unique", "",
+					"Row {0} has a company with the same {1}", strconv.Itoa(other), "id")))
+			}
+			names[company.Name], ids[company.ID] = row.Line, row.Line
+		}
+
+		if len(rowErrors) == 0 {
+			report.ValidRows++
+			continue
+		}
+		report.InvalidRows++
+		for _, rowErr := range rowErrors {
+			if len(report.Errors) < entity.MaxImportReportErrors {
+				report.Errors = append(report.Errors, rowErr)
+			}
+		}
+	}
+	return report, nil
+}
+
+// Run keeps the rows that a failed job already processed, which is why their
+// mapping cannot change.
+func (u *importUseCase) Run(ctx context.Context, id uuid.UUID, mapping entity.ImportMapping) (*entity.ImportJob, error) {
+	job, err := u.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if mapping == nil {
+		mapping = job.Mapping
+	}
+	if job.ProcessedRows > 0 && !reflect.DeepEqual(mapping, job.Mapping) {
+		return nil, customError.New(customError.CodeConflict, "The mapping cannot change after rows were imported")
+	}
+	if err := checkImportMapping(mapping, job.Columns); err != nil {
+		return nil, err
+	}
+	return u.repo.Queue(ctx, id, mapping)
+}
+
+func (u *importUseCase) ListErrors(ctx context.Context, id uuid.UUID, afterRow, limit int) ([]*entity.ImportRowError, error) {
+	if _, err := u.repo.GetByID(ctx, id); err != nil {
+		return nil, err
+	}
+	return u.repo.ListRowErrors(ctx, id, afterRow, limit)
+}
+
+func (u *importUseCase) ProcessNext(ctx context.Context) (bool, error) {
+	now := time.Now()
+	job, err := u.repo.Claim(ctx, now, now.Add(u.cfg.Lease))
+	if err != nil || job == nil {
+		return false, err
+	}
+	return true, u.process(ctx, job)
+}
+
+// process imports the rows of a claimed job after those it already processed
+// and saves the progress after every row, so that a job that stops for any
+// reason resumes with the next row.
+//
+// A row may have been imported without its progress being saved. When it is
+// processed again, the company it created is found by the request ID of its
+// first revision and the row counts as imported.
+func (u *importUseCase) process(ctx context.Context, job *entity.ImportJob) error {
+	reader, err := tabular.NewReader(job.Format, job.Data)
+	if err != nil {
+		u.logger.Error("Failed to read uploaded import", "error", err, "importID", job.ID)
+		return u.fail(ctx, job, customError.NewInternalServerError("Failed to read import"))
+	}
+	// The file is read again from the start, skipping the rows that were
+	// already processed.
+	for skipped := 0; skipped < job.ProcessedRows; skipped++ {
+		if _, err := reader.Read(); err != nil {
+			u.logger.Error("Failed to read uploaded import", "error", err, "importID", job.ID)
+			return u.fail(ctx, job, customError.NewInternalServerError("Failed to read import"))
+		}
+	}
+
+	// Companies are created on behalf of the user who uploaded the file, with
+	// the access they have now rather than when they uploaded it.
+	principal, err := u.principal(ctx, job)
+	if err != nil {
+		return u.fail(ctx, job, err)
+	}
+	jobCtx := tenant.WithOrganization(ctx, job.OrganizationID)
+	jobCtx = auth.WithPrincipal(jobCtx, principal)
+
+	for {
+		if ctx.Err() != nil {
+			// Release the job so that another worker resumes it at once.
+			return u.save(context.WithoutCancel(ctx), job, nil, nil)
+		}
+
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			u.logger.Error("Failed to read uploaded import", "error", err, "importID", job.ID)
+			return u.fail(ctx, job, customError.NewInternalServerError("Failed to read import"))
+		}
+		rowCtx := requestid.WithRequestID(jobCtx, importRequestID(job, row.Line))
+		rowErrors, err := u.importRow(rowCtx, job, reader.Columns, row)
+		if err != nil {
+			u.logger.Error("Failed to import row", "error", err, "importID", job.ID, "row", row.Line)
+			return u.fail(ctx, job, err)
+		}
+
+		job.ProcessedRows++
+		if len(rowErrors) == 0 {
+			job.SucceededRows++
+		} else {
+			job.FailedRows++
+		}
+		leaseUntil := time.Now().Add(u.cfg.Lease)
+		if err := u.save(ctx, job, rowErrors, &leaseUntil); err != nil {
+			return err
+		}
+	}
+
+	now := time.Now()
+	job.Status = entity.ImportCompleted
+	job.FinishedAt = &now
+	return u.save(ctx, job, nil, nil)
+}
+
+// principal loads the uploader of the job: an active member of its
+// organization who may still create companies, or a usable API key of the
+// organization that may.
+func (u *importUseCase) principal(ctx context.Context, job *entity.ImportJob) (*auth.Principal, error) {
+	forbidden := customError.New(customError.CodePermissionDenied, "The uploader can no longer create companies in the organization")
+	if job.CreatedBy == nil {
+		return nil, forbidden
+	}
+	user, err := u.users.GetByID(ctx, *job.CreatedBy)
+	if errors.Is(err, entity.ErrUserNotFound) {
+		return nil, forbidden
+	}
+	if err != nil {
+		return nil, err
+	}
+	if user.IsDisabled() {
+		return nil, forbidden
+	}
+	member, err := u.organizations.IsMember(ctx, job.OrganizationID, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !member {
+		return nil, forbidden
+	}
+
+	principal := &auth.Principal{UserID: user.ID, OrganizationID: job.OrganizationID}
+	if job.CreatedByAPIKey != nil {
+		key, err := u.apiKey(ctx, user.ID, *job.CreatedByAPIKey)
+		if err != nil {
+			return nil, err
+		}
+		if key == nil || !key.IsUsable(time.Now()) || key.OrganizationID != job.OrganizationID {
+			return nil, forbidden
+		}
+		principal.APIKeyID = key.ID
+		principal.Permissions = key.Permissions()
+	} else if principal.Roles, principal.Permissions, err = u.roles.GetUserAccess(ctx, user.ID); err != nil {
+		return nil, err
+	}
+	if !principal.HasPermission(entity.PermissionCompaniesCreate) {
+		return nil, forbidden
+	}
+	return principal, nil
+}
+
+// apiKey returns the key of the service account, or nil if it has none with
+// the ID.
+func (u *importUseCase) apiKey(ctx context.Context, serviceAccountID, id uuid.UUID) (*entity.APIKey, error) {
+	keys, err := u.apiKeys.ListAPIKeys(ctx, serviceAccountID)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range keys {
+		if key.ID == id {
+			return key, nil
+		}
+	}
+	return nil, nil
+}
+
+// importRow creates the company of the row. Rows that are invalid or rejected
+// by the company use case are reported as row errors; any other error stops
+// the job.
+func (u *importUseCase) importRow(ctx context.Context, job *entity.ImportJob, columns []string, row tabular.Row) ([]*entity.ImportRowError, error) {
+	company, rowErrors := importCompany(job, job.Mapping, columns, row)
+	if len(rowErrors) > 0 {
+		return rowErrors, nil
+	}
+
+	err := u.companies.Create(ctx, company)
+	if err == nil {
+		return nil, nil
+	}
+	if hasCode(err, customError.CodeCompanyExists) && u.importedBefore(ctx, company.ID) {
+		return nil, nil
+	}
+
+	var appErr *customError.AppError
+	if !errors.As(err, &appErr) || appErr.StatusCode >= http.StatusInternalServerError {
+		return nil, err
+	}
+	if len(appErr.Errors) == 0 {
+		return []*entity.ImportRowError{{Row: row.Line, Code: string(appErr.Code), Message: appErr.Message}}, nil
+	}
+	for _, fieldErr := range appErr.Errors {
+		rowErr := newImportRowError(row.Line, fieldErr)
+		rowErr.Code = string(appErr.Code)
+		rowErrors = append(rowErrors, rowErr)
+	}
+	return rowErrors, nil
+}
+
+// importedBefore reports whether the company was created by the request of
+// ctx, i.e. by an earlier attempt to import the same row.
+func (u *importUseCase) importedBefore(ctx context.Context, id uuid.UUID) bool {
+	revisions, err := u.companies.History(ctx, id)
+	return err == nil && len(revisions) > 0 && revisions[0].RequestID == requestid.FromContext(ctx)
+}
+
+// fail stops the job with the error. Its lease is released so that it only
+// runs again when it is run by a user.
+func (u *importUseCase) fail(ctx context.Context, job *entity.ImportJob, cause error) error {
+	job.Status = entity.ImportFailed
+	job.Error = cause.Error()
+	if err := u.save(context.WithoutCancel(ctx), job, nil, nil); err != nil {
+		return err
+	}
+	return cause
+}
+
+// save stores the progress of the job. A job taken over by another worker is
+// left to it.
+func (u *importUseCase) save(ctx context.Context, job *entity.ImportJob, rowErrors []*entity.ImportRowError, leaseUntil *time.Time) error {
+	err := u.repo.SaveProgress(ctx, job, rowErrors, leaseUntil)
+	if errors.Is(err, entity.ErrImportLeaseLost) {
+		u.logger.Warn("Import was taken over by another worker", "importID", job.ID)
+		return nil
+	}
+	if err != nil {
+		u.logger.Error("Failed to save import progress", "error", err, "importID", job.ID)
+	}
+	return err
+}
+
+// importCompany converts and validates the row. Rows without an ID get the
+// ID the job derives from their row.
+func importCompany(job *entity.ImportJob, mapping entity.ImportMapping, columns []string, row tabular.Row) (*entity.Company, []*entity.ImportRowError) {
+	company, valueErrors := mapping.Company(columns, row.Values)
+	var rowErrors []*entity.ImportRowError
+	reported := make(map[string]bool)
+	for _, valueErr := range valueErrors {
+		rowErrors = append(rowErrors, newImportRowError(row.Line, customError.NewFieldError(valueErr.Field, "type", "",
+			"{0} must be {1}", valueErr.Field, valueErr.Expected)))
+		reported[valueErr.Field] = true
+	}
+
+	if err := company.Validate(); err != nil {
+		for _, fieldErr := range customError.NewValidationError(err).Errors {
+			if !reported[fieldErr.Field] {
+				rowErrors = append(rowErrors, newImportRowError(row.Line, fieldErr))
+			}
+		}
+	}
+	if company.ID == uuid.Nil {
+		company.ID = job.CompanyID(row.Line)
+	}
+	return company, rowErrors
+}
+
+// checkImportMapping reports every field of the mapping that is unknown or
+// names a column the file does not have, and every required field it lacks.
+func checkImportMapping(mapping entity.ImportMapping, columns []string) error {
+	known := make(map[string]bool, len(entity.ImportFields))
+	for _, field := range entity.ImportFields {
+		known[field] = true
+	}
+	hasColumn := make(map[string]bool, len(columns))
+	for _, column := range columns {
+		hasColumn[column] = true
+	}
+
+	var fieldErrors []customError.FieldError
+	for _, field := range entity.ImportFields {
+		if column, ok := mapping[field]; ok && !hasColumn[column] {
+			fieldErrors = append(fieldErrors, customError.NewFieldError(field, "column", column, "The file has no column {0}", column))
+		}
+	}
+	unknown := make([]string, 0)
+	for field := range mapping {
+		if !known[field] {
+			unknown = append(unknown, field)
+		}
+	}
+	sort.Strings(unknown)
+	for _, field := range unknown {
+		fieldErrors = append(fieldErrors, customError.NewFieldError(field, "unknown", "", "{0} is not a known field", field))
+	}
+	for _, field := range entity.RequiredImportFields {
+		if _, ok := mapping[field]; !ok {
+			fieldErrors = append(fieldErrors, customError.NewFieldError(field, "required", "", "{0} must be mapped to a column", field))
+		}
+	}
+	if fieldErrors == nil {
+		return nil
+	}
+	appErr := customError.New(customError.CodeInvalidImportMapping, "The mapping cannot be used for the file")
+	appErr.Errors = fieldErrors
+	return appErr
+}
+
+func newImportRowError(line int, fieldErr customError.FieldError) *entity.ImportRowError {
+	return &entity.ImportRowError{
+		Row:     line,
+		Field:   fieldErr.Field,
+		Code:    string(customError.CodeValidationFailed),
+		Message: fieldErr.Message,
+	}
+}
+
+// importRequestID identifies the import of a row in the revisions of the
+// company it creates.
+func importRequestID(job *entity.ImportJob, line int) string {
+	return "import:" + job.ID.String() + ":" + strconv.Itoa(line)
+}
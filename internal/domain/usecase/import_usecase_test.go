@@ -0,0 +1,282 @@
+package usecase
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/assylzhan-a/company-task/internal/auth"
+	"github.com/assylzhan-a/company-task/internal/domain/entity"
+	r "github.com/assylzhan-a/company-task/internal/ports/repository"
+	uc "github.com/assylzhan-a/company-task/internal/ports/usecase"
+	"github.com/assylzhan-a/company-task/internal/tenant"
+	customError "github.com/assylzhan-a/company-task/pkg/errors"
+	"github.com/assylzhan-a/company-task/pkg/logger"
+	"github.com/assylzhan-a/company-task/pkg/tabular"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeImportRepo struct {
+	r.ImportRepository
+	job       *entity.ImportJob
+	rowErrors []*entity.ImportRowError
+	// leases holds the lease of every SaveProgress call.
+	leases []*time.Time
+}
+
+func (f *fakeImportRepo) GetByID(ctx context.Context, id uuid.UUID) (*entity.ImportJob, error) {
+	if f.job == nil || f.job.ID != id {
+		return nil, customError.New(customError.CodeImportNotFound, "Import not found")
+	}
+	job := *f.job
+	return &job, nil
+}
+
+func (f *fakeImportRepo) GetData(ctx context.Context, id uuid.UUID) ([]byte, error) {
+	return f.job.Data, nil
+}
+
+func (f *fakeImportRepo) Claim(ctx context.Context, now, leaseUntil time.Time) (*entity.ImportJob, error) {
+	if f.job.Status != entity.ImportQueued {
+		return nil, nil
+	}
+	f.job.Status = entity.ImportRunning
+	f.job.StartedAt = &now
+	f.job.LeaseUntil = &leaseUntil
+	job := *f.job
+	return &job, nil
+}
+
+func (f *fakeImportRepo) SaveProgress(ctx context.Context, job *entity.ImportJob, rowErrors []*entity.ImportRowError, leaseUntil *time.Time) error {
+	saved := *job
+	f.job = &saved
+	f.rowErrors = append(f.rowErrors, rowErrors...)
+	f.leases = append(f.leases, leaseUntil)
+	return nil
+}
+
+type fakeCompanies struct {
+	uc.CompanyUseCase
+	created []*entity.Company
+	// errs are returned by Create for the companies with the names.
+	errs      map[string]error
+	revisions map[uuid.UUID][]*entity.CompanyRevision
+}
+
+func (f *fakeCompanies) Create(ctx context.Context, company *entity.Company) error {
+	if err, ok := f.errs[company.Name]; ok {
+		return err
+	}
+	principal, _ := auth.PrincipalFromContext(ctx)
+	company.OwnerID = &principal.UserID
+	company.OrganizationID, _ = tenant.OrganizationFromContext(ctx)
+	f.created = append(f.created, company)
+	return nil
+}
+
+func (f *fakeCompanies) History(ctx context.Context, id uuid.UUID) ([]*entity.CompanyRevision, error) {
+	return f.revisions[id], nil
+}
+
+// fakeImportRoles gives every user the same permissions.
+type fakeImportRoles struct {
+	r.RoleRepository
+	permissions []string
+}
+
+func (f *fakeImportRoles) GetUserAccess(ctx context.Context, userID uuid.UUID) ([]string, []string, error) {
+	return []string{entity.RoleEditor}, f.permissions, nil
+}
+
+type fakeMembers struct {
+	r.OrganizationRepository
+	members map[uuid.UUID]bool
+}
+
+func (f *fakeMembers) IsMember(ctx context.Context, organizationID, userID uuid.UUID) (bool, error) {
+	return f.members[userID], nil
+}
+
+type fakeAPIKeys struct {
+	r.APIKeyRepository
+	keys []*entity.APIKey
+}
+
+func (f *fakeAPIKeys) ListAPIKeys(ctx context.Context, serviceAccountID uuid.UUID) ([]*entity.APIKey, error) {
+	return f.keys, nil
+}
+
+const importCSV = "name,employees,registered,type\n" +
+	"Alpha,10,true,Corporations\n" +
+	"Beta,many,true,NonProfit\n" +
+	"Gamma,3,yes,Cooperative\n"
+
+func newImportFixture(t *testing.T, data string) (*fakeImportRepo, *fakeCompanies, *importUseCase) {
+	t.Helper()
+	userID := uuid.New()
+	users := &fakeUserRepo{users: map[string]*entity.User{"uploader": {ID: userID, Username: "uploader"}}}
+	roles := &fakeImportRoles{permissions: []string{entity.PermissionCompaniesRead, entity.PermissionCompaniesCreate}}
+	members := &fakeMembers{members: map[uuid.UUID]bool{userID: true}}
+	repo := &fakeImportRepo{job: &entity.ImportJob{
+		ID:             uuid.New(),
+		OrganizationID: entity.DefaultOrganizationID,
+		CreatedBy:      &userID,
+		Format:         tabular.CSV,
+		Data:           []byte(data),
+		Columns:        []string{"name", "employees", "registered", "type"},
+		Mapping: entity.ImportMapping{
+			"name": "name", "amount_of_employees": "employees", "registered": "registered", "type": "type",
+		},
+		Status:    entity.ImportQueued,
+		TotalRows: 3,
+	}}
+	companies := &fakeCompanies{errs: map[string]error{}, revisions: map[uuid.UUID][]*entity.CompanyRevision{}}
+	useCase := NewImportUseCase(repo, companies, users, roles, members, &fakeAPIKeys{}, logger.NewLogger("error"),
+		ImportUseCaseConfig{Lease: time.Minute}).(*importUseCase)
+	return repo, companies, useCase
+}
+
+func TestImportResumesAfterProcessedRows(t *testing.T) {
+	repo, companies, useCase := newImportFixture(t, importCSV)
+	repo.job.ProcessedRows, repo.job.SucceededRows = 1, 1
+
+	processed, err := useCase.ProcessNext(context.Background())
+	require.NoError(t, err)
+	assert.True(t, processed)
+
+	require.Len(t, companies.created, 1)
+	created := companies.created[0]
+	assert.Equal(t, "Gamma", created.Name)
+	assert.Equal(t, repo.job.CompanyID(4), created.ID)
+	assert.Equal(t, repo.job.CreatedBy, created.OwnerID)
+	assert.Equal(t, entity.DefaultOrganizationID, created.OrganizationID)
+
+	assert.Equal(t, entity.ImportCompleted, repo.job.Status)
+	assert.NotNil(t, repo.job.FinishedAt)
+	assert.Equal(t, []int{3, 2, 1}, []int{repo.job.ProcessedRows, repo.job.SucceededRows, repo.job.FailedRows})
+	assert.Equal(t, []*entity.ImportRowError{
+		{Row: 3, Field: "amount_of_employees", Code: "validation_failed", Message: "amount_of_employees must be a whole number"},
+	}, repo.rowErrors)
+	// The lease is extended after every row and released at the end.
+	require.Len(t, repo.leases, 3)
+	assert.NotNil(t, repo.leases[0])
+	assert.Nil(t, repo.leases[2])
+
+	processed, err = useCase.ProcessNext(context.Background())
+	require.NoError(t, err)
+	assert.False(t, processed)
+}
+
+func TestImportReportsRejectedRowsAndFindsEarlierImports(t *testing.T) {
+	repo, companies, useCase := newImportFixture(t, importCSV)
+	companies.errs["Alpha"] = customError.New(customError.CodeCompanyExists, "Company with this name or ID already exists")
+	companies.errs["Gamma"] = customError.New(customError.CodeCompanyExists, "Company with this name or ID already exists")
+	// Gamma was created before the worker stopped without saving progress.
+	companies.revisions[repo.job.CompanyID(4)] = []*entity.CompanyRevision{
+		{RequestID: importRequestID(repo.job, 4)},
+	}
+
+	_, err := useCase.ProcessNext(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, entity.ImportCompleted, repo.job.Status)
+	assert.Equal(t, 1, repo.job.SucceededRows)
+	assert.Equal(t, 2, repo.job.FailedRows)
+	require.Len(t, repo.rowErrors, 2)
+	assert.Equal(t, &entity.ImportRowError{Row: 2, Code: "company_exists", Message: "Company with this name or ID already exists"}, repo.rowErrors[0])
+}
+
+func TestImportFailsWithoutSkippingRows(t *testing.T) {
+	repo, _, useCase := newImportFixture(t, importCSV)
+	useCase.companies.(*fakeCompanies).errs["Alpha"] = customError.NewInternalServerError("Failed to create company")
+
+	_, err := useCase.ProcessNext(context.Background())
+	assert.Error(t, err)
+
+	assert.Equal(t, entity.ImportFailed, repo.job.Status)
+	assert.Equal(t, "Failed to create company", repo.job.Error)
+	assert.Equal(t, 0, repo.job.ProcessedRows)
+	assert.Empty(t, repo.rowErrors)
+	assert.Nil(t, repo.leases[len(repo.leases)-1])
+}
+
+func TestImportChecksTheUploaderWhenProcessing(t *testing.T) {
+	for name, revoke := range map[string]func(u *importUseCase, job *entity.ImportJob){
+		"disabled": func(u *importUseCase, job *entity.ImportJob) {
+			now := time.Now()
+			u.users.(*fakeUserRepo).users["uploader"].DisabledAt = &now
+		},
+		"removed from the organization": func(u *importUseCase, job *entity.ImportJob) {
+			u.organizations.(*fakeMembers).members[*job.CreatedBy] = false
+		},
+		"without companies:create": func(u *importUseCase, job *entity.ImportJob) {
+			u.roles.(*fakeImportRoles).permissions = []string{entity.PermissionCompaniesRead}
+		},
+		"with a revoked API key": func(u *importUseCase, job *entity.ImportJob) {
+			now := time.Now()
+			key := &entity.APIKey{ID: uuid.New(), OrganizationID: job.OrganizationID, Scopes: []string{entity.ScopeCompaniesWrite}, RevokedAt: &now}
+			u.apiKeys.(*fakeAPIKeys).keys = []*entity.APIKey{key}
+			job.CreatedByAPIKey = &key.ID
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			repo, companies, useCase := newImportFixture(t, importCSV)
+			revoke(useCase, repo.job)
+
+			_, err := useCase.ProcessNext(context.Background())
+			assert.True(t, hasCode(err, customError.CodePermissionDenied))
+			assert.Empty(t, companies.created)
+			assert.Equal(t, entity.ImportFailed, repo.job.Status)
+			assert.Equal(t, 0, repo.job.ProcessedRows)
+		})
+	}
+
+	repo, companies, useCase := newImportFixture(t, importCSV)
+	key := &entity.APIKey{ID: uuid.New(), OrganizationID: repo.job.OrganizationID, Scopes: []string{entity.ScopeCompaniesWrite}}
+	useCase.apiKeys.(*fakeAPIKeys).keys = []*entity.APIKey{key}
+	repo.job.CreatedByAPIKey = &key.ID
+	useCase.roles.(*fakeImportRoles).permissions = nil
+
+	_, err := useCase.ProcessNext(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, companies.created, 2)
+}
+
+func TestImportDryRun(t *testing.T) {
+	repo, companies, useCase := newImportFixture(t, importCSV+"Alpha,4,yes,Cooperative\nDelta,2,no,NonProfit\n")
+	repo.job.Status = entity.ImportUploaded
+	ctx := tenant.WithOrganization(context.Background(), entity.DefaultOrganizationID)
+
+	report, err := useCase.DryRun(ctx, repo.job.ID, nil)
+	require.NoError(t, err)
+	assert.Empty(t, companies.created)
+	assert.Equal(t, 5, report.TotalRows)
+	// Delta is valid although it is not registered.
+	assert.Equal(t, 3, report.ValidRows)
+	assert.Equal(t, 2, report.InvalidRows)
+	require.Len(t, report.Errors, 2)
+	assert.Equal(t, "amount_of_employees", report.Errors[0].Field)
+	assert.Equal(t, &entity.ImportRowError{Row: 5, Field: "name", Code: "validation_failed", Message: "Row 2 has a company with the same name"}, report.Errors[1])
+
+	_, err = useCase.DryRun(ctx, repo.job.ID, entity.ImportMapping{"name": "name", "employees": "employees", "type": "kind"})
+	var appErr *customError.AppError
+	require.ErrorAs(t, err, &appErr)
+	assert.Equal(t, customError.CodeInvalidImportMapping, appErr.Code)
+	var fields []string
+	for _, fieldErr := range appErr.Errors {
+		fields = append(fields, fieldErr.Field+":"+fieldErr.Constraint)
+	}
+	assert.Equal(t, []string{"type:column", "employees:unknown", "amount_of_employees:required", "registered:required"}, fields)
+}
+
+func TestImportRunKeepsMappingOfStartedJobs(t *testing.T) {
+	repo, _, useCase := newImportFixture(t, importCSV)
+	repo.job.Status, repo.job.ProcessedRows = entity.ImportFailed, 1
+	ctx := tenant.WithOrganization(context.Background(), entity.DefaultOrganizationID)
+
+	mapping := entity.ImportMapping{"name": "type", "amount_of_employees": "employees", "registered": "registered", "type": "type"}
+	_, err := useCase.Run(ctx, repo.job.ID, mapping)
+	assert.True(t, hasCode(err, customError.CodeConflict))
+}
@@ -0,0 +1,133 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"github.com/assylzhan-a/company-task/internal/domain/entity"
+	r "github.com/assylzhan-a/company-task/internal/ports/repository"
+	uc "github.com/assylzhan-a/company-task/internal/ports/usecase"
+	"github.com/assylzhan-a/company-task/pkg/totp"
+	"github.com/google/uuid"
+	"strings"
+	"time"
+)
+
+type mfaUseCase struct {
+	mfaRepo  r.MFARepository
+	userRepo r.UserRepository
+	issuer   string
+}
+
+// NewMFAUseCase creates the MFA use case. The issuer names the service in
+// authenticator apps.
+func NewMFAUseCase(mfaRepo r.MFARepository, userRepo r.UserRepository, issuer string) uc.MFAUseCase {
+	return &mfaUseCase{
+		mfaRepo:  mfaRepo,
+		userRepo: userRepo,
+		issuer:   issuer,
+	}
+}
+
+func (u *mfaUseCase) Enroll(ctx context.Context, userID uuid.UUID) (*entity.MFAEnrollment, error) {
+	user, err := u.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	mfa, err := entity.NewUserMFA(user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate mfa secret: %w", err)
+	}
+	if err := u.mfaRepo.SaveEnrollment(ctx, mfa); err != nil {
+		return nil, err
+	}
+	return mfa.Enrollment(u.issuer, user.Username), nil
+}
+
+func (u *mfaUseCase) Confirm(ctx context.Context, userID uuid.UUID, code string) ([]string, error) {
+	mfa, err := u.mfaRepo.Get(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if mfa.Enabled {
+		return nil, entity.ErrMFAAlreadyEnabled
+	}
+
+	now := time.Now()
+	step, ok := mfa.VerifyCode(code, now)
+	if !ok {
+		return nil, entity.ErrInvalidMFACode
+	}
+
+	codes, raws, err := entity.NewRecoveryCodes(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate recovery codes: %w", err)
+	}
+	if err := u.mfaRepo.Enable(ctx, userID, now, step, codes); err != nil {
+		return nil, err
+	}
+	return raws, nil
+}
+
+func (u *mfaUseCase) Disable(ctx context.Context, userID uuid.UUID, code string) error {
+	if err := u.verifyEnabled(ctx, userID, code); err != nil {
+		return err
+	}
+	return u.mfaRepo.Delete(ctx, userID)
+}
+
+func (u *mfaUseCase) RegenerateRecoveryCodes(ctx context.Context, userID uuid.UUID, code string) ([]string, error) {
+	if err := u.verifyEnabled(ctx, userID, code); err != nil {
+		return nil, err
+	}
+
+	codes, raws, err := entity.NewRecoveryCodes(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate recovery codes: %w", err)
+	}
+	if err := u.mfaRepo.ReplaceRecoveryCodes(ctx, userID, codes); err != nil {
+		return nil, err
+	}
+	return raws, nil
+}
+
+func (u *mfaUseCase) verifyEnabled(ctx context.Context, userID uuid.UUID, code string) error {
+	mfa, err := u.mfaRepo.Get(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !mfa.Enabled {
+		return entity.ErrMFANotEnrolled
+	}
+	return verifySecondFactor(ctx, u.mfaRepo, mfa, code, time.Now())
+}
+
+// verifySecondFactor accepts either a TOTP code or an unused recovery code
+// and consumes it, so neither can be used twice.
+func verifySecondFactor(ctx context.Context, repo r.MFARepository, mfa *entity.UserMFA, code string, now time.Time) error {
+	code = strings.TrimSpace(code)
+
+	if len(code) != totp.Digits {
+		used, err := repo.UseRecoveryCode(ctx, mfa.UserID, entity.HashRecoveryCode(code))
+		if err != nil {
+			return err
+		}
+		if !used {
+			return entity.ErrInvalidMFACode
+		}
+		return nil
+	}
+
+	step, ok := mfa.VerifyCode(code, now)
+	if !ok {
+		return entity.ErrInvalidMFACode
+	}
+	used, err := repo.UseStep(ctx, mfa.UserID, step)
+	if err != nil {
+		return err
+	}
+	if !used {
+		return entity.ErrInvalidMFACode
+	}
+	return nil
+}
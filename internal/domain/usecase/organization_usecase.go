@@ -0,0 +1,47 @@
+package usecase
+
+import (
+	"context"
+	"github.com/assylzhan-a/company-task/internal/domain/entity"
+	r "github.com/assylzhan-a/company-task/internal/ports/repository"
+	uc "github.com/assylzhan-a/company-task/internal/ports/usecase"
+	"github.com/google/uuid"
+)
+
+type organizationUseCase struct {
+	repo r.OrganizationRepository
+}
+
+func NewOrganizationUseCase(repo r.OrganizationRepository) uc.OrganizationUseCase {
+	return &organizationUseCase{repo: repo}
+}
+
+func (u *organizationUseCase) Create(ctx context.Context, name string) (*entity.Organization, error) {
+	organization, err := entity.NewOrganization(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := u.repo.Create(ctx, organization); err != nil {
+		return nil, err
+	}
+	return organization, nil
+}
+
+func (u *organizationUseCase) List(ctx context.Context) ([]*entity.Organization, error) {
+	return u.repo.List(ctx)
+}
+
+func (u *organizationUseCase) ListForUser(ctx context.Context, userID uuid.UUID) ([]*entity.Organization, error) {
+	return u.repo.ListByUser(ctx, userID)
+}
+
+func (u *organizationUseCase) AddMember(ctx context.Context, organizationID, userID uuid.UUID) error {
+	return u.repo.AddMember(ctx, organizationID, userID)
+}
+
+// RemoveMember removes the user from the organization. Sessions scoped to it
+// end at the next refresh.
+func (u *organizationUseCase) RemoveMember(ctx context.Context, organizationID, userID uuid.UUID) error {
+	return u.repo.RemoveMember(ctx, organizationID, userID)
+}
@@ -0,0 +1,80 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"github.com/assylzhan-a/company-task/internal/domain/entity"
+	r "github.com/assylzhan-a/company-task/internal/ports/repository"
+	uc "github.com/assylzhan-a/company-task/internal/ports/usecase"
+	"github.com/google/uuid"
+)
+
+type roleUseCase struct {
+	roleRepo  r.RoleRepository
+	userRepo  r.UserRepository
+	eventRepo r.SecurityEventRepository
+}
+
+func NewRoleUseCase(roleRepo r.RoleRepository, userRepo r.UserRepository, eventRepo r.SecurityEventRepository) uc.RoleUseCase {
+	return &roleUseCase{
+		roleRepo:  roleRepo,
+		userRepo:  userRepo,
+		eventRepo: eventRepo,
+	}
+}
+
+func (u *roleUseCase) ListRoles(ctx context.Context) ([]*entity.Role, error) {
+	return u.roleRepo.List(ctx)
+}
+
+func (u *roleUseCase) GetUserRoles(ctx context.Context, userID uuid.UUID) ([]string, error) {
+	if _, err := u.userRepo.GetByID(ctx, userID); err != nil {
+		return nil, err
+	}
+	roles, _, err := u.roleRepo.GetUserAccess(ctx, userID)
+	return roles, err
+}
+
+func (u *roleUseCase) AssignRole(ctx context.Context, actorID, userID uuid.UUID, role string) error {
+	if err := u.roleRepo.AssignRole(ctx, userID, role); err != nil {
+		return err
+	}
+	return u.recordRoleChange(ctx, entity.SecurityEventRoleAssigned, actorID, userID, role)
+}
+
+func (u *roleUseCase) RevokeRole(ctx context.Context, actorID, userID uuid.UUID, role string) error {
+	if err := u.roleRepo.RevokeRole(ctx, userID, role); err != nil {
+		return err
+	}
+	return u.recordRoleChange(ctx, entity.SecurityEventRoleRevoked, actorID, userID, role)
+}
+
+func (u *roleUseCase) BootstrapAdmin(ctx context.Context, username string) error {
+	user, err := u.userRepo.GetByUsername(ctx, username)
+	if err != nil {
+		return err
+	}
+	roles, _, err := u.roleRepo.GetUserAccess(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	for _, role := range roles {
+		if role == entity.RoleAdmin {
+			return nil
+		}
+	}
+	if err := u.roleRepo.AssignRole(ctx, user.ID, entity.RoleAdmin); err != nil {
+		return err
+	}
+	reason := fmt.Sprintf("role %s by bootstrap", entity.RoleAdmin)
+	return u.eventRepo.Create(ctx, entity.NewSecurityEvent(entity.SecurityEventRoleAssigned, user.Username, "", &user.ID, reason))
+}
+
+func (u *roleUseCase) recordRoleChange(ctx context.Context, eventType string, actorID, userID uuid.UUID, role string) error {
+	user, err := u.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	reason := fmt.Sprintf("role %s by %s", role, actorID)
+	return u.eventRepo.Create(ctx, entity.NewSecurityEvent(eventType, user.Username, "", &user.ID, reason))
+}
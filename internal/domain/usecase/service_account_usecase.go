@@ -0,0 +1,101 @@
+package usecase
+
+import (
+	"context"
+	"github.com/assylzhan-a/company-task/internal/auth"
+	"github.com/assylzhan-a/company-task/internal/domain/entity"
+	r "github.com/assylzhan-a/company-task/internal/ports/repository"
+	uc "github.com/assylzhan-a/company-task/internal/ports/usecase"
+	"github.com/assylzhan-a/company-task/internal/tenant"
+	customError "github.com/assylzhan-a/company-task/pkg/errors"
+	"github.com/google/uuid"
+	"time"
+)
+
+type serviceAccountUseCase struct {
+	repo r.APIKeyRepository
+}
+
+func NewServiceAccountUseCase(repo r.APIKeyRepository) uc.ServiceAccountUseCase {
+	return &serviceAccountUseCase{repo: repo}
+}
+
+func (u *serviceAccountUseCase) Create(ctx context.Context, name string) (*entity.ServiceAccount, error) {
+	organizationID, ok := tenant.OrganizationFromContext(ctx)
+	if !ok {
+		return nil, customError.New(customError.CodeNoOrganization, "No active organization")
+	}
+
+	var createdBy *uuid.UUID
+	if principal, ok := auth.PrincipalFromContext(ctx); ok {
+		userID := principal.UserID
+		createdBy = &userID
+	}
+
+	account, err := entity.NewServiceAccount(organizationID, name, createdBy)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := u.repo.CreateServiceAccount(ctx, account); err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+func (u *serviceAccountUseCase) List(ctx context.Context) ([]*entity.ServiceAccount, error) {
+	organizationID, ok := tenant.OrganizationFromContext(ctx)
+	if !ok {
+		return nil, customError.New(customError.CodeNoOrganization, "No active organization")
+	}
+	return u.repo.ListServiceAccounts(ctx, organizationID)
+}
+
+func (u *serviceAccountUseCase) CreateAPIKey(ctx context.Context, serviceAccountID uuid.UUID, scopes []string, expiresAt *time.Time) (*entity.APIKey, string, error) {
+	account, err := u.getServiceAccount(ctx, serviceAccountID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	key, raw, err := entity.NewAPIKey(account, scopes, expiresAt)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if err := u.repo.CreateAPIKey(ctx, key); err != nil {
+		return nil, "", err
+	}
+	return key, raw, nil
+}
+
+func (u *serviceAccountUseCase) ListAPIKeys(ctx context.Context, serviceAccountID uuid.UUID) ([]*entity.APIKey, error) {
+	if _, err := u.getServiceAccount(ctx, serviceAccountID); err != nil {
+		return nil, err
+	}
+	return u.repo.ListAPIKeys(ctx, serviceAccountID)
+}
+
+func (u *serviceAccountUseCase) RevokeAPIKey(ctx context.Context, serviceAccountID, keyID uuid.UUID) error {
+	if _, err := u.getServiceAccount(ctx, serviceAccountID); err != nil {
+		return err
+	}
+	return u.repo.RevokeAPIKey(ctx, serviceAccountID, keyID)
+}
+
+// getServiceAccount loads the account and hides accounts of other
+// organizations behind ErrServiceAccountNotFound.
+func (u *serviceAccountUseCase) getServiceAccount(ctx context.Context, id uuid.UUID) (*entity.ServiceAccount, error) {
+	organizationID, ok := tenant.OrganizationFromContext(ctx)
+	if !ok {
+		return nil, customError.New(customError.CodeNoOrganization, "No active organization")
+	}
+
+	account, err := u.repo.GetServiceAccount(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if account.OrganizationID != organizationID {
+		return nil, entity.ErrServiceAccountNotFound
+	}
+	return account, nil
+}
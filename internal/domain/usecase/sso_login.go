@@ -0,0 +1,177 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/assylzhan-a/company-task/internal/domain/entity"
+	"github.com/assylzhan-a/company-task/internal/oidc"
+	"github.com/google/uuid"
+)
+
+// StartSSOLogin begins an authorization code login with PKCE at the identity
+// provider. The state has to be presented with the code on the callback.
+func (u *userUseCase) StartSSOLogin(ctx context.Context) (*entity.SSORedirect, error) {
+	if u.sso == nil {
+		return nil, entity.ErrSSODisabled
+	}
+
+	state, err := entity.NewSSOLoginState(u.cfg.SSO.LoginTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate sso login state: %w", err)
+	}
+	if err := u.ssoStateRepo.Create(ctx, state); err != nil {
+		return nil, err
+	}
+
+	authURL, err := u.sso.AuthCodeURL(ctx, state.State, state.Nonce, oidc.CodeChallenge(state.CodeVerifier))
+	if err != nil {
+		return nil, err
+	}
+	return &entity.SSORedirect{URL: authURL, State: state.State, ExpiresAt: state.ExpiresAt}, nil
+}
+
+// CompleteSSOLogin exchanges the authorization code and starts a session for
+// the user linked to the ID token's subject. Unknown subjects are provisioned
+// as new users; the roles of the role mapping follow the role claim on every
+// login. Users with MFA enabled get a challenge, as with a password login.
+func (u *userUseCase) CompleteSSOLogin(ctx context.Context, state, code, clientIP string) (*entity.LoginResult, error) {
+	if u.sso == nil {
+		return nil, entity.ErrSSODisabled
+	}
+
+	loginState, err := u.ssoStateRepo.Consume(ctx, state, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	idToken, err := u.sso.Exchange(ctx, code, loginState.CodeVerifier, loginState.Nonce)
+	if err != nil {
+		if err := u.recordEvent(ctx, entity.SecurityEventLoginFailed, "", clientIP, nil, "sso: "+err.Error()); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: %v", entity.ErrSSOLoginFailed, err)
+	}
+
+	user, err := u.userRepo.GetByIdentity(ctx, idToken.Issuer, idToken.Subject)
+	switch {
+	case errors.Is(err, entity.ErrUserNotFound):
+		user, err = u.provisionSSOUser(ctx, idToken, clientIP)
+		if err != nil {
+			return nil, err
+		}
+	case err != nil:
+		return nil, err
+	default:
+		if err := u.syncSSORoles(ctx, user, idToken); err != nil {
+			return nil, err
+		}
+	}
+
+	// Email verification is left to the identity provider. MFA is not, since
+	// sessions of users with MFA enabled are trusted to have passed it.
+	if user.IsDisabled() {
+		return nil, entity.ErrUserDisabled
+	}
+	mfaEnabled, err := u.mfaEnabled(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if mfaEnabled {
+		return u.issueMFAChallenge(ctx, user, clientIP, nil)
+	}
+
+	tokens, err := u.completeLogin(ctx, user, clientIP, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &entity.LoginResult{Tokens: tokens}, nil
+}
+
+// provisionSSOUser creates a user for an identity seen for the first time.
+// Users cannot log in with a password until they set one through a reset.
+func (u *userUseCase) provisionSSOUser(ctx context.Context, idToken *oidc.IDToken, clientIP string) (*entity.User, error) {
+	username := idToken.String(u.cfg.SSO.UsernameClaim)
+	if username == "" {
+		username = idToken.String("email")
+	}
+	if username == "" {
+		username = idToken.Subject
+	}
+
+	user := &entity.User{
+		ID:        uuid.New(),
+		Username:  username,
+		CreatedAt: time.Now(),
+		Identities: []entity.UserIdentity{{
+			Issuer:    idToken.Issuer,
+			Subject:   idToken.Subject,
+			CreatedAt: time.Now(),
+		}},
+	}
+	if email := idToken.String("email"); email != "" && user.SetEmail(email) == nil {
+		user.EmailVerified = idToken.Bool("email_verified")
+	}
+	u.applyDefaults(user)
+	for _, role := range u.cfg.SSO.RoleMapping.Roles(idToken.Strings(u.cfg.SSO.RoleClaim)) {
+		if !slices.Contains(user.Roles, role) {
+			user.Roles = append(user.Roles, role)
+		}
+	}
+
+	if err := u.userRepo.Create(ctx, user); err != nil {
+		// An existing local account is not linked automatically, since the
+		// provider may not have verified that the person owns it.
+		if errors.Is(err, entity.ErrUsernameTaken) || errors.Is(err, entity.ErrEmailTaken) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to provision user: %w", err)
+	}
+
+	reason := fmt.Sprintf("sso subject %s of %s", idToken.Subject, idToken.Issuer)
+	if err := u.recordEvent(ctx, entity.SecurityEventUserProvisioned, user.Username, clientIP, &user.ID, reason); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// syncSSORoles grants and revokes the roles managed by the role mapping so
+// that they match the role claim.
+func (u *userUseCase) syncSSORoles(ctx context.Context, user *entity.User, idToken *oidc.IDToken) error {
+	mapping := u.cfg.SSO.RoleMapping
+	if len(mapping) == 0 {
+		return nil
+	}
+
+	current, _, err := u.roleRepo.GetUserAccess(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	granted := mapping.Roles(idToken.Strings(u.cfg.SSO.RoleClaim))
+
+	for _, role := range mapping.ManagedRoles() {
+		want, has := slices.Contains(granted, role), slices.Contains(current, role)
+		switch {
+		case want && !has:
+			if err := u.roleRepo.AssignRole(ctx, user.ID, role); err != nil {
+				return fmt.Errorf("failed to assign role %q: %w", role, err)
+			}
+			if err := u.recordEvent(ctx, entity.SecurityEventRoleAssigned, user.Username, "", &user.ID,
+				fmt.Sprintf("role %s by single sign-on", role)); err != nil {
+				return err
+			}
+		case !want && has:
+			if err := u.roleRepo.RevokeRole(ctx, user.ID, role); err != nil {
+				return fmt.Errorf("failed to revoke role %q: %w", role, err)
+			}
+			if err := u.recordEvent(ctx, entity.SecurityEventRoleRevoked, user.Username, "", &user.ID,
+				fmt.Sprintf("role %s by single sign-on", role)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
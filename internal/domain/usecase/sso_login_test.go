@@ -0,0 +1,161 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/assylzhan-a/company-task/internal/domain/entity"
+	"github.com/assylzhan-a/company-task/internal/oidc"
+	"github.com/assylzhan-a/company-task/internal/oidc/oidctest"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newSSOFixture(t *testing.T, cfg UserUseCaseConfig) (*userUseCaseFixture, *oidctest.Provider) {
+	t.Helper()
+
+	provider := oidctest.NewProvider("company-service", "secret")
+	t.Cleanup(provider.Close)
+	client := oidc.NewClient(oidc.Config{
+		IssuerURL:    provider.Issuer(),
+		ClientID:     provider.ClientID,
+		ClientSecret: provider.ClientSecret,
+		RedirectURL:  "https://app.example.com/v1/users/sso/callback",
+	}, nil)
+
+	mapping, err := entity.ParseRoleMapping("engineering=editor,it=admin,it=editor")
+	require.NoError(t, err)
+	cfg.SSO = SSOConfig{
+		UsernameClaim: "preferred_username",
+		RoleClaim:     "groups",
+		RoleMapping:   mapping,
+		LoginTimeout:  time.Minute,
+	}
+	cfg.DefaultRole = entity.RoleViewer
+	return newUserUseCaseFixtureWithConfig(t, client, cfg), provider
+}
+
+// ssoLogin logs in at the provider and returns the callback parameters.
+func ssoLogin(t *testing.T, f *userUseCaseFixture, provider *oidctest.Provider) url.Values {
+	t.Helper()
+
+	redirect, err := f.useCase.StartSSOLogin(context.Background())
+	require.NoError(t, err)
+	callback, err := provider.Authorize(redirect.URL)
+	require.NoError(t, err)
+	return callback.Query()
+}
+
+func TestSSOLoginProvisionsUserAndSyncsRoles(t *testing.T) {
+	f, provider := newSSOFixture(t, UserUseCaseConfig{})
+	ctx := context.Background()
+
+	provider.SetClaims(map[string]interface{}{
+		"sub":                "employee-7",
+		"preferred_username": "jdoe",
+		"email":              "JDoe@Example.com",
+		"email_verified":     true,
+		"groups":             []string{"engineering", "sales"},
+	})
+	callback := ssoLogin(t, f, provider)
+	result, err := f.useCase.CompleteSSOLogin(ctx, callback.Get("state"), callback.Get("code"), "192.0.2.1")
+	require.NoError(t, err)
+	assert.NotEmpty(t, result.Tokens.AccessToken)
+
+	user := f.users.users["jdoe"]
+	require.NotNil(t, user)
+	assert.Equal(t, "jdoe@example.com", user.Email)
+	assert.True(t, user.EmailVerified)
+	assert.Empty(t, user.Password)
+	assert.ElementsMatch(t, []string{entity.RoleViewer, entity.RoleEditor}, user.Roles)
+	assert.Contains(t, f.events.types(), entity.SecurityEventUserProvisioned)
+
+	// The state is single-use.
+	_, err = f.useCase.CompleteSSOLogin(ctx, callback.Get("state"), callback.Get("code"), "192.0.2.1")
+	assert.Equal(t, entity.ErrInvalidSSOState, err)
+
+	// Managed roles follow the groups; the default role is left alone.
+	provider.SetClaims(map[string]interface{}{"sub": "employee-7", "preferred_username": "renamed", "groups": "it"})
+	callback = ssoLogin(t, f, provider)
+	_, err = f.useCase.CompleteSSOLogin(ctx, callback.Get("state"), callback.Get("code"), "192.0.2.1")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{entity.RoleViewer, entity.RoleEditor, entity.RoleAdmin}, user.Roles)
+	assert.Len(t, f.users.users, 2, "the subject, not the username, identifies the user")
+
+	provider.SetClaims(map[string]interface{}{"sub": "employee-7"})
+	callback = ssoLogin(t, f, provider)
+	_, err = f.useCase.CompleteSSOLogin(ctx, callback.Get("state"), callback.Get("code"), "192.0.2.1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{entity.RoleViewer}, user.Roles)
+}
+
+func TestSSOLoginRequiresMFA(t *testing.T) {
+	f, provider := newSSOFixture(t, UserUseCaseConfig{})
+	ctx := context.Background()
+
+	provider.SetClaims(map[string]interface{}{"sub": "employee-8", "preferred_username": "mfauser"})
+	callback := ssoLogin(t, f, provider)
+	_, err := f.useCase.CompleteSSOLogin(ctx, callback.Get("state"), callback.Get("code"), "192.0.2.1")
+	require.NoError(t, err)
+
+	user := f.users.users["mfauser"]
+	mfa, err := entity.NewUserMFA(user.ID)
+	require.NoError(t, err)
+	mfa.Enabled = true
+	f.mfa.enrollments[user.ID] = mfa
+	f.mfa.recoveryCodes[entity.HashRecoveryCode("abcd-efgh")] = true
+
+	callback = ssoLogin(t, f, provider)
+	result, err := f.useCase.CompleteSSOLogin(ctx, callback.Get("state"), callback.Get("code"), "192.0.2.1")
+	require.NoError(t, err)
+	assert.Nil(t, result.Tokens)
+	require.NotNil(t, result.Challenge)
+
+	tokens, err := f.useCase.VerifyMFA(ctx, result.Challenge.MFAToken, "abcd-efgh", "192.0.2.1")
+	require.NoError(t, err)
+	assert.NotEmpty(t, tokens.AccessToken)
+}
+
+func TestSSOLoginDoesNotTakeOverLocalAccounts(t *testing.T) {
+	f, provider := newSSOFixture(t, UserUseCaseConfig{})
+
+	provider.SetClaims(map[string]interface{}{"sub": "someone-else", "preferred_username": "alice"})
+	callback := ssoLogin(t, f, provider)
+	_, err := f.useCase.CompleteSSOLogin(context.Background(), callback.Get("state"), callback.Get("code"), "192.0.2.1")
+	assert.Equal(t, entity.ErrUsernameTaken, err)
+}
+
+func TestSSOLoginRejectsInvalidIDToken(t *testing.T) {
+	f, provider := newSSOFixture(t, UserUseCaseConfig{})
+	provider.ModifyIDToken = func(claims jwt.MapClaims) { claims["aud"] = "another-client" }
+
+	callback := ssoLogin(t, f, provider)
+	_, err := f.useCase.CompleteSSOLogin(context.Background(), callback.Get("state"), callback.Get("code"), "192.0.2.1")
+	assert.True(t, errors.Is(err, entity.ErrSSOLoginFailed))
+	assert.Equal(t, entity.SecurityEventLoginFailed, f.events.events[len(f.events.events)-1].Type)
+}
+
+func TestLocalLoginCanBeDisabled(t *testing.T) {
+	f, provider := newSSOFixture(t, UserUseCaseConfig{LocalLoginDisabled: true})
+	ctx := context.Background()
+
+	assert.Equal(t, entity.ErrLocalLoginDisabled, f.useCase.Register(ctx, "bob", "correct horse battery", ""))
+	_, err := f.useCase.Login(ctx, "bob", "correct horse battery", "192.0.2.1", nil)
+	assert.Equal(t, entity.ErrLocalLoginDisabled, err)
+	assert.Equal(t, entity.ErrLocalLoginDisabled, f.useCase.RequestPasswordReset(ctx, "bob@example.com", "192.0.2.1"))
+
+	callback := ssoLogin(t, f, provider)
+	_, err = f.useCase.CompleteSSOLogin(ctx, callback.Get("state"), callback.Get("code"), "192.0.2.1")
+	assert.NoError(t, err)
+}
+
+func TestSSOLoginRequiresProvider(t *testing.T) {
+	f := newUserUseCaseFixture(t, entity.LockoutPolicy{})
+
+	_, err := f.useCase.StartSSOLogin(context.Background())
+	assert.Equal(t, entity.ErrSSODisabled, err)
+}
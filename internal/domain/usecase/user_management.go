@@ -0,0 +1,154 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/assylzhan-a/company-task/internal/domain/entity"
+	"github.com/google/uuid"
+)
+
+// GetProfile returns the user with the current roles, permissions and
+// organizations.
+func (u *userUseCase) GetProfile(ctx context.Context, userID uuid.UUID) (*entity.UserProfile, error) {
+	user, err := u.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	roles, permissions, err := u.roleRepo.GetUserAccess(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	user.Roles = roles
+	user.Permissions = permissions
+
+	organizations, err := u.organizationRepo.ListByUser(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &entity.UserProfile{User: user, Organizations: organizations}, nil
+}
+
+// ChangePassword sets a new password after checking the current one. All
+// sessions of the user end, and a new one is started in the given
+// organization so that the caller stays logged in.
+func (u *userUseCase) ChangePassword(ctx context.Context, userID uuid.UUID, organizationID *uuid.UUID, currentPassword, newPassword, clientIP string) (*entity.TokenPair, error) {
+	user, err := u.reauthenticate(ctx, userID, currentPassword, clientIP)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := user.SetPassword(newPassword, u.cfg.PasswordPolicy); err != nil {
+		return nil, err
+	}
+	if err := u.checkBreached(ctx, newPassword); err != nil {
+		return nil, err
+	}
+	if err := u.userRepo.UpdatePassword(ctx, user.ID, user.Password); err != nil {
+		return nil, err
+	}
+	if err := u.LogoutAll(ctx, user.ID); err != nil {
+		return nil, err
+	}
+	if err := u.recordEvent(ctx, entity.SecurityEventPasswordChanged, user.Username, clientIP, &user.ID, ""); err != nil {
+		return nil, err
+	}
+
+	activeOrganization, err := u.resolveOrganization(ctx, user.ID, organizationID)
+	if err != nil {
+		return nil, err
+	}
+	return u.startSession(ctx, user, activeOrganization)
+}
+
+// DeleteAccount deletes the user after checking the password. Companies the
+// user owns are kept without an owner.
+func (u *userUseCase) DeleteAccount(ctx context.Context, userID uuid.UUID, password, clientIP string) error {
+	user, err := u.reauthenticate(ctx, userID, password, clientIP)
+	if err != nil {
+		return err
+	}
+
+	if err := u.LogoutAll(ctx, user.ID); err != nil {
+		return err
+	}
+	if err := u.userRepo.Delete(ctx, user.ID); err != nil {
+		return err
+	}
+	// The event outlives the user, like the rest of its audit trail.
+	return u.recordEvent(ctx, entity.SecurityEventAccountDeleted, user.Username, clientIP, &user.ID, "")
+}
+
+func (u *userUseCase) ListUsers(ctx context.Context, filter entity.UserFilter) ([]*entity.User, error) {
+	return u.userRepo.List(ctx, filter)
+}
+
+// DisableUser prevents the user from logging in and ends all of its sessions.
+// Administrators cannot disable themselves, so that an instance is not left
+// without one by accident.
+func (u *userUseCase) DisableUser(ctx context.Context, actorID, userID uuid.UUID) error {
+	if actorID == userID {
+		return entity.ErrCannotModifySelf
+	}
+	user, err := u.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user.IsDisabled() {
+		return nil
+	}
+
+	now := time.Now()
+	if err := u.userRepo.SetDisabled(ctx, user.ID, &now); err != nil {
+		return err
+	}
+	if err := u.LogoutAll(ctx, user.ID); err != nil {
+		return err
+	}
+	return u.recordEvent(ctx, entity.SecurityEventUserDisabled, user.Username, "", &user.ID,
+		fmt.Sprintf("disabled by %s", actorID))
+}
+
+func (u *userUseCase) EnableUser(ctx context.Context, actorID, userID uuid.UUID) error {
+	user, err := u.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !user.IsDisabled() {
+		return nil
+	}
+
+	if err := u.userRepo.SetDisabled(ctx, user.ID, nil); err != nil {
+		return err
+	}
+	return u.recordEvent(ctx, entity.SecurityEventUserEnabled, user.Username, "", &user.ID,
+		fmt.Sprintf("enabled by %s", actorID))
+}
+
+// reauthenticate checks the password of a logged in user before a sensitive
+// change. Wrong passwords count as failed logins, so a stolen access token
+// cannot be used to guess the password.
+func (u *userUseCase) reauthenticate(ctx context.Context, userID uuid.UUID, password, clientIP string) (*entity.User, error) {
+	user, err := u.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.Password == "" {
+		// Users provisioned by single sign-on have no password to confirm.
+		return nil, entity.ErrPasswordNotSet
+	}
+
+	now := time.Now()
+	throttles, err := u.acquireThrottles(ctx, user.Username, clientIP, now)
+	if err != nil {
+		return nil, err
+	}
+	if err := user.ComparePassword(password); err != nil {
+		return nil, u.loginFailed(ctx, throttles, user.Username, clientIP, &user.ID, "invalid password", entity.ErrInvalidCredentials, now)
+	}
+	if err := u.releaseThrottles(ctx, throttles); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
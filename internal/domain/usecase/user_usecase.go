@@ -4,54 +4,604 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"github.com/assylzhan-a/company-task/config"
+	"github.com/assylzhan-a/company-task/internal/auth"
+	"github.com/assylzhan-a/company-task/internal/breach"
 	"github.com/assylzhan-a/company-task/internal/domain/entity"
+	"github.com/assylzhan-a/company-task/internal/mailer"
+	"github.com/assylzhan-a/company-task/internal/oidc"
 	r "github.com/assylzhan-a/company-task/internal/ports/repository"
 	uc "github.com/assylzhan-a/company-task/internal/ports/usecase"
-	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+	"slices"
 	"time"
 )
 
+// UserUseCaseConfig holds the settings of the user use case.
+type UserUseCaseConfig struct {
+	RefreshTokenTTL time.Duration
+	// DefaultRole is assigned to every newly registered user.
+	DefaultRole string
+	// DefaultOrganizationID is the organization newly registered users join.
+	DefaultOrganizationID uuid.UUID
+	PasswordPolicy        entity.PasswordPolicy
+	LockoutPolicy         entity.LockoutPolicy
+	// MFAChallengeTTL is how long users with MFA have to enter their code
+	// after the password.
+	MFAChallengeTTL time.Duration
+	// MFARequiredPermissions are only granted to sessions of users with MFA
+	// enabled. Since such users always pass the second factor to log in,
+	// every session holding these permissions did.
+	MFARequiredPermissions []string
+	// RequireEmailVerification makes an email mandatory on registration and
+	// refuses logins until it is verified.
+	RequireEmailVerification bool
+	EmailVerificationTTL     time.Duration
+	PasswordResetTTL         time.Duration
+	// PasswordResetThrottle limits reset requests per email address and
+	// client IP.
+	PasswordResetThrottle entity.LockoutPolicy
+	// BaseURL is the address of the frontend that the links in emails
+	// point to.
+	BaseURL string
+	// LocalLoginDisabled turns off registration, password login and password
+	// reset, leaving single sign-on as the only way to log in.
+	LocalLoginDisabled bool
+	SSO                SSOConfig
+}
+
+// SSOConfig holds the settings of single sign-on.
+type SSOConfig struct {
+	// UsernameClaim is the ID token claim used as the username of provisioned
+	// users. The email and then the subject are used if it is missing.
+	UsernameClaim string
+	// RoleClaim names the claim, such as groups, whose values RoleMapping
+	// maps to roles.
+	RoleClaim   string
+	RoleMapping entity.RoleMapping
+	// LoginTimeout is how long users have to complete the login at the
+	// identity provider.
+	LoginTimeout time.Duration
+}
+
 type userUseCase struct {
-	userRepo r.UserRepository
+	userRepo         r.UserRepository
+	tokenRepo        r.TokenRepository
+	roleRepo         r.RoleRepository
+	organizationRepo r.OrganizationRepository
+	throttleRepo     r.LoginThrottleRepository
+	eventRepo        r.SecurityEventRepository
+	mfaRepo          r.MFARepository
+	mailer           mailer.Mailer
+	breaches         breach.Checker
+	ssoStateRepo     r.SSOStateRepository
+	sso              oidc.Provider
+	tokens           *auth.TokenService
+	denylist         *auth.Denylist
+	cfg              UserUseCaseConfig
 }
 
-func NewUserUseCase(userRepo r.UserRepository) uc.UserUseCase {
+func NewUserUseCase(userRepo r.UserRepository, tokenRepo r.TokenRepository, roleRepo r.RoleRepository, organizationRepo r.OrganizationRepository, throttleRepo r.LoginThrottleRepository, eventRepo r.SecurityEventRepository, mfaRepo r.MFARepository, mailer mailer.Mailer, breaches breach.Checker, ssoStateRepo r.SSOStateRepository, sso oidc.Provider, tokens *auth.TokenService, denylist *auth.Denylist, cfg UserUseCaseConfig) uc.UserUseCase {
 	return &userUseCase{
-		userRepo: userRepo,
+		userRepo:         userRepo,
+		tokenRepo:        tokenRepo,
+		roleRepo:         roleRepo,
+		organizationRepo: organizationRepo,
+		throttleRepo:     throttleRepo,
+		eventRepo:        eventRepo,
+		mfaRepo:          mfaRepo,
+		mailer:           mailer,
+		breaches:         breaches,
+		ssoStateRepo:     ssoStateRepo,
+		sso:              sso,
+		tokens:           tokens,
+		denylist:         denylist,
+		cfg:              cfg,
 	}
 }
 
-func (u *userUseCase) Register(ctx context.Context, username, password string) error {
-	user, err := entity.NewUser(username, password)
+// Register creates the user. If an email is given, a verification link is
+// sent to it; failing to send it returns ErrVerificationEmailNotSent, but
+// the user is still created and can request another link.
+func (u *userUseCase) Register(ctx context.Context, username, password, email string) error {
+	if u.cfg.LocalLoginDisabled {
+		return entity.ErrLocalLoginDisabled
+	}
+
+	user, err := entity.NewUser(username, password, u.cfg.PasswordPolicy)
 	if err != nil {
 		return err
 	}
+	if err := u.checkBreached(ctx, password); err != nil {
+		return err
+	}
+
+	if email != "" {
+		if err := user.SetEmail(email); err != nil {
+			return err
+		}
+	} else if u.cfg.RequireEmailVerification {
+		return entity.ErrEmailRequired
+	}
+
+	u.applyDefaults(user)
 
 	err = u.userRepo.Create(ctx, user)
 	if err != nil {
+		if errors.Is(err, entity.ErrEmailTaken) {
+			// Answering as if the user was created keeps registration from
+			// revealing which addresses are registered. The owner of the
+			// address is told instead.
+			if err := u.sendEmailTakenNotice(ctx, user); err != nil {
+				return fmt.Errorf("%w: %v", entity.ErrVerificationEmailNotSent, err)
+			}
+			return nil
+		}
 		if errors.Is(err, entity.ErrUsernameTaken) {
 			return err
 		}
 		return fmt.Errorf("failed to create user: %w", err)
 	}
+
+	if user.Email != "" {
+		if err := u.sendVerificationEmail(ctx, user); err != nil {
+			return fmt.Errorf("%w: %v", entity.ErrVerificationEmailNotSent, err)
+		}
+	}
 	return nil
 }
 
-func (u *userUseCase) Login(ctx context.Context, username, password string) (string, error) {
+// checkBreached rejects a password that has appeared in a breach. Breaches
+// are checked only if a checker is configured, and only after the rest of the
+// policy, so that passwords rejected anyway are not looked up.
+func (u *userUseCase) checkBreached(ctx context.Context, password string) error {
+	if u.breaches != nil && u.breaches.IsBreached(ctx, password) {
+		return u.cfg.PasswordPolicy.BreachedPasswordError()
+	}
+	return nil
+}
+
+// applyDefaults gives a new user the default role and organization.
+func (u *userUseCase) applyDefaults(user *entity.User) {
+	if u.cfg.DefaultRole != "" {
+		user.Roles = append(user.Roles, u.cfg.DefaultRole)
+	}
+	if u.cfg.DefaultOrganizationID != uuid.Nil {
+		user.OrganizationIDs = append(user.OrganizationIDs, u.cfg.DefaultOrganizationID)
+	}
+}
+
+// Login starts a new session. The session is scoped to the requested
+// organization or, if none is given, to the user's oldest membership.
+// Users with MFA enabled get a challenge instead, to be completed with
+// VerifyMFA. Repeated failures for the account or the client IP delay
+// further attempts and eventually lock them out for a while.
+func (u *userUseCase) Login(ctx context.Context, username, password, clientIP string, organizationID *uuid.UUID) (*entity.LoginResult, error) {
+	if u.cfg.LocalLoginDisabled {
+		return nil, entity.ErrLocalLoginDisabled
+	}
+
+	now := time.Now()
+	throttles, err := u.acquireThrottles(ctx, username, clientIP, now)
+	if err != nil {
+		return nil, err
+	}
+
 	user, err := u.userRepo.GetByUsername(ctx, username)
 	if err != nil {
-		return "", entity.ErrInvalidCredentials
+		return nil, u.loginFailed(ctx, throttles, username, clientIP, nil, "unknown user", entity.ErrInvalidCredentials, now)
 	}
 
 	if err := user.ComparePassword(password); err != nil {
-		return "", entity.ErrInvalidCredentials
+		return nil, u.loginFailed(ctx, throttles, username, clientIP, &user.ID, "invalid password", entity.ErrInvalidCredentials, now)
+	}
+	if err := u.releaseThrottles(ctx, throttles); err != nil {
+		return nil, err
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id": user.ID,
-		"exp":     time.Now().Add(time.Hour * 24).Unix(),
-	})
+	if user.IsDisabled() {
+		return nil, entity.ErrUserDisabled
+	}
+	if u.cfg.RequireEmailVerification && !user.EmailVerified {
+		return nil, entity.ErrEmailNotVerified
+	}
+
+	mfaEnabled, err := u.mfaEnabled(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if mfaEnabled {
+		// The account's failure counter is only reset once the second factor
+		// is verified, so knowing the password does not allow unlimited
+		// code guesses.
+		return u.issueMFAChallenge(ctx, user, clientIP, organizationID)
+	}
+
+	tokens, err := u.completeLogin(ctx, user, clientIP, organizationID)
+	if err != nil {
+		return nil, err
+	}
+	return &entity.LoginResult{Tokens: tokens}, nil
+}
+
+// VerifyMFA completes a login that was answered with an MFA challenge. The
+// code may be a TOTP code or one of the user's recovery codes.
+func (u *userUseCase) VerifyMFA(ctx context.Context, mfaToken, code, clientIP string) (*entity.TokenPair, error) {
+	claims, err := u.tokens.ParseMFAToken(mfaToken)
+	if err != nil {
+		return nil, entity.ErrInvalidMFAToken
+	}
+
+	user, err := u.userRepo.GetByID(ctx, claims.UserID)
+	if err != nil {
+		if errors.Is(err, entity.ErrUserNotFound) {
+			return nil, entity.ErrInvalidMFAToken
+		}
+		return nil, err
+	}
+
+	mfa, err := u.mfaRepo.Get(ctx, user.ID)
+	if err != nil {
+		if errors.Is(err, entity.ErrMFANotEnrolled) {
+			return nil, entity.ErrInvalidMFAToken
+		}
+		return nil, err
+	}
+	if !mfa.Enabled {
+		return nil, entity.ErrInvalidMFAToken
+	}
+
+	now := time.Now()
+	throttles, err := u.acquireThrottles(ctx, user.Username, clientIP, now)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifySecondFactor(ctx, u.mfaRepo, mfa, code, now); err != nil {
+		if errors.Is(err, entity.ErrInvalidMFACode) {
+			return nil, u.loginFailed(ctx, throttles, user.Username, clientIP, &user.ID, "invalid mfa code", err, now)
+		}
+		u.releaseThrottles(ctx, throttles)
+		return nil, err
+	}
+	if err := u.releaseThrottles(ctx, throttles); err != nil {
+		return nil, err
+	}
+
+	return u.completeLogin(ctx, user, clientIP, claims.OrganizationID)
+}
+
+// mfaEnabled reports whether the user has confirmed an MFA enrollment.
+func (u *userUseCase) mfaEnabled(ctx context.Context, userID uuid.UUID) (bool, error) {
+	mfa, err := u.mfaRepo.Get(ctx, userID)
+	if err != nil {
+		if errors.Is(err, entity.ErrMFANotEnrolled) {
+			return false, nil
+		}
+		return false, err
+	}
+	return mfa.Enabled, nil
+}
+
+func (u *userUseCase) issueMFAChallenge(ctx context.Context, user *entity.User, clientIP string, organizationID *uuid.UUID) (*entity.LoginResult, error) {
+	mfaToken, expiresAt, err := u.tokens.IssueMFAToken(user.ID, organizationID, u.cfg.MFAChallengeTTL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign mfa token: %w", err)
+	}
+	if err := u.recordEvent(ctx, entity.SecurityEventMFAChallenged, user.Username, clientIP, &user.ID, ""); err != nil {
+		return nil, err
+	}
+
+	return &entity.LoginResult{Challenge: &entity.MFAChallenge{
+		MFARequired: true,
+		MFAToken:    mfaToken,
+		ExpiresAt:   expiresAt,
+	}}, nil
+}
+
+// completeLogin resets the account's failure counter, records the login and
+// starts the session.
+func (u *userUseCase) completeLogin(ctx context.Context, user *entity.User, clientIP string, organizationID *uuid.UUID) (*entity.TokenPair, error) {
+	if user.IsDisabled() {
+		return nil, entity.ErrUserDisabled
+	}
+	if err := u.throttleRepo.Reset(ctx, entity.LoginThrottleAccount, entity.AccountThrottleKey(user.Username)); err != nil {
+		return nil, err
+	}
+	if err := u.recordEvent(ctx, entity.SecurityEventLoginSucceeded, user.Username, clientIP, &user.ID, ""); err != nil {
+		return nil, err
+	}
+
+	activeOrganization, err := u.resolveOrganization(ctx, user.ID, organizationID)
+	if err != nil {
+		return nil, err
+	}
+
+	return u.startSession(ctx, user, activeOrganization)
+}
+
+// SwitchOrganization ends the current session and starts a new one scoped to
+// another organization of the user.
+func (u *userUseCase) SwitchOrganization(ctx context.Context, userID, sessionID, organizationID uuid.UUID) (*entity.TokenPair, error) {
+	user, err := u.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	activeOrganization, err := u.resolveOrganization(ctx, user.ID, &organizationID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := u.Logout(ctx, sessionID); err != nil {
+		return nil, err
+	}
+
+	return u.startSession(ctx, user, activeOrganization)
+}
+
+// Refresh exchanges a refresh token for a new token pair. Each refresh token can
+// be used once; presenting an already rotated token is treated as theft and
+// revokes the whole session.
+func (u *userUseCase) Refresh(ctx context.Context, refreshToken, clientIP string) (*entity.TokenPair, error) {
+	current, err := u.tokenRepo.GetRefreshTokenByHash(ctx, entity.HashRefreshToken(refreshToken))
+	if err != nil {
+		return nil, err
+	}
+
+	if current.IsRevoked() {
+		return nil, u.revokeReusedSession(ctx, current, clientIP)
+	}
+	if current.IsExpired(time.Now()) {
+		return nil, entity.ErrInvalidRefreshToken
+	}
+
+	user, err := u.userRepo.GetByID(ctx, current.UserID)
+	if err != nil {
+		if errors.Is(err, entity.ErrUserNotFound) {
+			return nil, entity.ErrInvalidRefreshToken
+		}
+		return nil, err
+	}
+	if user.IsDisabled() {
+		return nil, entity.ErrInvalidRefreshToken
+	}
+
+	// Users removed from an organization lose their sessions in it.
+	if current.OrganizationID != nil {
+		member, err := u.organizationRepo.IsMember(ctx, *current.OrganizationID, user.ID)
+		if err != nil {
+			return nil, err
+		}
+		if !member {
+			if err := u.Logout(ctx, current.FamilyID); err != nil {
+				return nil, err
+			}
+			return nil, entity.ErrNotOrganizationMember
+		}
+	}
+
+	next, raw, err := entity.NewRefreshToken(current.UserID, current.FamilyID, current.OrganizationID, u.cfg.RefreshTokenTTL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate refresh token: %w", err)
+	}
+	if err := u.tokenRepo.RotateRefreshToken(ctx, current.ID, next); err != nil {
+		if errors.Is(err, entity.ErrRefreshTokenReused) {
+			return nil, u.revokeReusedSession(ctx, current, clientIP)
+		}
+		return nil, err
+	}
+	if err := u.recordEvent(ctx, entity.SecurityEventTokenRefreshed, user.Username, clientIP, &user.ID, ""); err != nil {
+		return nil, err
+	}
+
+	return u.issueTokenPair(ctx, user, current.FamilyID, current.OrganizationID, raw)
+}
+
+func (u *userUseCase) Logout(ctx context.Context, sessionID uuid.UUID) error {
+	if err := u.tokenRepo.RevokeRefreshTokenFamily(ctx, sessionID); err != nil {
+		return err
+	}
+	return u.revokeSessions(ctx, sessionID)
+}
+
+func (u *userUseCase) LogoutAll(ctx context.Context, userID uuid.UUID) error {
+	families, err := u.tokenRepo.RevokeUserRefreshTokens(ctx, userID)
+	if err != nil {
+		return err
+	}
+	return u.revokeSessions(ctx, families...)
+}
+
+// acquireThrottles counts the attempt as a failure against the account and
+// the client IP before the credentials are checked, so that concurrent
+// attempts cannot all pass before any of their failures is counted. It
+// rejects the attempt while either has to wait after earlier failures.
+func (u *userUseCase) acquireThrottles(ctx context.Context, username, clientIP string, now time.Time) ([]*entity.LoginThrottle, error) {
+	acquired, retryAfter, err := u.acquire(ctx, throttleKeys(username, clientIP), u.cfg.LockoutPolicy, now)
+	if err != nil {
+		return nil, err
+	}
+	if retryAfter == 0 {
+		return acquired, nil
+	}
+	if err := u.recordEvent(ctx, entity.SecurityEventLoginThrottled, username, clientIP, nil, ""); err != nil {
+		return nil, err
+	}
+	return nil, &entity.LoginThrottledError{RetryAfter: retryAfter}
+}
+
+// acquire counts an attempt against the throttle of every kind and key. If
+// any of them has to wait, nothing is counted and the longest wait is
+// returned instead.
+func (u *userUseCase) acquire(ctx context.Context, keys map[string]string, policy entity.LockoutPolicy, now time.Time) ([]*entity.LoginThrottle, time.Duration, error) {
+	var acquired []*entity.LoginThrottle
+	var retryAfter time.Duration
+	for kind, key := range keys {
+		throttle, ok, err := u.throttleRepo.Acquire(ctx, kind, key, now, policy)
+		if err != nil {
+			u.releaseThrottles(ctx, acquired)
+			return nil, 0, err
+		}
+		if ok {
+			acquired = append(acquired, throttle)
+		} else if wait := throttle.RetryAfter(now, policy); wait > retryAfter {
+			retryAfter = wait
+		}
+	}
+	if retryAfter == 0 {
+		return acquired, 0, nil
+	}
+
+	// A rejected attempt is not counted by the other throttles either.
+	if err := u.releaseThrottles(ctx, acquired); err != nil {
+		return nil, 0, err
+	}
+	return nil, retryAfter, nil
+}
+
+// releaseThrottles takes back the failures counted by acquireThrottles once
+// the credentials turned out to be correct.
+func (u *userUseCase) releaseThrottles(ctx context.Context, throttles []*entity.LoginThrottle) error {
+	for _, throttle := range throttles {
+		if err := u.throttleRepo.Release(ctx, throttle); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loginFailed locks whichever of the acquired throttles reached its
+// threshold and returns failure. The failure itself was already counted by
+// acquireThrottles.
+func (u *userUseCase) loginFailed(ctx context.Context, throttles []*entity.LoginThrottle, username, clientIP string, userID *uuid.UUID, reason string, failure error, now time.Time) error {
+	if err := u.recordEvent(ctx, entity.SecurityEventLoginFailed, username, clientIP, userID, reason); err != nil {
+		return err
+	}
+
+	for _, throttle := range throttles {
+		if !throttle.ShouldLock(u.cfg.LockoutPolicy) {
+			continue
+		}
+
+		if err := u.throttleRepo.Lock(ctx, throttle.Kind, throttle.Key, now.Add(u.cfg.LockoutPolicy.LockoutDuration)); err != nil {
+			return err
+		}
+		lockReason := fmt.Sprintf("%s locked after %d failed logins", throttle.Kind, throttle.Failures)
+		if err := u.recordEvent(ctx, entity.SecurityEventAccountLocked, username, clientIP, userID, lockReason); err != nil {
+			return err
+		}
+	}
+
+	return failure
+}
+
+func (u *userUseCase) recordEvent(ctx context.Context, eventType, username, clientIP string, userID *uuid.UUID, reason string) error {
+	return u.eventRepo.Create(ctx, entity.NewSecurityEvent(eventType, username, clientIP, userID, reason))
+}
+
+func throttleKeys(username, clientIP string) map[string]string {
+	keys := map[string]string{entity.LoginThrottleAccount: entity.AccountThrottleKey(username)}
+	if clientIP != "" {
+		keys[entity.LoginThrottleIP] = clientIP
+	}
+	return keys
+}
+
+func (u *userUseCase) resolveOrganization(ctx context.Context, userID uuid.UUID, requested *uuid.UUID) (*uuid.UUID, error) {
+	if requested != nil {
+		member, err := u.organizationRepo.IsMember(ctx, *requested, userID)
+		if err != nil {
+			return nil, err
+		}
+		if !member {
+			return nil, entity.ErrNotOrganizationMember
+		}
+		return requested, nil
+	}
+
+	organizations, err := u.organizationRepo.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(organizations) == 0 {
+		return nil, nil
+	}
+	return &organizations[0].ID, nil
+}
+
+func (u *userUseCase) startSession(ctx context.Context, user *entity.User, organizationID *uuid.UUID) (*entity.TokenPair, error) {
+	refreshToken, raw, err := entity.NewRefreshToken(user.ID, uuid.New(), organizationID, u.cfg.RefreshTokenTTL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate refresh token: %w", err)
+	}
+	if err := u.tokenRepo.CreateRefreshToken(ctx, refreshToken); err != nil {
+		return nil, err
+	}
+
+	return u.issueTokenPair(ctx, user, refreshToken.FamilyID, organizationID, raw)
+}
+
+// issueTokenPair loads the current roles of the user so that they are
+// reflected in the new access token.
+func (u *userUseCase) issueTokenPair(ctx context.Context, user *entity.User, sessionID uuid.UUID, organizationID *uuid.UUID, refreshToken string) (*entity.TokenPair, error) {
+	roles, permissions, err := u.roleRepo.GetUserAccess(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	permissions, err = u.withholdMFARequiredPermissions(ctx, user.ID, permissions)
+	if err != nil {
+		return nil, err
+	}
+	user.Roles = roles
+	user.Permissions = permissions
+
+	accessToken, expiresAt, err := u.tokens.IssueAccessToken(user, sessionID, organizationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign access token: %w", err)
+	}
+
+	return &entity.TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(u.tokens.AccessTTL().Seconds()),
+		ExpiresAt:    expiresAt,
+	}, nil
+}
+
+// withholdMFARequiredPermissions removes the permissions that require MFA
+// unless the user has it enabled. The user can still log in to enroll.
+func (u *userUseCase) withholdMFARequiredPermissions(ctx context.Context, userID uuid.UUID, permissions []string) ([]string, error) {
+	required := func(permission string) bool { return slices.Contains(u.cfg.MFARequiredPermissions, permission) }
+	if !slices.ContainsFunc(permissions, required) {
+		return permissions, nil
+	}
+
+	mfaEnabled, err := u.mfaEnabled(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if mfaEnabled {
+		return permissions, nil
+	}
+	return slices.DeleteFunc(permissions, required), nil
+}
+
+func (u *userUseCase) revokeReusedSession(ctx context.Context, token *entity.RefreshToken, clientIP string) error {
+	if err := u.tokenRepo.RevokeRefreshTokenFamily(ctx, token.FamilyID); err != nil {
+		return err
+	}
+	if err := u.revokeSessions(ctx, token.FamilyID); err != nil {
+		return err
+	}
+	reason := fmt.Sprintf("session %s revoked", token.FamilyID)
+	if err := u.recordEvent(ctx, entity.SecurityEventRefreshTokenReused, "", clientIP, &token.UserID, reason); err != nil {
+		return err
+	}
+	return entity.ErrRefreshTokenReused
+}
 
-	return token.SignedString([]byte(config.Load().JWTSecret))
+// revokeSessions denylists the sessions for as long as any access token issued
+// for them could still be valid.
+func (u *userUseCase) revokeSessions(ctx context.Context, sessionIDs ...uuid.UUID) error {
+	return u.denylist.Revoke(ctx, time.Now().Add(u.tokens.AccessTTL()), sessionIDs...)
 }
@@ -0,0 +1,792 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/assylzhan-a/company-task/internal/auth"
+	"github.com/assylzhan-a/company-task/internal/domain/entity"
+	"github.com/assylzhan-a/company-task/internal/mailer"
+	"github.com/assylzhan-a/company-task/internal/oidc"
+	r "github.com/assylzhan-a/company-task/internal/ports/repository"
+	uc "github.com/assylzhan-a/company-task/internal/ports/usecase"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The fakes embed the port interfaces so that only the methods used by a test
+// need an implementation; calling any other method panics.
+
+type fakeUserRepo struct {
+	r.UserRepository
+	users map[string]*entity.User
+	// beforeGetByUsername, if set, runs once at the next GetByUsername.
+	beforeGetByUsername func()
+}
+
+func (f *fakeUserRepo) Create(ctx context.Context, user *entity.User) error {
+	if _, ok := f.users[user.Username]; ok {
+		return entity.ErrUsernameTaken
+	}
+	for _, existing := range f.users {
+		if user.Email != "" && existing.Email == user.Email {
+			return entity.ErrEmailTaken
+		}
+	}
+	f.users[user.Username] = user
+	return nil
+}
+
+func (f *fakeUserRepo) GetByUsername(ctx context.Context, username string) (*entity.User, error) {
+	if hook := f.beforeGetByUsername; hook != nil {
+		f.beforeGetByUsername = nil
+		hook()
+	}
+	user, ok := f.users[username]
+	if !ok {
+		return nil, entity.ErrUserNotFound
+	}
+	return user, nil
+}
+
+func (f *fakeUserRepo) GetByID(ctx context.Context, id uuid.UUID) (*entity.User, error) {
+	for _, user := range f.users {
+		if user.ID == id {
+			return user, nil
+		}
+	}
+	return nil, entity.ErrUserNotFound
+}
+
+func (f *fakeUserRepo) GetByEmail(ctx context.Context, email string) (*entity.User, error) {
+	for _, user := range f.users {
+		if user.Email != "" && strings.EqualFold(user.Email, email) {
+			return user, nil
+		}
+	}
+	return nil, entity.ErrUserNotFound
+}
+
+func (f *fakeUserRepo) UpdatePassword(ctx context.Context, userID uuid.UUID, passwordHash string) error {
+	user, err := f.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	user.Password = passwordHash
+	return nil
+}
+
+func (f *fakeUserRepo) MarkEmailVerified(ctx context.Context, userID uuid.UUID, email string) error {
+	user, err := f.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user.Email != email {
+		return entity.ErrInvalidOneTimeToken
+	}
+	user.EmailVerified = true
+	return nil
+}
+
+func (f *fakeUserRepo) GetByIdentity(ctx context.Context, issuer, subject string) (*entity.User, error) {
+	for _, user := range f.users {
+		for _, identity := range user.Identities {
+			if identity.Issuer == issuer && identity.Subject == subject {
+				return user, nil
+			}
+		}
+	}
+	return nil, entity.ErrUserNotFound
+}
+
+func (f *fakeUserRepo) SetDisabled(ctx context.Context, userID uuid.UUID, disabledAt *time.Time) error {
+	user, err := f.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	user.DisabledAt = disabledAt
+	return nil
+}
+
+func (f *fakeUserRepo) Delete(ctx context.Context, userID uuid.UUID) error {
+	user, err := f.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	delete(f.users, user.Username)
+	return nil
+}
+
+type fakeTokenRepo struct {
+	r.TokenRepository
+	oneTimeTokens []*entity.OneTimeToken
+}
+
+func (f *fakeTokenRepo) CreateRefreshToken(ctx context.Context, token *entity.RefreshToken) error {
+	return nil
+}
+
+func (f *fakeTokenRepo) RevokeUserRefreshTokens(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error) {
+	return nil, nil
+}
+
+func (f *fakeTokenRepo) CreateOneTimeToken(ctx context.Context, token *entity.OneTimeToken) error {
+	for _, existing := range f.oneTimeTokens {
+		if existing.UserID == token.UserID && existing.Purpose == token.Purpose && existing.UsedAt == nil {
+			existing.UsedAt = &token.CreatedAt
+		}
+	}
+	f.oneTimeTokens = append(f.oneTimeTokens, token)
+	return nil
+}
+
+func (f *fakeTokenRepo) ConsumeOneTimeToken(ctx context.Context, purpose, hash string, now time.Time) (*entity.OneTimeToken, error) {
+	for _, token := range f.oneTimeTokens {
+		if token.Purpose == purpose && token.TokenHash == hash && token.UsedAt == nil && token.ExpiresAt.After(now) {
+			token.UsedAt = &now
+			return token, nil
+		}
+	}
+	return nil, entity.ErrInvalidOneTimeToken
+}
+
+// fakeRoleRepo keeps the roles on the users of the fake user repository.
+type fakeRoleRepo struct {
+	r.RoleRepository
+	users *fakeUserRepo
+}
+
+func (f *fakeRoleRepo) GetUserAccess(ctx context.Context, userID uuid.UUID) ([]string, []string, error) {
+	user, err := f.users.GetByID(ctx, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	permissions := []string{entity.PermissionCompaniesRead}
+	if slices.Contains(user.Roles, entity.RoleAdmin) {
+		permissions = append(permissions, entity.PermissionCompaniesDelete)
+	}
+	return append([]string(nil), user.Roles...), permissions, nil
+}
+
+func (f *fakeRoleRepo) AssignRole(ctx context.Context, userID uuid.UUID, role string) error {
+	user, err := f.users.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	user.Roles = append(user.Roles, role)
+	return nil
+}
+
+func (f *fakeRoleRepo) RevokeRole(ctx context.Context, userID uuid.UUID, role string) error {
+	user, err := f.users.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	user.Roles = slices.DeleteFunc(user.Roles, func(r string) bool { return r == role })
+	return nil
+}
+
+type fakeSSOStateRepo struct {
+	states map[string]*entity.SSOLoginState
+}
+
+func (f *fakeSSOStateRepo) Create(ctx context.Context, state *entity.SSOLoginState) error {
+	f.states[state.State] = state
+	return nil
+}
+
+func (f *fakeSSOStateRepo) Consume(ctx context.Context, state string, now time.Time) (*entity.SSOLoginState, error) {
+	loginState, ok := f.states[state]
+	delete(f.states, state)
+	if !ok || !loginState.ExpiresAt.After(now) {
+		return nil, entity.ErrInvalidSSOState
+	}
+	return loginState, nil
+}
+
+type fakeOrganizationRepo struct {
+	r.OrganizationRepository
+}
+
+func (f *fakeOrganizationRepo) ListByUser(ctx context.Context, userID uuid.UUID) ([]*entity.Organization, error) {
+	return nil, nil
+}
+
+type fakeThrottleRepo struct {
+	throttles map[string]*entity.LoginThrottle
+}
+
+func (f *fakeThrottleRepo) Acquire(ctx context.Context, kind, key string, now time.Time, policy entity.LockoutPolicy) (*entity.LoginThrottle, bool, error) {
+	throttle, ok := f.throttles[kind+"/"+key]
+	if !ok {
+		throttle = &entity.LoginThrottle{Kind: kind, Key: key, LastFailureAt: now}
+		f.throttles[kind+"/"+key] = throttle
+	}
+	if throttle.RetryAfter(now, policy) > 0 {
+		copied := *throttle
+		return &copied, false, nil
+	}
+	throttle.AddFailure(now, policy)
+	copied := *throttle
+	return &copied, true, nil
+}
+
+func (f *fakeThrottleRepo) Release(ctx context.Context, released *entity.LoginThrottle) error {
+	throttle, ok := f.throttles[released.Kind+"/"+released.Key]
+	if !ok {
+		return nil
+	}
+	throttle.Failures = max(throttle.Failures-1, 0)
+	if throttle.LastFailureAt.Equal(released.LastFailureAt) {
+		throttle.LastFailureAt = released.PreviousFailureAt
+	}
+	return nil
+}
+
+func (f *fakeThrottleRepo) Lock(ctx context.Context, kind, key string, until time.Time) error {
+	f.throttles[kind+"/"+key].LockedUntil = &until
+	return nil
+}
+
+func (f *fakeThrottleRepo) Reset(ctx context.Context, kind, key string) error {
+	delete(f.throttles, kind+"/"+key)
+	return nil
+}
+
+type fakeMFARepo struct {
+	r.MFARepository
+	enrollments   map[uuid.UUID]*entity.UserMFA
+	recoveryCodes map[string]bool
+}
+
+func (f *fakeMFARepo) Get(ctx context.Context, userID uuid.UUID) (*entity.UserMFA, error) {
+	mfa, ok := f.enrollments[userID]
+	if !ok {
+		return nil, entity.ErrMFANotEnrolled
+	}
+	copied := *mfa
+	return &copied, nil
+}
+
+func (f *fakeMFARepo) UseStep(ctx context.Context, userID uuid.UUID, step int64) (bool, error) {
+	mfa := f.enrollments[userID]
+	if mfa.LastUsedStep >= step {
+		return false, nil
+	}
+	mfa.LastUsedStep = step
+	return true, nil
+}
+
+func (f *fakeMFARepo) UseRecoveryCode(ctx context.Context, userID uuid.UUID, codeHash string) (bool, error) {
+	if !f.recoveryCodes[codeHash] {
+		return false, nil
+	}
+	f.recoveryCodes[codeHash] = false
+	return true, nil
+}
+
+type fakeEventRepo struct {
+	r.SecurityEventRepository
+	events []*entity.SecurityEvent
+}
+
+func (f *fakeEventRepo) Create(ctx context.Context, event *entity.SecurityEvent) error {
+	f.events = append(f.events, event)
+	return nil
+}
+
+func (f *fakeEventRepo) types() []string {
+	var types []string
+	for _, event := range f.events {
+		types = append(types, event.Type)
+	}
+	return types
+}
+
+type fakeMailer struct {
+	messages []mailer.Message
+	err      error
+}
+
+func (f *fakeMailer) Send(ctx context.Context, message mailer.Message) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.messages = append(f.messages, message)
+	return nil
+}
+
+// token extracts the token from the link in the last message.
+func (f *fakeMailer) token(t *testing.T) string {
+	t.Helper()
+	require.NotEmpty(t, f.messages)
+	body := f.messages[len(f.messages)-1].Body
+	start := strings.Index(body, "?token=")
+	require.NotEqual(t, -1, start)
+	token, _, _ := strings.Cut(body[start+len("?token="):], "\n")
+	return token
+}
+
+// fakeBreaches reports the passwords in the set as breached.
+type fakeBreaches map[string]bool
+
+func (f fakeBreaches) IsBreached(ctx context.Context, password string) bool {
+	return f[password]
+}
+
+type userUseCaseFixture struct {
+	useCase   uc.UserUseCase
+	users     *fakeUserRepo
+	throttles *fakeThrottleRepo
+	events    *fakeEventRepo
+	mfa       *fakeMFARepo
+	mail      *fakeMailer
+}
+
+func newUserUseCaseFixture(t *testing.T, lockout entity.LockoutPolicy) *userUseCaseFixture {
+	return newUserUseCaseFixtureWithConfig(t, nil, UserUseCaseConfig{LockoutPolicy: lockout})
+}
+
+// newUserUseCaseFixtureWithConfig registers alice unless local login is
+// disabled. sso may be nil.
+func newUserUseCaseFixtureWithConfig(t *testing.T, sso oidc.Provider, cfg UserUseCaseConfig) *userUseCaseFixture {
+	t.Helper()
+
+	tokens := auth.NewTokenService(auth.NewHMACKeySet("test-secret"), "test", "test", time.Minute)
+	throttles := &fakeThrottleRepo{throttles: make(map[string]*entity.LoginThrottle)}
+	events := &fakeEventRepo{}
+	users := &fakeUserRepo{users: make(map[string]*entity.User)}
+	mfa := &fakeMFARepo{enrollments: make(map[uuid.UUID]*entity.UserMFA), recoveryCodes: make(map[string]bool)}
+
+	mail := &fakeMailer{}
+
+	cfg.RefreshTokenTTL = time.Hour
+	cfg.PasswordPolicy = entity.DefaultPasswordPolicy()
+	cfg.MFAChallengeTTL = time.Minute
+	cfg.EmailVerificationTTL = time.Hour
+	cfg.PasswordResetTTL = time.Hour
+	cfg.BaseURL = "https://app.example.com/"
+	ssoStates := &fakeSSOStateRepo{states: make(map[string]*entity.SSOLoginState)}
+	useCase := NewUserUseCase(users, &fakeTokenRepo{}, &fakeRoleRepo{users: users}, &fakeOrganizationRepo{}, throttles, events, mfa,
+		mail, fakeBreaches{"password123": true}, ssoStates, sso, tokens, nil, cfg)
+	if !cfg.LocalLoginDisabled {
+		require.NoError(t, useCase.Register(context.Background(), "alice", "correct horse battery", "Alice@Example.com"))
+	}
+
+	return &userUseCaseFixture{useCase: useCase, users: users, throttles: throttles, events: events, mfa: mfa, mail: mail}
+}
+
+func TestRegisterRejectsWeakPassword(t *testing.T) {
+	f := newUserUseCaseFixture(t, entity.LockoutPolicy{})
+
+	err := f.useCase.Register(context.Background(), "bob", "letmein", "")
+	assert.True(t, errors.Is(err, entity.ErrWeakPassword))
+}
+
+func TestRegisterRejectsBreachedPassword(t *testing.T) {
+	f := newUserUseCaseFixture(t, entity.LockoutPolicy{})
+
+	err := f.useCase.Register(context.Background(), "bob", "password123", "")
+	var policyErr *entity.PasswordPolicyError
+	require.True(t, errors.As(err, &policyErr))
+	assert.Equal(t, []string{entity.PasswordRuleBreached}, policyErr.Rules)
+	assert.NotContains(t, f.users.users, "bob")
+}
+
+func TestRegisterDoesNotGrantAdmin(t *testing.T) {
+	f := newUserUseCaseFixtureWithConfig(t, nil, UserUseCaseConfig{DefaultRole: entity.RoleViewer})
+	ctx := context.Background()
+
+	require.NoError(t, f.useCase.Register(ctx, "admin", "correct horse battery", ""))
+	assert.Equal(t, []string{entity.RoleViewer}, f.users.users["admin"].Roles)
+
+	// Only the operator promotes an existing user.
+	roles := NewRoleUseCase(&fakeRoleRepo{users: f.users}, f.users, f.events)
+	assert.Equal(t, entity.ErrUserNotFound, roles.BootstrapAdmin(ctx, "root"))
+	require.NoError(t, roles.BootstrapAdmin(ctx, "admin"))
+	require.NoError(t, roles.BootstrapAdmin(ctx, "admin"))
+	assert.Equal(t, []string{entity.RoleViewer, entity.RoleAdmin}, f.users.users["admin"].Roles)
+	assert.Equal(t, []string{entity.SecurityEventRoleAssigned}, f.events.types())
+}
+
+func TestEmailVerification(t *testing.T) {
+	f := newUserUseCaseFixtureWithConfig(t, nil, UserUseCaseConfig{RequireEmailVerification: true})
+	ctx := context.Background()
+
+	require.Len(t, f.mail.messages, 1)
+	assert.Equal(t, "alice@example.com", f.mail.messages[0].To)
+	assert.Contains(t, f.mail.messages[0].Body, "https://app.example.com/verify-email?token=")
+
+	_, err := f.useCase.Login(ctx, "alice", "correct horse battery", "192.0.2.1", nil)
+	assert.Equal(t, entity.ErrEmailNotVerified, err)
+
+	err = f.useCase.Register(ctx, "bob", "correct horse battery", "")
+	assert.Equal(t, entity.ErrEmailRequired, err)
+
+	// Resending invalidates the first link.
+	first := f.mail.token(t)
+	require.NoError(t, f.useCase.SendVerificationEmail(ctx, f.users.users["alice"].ID))
+	assert.Equal(t, entity.ErrInvalidOneTimeToken, f.useCase.VerifyEmail(ctx, first))
+
+	require.NoError(t, f.useCase.VerifyEmail(ctx, f.mail.token(t)))
+	assert.True(t, f.users.users["alice"].EmailVerified)
+	assert.Equal(t, entity.ErrInvalidOneTimeToken, f.useCase.VerifyEmail(ctx, f.mail.token(t)))
+
+	_, err = f.useCase.Login(ctx, "alice", "correct horse battery", "192.0.2.1", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, entity.ErrEmailAlreadyVerified, f.useCase.SendVerificationEmail(ctx, f.users.users["alice"].ID))
+}
+
+func TestRegisterReportsUnsentVerificationEmail(t *testing.T) {
+	f := newUserUseCaseFixture(t, entity.LockoutPolicy{})
+	f.mail.err = errors.New("smtp unavailable")
+
+	err := f.useCase.Register(context.Background(), "bob", "correct horse battery", "bob@example.com")
+	assert.True(t, errors.Is(err, entity.ErrVerificationEmailNotSent))
+	assert.Contains(t, f.users.users, "bob", "the user is created anyway")
+}
+
+func TestRegisterDoesNotRevealTakenEmail(t *testing.T) {
+	f := newUserUseCaseFixture(t, entity.LockoutPolicy{})
+	sent := len(f.mail.messages)
+
+	require.NoError(t, f.useCase.Register(context.Background(), "mallory", "correct horse battery", "ALICE@example.com"))
+	assert.NotContains(t, f.users.users, "mallory")
+	require.Len(t, f.mail.messages, sent+1)
+	notice := f.mail.messages[sent]
+	assert.Equal(t, "alice@example.com", notice.To)
+	assert.Equal(t, "Your email address is already registered", notice.Subject)
+	assert.NotContains(t, notice.Body, "token=")
+}
+
+func TestPasswordResetRequestsAreThrottled(t *testing.T) {
+	f := newUserUseCaseFixtureWithConfig(t, nil, UserUseCaseConfig{PasswordResetThrottle: entity.LockoutPolicy{
+		FreeAttempts: 2,
+		BaseDelay:    time.Minute,
+		MaxDelay:     time.Hour,
+		Window:       time.Hour,
+	}})
+	ctx := context.Background()
+
+	// Unknown addresses count like registered ones.
+	require.NoError(t, f.useCase.RequestPasswordReset(ctx, "nobody@example.com", "192.0.2.1"))
+	require.NoError(t, f.useCase.RequestPasswordReset(ctx, "Nobody@example.com", "198.51.100.1"))
+	require.NoError(t, f.useCase.RequestPasswordReset(ctx, "nobody@example.com", "198.51.100.2"))
+	err := f.useCase.RequestPasswordReset(ctx, "nobody@example.com", "198.51.100.3")
+	var throttled *entity.ResetThrottledError
+	require.True(t, errors.As(err, &throttled))
+	assert.Equal(t, time.Minute, throttled.RetryAfter.Round(time.Minute))
+	assert.Zero(t, f.throttles.throttles[entity.PasswordResetThrottleIP+"/198.51.100.3"].Failures,
+		"the rejected request is not counted against its IP")
+
+	require.NoError(t, f.useCase.RequestPasswordReset(ctx, "alice@example.com", "192.0.2.1"))
+	require.NoError(t, f.useCase.RequestPasswordReset(ctx, "bob@example.com", "192.0.2.1"))
+	err = f.useCase.RequestPasswordReset(ctx, "someone@example.com", "192.0.2.1")
+	assert.True(t, errors.Is(err, entity.ErrTooManyResetRequests), "the IP is throttled as well")
+}
+
+func TestPasswordReset(t *testing.T) {
+	f := newUserUseCaseFixture(t, entity.DefaultLockoutPolicy())
+	ctx := context.Background()
+	sent := len(f.mail.messages)
+
+	// Unverified and unknown addresses are accepted silently without a mail.
+	require.NoError(t, f.useCase.RequestPasswordReset(ctx, "alice@example.com", "192.0.2.1"))
+	require.NoError(t, f.useCase.RequestPasswordReset(ctx, "nobody@example.com", "192.0.2.1"))
+	assert.Len(t, f.mail.messages, sent)
+
+	f.users.users["alice"].EmailVerified = true
+	_, _ = f.useCase.Login(ctx, "alice", "wrong", "192.0.2.1", nil)
+	require.NoError(t, f.useCase.RequestPasswordReset(ctx, "ALICE@example.com", "192.0.2.1"))
+	require.Len(t, f.mail.messages, sent+1)
+	token := f.mail.token(t)
+
+	err := f.useCase.ResetPassword(ctx, token, "letmein", "192.0.2.1")
+	assert.True(t, errors.Is(err, entity.ErrWeakPassword))
+
+	require.NoError(t, f.useCase.ResetPassword(ctx, token, "a brand new passphrase", "192.0.2.1"))
+	assert.Equal(t, entity.ErrInvalidOneTimeToken, f.useCase.ResetPassword(ctx, token, "another new passphrase", "192.0.2.1"))
+	assert.NotContains(t, f.throttles.throttles, entity.LoginThrottleAccount+"/alice")
+
+	_, err = f.useCase.Login(ctx, "alice", "correct horse battery", "192.0.2.1", nil)
+	assert.Equal(t, entity.ErrInvalidCredentials, err)
+	_, err = f.useCase.Login(ctx, "alice", "a brand new passphrase", "192.0.2.1", nil)
+	assert.NoError(t, err)
+	assert.Contains(t, f.events.types(), entity.SecurityEventPasswordReset)
+}
+
+func TestChangePasswordRequiresCurrentPassword(t *testing.T) {
+	f := newUserUseCaseFixture(t, entity.DefaultLockoutPolicy())
+	ctx := context.Background()
+	alice := f.users.users["alice"]
+
+	_, err := f.useCase.ChangePassword(ctx, alice.ID, nil, "wrong", "another long passphrase", "192.0.2.1")
+	assert.True(t, errors.Is(err, entity.ErrInvalidCredentials))
+	assert.Equal(t, entity.SecurityEventLoginFailed, f.events.events[len(f.events.events)-1].Type)
+
+	_, err = f.useCase.ChangePassword(ctx, alice.ID, nil, "correct horse battery", "short", "192.0.2.1")
+	assert.True(t, errors.Is(err, entity.ErrWeakPassword))
+
+	tokens, err := f.useCase.ChangePassword(ctx, alice.ID, nil, "correct horse battery", "another long passphrase", "192.0.2.1")
+	require.NoError(t, err)
+	assert.NotEmpty(t, tokens.AccessToken)
+	assert.Contains(t, f.events.types(), entity.SecurityEventPasswordChanged)
+
+	_, err = f.useCase.Login(ctx, "alice", "another long passphrase", "192.0.2.1", nil)
+	assert.NoError(t, err)
+}
+
+func TestDeleteAccount(t *testing.T) {
+	f := newUserUseCaseFixture(t, entity.DefaultLockoutPolicy())
+	ctx := context.Background()
+	alice := f.users.users["alice"]
+
+	err := f.useCase.DeleteAccount(ctx, alice.ID, "wrong", "192.0.2.1")
+	assert.True(t, errors.Is(err, entity.ErrInvalidCredentials))
+
+	require.NoError(t, f.useCase.DeleteAccount(ctx, alice.ID, "correct horse battery", "192.0.2.1"))
+	assert.NotContains(t, f.users.users, "alice")
+	assert.Equal(t, entity.SecurityEventAccountDeleted, f.events.events[len(f.events.events)-1].Type)
+}
+
+func TestDisabledUserCannotLogIn(t *testing.T) {
+	f := newUserUseCaseFixture(t, entity.DefaultLockoutPolicy())
+	ctx := context.Background()
+	alice := f.users.users["alice"]
+	admin := uuid.New()
+
+	assert.True(t, errors.Is(f.useCase.DisableUser(ctx, alice.ID, alice.ID), entity.ErrCannotModifySelf))
+
+	require.NoError(t, f.useCase.DisableUser(ctx, admin, alice.ID))
+	assert.True(t, alice.IsDisabled())
+	_, err := f.useCase.Login(ctx, "alice", "correct horse battery", "192.0.2.1", nil)
+	assert.True(t, errors.Is(err, entity.ErrUserDisabled))
+
+	require.NoError(t, f.useCase.EnableUser(ctx, admin, alice.ID))
+	_, err = f.useCase.Login(ctx, "alice", "correct horse battery", "192.0.2.1", nil)
+	assert.NoError(t, err)
+	assert.Contains(t, f.events.types(), entity.SecurityEventUserDisabled)
+	assert.Contains(t, f.events.types(), entity.SecurityEventUserEnabled)
+}
+
+func TestLoginRecordsSecurityEvents(t *testing.T) {
+	f := newUserUseCaseFixture(t, entity.DefaultLockoutPolicy())
+	ctx := context.Background()
+
+	_, err := f.useCase.Login(ctx, "alice", "wrong", "192.0.2.1", nil)
+	assert.Equal(t, entity.ErrInvalidCredentials, err)
+
+	_, err = f.useCase.Login(ctx, "mallory", "wrong", "192.0.2.1", nil)
+	assert.Equal(t, entity.ErrInvalidCredentials, err)
+
+	result, err := f.useCase.Login(ctx, "alice", "correct horse battery", "192.0.2.1", nil)
+	require.NoError(t, err)
+	assert.NotEmpty(t, result.Tokens.AccessToken)
+
+	assert.Equal(t, []string{
+		entity.SecurityEventLoginFailed,
+		entity.SecurityEventLoginFailed,
+		entity.SecurityEventLoginSucceeded,
+	}, f.events.types())
+	assert.Equal(t, "invalid password", f.events.events[0].Reason)
+	assert.NotNil(t, f.events.events[0].UserID)
+	assert.Equal(t, "unknown user", f.events.events[1].Reason)
+	assert.Equal(t, "192.0.2.1", f.events.events[2].IPAddress)
+}
+
+func TestLoginSuccessResetsAccountButNotIPFailures(t *testing.T) {
+	f := newUserUseCaseFixture(t, entity.DefaultLockoutPolicy())
+	ctx := context.Background()
+
+	_, _ = f.useCase.Login(ctx, "alice", "wrong", "192.0.2.1", nil)
+	_, err := f.useCase.Login(ctx, "Alice", "correct horse battery", "192.0.2.1", nil)
+	require.Error(t, err, "usernames are case-sensitive")
+
+	_, err = f.useCase.Login(ctx, "alice", "correct horse battery", "192.0.2.1", nil)
+	require.NoError(t, err)
+
+	assert.NotContains(t, f.throttles.throttles, entity.LoginThrottleAccount+"/alice")
+	assert.Equal(t, 2, f.throttles.throttles[entity.LoginThrottleIP+"/192.0.2.1"].Failures)
+}
+
+func TestLoginDelaysAfterFreeAttempts(t *testing.T) {
+	f := newUserUseCaseFixture(t, entity.LockoutPolicy{
+		FreeAttempts: 2,
+		BaseDelay:    time.Minute,
+		MaxDelay:     time.Hour,
+		Window:       time.Hour,
+	})
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		_, err := f.useCase.Login(ctx, "alice", "wrong", "192.0.2.1", nil)
+		require.Equal(t, entity.ErrInvalidCredentials, err)
+	}
+
+	// Even the correct password has to wait, and from another IP too.
+	_, err := f.useCase.Login(ctx, "alice", "correct horse battery", "198.51.100.1", nil)
+	var throttled *entity.LoginThrottledError
+	require.True(t, errors.As(err, &throttled))
+	assert.InDelta(t, time.Minute.Seconds(), throttled.RetryAfter.Seconds(), 1)
+	assert.Equal(t, entity.SecurityEventLoginThrottled, f.events.events[len(f.events.events)-1].Type)
+}
+
+func TestLoginCountsAttemptBeforeCheckingPassword(t *testing.T) {
+	f := newUserUseCaseFixture(t, entity.LockoutPolicy{
+		FreeAttempts: 1,
+		BaseDelay:    time.Minute,
+		MaxDelay:     time.Hour,
+		Window:       time.Hour,
+	})
+	ctx := context.Background()
+
+	_, err := f.useCase.Login(ctx, "alice", "wrong", "192.0.2.1", nil)
+	require.Equal(t, entity.ErrInvalidCredentials, err)
+
+	// An attempt racing with the second one sees it counted already, so it
+	// has to wait even though neither was checked yet.
+	var concurrent error
+	f.users.beforeGetByUsername = func() {
+		_, concurrent = f.useCase.Login(ctx, "alice", "correct horse battery", "198.51.100.1", nil)
+	}
+	_, err = f.useCase.Login(ctx, "alice", "wrong", "192.0.2.1", nil)
+	require.Equal(t, entity.ErrInvalidCredentials, err)
+	assert.True(t, errors.Is(concurrent, entity.ErrTooManyLoginAttempts))
+	assert.Equal(t, 2, f.throttles.throttles[entity.LoginThrottleAccount+"/alice"].Failures)
+	assert.Zero(t, f.throttles.throttles[entity.LoginThrottleIP+"/198.51.100.1"].Failures,
+		"the rejected attempt is not counted against its IP")
+}
+
+func TestLoginLocksAccountAfterMaxFailures(t *testing.T) {
+	f := newUserUseCaseFixture(t, entity.LockoutPolicy{
+		FreeAttempts:       100,
+		MaxAccountFailures: 3,
+		MaxIPFailures:      100,
+		LockoutDuration:    time.Hour,
+		Window:             time.Hour,
+	})
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		_, _ = f.useCase.Login(ctx, "alice", "wrong", "192.0.2.1", nil)
+	}
+
+	account := f.throttles.throttles[entity.LoginThrottleAccount+"/alice"]
+	require.NotNil(t, account.LockedUntil)
+	assert.Nil(t, f.throttles.throttles[entity.LoginThrottleIP+"/192.0.2.1"].LockedUntil)
+	assert.Contains(t, f.events.types(), entity.SecurityEventAccountLocked)
+
+	_, err := f.useCase.Login(ctx, "alice", "correct horse battery", "192.0.2.1", nil)
+	assert.True(t, errors.Is(err, entity.ErrTooManyLoginAttempts))
+}
+
+func TestLoginLocksIPAfterMaxFailures(t *testing.T) {
+	f := newUserUseCaseFixture(t, entity.LockoutPolicy{
+		FreeAttempts:       100,
+		MaxAccountFailures: 100,
+		MaxIPFailures:      3,
+		LockoutDuration:    time.Hour,
+		Window:             time.Hour,
+	})
+	ctx := context.Background()
+
+	// Spraying many accounts from one IP trips the IP lockout.
+	for _, username := range []string{"a", "b", "c"} {
+		_, _ = f.useCase.Login(ctx, username, "wrong", "192.0.2.1", nil)
+	}
+
+	_, err := f.useCase.Login(ctx, "alice", "correct horse battery", "192.0.2.1", nil)
+	assert.True(t, errors.Is(err, entity.ErrTooManyLoginAttempts))
+
+	_, err = f.useCase.Login(ctx, "alice", "correct horse battery", "198.51.100.1", nil)
+	assert.NoError(t, err)
+}
+
+func TestLoginWithMFARequiresSecondFactor(t *testing.T) {
+	f := newUserUseCaseFixture(t, entity.DefaultLockoutPolicy())
+	ctx := context.Background()
+
+	alice := f.users.users["alice"]
+	mfa, err := entity.NewUserMFA(alice.ID)
+	require.NoError(t, err)
+	mfa.Enabled = true
+	f.mfa.enrollments[alice.ID] = mfa
+	f.mfa.recoveryCodes[entity.HashRecoveryCode("abcd-efgh")] = true
+
+	result, err := f.useCase.Login(ctx, "alice", "correct horse battery", "192.0.2.1", nil)
+	require.NoError(t, err)
+	require.Nil(t, result.Tokens)
+	require.NotNil(t, result.Challenge)
+	assert.True(t, result.Challenge.MFARequired)
+
+	_, err = f.useCase.VerifyMFA(ctx, result.Challenge.MFAToken, "000000", "192.0.2.1")
+	assert.True(t, errors.Is(err, entity.ErrInvalidMFACode))
+	assert.Equal(t, "invalid mfa code", f.events.events[len(f.events.events)-1].Reason)
+
+	_, err = f.useCase.VerifyMFA(ctx, "not-a-token", "abcd-efgh", "192.0.2.1")
+	assert.True(t, errors.Is(err, entity.ErrInvalidMFAToken))
+
+	tokens, err := f.useCase.VerifyMFA(ctx, result.Challenge.MFAToken, "ABCD-EFGH", "192.0.2.1")
+	require.NoError(t, err)
+	assert.NotEmpty(t, tokens.AccessToken)
+	assert.Equal(t, entity.SecurityEventLoginSucceeded, f.events.events[len(f.events.events)-1].Type)
+
+	_, err = f.useCase.VerifyMFA(ctx, result.Challenge.MFAToken, "abcd-efgh", "192.0.2.1")
+	assert.True(t, errors.Is(err, entity.ErrInvalidMFACode), "recovery codes are single-use")
+}
+
+// accessTokenPermissions returns the permissions granted by an access token of
+// the fixture's token service.
+func accessTokenPermissions(t *testing.T, token string) []string {
+	t.Helper()
+	claims, err := auth.NewTokenService(auth.NewHMACKeySet("test-secret"), "test", "test", time.Minute).ParseAccessToken(token)
+	require.NoError(t, err)
+	return claims.Permissions
+}
+
+func TestMFARequiredPermissionsNeedMFA(t *testing.T) {
+	f := newUserUseCaseFixtureWithConfig(t, nil, UserUseCaseConfig{
+		LockoutPolicy:          entity.DefaultLockoutPolicy(),
+		MFARequiredPermissions: []string{entity.PermissionCompaniesDelete},
+	})
+	ctx := context.Background()
+	alice := f.users.users["alice"]
+	alice.Roles = append(alice.Roles, entity.RoleAdmin)
+
+	// Without MFA the permission is withheld.
+	result, err := f.useCase.Login(ctx, "alice", "correct horse battery", "192.0.2.1", nil)
+	require.NoError(t, err)
+	require.NotNil(t, result.Tokens)
+	assert.Equal(t, []string{entity.PermissionCompaniesRead}, accessTokenPermissions(t, result.Tokens.AccessToken))
+
+	mfa, err := entity.NewUserMFA(alice.ID)
+	require.NoError(t, err)
+	mfa.Enabled = true
+	f.mfa.enrollments[alice.ID] = mfa
+	f.mfa.recoveryCodes[entity.HashRecoveryCode("abcd-efgh")] = true
+
+	result, err = f.useCase.Login(ctx, "alice", "correct horse battery", "192.0.2.1", nil)
+	require.NoError(t, err)
+	require.NotNil(t, result.Challenge)
+	tokens, err := f.useCase.VerifyMFA(ctx, result.Challenge.MFAToken, "abcd-efgh", "192.0.2.1")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{entity.PermissionCompaniesRead, entity.PermissionCompaniesDelete},
+		accessTokenPermissions(t, tokens.AccessToken))
+}
+
+func TestMFAChallengeTokenIsNotAnAccessToken(t *testing.T) {
+	tokens := auth.NewTokenService(auth.NewHMACKeySet("test-secret"), "test", "test", time.Minute)
+
+	mfaToken, _, err := tokens.IssueMFAToken(uuid.New(), nil, time.Minute)
+	require.NoError(t, err)
+
+	_, err = tokens.ParseAccessToken(mfaToken)
+	assert.Error(t, err)
+	_, err = tokens.ParseMFAToken(mfaToken)
+	assert.NoError(t, err)
+}
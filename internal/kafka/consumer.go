@@ -0,0 +1,56 @@
+package kafka
+
+import (
+	"context"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Message is a message read by a Consumer.
+type Message struct {
+	Topic   string
+	Key     []byte
+	Value   []byte
+	Headers map[string]string
+}
+
+type Consumer interface {
+	// Fetch waits for the next message, or returns the error of ctx.
+	Fetch(ctx context.Context) (*Message, error)
+	Close() error
+}
+
+type CompanyConsumer struct {
+	reader *kafka.Reader
+}
+
+// NewConsumer reads the topics as the only member of the consumer group,
+// starting with the messages published after it joins. Offsets are not
+// committed, so a consumer that restarts does not read the messages it
+// missed.
+func NewConsumer(brokers []string, groupID string, topics []string) Consumer {
+	return &CompanyConsumer{
+		reader: kafka.NewReader(kafka.ReaderConfig{
+			Brokers:     brokers,
+			GroupID:     groupID,
+			GroupTopics: topics,
+			StartOffset: kafka.LastOffset,
+		}),
+	}
+}
+
+func (c *CompanyConsumer) Fetch(ctx context.Context) (*Message, error) {
+	message, err := c.reader.FetchMessage(ctx)
+	if err != nil {
+		return nil, err
+	}
+	headers := make(map[string]string, len(message.Headers))
+	for _, header := range message.Headers {
+		headers[header.Key] = string(header.Value)
+	}
+	return &Message{Topic: message.Topic, Key: message.Key, Value: message.Value, Headers: headers}, nil
+}
+
+func (c *CompanyConsumer) Close() error {
+	return c.reader.Close()
+}
@@ -6,8 +6,11 @@ import (
 	"github.com/segmentio/kafka-go"
 )
 
+// HeaderOrganizationID is the message header carrying the tenant of an event.
+const HeaderOrganizationID = "organization_id"
+
 type Producer interface {
-	Produce(ctx context.Context, topic string, key, value []byte) error
+	Produce(ctx context.Context, topic string, key, value []byte, headers map[string]string) error
 	Close() error
 }
 
@@ -26,12 +29,15 @@ func NewProducer(brokers []string, logger *logger.Logger) Producer {
 	}
 }
 
-func (p *CompanyProducer) Produce(ctx context.Context, topic string, key, value []byte) error {
+func (p *CompanyProducer) Produce(ctx context.Context, topic string, key, value []byte, headers map[string]string) error {
 	message := kafka.Message{
 		Topic: topic,
 		Key:   key,
 		Value: value,
 	}
+	for k, v := range headers {
+		message.Headers = append(message.Headers, kafka.Header{Key: k, Value: []byte(v)})
+	}
 
 	err := p.writer.WriteMessages(ctx, message)
 	if err != nil {
@@ -0,0 +1,171 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/assylzhan-a/company-task/pkg/logger"
+)
+
+// Component is a part of the application that has to be started and stopped
+// together with it. Start must not block: long-running work is expected to be
+// moved to a goroutine and terminated by Stop.
+type Component interface {
+	Name() string
+	Start(ctx context.Context) error
+	Stop(ctx context.Context) error
+}
+
+// Hook adapts plain functions to the Component interface.
+type Hook struct {
+	ComponentName string
+	OnStart       func(ctx context.Context) error
+	OnStop        func(ctx context.Context) error
+}
+
+func (h Hook) Name() string {
+	return h.ComponentName
+}
+
+func (h Hook) Start(ctx context.Context) error {
+	if h.OnStart == nil {
+		return nil
+	}
+	return h.OnStart(ctx)
+}
+
+func (h Hook) Stop(ctx context.Context) error {
+	if h.OnStop == nil {
+		return nil
+	}
+	return h.OnStop(ctx)
+}
+
+// ComponentError describes a component that failed to start or stop.
+type ComponentError struct {
+	Component string
+	Err       error
+}
+
+func (e ComponentError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Component, e.Err)
+}
+
+// ShutdownError is returned by Run when one or more components did not stop cleanly.
+type ShutdownError struct {
+	Failures []ComponentError
+}
+
+func (e *ShutdownError) Error() string {
+	names := make([]string, 0, len(e.Failures))
+	for _, f := range e.Failures {
+		names = append(names, f.Error())
+	}
+	return "components failed to stop cleanly: " + strings.Join(names, "; ")
+}
+
+// Manager starts components in registration order under a shared cancellable
+// context and stops them in reverse order once that context is done.
+type Manager struct {
+	components      []Component
+	shutdownTimeout time.Duration
+	logger          *logger.Logger
+
+	mu      sync.Mutex
+	cancel  context.CancelCauseFunc
+	started []Component
+}
+
+func NewManager(shutdownTimeout time.Duration, logger *logger.Logger) *Manager {
+	return &Manager{
+		shutdownTimeout: shutdownTimeout,
+		logger:          logger,
+	}
+}
+
+// Add registers a component. Components must be added in dependency order:
+// a component may only depend on components added before it.
+func (m *Manager) Add(c Component) {
+	m.components = append(m.components, c)
+}
+
+// Fail triggers a shutdown because a component failed while running.
+func (m *Manager) Fail(component string, err error) {
+	m.logger.Error("Component failed", "component", component, "error", err)
+	m.mu.Lock()
+	cancel := m.cancel
+	m.mu.Unlock()
+	if cancel != nil {
+		cancel(ComponentError{Component: component, Err: err})
+	}
+}
+
+// Run starts all components and blocks until ctx is cancelled or a component
+// reports a failure through Fail, then stops every started component within the
+// shutdown timeout. The returned error is a start error, a *ShutdownError, or
+// the runtime failure that caused the shutdown.
+func (m *Manager) Run(ctx context.Context) error {
+	runCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	m.mu.Lock()
+	m.cancel = cancel
+	m.mu.Unlock()
+
+	var startErr error
+	for _, c := range m.components {
+		m.logger.Info("Starting component", "component", c.Name())
+		if err := c.Start(runCtx); err != nil {
+			startErr = ComponentError{Component: c.Name(), Err: err}
+			m.logger.Error("Failed to start component", "component", c.Name(), "error", err)
+			break
+		}
+		m.started = append(m.started, c)
+	}
+
+	if startErr == nil {
+		<-runCtx.Done()
+	}
+
+	cause := context.Cause(runCtx)
+	cancel(nil)
+
+	stopErr := m.stop()
+
+	switch {
+	case startErr != nil:
+		return errors.Join(startErr, stopErr)
+	case stopErr != nil:
+		return stopErr
+	case cause != nil && !errors.Is(cause, context.Canceled):
+		return cause
+	}
+	return nil
+}
+
+func (m *Manager) stop() error {
+	ctx, cancel := context.WithTimeout(context.Background(), m.shutdownTimeout)
+	defer cancel()
+
+	var failures []ComponentError
+	for i := len(m.started) - 1; i >= 0; i-- {
+		c := m.started[i]
+		m.logger.Info("Stopping component", "component", c.Name())
+		if err := c.Stop(ctx); err != nil {
+			m.logger.Error("Component failed to stop cleanly", "component", c.Name(), "error", err)
+			failures = append(failures, ComponentError{Component: c.Name(), Err: err})
+			continue
+		}
+		m.logger.Info("Component stopped", "component", c.Name())
+	}
+	m.started = nil
+
+	if len(failures) > 0 {
+		return &ShutdownError{Failures: failures}
+	}
+	return nil
+}
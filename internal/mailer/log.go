@@ -0,0 +1,21 @@
+package mailer
+
+import (
+	"context"
+	"github.com/assylzhan-a/company-task/pkg/logger"
+)
+
+type LogMailer struct {
+	logger *logger.Logger
+}
+
+// NewLogMailer logs messages instead of sending them. It is meant for local
+// development, where the links in the messages can be copied from the log.
+func NewLogMailer(logger *logger.Logger) Mailer {
+	return &LogMailer{logger: logger}
+}
+
+func (m *LogMailer) Send(ctx context.Context, message Message) error {
+	m.logger.Info("Mail", "to", message.To, "subject", message.Subject, "body", message.Body)
+	return nil
+}
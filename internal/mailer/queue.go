@@ -0,0 +1,97 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+
+	"github.com/assylzhan-a/company-task/pkg/logger"
+)
+
+var ErrQueueFull = errors.New("mail queue is full")
+
+// Queue sends mail in the background, so that requests neither wait for the
+// mail server nor reveal by their response time or errors whether a message
+// was sent. Failed messages are logged.
+type Queue struct {
+	mailer   Mailer
+	logger   *logger.Logger
+	messages chan Message
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewQueue returns a queue holding up to size messages that are sent with
+// mailer once the queue is started.
+func NewQueue(mailer Mailer, size int, logger *logger.Logger) *Queue {
+	return &Queue{
+		mailer:   mailer,
+		logger:   logger,
+		messages: make(chan Message, size),
+	}
+}
+
+// Send queues the message. It only fails if the queue is full, which is
+// logged as well.
+func (q *Queue) Send(ctx context.Context, message Message) error {
+	select {
+	case q.messages <- message:
+		return nil
+	default:
+		q.logger.Error("Failed to queue mail", "subject", message.Subject, "error", ErrQueueFull)
+		return ErrQueueFull
+	}
+}
+
+func (q *Queue) Name() string {
+	return "mail_queue"
+}
+
+func (q *Queue) Start(ctx context.Context) error {
+	q.stop = make(chan struct{})
+	q.done = make(chan struct{})
+	go func() {
+		defer close(q.done)
+		for {
+			select {
+			case message := <-q.messages:
+				q.send(message)
+			case <-q.stop:
+				q.drain()
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// Stop sends the messages still queued before returning.
+func (q *Queue) Stop(ctx context.Context) error {
+	if q.stop == nil {
+		return nil
+	}
+	close(q.stop)
+	select {
+	case <-q.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (q *Queue) drain() {
+	for {
+		select {
+		case message := <-q.messages:
+			q.send(message)
+		default:
+			return
+		}
+	}
+}
+
+func (q *Queue) send(message Message) {
+	if err := q.mailer.Send(context.Background(), message); err != nil {
+		q.logger.Error("Failed to send mail", "subject", message.Subject, "error", err)
+	}
+}
@@ -0,0 +1,38 @@
+package mailer
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/assylzhan-a/company-task/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingMailer struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+func (m *recordingMailer) Send(ctx context.Context, message Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = append(m.messages, message)
+	return nil
+}
+
+func TestQueueSendsQueuedMessagesOnStop(t *testing.T) {
+	recorder := &recordingMailer{}
+	queue := NewQueue(recorder, 2, logger.NewLogger("error"))
+	ctx := context.Background()
+
+	require.NoError(t, queue.Send(ctx, Message{To: "a@example.com"}))
+	require.NoError(t, queue.Send(ctx, Message{To: "b@example.com"}))
+	assert.ErrorIs(t, queue.Send(ctx, Message{To: "c@example.com"}), ErrQueueFull)
+	assert.Empty(t, recorder.messages, "nothing is sent before the queue starts")
+
+	require.NoError(t, queue.Start(ctx))
+	require.NoError(t, queue.Stop(ctx))
+	assert.Len(t, recorder.messages, 2)
+}
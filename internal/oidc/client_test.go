@@ -0,0 +1,108 @@
+package oidc_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/assylzhan-a/company-task/internal/oidc"
+	"github.com/assylzhan-a/company-task/internal/oidc/oidctest"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	redirectURL  = "https://app.example.com/v1/users/sso/callback"
+	codeVerifier = "verifier-0123456789-0123456789-0123456789"
+)
+
+// login runs the authorization code flow with the challenge of codeVerifier
+// and exchanges the code with the given verifier.
+func login(t *testing.T, provider *oidctest.Provider, client oidc.Provider, verifier, nonce string) (*oidc.IDToken, error) {
+	t.Helper()
+	ctx := context.Background()
+
+	authURL, err := client.AuthCodeURL(ctx, "state-1", nonce, oidc.CodeChallenge(codeVerifier))
+	require.NoError(t, err)
+	callback, err := provider.Authorize(authURL)
+	require.NoError(t, err)
+	require.Equal(t, "state-1", callback.Query().Get("state"))
+
+	return client.Exchange(ctx, callback.Query().Get("code"), verifier, nonce)
+}
+
+func newClient(provider *oidctest.Provider) oidc.Provider {
+	return oidc.NewClient(oidc.Config{
+		IssuerURL:    provider.Issuer(),
+		ClientID:     provider.ClientID,
+		ClientSecret: provider.ClientSecret,
+		RedirectURL:  redirectURL,
+	}, nil)
+}
+
+func TestExchangeValidatesIDToken(t *testing.T) {
+	provider := oidctest.NewProvider("client", "secret")
+	defer provider.Close()
+	provider.SetClaims(map[string]interface{}{
+		"sub":                "u-42",
+		"preferred_username": "jdoe",
+		"email_verified":     true,
+		"groups":             []string{"engineering", "admins"},
+	})
+
+	token, err := login(t, provider, newClient(provider), codeVerifier, "nonce-1")
+	require.NoError(t, err)
+	assert.Equal(t, provider.Issuer(), token.Issuer)
+	assert.Equal(t, "u-42", token.Subject)
+	assert.Equal(t, "jdoe", token.String("preferred_username"))
+	assert.True(t, token.Bool("email_verified"))
+	assert.Equal(t, []string{"engineering", "admins"}, token.Strings("groups"))
+}
+
+func TestExchangeRequiresMatchingCodeVerifier(t *testing.T) {
+	provider := oidctest.NewProvider("client", "secret")
+	defer provider.Close()
+
+	_, err := login(t, provider, newClient(provider), "another-verifier", "nonce-1")
+	assert.Error(t, err)
+}
+
+func TestExchangeRejectsInvalidIDTokens(t *testing.T) {
+	tests := map[string]func(claims jwt.MapClaims){
+		"wrong nonce":     func(claims jwt.MapClaims) { claims["nonce"] = "other" },
+		"wrong audience":  func(claims jwt.MapClaims) { claims["aud"] = "other-client" },
+		"wrong issuer":    func(claims jwt.MapClaims) { claims["iss"] = "https://evil.example.com" },
+		"expired":         func(claims jwt.MapClaims) { claims["exp"] = time.Now().Add(-time.Minute).Unix() },
+		"missing subject": func(claims jwt.MapClaims) { delete(claims, "sub") },
+		"other authorized party": func(claims jwt.MapClaims) {
+			claims["aud"] = []string{"client", "other-client"}
+			claims["azp"] = "other-client"
+		},
+	}
+
+	for name, modify := range tests {
+		t.Run(name, func(t *testing.T) {
+			provider := oidctest.NewProvider("client", "secret")
+			defer provider.Close()
+			provider.ModifyIDToken = modify
+
+			_, err := login(t, provider, newClient(provider), codeVerifier, "nonce-1")
+			assert.True(t, errors.Is(err, oidc.ErrInvalidIDToken), "got %v", err)
+		})
+	}
+}
+
+func TestDiscoveryRequiresConfiguredIssuer(t *testing.T) {
+	provider := oidctest.NewProvider("client", "secret")
+	defer provider.Close()
+
+	client := oidc.NewClient(oidc.Config{
+		IssuerURL:   provider.Issuer() + "/",
+		ClientID:    "client",
+		RedirectURL: redirectURL,
+	}, nil)
+	_, err := client.AuthCodeURL(context.Background(), "state", "nonce", "challenge")
+	assert.Error(t, err)
+}
@@ -0,0 +1,64 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrInvalidIDToken is returned when the ID token fails validation.
+var ErrInvalidIDToken = errors.New("invalid id token")
+
+// Provider is an OpenID Connect identity provider that users are sent to for
+// login with the authorization code flow.
+type Provider interface {
+	// AuthCodeURL returns the authorization endpoint URL to redirect the user
+	// to. codeChallenge is the S256 PKCE challenge of the verifier that is later
+	// passed to Exchange.
+	AuthCodeURL(ctx context.Context, state, nonce, codeChallenge string) (string, error)
+	// Exchange redeems the authorization code and returns the validated ID
+	// token, which has to carry the nonce of the authorization request.
+	Exchange(ctx context.Context, code, codeVerifier, nonce string) (*IDToken, error)
+}
+
+// IDToken holds the validated claims of an ID token.
+type IDToken struct {
+	Issuer  string
+	Subject string
+	Claims  map[string]interface{}
+}
+
+// String returns a string claim or "" if it is missing or not a string.
+func (t *IDToken) String(name string) string {
+	value, _ := t.Claims[name].(string)
+	return value
+}
+
+// Bool returns a boolean claim. Some providers send booleans as strings.
+func (t *IDToken) Bool(name string) bool {
+	switch value := t.Claims[name].(type) {
+	case bool:
+		return value
+	case string:
+		return value == "true"
+	default:
+		return false
+	}
+}
+
+// Strings returns a claim that is either a list of strings or a single string.
+func (t *IDToken) Strings(name string) []string {
+	switch value := t.Claims[name].(type) {
+	case string:
+		return []string{value}
+	case []interface{}:
+		values := make([]string, 0, len(value))
+		for _, item := range value {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,23 @@
+package repository
+
+import (
+	"context"
+	"github.com/assylzhan-a/company-task/internal/domain/entity"
+	"github.com/google/uuid"
+	"time"
+)
+
+type APIKeyRepository interface {
+	// CreateServiceAccount stores the account together with its backing user
+	// and organization membership.
+	CreateServiceAccount(ctx context.Context, account *entity.ServiceAccount) error
+	GetServiceAccount(ctx context.Context, id uuid.UUID) (*entity.ServiceAccount, error)
+	ListServiceAccounts(ctx context.Context, organizationID uuid.UUID) ([]*entity.ServiceAccount, error)
+	CreateAPIKey(ctx context.Context, key *entity.APIKey) error
+	GetAPIKeyByPrefix(ctx context.Context, prefix string) (*entity.APIKey, error)
+	ListAPIKeys(ctx context.Context, serviceAccountID uuid.UUID) ([]*entity.APIKey, error)
+	RevokeAPIKey(ctx context.Context, serviceAccountID, keyID uuid.UUID) error
+	// TouchAPIKey records a use of the key. Writes are coalesced to at most
+	// one per entity.APIKeyTouchInterval per key, also across instances.
+	TouchAPIKey(ctx context.Context, id uuid.UUID, usedAt time.Time) error
+}
@@ -4,13 +4,38 @@ import (
 	"context"
 	"github.com/assylzhan-a/company-task/internal/domain/entity"
 	"github.com/google/uuid"
+	"time"
 )
 
 type CompanyRepository interface {
-	CreateWithOutboxEvent(ctx context.Context, company *entity.Company, event *entity.OutboxEvent) error
-	UpdateWithOutboxEvent(ctx context.Context, company *entity.Company, event *entity.OutboxEvent) error
-	Delete(ctx context.Context, id uuid.UUID) error
+	// CreateWithOutboxEvent, UpdateWithOutboxEvent and DeleteWithOutboxEvent
+	// store the revision in the same transaction as the change and number it.
+	// Creating a company makes its slug unique within the organization by
+	// adding a numeric suffix if needed. Updates and deletes only apply if
+	// the company still has expectedVersion, unless it is 0.
+	CreateWithOutboxEvent(ctx context.Context, company *entity.Company, event *entity.OutboxEvent, revision *entity.CompanyRevision) error
+	UpdateWithOutboxEvent(ctx context.Context, company *entity.Company, expectedVersion int64, event *entity.OutboxEvent, revision *entity.CompanyRevision) error
+	// UpsertWithOutboxEvent inserts the company or replaces it if its version
+	// is expectedVersion; 0 requires that it does not exist yet.
+	UpsertWithOutboxEvent(ctx context.Context, company *entity.Company, expectedVersion int64, event *entity.OutboxEvent, revision *entity.CompanyRevision) error
+	DeleteWithOutboxEvent(ctx context.Context, id uuid.UUID, expectedVersion int64, event *entity.OutboxEvent, revision *entity.CompanyRevision) error
+	// ApplyChanges stores the changes in order in one transaction, or none
+	// of them; an *entity.CompanyChangeError names the change that failed.
+	ApplyChanges(ctx context.Context, changes []*entity.CompanyChange) error
 	GetByID(ctx context.Context, id uuid.UUID) (*entity.Company, error)
+	GetBySlug(ctx context.Context, slug string) (*entity.Company, error)
+	// Stream calls fn for every company of the organization that matches the
+	// filter, oldest first, as of when it starts. It stops at the first error
+	// fn returns.
+	Stream(ctx context.Context, filter entity.CompanyFilter, fn func(*entity.Company) error) error
+	// Stats counts the companies of the organization that match the filter
+	// of the query, in groups ordered by the dimensions of the query.
+	Stats(ctx context.Context, query entity.CompanyStatsQuery) ([]*entity.CompanyStatsGroup, error)
+	// ListRevisions returns the revisions of the company, oldest first.
+	ListRevisions(ctx context.Context, id uuid.UUID) ([]*entity.CompanyRevision, error)
+	GetRevision(ctx context.Context, id uuid.UUID, revision int64) (*entity.CompanyRevision, error)
+	// GetRevisionAt returns the latest revision made at or before asOf.
+	GetRevisionAt(ctx context.Context, id uuid.UUID, asOf time.Time) (*entity.CompanyRevision, error)
 	GetOutboxEvents(ctx context.Context, limit int) ([]*entity.OutboxEvent, error)
 	DeleteOutboxEvent(ctx context.Context, id uuid.UUID) error
 }
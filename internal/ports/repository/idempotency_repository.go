@@ -0,0 +1,25 @@
+package repository
+
+import (
+	"context"
+	"github.com/assylzhan-a/company-task/internal/domain/entity"
+	"time"
+)
+
+type IdempotencyRepository interface {
+	// Claim reserves the scope and key of the record for a request until the
+	// record expires. If the key already holds a record that has not
+	// expired, a stored response or another request's claim, Claim returns
+	// it instead.
+	Claim(ctx context.Context, record *entity.IdempotencyRecord) (*entity.IdempotencyRecord, error)
+	// Store saves the response of a claimed record, which is kept until its
+	// new expiry.
+	Store(ctx context.Context, record *entity.IdempotencyRecord) error
+	// Extend moves the expiry of a claim that has no response yet to the
+	// record's, so that a request running for longer than its claim keeps it.
+	Extend(ctx context.Context, record *entity.IdempotencyRecord) error
+	// Release gives up a claim without a response, so that a retry runs the
+	// request again.
+	Release(ctx context.Context, record *entity.IdempotencyRecord) error
+	DeleteExpired(ctx context.Context, now time.Time) error
+}
@@ -0,0 +1,31 @@
+package repository
+
+import (
+	"context"
+	"github.com/assylzhan-a/company-task/internal/domain/entity"
+	"github.com/google/uuid"
+	"time"
+)
+
+// ImportRepository stores import jobs. Except for Claim, it only finds the
+// jobs of the organization of ctx.
+type ImportRepository interface {
+	Create(ctx context.Context, job *entity.ImportJob) error
+	// GetByID returns the job without its data.
+	GetByID(ctx context.Context, id uuid.UUID) (*entity.ImportJob, error)
+	// GetData returns the uploaded file of the job.
+	GetData(ctx context.Context, id uuid.UUID) ([]byte, error)
+	// Queue sets the mapping of an uploaded or failed job and queues it.
+	Queue(ctx context.Context, id uuid.UUID, mapping entity.ImportMapping) (*entity.ImportJob, error)
+	// Claim leases the oldest queued job, or a running job whose lease has
+	// expired, to the caller until leaseUntil, including its data. It
+	// returns nil if there is no such job.
+	Claim(ctx context.Context, now, leaseUntil time.Time) (*entity.ImportJob, error)
+	// SaveProgress stores the counters, status and error of a claimed job,
+	// adds the row errors and sets its lease to leaseUntil; nil releases it.
+	// It returns entity.ErrImportLeaseLost if another worker took the job.
+	SaveProgress(ctx context.Context, job *entity.ImportJob, rowErrors []*entity.ImportRowError, leaseUntil *time.Time) error
+	// ListRowErrors returns the errors of up to limit rows after afterRow,
+	// in the order of the rows.
+	ListRowErrors(ctx context.Context, id uuid.UUID, afterRow, limit int) ([]*entity.ImportRowError, error)
+}
@@ -0,0 +1,20 @@
+package repository
+
+import (
+	"context"
+	"github.com/assylzhan-a/company-task/internal/domain/entity"
+	"time"
+)
+
+type LoginThrottleRepository interface {
+	// Acquire counts a login attempt as a failure before the credentials are
+	// checked, unless the account or IP has to wait first; then it returns
+	// the throttle unchanged and false. Attempts for the same throttle are
+	// serialized, so concurrent attempts see each other's failures.
+	Acquire(ctx context.Context, kind, key string, now time.Time, policy entity.LockoutPolicy) (*entity.LoginThrottle, bool, error)
+	// Release takes back the failure counted by Acquire, for an attempt
+	// whose credentials turned out to be correct.
+	Release(ctx context.Context, throttle *entity.LoginThrottle) error
+	Lock(ctx context.Context, kind, key string, until time.Time) error
+	Reset(ctx context.Context, kind, key string) error
+}
@@ -0,0 +1,26 @@
+package repository
+
+import (
+	"context"
+	"github.com/assylzhan-a/company-task/internal/domain/entity"
+	"github.com/google/uuid"
+	"time"
+)
+
+type MFARepository interface {
+	// Get returns entity.ErrMFANotEnrolled if the user has no enrollment.
+	Get(ctx context.Context, userID uuid.UUID) (*entity.UserMFA, error)
+	// SaveEnrollment stores a new, not yet enabled enrollment, replacing any
+	// earlier unconfirmed one.
+	SaveEnrollment(ctx context.Context, mfa *entity.UserMFA) error
+	// Enable confirms the enrollment, records the step of the confirming code
+	// and stores the recovery codes.
+	Enable(ctx context.Context, userID uuid.UUID, confirmedAt time.Time, step int64, codes []*entity.RecoveryCode) error
+	Delete(ctx context.Context, userID uuid.UUID) error
+	// UseStep records a used TOTP step. It returns false if the step or a later
+	// one was already used, so concurrent replays of a code fail.
+	UseStep(ctx context.Context, userID uuid.UUID, step int64) (bool, error)
+	// UseRecoveryCode marks an unused code as used and reports whether it existed.
+	UseRecoveryCode(ctx context.Context, userID uuid.UUID, codeHash string) (bool, error)
+	ReplaceRecoveryCodes(ctx context.Context, userID uuid.UUID, codes []*entity.RecoveryCode) error
+}
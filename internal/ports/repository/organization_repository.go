@@ -0,0 +1,18 @@
+package repository
+
+import (
+	"context"
+	"github.com/assylzhan-a/company-task/internal/domain/entity"
+	"github.com/google/uuid"
+)
+
+type OrganizationRepository interface {
+	Create(ctx context.Context, organization *entity.Organization) error
+	GetByID(ctx context.Context, id uuid.UUID) (*entity.Organization, error)
+	List(ctx context.Context) ([]*entity.Organization, error)
+	// ListByUser returns the organizations of the user, oldest membership first.
+	ListByUser(ctx context.Context, userID uuid.UUID) ([]*entity.Organization, error)
+	AddMember(ctx context.Context, organizationID, userID uuid.UUID) error
+	RemoveMember(ctx context.Context, organizationID, userID uuid.UUID) error
+	IsMember(ctx context.Context, organizationID, userID uuid.UUID) (bool, error)
+}
@@ -0,0 +1,15 @@
+package repository
+
+import (
+	"context"
+	"github.com/assylzhan-a/company-task/internal/domain/entity"
+	"github.com/google/uuid"
+)
+
+type RoleRepository interface {
+	List(ctx context.Context) ([]*entity.Role, error)
+	// GetUserAccess returns the roles of the user and the union of their permissions.
+	GetUserAccess(ctx context.Context, userID uuid.UUID) (roles []string, permissions []string, err error)
+	AssignRole(ctx context.Context, userID uuid.UUID, role string) error
+	RevokeRole(ctx context.Context, userID uuid.UUID, role string) error
+}
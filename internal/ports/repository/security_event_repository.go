@@ -0,0 +1,22 @@
+package repository
+
+import (
+	"context"
+	"github.com/assylzhan-a/company-task/internal/domain/entity"
+)
+
+type SecurityEventRepository interface {
+	// Create queues the event. It is listed once AppendQueued has added it
+	// to the hash chain.
+	Create(ctx context.Context, event *entity.SecurityEvent) error
+	// AppendQueued appends up to limit queued events to the hash chain in the
+	// order they were queued and returns how many it appended. It appends
+	// nothing if another instance is appending at the same time.
+	AppendQueued(ctx context.Context, limit int) (int, error)
+	// List returns events matching the filter in sequence order. Events from
+	// before the chain are listed first.
+	List(ctx context.Context, filter entity.SecurityEventFilter) ([]*entity.SecurityEvent, error)
+	// Stream calls fn for every event matching the filter, in the same order
+	// as List, ignoring the limit. It stops at the first error fn returns.
+	Stream(ctx context.Context, filter entity.SecurityEventFilter, fn func(*entity.SecurityEvent) error) error
+}
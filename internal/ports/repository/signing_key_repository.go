@@ -0,0 +1,14 @@
+package repository
+
+import (
+	"context"
+	"github.com/assylzhan-a/company-task/internal/domain/entity"
+	"time"
+)
+
+type SigningKeyRepository interface {
+	Create(ctx context.Context, key *entity.SigningKey) error
+	List(ctx context.Context, algorithm string, now time.Time) ([]*entity.SigningKey, error)
+	UpdatePrivateKey(ctx context.Context, id string, privateKey []byte) error
+	DeleteExpired(ctx context.Context, now time.Time) error
+}
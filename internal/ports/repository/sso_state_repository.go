@@ -0,0 +1,15 @@
+package repository
+
+import (
+	"context"
+	"github.com/assylzhan-a/company-task/internal/domain/entity"
+	"time"
+)
+
+type SSOStateRepository interface {
+	// Create stores the login state; only a hash of the state value is kept.
+	Create(ctx context.Context, state *entity.SSOLoginState) error
+	// Consume deletes and returns the unexpired login state with the given
+	// state value, or entity.ErrInvalidSSOState.
+	Consume(ctx context.Context, state string, now time.Time) (*entity.SSOLoginState, error)
+}
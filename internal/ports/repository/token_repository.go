@@ -0,0 +1,30 @@
+package repository
+
+import (
+	"context"
+	"github.com/assylzhan-a/company-task/internal/domain/entity"
+	"github.com/google/uuid"
+	"time"
+)
+
+type TokenRepository interface {
+	CreateRefreshToken(ctx context.Context, token *entity.RefreshToken) error
+	GetRefreshTokenByHash(ctx context.Context, hash string) (*entity.RefreshToken, error)
+	// RotateRefreshToken revokes the old token and stores its replacement atomically.
+	// It returns entity.ErrRefreshTokenReused if the old token was already revoked.
+	RotateRefreshToken(ctx context.Context, oldID uuid.UUID, replacement *entity.RefreshToken) error
+	RevokeRefreshTokenFamily(ctx context.Context, familyID uuid.UUID) error
+	// RevokeUserRefreshTokens revokes every active token of the user and returns the affected families.
+	RevokeUserRefreshTokens(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error)
+
+	RevokeTokenIDs(ctx context.Context, ids []uuid.UUID, expiresAt time.Time) error
+	GetRevokedTokenIDs(ctx context.Context) (map[uuid.UUID]time.Time, error)
+	DeleteExpiredTokens(ctx context.Context, now time.Time) error
+
+	// CreateOneTimeToken stores the token and invalidates earlier unused
+	// tokens of the user for the same purpose.
+	CreateOneTimeToken(ctx context.Context, token *entity.OneTimeToken) error
+	// ConsumeOneTimeToken marks an unused, unexpired token as used and returns
+	// it, or entity.ErrInvalidOneTimeToken.
+	ConsumeOneTimeToken(ctx context.Context, purpose, hash string, now time.Time) (*entity.OneTimeToken, error)
+}
@@ -3,9 +3,24 @@ package repository
 import (
 	"context"
 	"github.com/assylzhan-a/company-task/internal/domain/entity"
+	"github.com/google/uuid"
+	"time"
 )
 
 type UserRepository interface {
 	Create(ctx context.Context, user *entity.User) error
+	GetByID(ctx context.Context, id uuid.UUID) (*entity.User, error)
 	GetByUsername(ctx context.Context, username string) (*entity.User, error)
+	// GetByIdentity returns the user linked to the external identity.
+	GetByIdentity(ctx context.Context, issuer, subject string) (*entity.User, error)
+	// GetByEmail matches the address case-insensitively.
+	GetByEmail(ctx context.Context, email string) (*entity.User, error)
+	UpdatePassword(ctx context.Context, userID uuid.UUID, passwordHash string) error
+	// MarkEmailVerified verifies the user's email if it is still the given one.
+	MarkEmailVerified(ctx context.Context, userID uuid.UUID, email string) error
+	// List returns users, except service accounts, ordered by creation.
+	List(ctx context.Context, filter entity.UserFilter) ([]*entity.User, error)
+	// SetDisabled disables the user at the given time or, with nil, enables it.
+	SetDisabled(ctx context.Context, userID uuid.UUID, disabledAt *time.Time) error
+	Delete(ctx context.Context, userID uuid.UUID) error
 }
@@ -0,0 +1,17 @@
+package usecase
+
+import (
+	"context"
+	"github.com/assylzhan-a/company-task/internal/domain/entity"
+)
+
+// AuditUseCase reads the security audit log.
+type AuditUseCase interface {
+	ListEvents(ctx context.Context, filter entity.SecurityEventFilter) ([]*entity.SecurityEvent, error)
+	// ExportEvents calls fn for every event matching the filter, ignoring the
+	// limit.
+	ExportEvents(ctx context.Context, filter entity.SecurityEventFilter, fn func(*entity.SecurityEvent) error) error
+	// VerifyChain recomputes the hash chain and reports the first event that
+	// was changed, inserted or removed.
+	VerifyChain(ctx context.Context) (*entity.ChainVerification, error)
+}
@@ -0,0 +1,18 @@
+package usecase
+
+import (
+	"context"
+	"github.com/assylzhan-a/company-task/internal/domain/entity"
+)
+
+// CompanyStatsUseCase computes statistics of the companies of the caller's
+// active organization.
+type CompanyStatsUseCase interface {
+	// Stats returns the results of the query, cached unless a company of
+	// the organization changed since they were computed. They must not be
+	// modified.
+	Stats(ctx context.Context, query entity.CompanyStatsQuery) (*entity.CompanyStats, error)
+	// HandleOutboxEvent drops the cached results of the organization of a
+	// company event published by any instance.
+	HandleOutboxEvent(event *entity.OutboxEvent)
+}
@@ -3,12 +3,29 @@ package usecase
 import (
 	"context"
 	"github.com/assylzhan-a/company-task/internal/domain/entity"
+	"github.com/assylzhan-a/company-task/pkg/tabular"
 	"github.com/google/uuid"
+	"io"
+	"time"
 )
 
 type CompanyUseCase interface {
+	// Create generates the ID of the company if it has none.
 	Create(ctx context.Context, company *entity.Company) error
-	Patch(ctx context.Context, id uuid.UUID, patch *entity.PatchCompany) error
+	// Put creates the company or replaces it and reports whether it was
+	// created.
+	Put(ctx context.Context, company *entity.Company, precondition entity.CompanyPrecondition) (bool, error)
+	Patch(ctx context.Context, id uuid.UUID, patch entity.CompanyPatch) error
+	TransferOwnership(ctx context.Context, id uuid.UUID, newOwnerID uuid.UUID) error
 	Delete(ctx context.Context, id uuid.UUID) error
+	// Batch runs the operations of the batch and reports the outcome of each.
+	Batch(ctx context.Context, batch *entity.CompanyBatch) ([]*entity.CompanyBatchResult, error)
 	GetByID(ctx context.Context, id uuid.UUID) (*entity.Company, error)
+	GetBySlug(ctx context.Context, slug string) (*entity.Company, error)
+	// Export writes the companies matching the filter to w as a file of the
+	// format with entity.CompanyExportColumns, oldest first.
+	Export(ctx context.Context, filter entity.CompanyFilter, format tabular.Format, w io.Writer) error
+	GetAsOf(ctx context.Context, id uuid.UUID, asOf time.Time) (*entity.Company, error)
+	History(ctx context.Context, id uuid.UUID) ([]*entity.CompanyRevision, error)
+	Diff(ctx context.Context, id uuid.UUID, fromRevision, toRevision int64) (*entity.CompanyDiff, error)
 }
@@ -0,0 +1,27 @@
+package usecase
+
+import (
+	"context"
+	"github.com/assylzhan-a/company-task/internal/domain/entity"
+	"github.com/assylzhan-a/company-task/pkg/tabular"
+	"github.com/google/uuid"
+)
+
+// ImportUseCase imports companies from files into the caller's active
+// organization.
+type ImportUseCase interface {
+	// Upload stores the file with a suggested mapping of its columns.
+	Upload(ctx context.Context, format tabular.Format, data []byte) (*entity.ImportJob, error)
+	Get(ctx context.Context, id uuid.UUID) (*entity.ImportJob, error)
+	// DryRun checks every row with the mapping, or the mapping of the job if
+	// nil, without importing anything.
+	DryRun(ctx context.Context, id uuid.UUID, mapping entity.ImportMapping) (*entity.ImportReport, error)
+	// Run queues the job to be imported in the background with the mapping,
+	// or the mapping of the job if nil.
+	Run(ctx context.Context, id uuid.UUID, mapping entity.ImportMapping) (*entity.ImportJob, error)
+	// ListErrors returns the errors of up to limit rows after afterRow.
+	ListErrors(ctx context.Context, id uuid.UUID, afterRow, limit int) ([]*entity.ImportRowError, error)
+	// ProcessNext claims a queued job and imports its remaining rows. It
+	// reports whether there was a job to process.
+	ProcessNext(ctx context.Context) (bool, error)
+}
@@ -0,0 +1,17 @@
+package usecase
+
+import (
+	"context"
+	"github.com/assylzhan-a/company-task/internal/domain/entity"
+	"github.com/google/uuid"
+)
+
+// MFAUseCase manages a user's TOTP enrollment and recovery codes.
+type MFAUseCase interface {
+	Enroll(ctx context.Context, userID uuid.UUID) (*entity.MFAEnrollment, error)
+	// Confirm enables MFA with a first code and returns the recovery codes.
+	Confirm(ctx context.Context, userID uuid.UUID, code string) ([]string, error)
+	// Disable and RegenerateRecoveryCodes accept a TOTP or a recovery code.
+	Disable(ctx context.Context, userID uuid.UUID, code string) error
+	RegenerateRecoveryCodes(ctx context.Context, userID uuid.UUID, code string) ([]string, error)
+}
@@ -0,0 +1,15 @@
+package usecase
+
+import (
+	"context"
+	"github.com/assylzhan-a/company-task/internal/domain/entity"
+	"github.com/google/uuid"
+)
+
+type OrganizationUseCase interface {
+	Create(ctx context.Context, name string) (*entity.Organization, error)
+	List(ctx context.Context) ([]*entity.Organization, error)
+	ListForUser(ctx context.Context, userID uuid.UUID) ([]*entity.Organization, error)
+	AddMember(ctx context.Context, organizationID, userID uuid.UUID) error
+	RemoveMember(ctx context.Context, organizationID, userID uuid.UUID) error
+}
@@ -0,0 +1,19 @@
+package usecase
+
+import (
+	"context"
+	"github.com/assylzhan-a/company-task/internal/domain/entity"
+	"github.com/google/uuid"
+)
+
+type RoleUseCase interface {
+	ListRoles(ctx context.Context) ([]*entity.Role, error)
+	GetUserRoles(ctx context.Context, userID uuid.UUID) ([]string, error)
+	// AssignRole grants the role to the user on behalf of the actor.
+	AssignRole(ctx context.Context, actorID, userID uuid.UUID, role string) error
+	RevokeRole(ctx context.Context, actorID, userID uuid.UUID, role string) error
+	// BootstrapAdmin grants the admin role to an existing user, so that the
+	// first administrator can assign roles. It is run by the operator at
+	// startup, never on behalf of a request.
+	BootstrapAdmin(ctx context.Context, username string) error
+}
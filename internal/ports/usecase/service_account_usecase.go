@@ -0,0 +1,19 @@
+package usecase
+
+import (
+	"context"
+	"github.com/assylzhan-a/company-task/internal/domain/entity"
+	"github.com/google/uuid"
+	"time"
+)
+
+// ServiceAccountUseCase manages service accounts of the caller's active
+// organization and their API keys.
+type ServiceAccountUseCase interface {
+	Create(ctx context.Context, name string) (*entity.ServiceAccount, error)
+	List(ctx context.Context) ([]*entity.ServiceAccount, error)
+	// CreateAPIKey returns the new key and its raw value, which is not stored.
+	CreateAPIKey(ctx context.Context, serviceAccountID uuid.UUID, scopes []string, expiresAt *time.Time) (*entity.APIKey, string, error)
+	ListAPIKeys(ctx context.Context, serviceAccountID uuid.UUID) ([]*entity.APIKey, error)
+	RevokeAPIKey(ctx context.Context, serviceAccountID, keyID uuid.UUID) error
+}
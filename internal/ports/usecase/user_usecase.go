@@ -1,8 +1,29 @@
 package usecase
 
-import "context"
+import (
+	"context"
+	"github.com/assylzhan-a/company-task/internal/domain/entity"
+	"github.com/google/uuid"
+)
 
 type UserUseCase interface {
-	Register(ctx context.Context, username, password string) error
-	Login(ctx context.Context, username, password string) (string, error)
+	Register(ctx context.Context, username, password, email string) error
+	Login(ctx context.Context, username, password, clientIP string, organizationID *uuid.UUID) (*entity.LoginResult, error)
+	VerifyMFA(ctx context.Context, mfaToken, code, clientIP string) (*entity.TokenPair, error)
+	SwitchOrganization(ctx context.Context, userID, sessionID, organizationID uuid.UUID) (*entity.TokenPair, error)
+	Refresh(ctx context.Context, refreshToken, clientIP string) (*entity.TokenPair, error)
+	Logout(ctx context.Context, sessionID uuid.UUID) error
+	LogoutAll(ctx context.Context, userID uuid.UUID) error
+	SendVerificationEmail(ctx context.Context, userID uuid.UUID) error
+	VerifyEmail(ctx context.Context, token string) error
+	RequestPasswordReset(ctx context.Context, email, clientIP string) error
+	ResetPassword(ctx context.Context, token, password, clientIP string) error
+	StartSSOLogin(ctx context.Context) (*entity.SSORedirect, error)
+	CompleteSSOLogin(ctx context.Context, state, code, clientIP string) (*entity.LoginResult, error)
+	GetProfile(ctx context.Context, userID uuid.UUID) (*entity.UserProfile, error)
+	ChangePassword(ctx context.Context, userID uuid.UUID, organizationID *uuid.UUID, currentPassword, newPassword, clientIP string) (*entity.TokenPair, error)
+	DeleteAccount(ctx context.Context, userID uuid.UUID, password, clientIP string) error
+	ListUsers(ctx context.Context, filter entity.UserFilter) ([]*entity.User, error)
+	DisableUser(ctx context.Context, actorID, userID uuid.UUID) error
+	EnableUser(ctx context.Context, actorID, userID uuid.UUID) error
 }
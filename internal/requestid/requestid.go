@@ -0,0 +1,59 @@
+package requestid
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/google/uuid"
+)
+
+// Header carries the request ID in requests and responses.
+const Header = "X-Request-ID"
+
+// maxLength bounds request IDs accepted from clients.
+const maxLength = 128
+
+type contextKey struct{}
+
+// WithRequestID returns a copy of ctx carrying the request ID.
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	ctx = context.WithValue(ctx, contextKey{}, requestID)
+	// Lets chi's request logger print the same ID.
+	return context.WithValue(ctx, middleware.RequestIDKey, requestID)
+}
+
+// FromContext returns the ID of the request, or "" outside of a request.
+func FromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(contextKey{}).(string)
+	return requestID
+}
+
+// Middleware assigns every request an ID, keeping the one sent by the client
+// or a proxy in the X-Request-ID header if it is reasonable, and echoes it in
+// the response.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(Header)
+		if !valid(requestID) {
+			requestID = uuid.NewString()
+		}
+
+		w.Header().Set(Header, requestID)
+		next.ServeHTTP(w, r.WithContext(WithRequestID(r.Context(), requestID)))
+	})
+}
+
+// valid accepts non-empty IDs of printable ASCII so that they can be logged
+// and stored safely.
+func valid(requestID string) bool {
+	if requestID == "" || len(requestID) > maxLength {
+		return false
+	}
+	for i := 0; i < len(requestID); i++ {
+		if requestID[i] < 0x21 || requestID[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,20 @@
+package tenant
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+type contextKey struct{}
+
+// WithOrganization returns a copy of ctx scoped to the organization.
+func WithOrganization(ctx context.Context, organizationID uuid.UUID) context.Context {
+	return context.WithValue(ctx, contextKey{}, organizationID)
+}
+
+// OrganizationFromContext returns the organization the request is scoped to.
+func OrganizationFromContext(ctx context.Context) (uuid.UUID, bool) {
+	organizationID, ok := ctx.Value(contextKey{}).(uuid.UUID)
+	return organizationID, ok && organizationID != uuid.Nil
+}
@@ -0,0 +1,97 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"github.com/assylzhan-a/company-task/internal/domain/entity"
+	"github.com/assylzhan-a/company-task/internal/kafka"
+	"github.com/assylzhan-a/company-task/pkg/logger"
+	"github.com/google/uuid"
+)
+
+// consumerRetryDelay is how long the consumer waits after failing to fetch a
+// message.
+const consumerRetryDelay = time.Second
+
+// CompanyEventConsumer reads the company events published by any instance and
+// passes them to its listeners, so that every instance drops the data it
+// cached from the changed companies and not only the one that published them.
+type CompanyEventConsumer struct {
+	consumer  kafka.Consumer
+	logger    *logger.Logger
+	listeners []OutboxListener
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func NewCompanyEventConsumer(consumer kafka.Consumer, logger *logger.Logger) *CompanyEventConsumer {
+	return &CompanyEventConsumer{consumer: consumer, logger: logger}
+}
+
+// WithListener adds a listener for the consumed events. Listeners are called
+// by the consumer's goroutine and must not block.
+func (c *CompanyEventConsumer) WithListener(listener OutboxListener) *CompanyEventConsumer {
+	c.listeners = append(c.listeners, listener)
+	return c
+}
+
+func (c *CompanyEventConsumer) Name() string {
+	return "company_event_consumer"
+}
+
+func (c *CompanyEventConsumer) Start(ctx context.Context) error {
+	ctx, c.cancel = context.WithCancel(ctx)
+	c.done = make(chan struct{})
+	go func() {
+		defer close(c.done)
+		for ctx.Err() == nil {
+			message, err := c.consumer.Fetch(ctx)
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				c.logger.Error("Failed to consume company event", "error", err)
+				select {
+				case <-ctx.Done():
+				case <-time.After(consumerRetryDelay):
+				}
+				continue
+			}
+			c.handle(message)
+		}
+	}()
+	return nil
+}
+
+// Stop stops consuming and closes the consumer, which leaves its group.
+func (c *CompanyEventConsumer) Stop(ctx context.Context) error {
+	if c.cancel == nil {
+		return nil
+	}
+	c.cancel()
+	select {
+	case <-c.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return c.consumer.Close()
+}
+
+// handle passes the message to the listeners as the outbox event it was
+// published from. Only its type and organization are known.
+func (c *CompanyEventConsumer) handle(message *kafka.Message) {
+	event := &entity.OutboxEvent{EventType: message.Topic, Payload: message.Value}
+	if value, ok := message.Headers[kafka.HeaderOrganizationID]; ok {
+		organizationID, err := uuid.Parse(value)
+		if err != nil {
+			c.logger.Warn("Ignoring company event with an invalid organization", "topic", message.Topic, "organization_id", value)
+			return
+		}
+		event.OrganizationID = &organizationID
+	}
+	for _, listener := range c.listeners {
+		listener.HandleOutboxEvent(event)
+	}
+}
@@ -0,0 +1,79 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/assylzhan-a/company-task/internal/domain/entity"
+	"github.com/assylzhan-a/company-task/internal/kafka"
+	"github.com/assylzhan-a/company-task/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// channelConsumer returns the messages of its channel after failing once.
+type channelConsumer struct {
+	messages chan *kafka.Message
+	failed   bool
+	closed   bool
+}
+
+func (c *channelConsumer) Fetch(ctx context.Context) (*kafka.Message, error) {
+	if !c.failed {
+		c.failed = true
+		return nil, errors.New("broker unavailable")
+	}
+	select {
+	case message := <-c.messages:
+		return message, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (c *channelConsumer) Close() error {
+	c.closed = true
+	return nil
+}
+
+type recordingListener struct {
+	mu     sync.Mutex
+	events []*entity.OutboxEvent
+}
+
+func (l *recordingListener) HandleOutboxEvent(event *entity.OutboxEvent) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events = append(l.events, event)
+}
+
+func (l *recordingListener) handled() []*entity.OutboxEvent {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]*entity.OutboxEvent(nil), l.events...)
+}
+
+func TestCompanyEventConsumerPassesEventsToListeners(t *testing.T) {
+	consumer := &channelConsumer{messages: make(chan *kafka.Message, 3)}
+	consumer.messages <- &kafka.Message{Topic: entity.CompanyDeletedEvent, Value: []byte(`{}`),
+		Headers: map[string]string{kafka.HeaderOrganizationID: entity.DefaultOrganizationID.String()}}
+	consumer.messages <- &kafka.Message{Topic: entity.CompanyCreatedEvent, Headers: map[string]string{kafka.HeaderOrganizationID: "invalid"}}
+	consumer.messages <- &kafka.Message{Topic: entity.CompanyUpdatedEvent}
+	listener := &recordingListener{}
+	c := NewCompanyEventConsumer(consumer, logger.NewLogger("error")).WithListener(listener)
+
+	require.NoError(t, c.Start(context.Background()))
+	assert.Eventually(t, func() bool { return len(listener.handled()) == 2 }, 3*time.Second, 5*time.Millisecond)
+	require.NoError(t, c.Stop(context.Background()))
+	assert.True(t, consumer.closed)
+
+	events := listener.handled()
+	assert.Equal(t, entity.CompanyDeletedEvent, events[0].EventType)
+	assert.Equal(t, entity.DefaultOrganizationID, *events[0].OrganizationID)
+	// Events without an organization concern all of them.
+	assert.Equal(t, entity.CompanyUpdatedEvent, events[1].EventType)
+	assert.Nil(t, events[1].OrganizationID)
+}
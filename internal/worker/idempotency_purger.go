@@ -0,0 +1,69 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	r "github.com/assylzhan-a/company-task/internal/ports/repository"
+	"github.com/assylzhan-a/company-task/pkg/logger"
+)
+
+// IdempotencyKeyPurger periodically deletes idempotency keys whose responses
+// have expired. Expired keys are already ignored when a request reuses them;
+// purging only bounds the size of the table.
+type IdempotencyKeyPurger struct {
+	repo     r.IdempotencyRepository
+	logger   *logger.Logger
+	interval time.Duration
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func NewIdempotencyKeyPurger(repo r.IdempotencyRepository, interval time.Duration, logger *logger.Logger) *IdempotencyKeyPurger {
+	return &IdempotencyKeyPurger{
+		repo:     repo,
+		logger:   logger,
+		interval: interval,
+	}
+}
+
+func (p *IdempotencyKeyPurger) Name() string {
+	return "idempotency_key_purger"
+}
+
+func (p *IdempotencyKeyPurger) Start(ctx context.Context) error {
+	p.stop = make(chan struct{})
+	p.done = make(chan struct{})
+	go func() {
+		defer close(p.done)
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-p.stop:
+				return
+			case <-ticker.C:
+				if err := p.repo.DeleteExpired(ctx, time.Now()); err != nil {
+					p.logger.Error("Failed to purge idempotency keys", "error", err)
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+func (p *IdempotencyKeyPurger) Stop(ctx context.Context) error {
+	if p.stop == nil {
+		return nil
+	}
+	close(p.stop)
+	select {
+	case <-p.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
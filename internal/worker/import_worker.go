@@ -0,0 +1,82 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	uc "github.com/assylzhan-a/company-task/internal/ports/usecase"
+	"github.com/assylzhan-a/company-task/pkg/logger"
+)
+
+// ImportWorker runs queued company imports one at a time. Several instances
+// of the service share the queue; each job is leased to one worker.
+type ImportWorker struct {
+	imports uc.ImportUseCase
+	logger  *logger.Logger
+	tick    time.Duration
+
+	stop   chan struct{}
+	done   chan struct{}
+	cancel context.CancelFunc
+}
+
+func NewImportWorker(imports uc.ImportUseCase, tick time.Duration, logger *logger.Logger) *ImportWorker {
+	return &ImportWorker{
+		imports: imports,
+		logger:  logger,
+		tick:    tick,
+	}
+}
+
+func (w *ImportWorker) Name() string {
+	return "import_worker"
+}
+
+// Start polls for queued jobs every tick and processes them until none is
+// left.
+func (w *ImportWorker) Start(ctx context.Context) error {
+	jobCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	w.stop = make(chan struct{})
+	w.done = make(chan struct{})
+	w.cancel = cancel
+	go func() {
+		defer close(w.done)
+		ticker := time.NewTicker(w.tick)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-w.stop:
+				return
+			case <-ticker.C:
+				for jobCtx.Err() == nil {
+					processed, err := w.imports.ProcessNext(jobCtx)
+					if err != nil {
+						w.logger.Error("Failed to process import", "error", err)
+					}
+					if !processed {
+						break
+					}
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// Stop interrupts the running job, which saves its progress and is resumed
+// by the next worker to poll for jobs.
+func (w *ImportWorker) Stop(ctx context.Context) error {
+	if w.stop == nil {
+		return nil
+	}
+	close(w.stop)
+	w.cancel()
+	select {
+	case <-w.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
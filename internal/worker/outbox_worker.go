@@ -2,16 +2,35 @@ package worker
 
 import (
 	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/assylzhan-a/company-task/internal/domain/entity"
 	"github.com/assylzhan-a/company-task/internal/kafka"
 	r "github.com/assylzhan-a/company-task/internal/ports/repository"
 	"github.com/assylzhan-a/company-task/pkg/logger"
-	"time"
 )
 
+var ErrDrainTimeout = errors.New("outbox worker did not drain in-flight batch before deadline")
+
+// OutboxListener is told about every event the worker publishes, e.g. to drop
+// data cached from the changed records.
+type OutboxListener interface {
+	HandleOutboxEvent(event *entity.OutboxEvent)
+}
+
 type OutboxWorker struct {
-	repo     r.CompanyRepository
-	producer kafka.Producer
-	logger   *logger.Logger
+	repo      r.CompanyRepository
+	producer  kafka.Producer
+	logger    *logger.Logger
+	tick      time.Duration
+	listeners []OutboxListener
+
+	mu          sync.Mutex
+	stop        chan struct{}
+	done        chan struct{}
+	cancelBatch context.CancelFunc
 }
 
 func NewOutboxWorker(repo r.CompanyRepository, producer kafka.Producer, logger *logger.Logger) *OutboxWorker {
@@ -19,19 +38,84 @@ func NewOutboxWorker(repo r.CompanyRepository, producer kafka.Producer, logger *
 		repo:     repo,
 		producer: producer,
 		logger:   logger,
+		tick:     5 * time.Second,
+	}
+}
+
+// WithTick overrides the polling interval of the worker.
+func (w *OutboxWorker) WithTick(tick time.Duration) *OutboxWorker {
+	if tick > 0 {
+		w.tick = tick
+	}
+	return w
+}
+
+// WithListener adds a listener for the published events. Listeners are called
+// by the worker's goroutine and must not block.
+func (w *OutboxWorker) WithListener(listener OutboxListener) *OutboxWorker {
+	w.listeners = append(w.listeners, listener)
+	return w
+}
+
+func (w *OutboxWorker) Name() string {
+	return "outbox_worker"
+}
+
+// Start launches the polling loop in the background. Batches are processed with
+// a context detached from ctx so that a shutdown lets the in-flight batch finish;
+// it is only aborted when Stop runs out of time.
+func (w *OutboxWorker) Start(ctx context.Context) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	batchCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	w.stop = make(chan struct{})
+	w.done = make(chan struct{})
+	w.cancelBatch = cancel
+
+	go w.run(ctx, batchCtx, w.stop, w.done)
+	return nil
+}
+
+// Stop stops polling and waits for the in-flight batch to finish. If ctx expires
+// first, the batch is cancelled and ErrDrainTimeout is returned without waiting
+// any longer, since a call that ignores the cancellation would otherwise hold up
+// the shutdown.
+func (w *OutboxWorker) Stop(ctx context.Context) error {
+	w.mu.Lock()
+	stop, done, cancel := w.stop, w.done, w.cancelBatch
+	w.stop = nil
+	w.mu.Unlock()
+
+	if stop == nil {
+		return nil
+	}
+	close(stop)
+	defer cancel()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		cancel()
+		return ErrDrainTimeout
 	}
 }
 
-func (w *OutboxWorker) Start(ctx context.Context) {
-	ticker := time.NewTicker(5 * time.Second)
+func (w *OutboxWorker) run(ctx, batchCtx context.Context, stop, done chan struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(w.tick)
 	defer ticker.Stop()
 
 	for {
 		select {
 		case <-ctx.Done():
 			return
+		case <-stop:
+			return
 		case <-ticker.C:
-			if err := w.ProcessOutboxEvents(ctx); err != nil {
+			if err := w.ProcessOutboxEvents(batchCtx); err != nil {
 				w.logger.Error("Failed to process outbox events", "error", err)
 			}
 		}
@@ -45,10 +129,24 @@ func (w *OutboxWorker) ProcessOutboxEvents(ctx context.Context) error {
 	}
 
 	for _, event := range events {
-		if err := w.producer.Produce(ctx, event.EventType, nil, event.Payload); err != nil {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		headers := map[string]string{}
+		if event.OrganizationID != nil {
+			headers[kafka.HeaderOrganizationID] = event.OrganizationID.String()
+		}
+
+		if err := w.producer.Produce(ctx, event.EventType, nil, event.Payload, headers); err != nil {
 			w.logger.Error("Failed to produce Kafka message", "error", err, "event_id", event.ID)
 			continue
 		}
+		// An event that is not deleted is published again, which listeners
+		// must tolerate like consumers do.
+		for _, listener := range w.listeners {
+			listener.HandleOutboxEvent(event)
+		}
 
 		if err := w.repo.DeleteOutboxEvent(ctx, event.ID); err != nil {
 			w.logger.Error("Failed to delete outbox event", "error", err, "event_id", event.ID)
@@ -0,0 +1,151 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/assylzhan-a/company-task/internal/domain/entity"
+	r "github.com/assylzhan-a/company-task/internal/ports/repository"
+	"github.com/assylzhan-a/company-task/pkg/logger"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type outboxRepo struct {
+	r.CompanyRepository
+	mu     sync.Mutex
+	events []*entity.OutboxEvent
+}
+
+func (f *outboxRepo) GetOutboxEvents(ctx context.Context, limit int) ([]*entity.OutboxEvent, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]*entity.OutboxEvent(nil), f.events...), nil
+}
+
+func (f *outboxRepo) DeleteOutboxEvent(ctx context.Context, id uuid.UUID) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i, event := range f.events {
+		if event.ID == id {
+			f.events = append(f.events[:i], f.events[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func (f *outboxRepo) pending() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.events)
+}
+
+// blockingProducer publishes messages and, if block is set, waits for it to
+// be closed first, ignoring cancellation when ignoreCancel is set.
+type blockingProducer struct {
+	mu           sync.Mutex
+	topics       []string
+	err          error
+	block        chan struct{}
+	ignoreCancel bool
+}
+
+func (p *blockingProducer) Produce(ctx context.Context, topic string, key, value []byte, headers map[string]string) error {
+	if p.block != nil {
+		if p.ignoreCancel {
+			<-p.block
+		} else {
+			select {
+			case <-p.block:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err != nil {
+		return p.err
+	}
+	p.topics = append(p.topics, topic)
+	return nil
+}
+
+func (p *blockingProducer) Close() error { return nil }
+
+func (p *blockingProducer) published() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.topics...)
+}
+
+func newOutboxEvent(eventType string) *entity.OutboxEvent {
+	organizationID := entity.DefaultOrganizationID
+	return &entity.OutboxEvent{ID: uuid.New(), OrganizationID: &organizationID, EventType: eventType, CreatedAt: time.Now()}
+}
+
+func TestOutboxWorkerPublishesOnTick(t *testing.T) {
+	repo := &outboxRepo{events: []*entity.OutboxEvent{newOutboxEvent("company_created"), newOutboxEvent("company_deleted")}}
+	producer := &blockingProducer{}
+	w := NewOutboxWorker(repo, producer, logger.NewLogger("error")).WithTick(10 * time.Millisecond)
+
+	require.NoError(t, w.Start(context.Background()))
+	assert.Eventually(t, func() bool { return repo.pending() == 0 }, time.Second, 5*time.Millisecond)
+	require.NoError(t, w.Stop(context.Background()))
+	assert.Equal(t, []string{"company_created", "company_deleted"}, producer.published())
+
+	// Stopping again is a no-op.
+	assert.NoError(t, w.Stop(context.Background()))
+}
+
+func TestOutboxWorkerTick(t *testing.T) {
+	w := NewOutboxWorker(&outboxRepo{}, &blockingProducer{}, logger.NewLogger("error"))
+	assert.Equal(t, 5*time.Second, w.tick)
+	assert.Equal(t, time.Second, w.WithTick(time.Second).tick)
+	assert.Equal(t, time.Second, w.WithTick(0).tick, "non-positive ticks are ignored")
+}
+
+func TestOutboxWorkerKeepsEventsThatFailToPublish(t *testing.T) {
+	repo := &outboxRepo{events: []*entity.OutboxEvent{newOutboxEvent("company_created")}}
+	producer := &blockingProducer{err: errors.New("broker unavailable")}
+	w := NewOutboxWorker(repo, producer, logger.NewLogger("error"))
+
+	require.NoError(t, w.ProcessOutboxEvents(context.Background()))
+	assert.Equal(t, 1, repo.pending())
+}
+
+func TestOutboxWorkerStopDrainsInFlightBatch(t *testing.T) {
+	repo := &outboxRepo{events: []*entity.OutboxEvent{newOutboxEvent("company_created")}}
+	producer := &blockingProducer{block: make(chan struct{})}
+	w := NewOutboxWorker(repo, producer, logger.NewLogger("error")).WithTick(5 * time.Millisecond)
+	require.NoError(t, w.Start(context.Background()))
+
+	// Let the batch start, then release it while Stop waits.
+	time.Sleep(20 * time.Millisecond)
+	time.AfterFunc(20*time.Millisecond, func() { close(producer.block) })
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(t, w.Stop(ctx))
+	assert.Equal(t, []string{"company_created"}, producer.published())
+	assert.Equal(t, 0, repo.pending())
+}
+
+func TestOutboxWorkerStopIsBoundedByContext(t *testing.T) {
+	repo := &outboxRepo{events: []*entity.OutboxEvent{newOutboxEvent("company_created")}}
+	producer := &blockingProducer{block: make(chan struct{}), ignoreCancel: true}
+	defer close(producer.block)
+	w := NewOutboxWorker(repo, producer, logger.NewLogger("error")).WithTick(5 * time.Millisecond)
+	require.NoError(t, w.Start(context.Background()))
+	time.Sleep(20 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	started := time.Now()
+	assert.ErrorIs(t, w.Stop(ctx), ErrDrainTimeout)
+	assert.Less(t, time.Since(started), 500*time.Millisecond)
+}
@@ -0,0 +1,87 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	r "github.com/assylzhan-a/company-task/internal/ports/repository"
+	"github.com/assylzhan-a/company-task/pkg/logger"
+)
+
+// securityEventBatchSize bounds the events appended in one transaction.
+const securityEventBatchSize = 500
+
+// SecurityEventAppender periodically appends queued security events to the
+// hash chain. Only one instance appends at a time; the others skip their turn,
+// so recording an event never waits for the chain.
+type SecurityEventAppender struct {
+	repo     r.SecurityEventRepository
+	logger   *logger.Logger
+	interval time.Duration
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func NewSecurityEventAppender(repo r.SecurityEventRepository, interval time.Duration, logger *logger.Logger) *SecurityEventAppender {
+	return &SecurityEventAppender{
+		repo:     repo,
+		logger:   logger,
+		interval: interval,
+	}
+}
+
+func (a *SecurityEventAppender) Name() string {
+	return "security_event_appender"
+}
+
+func (a *SecurityEventAppender) Start(ctx context.Context) error {
+	a.stop = make(chan struct{})
+	a.done = make(chan struct{})
+	go func() {
+		defer close(a.done)
+		ticker := time.NewTicker(a.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-a.stop:
+				// Append what was queued before shutdown.
+				a.append(context.Background())
+				return
+			case <-ticker.C:
+				a.append(ctx)
+			}
+		}
+	}()
+	return nil
+}
+
+func (a *SecurityEventAppender) Stop(ctx context.Context) error {
+	if a.stop == nil {
+		return nil
+	}
+	close(a.stop)
+	select {
+	case <-a.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// append appends batches until the queue is empty or another instance holds
+// the chain.
+func (a *SecurityEventAppender) append(ctx context.Context) {
+	for {
+		n, err := a.repo.AppendQueued(ctx, securityEventBatchSize)
+		if err != nil {
+			a.logger.Error("Failed to append security events", "error", err)
+			return
+		}
+		if n < securityEventBatchSize {
+			return
+		}
+	}
+}
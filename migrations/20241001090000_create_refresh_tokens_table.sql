@@ -0,0 +1,29 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+                                              id UUID PRIMARY KEY,
+                                              user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+                                              family_id UUID NOT NULL,
+                                              token_hash VARCHAR(64) NOT NULL UNIQUE,
+                                              expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+                                              created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+                                              revoked_at TIMESTAMP WITH TIME ZONE,
+                                              replaced_by UUID
+);
+
+CREATE INDEX idx_refresh_tokens_user_id ON refresh_tokens (user_id);
+CREATE INDEX idx_refresh_tokens_family_id ON refresh_tokens (family_id);
+
+CREATE TABLE IF NOT EXISTS revoked_tokens (
+                                              token_id UUID PRIMARY KEY,
+                                              expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX idx_revoked_tokens_expires_at ON revoked_tokens (expires_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS revoked_tokens;
+DROP TABLE IF EXISTS refresh_tokens;
+-- +goose StatementEnd
@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS signing_keys (
+                                            id VARCHAR(64) PRIMARY KEY,
+                                            algorithm VARCHAR(16) NOT NULL,
+                                            private_key BYTEA NOT NULL,
+                                            not_before TIMESTAMP WITH TIME ZONE NOT NULL,
+                                            retires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+                                            expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+                                            created_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX idx_signing_keys_expires_at ON signing_keys (expires_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS signing_keys;
+-- +goose StatementEnd
@@ -0,0 +1,60 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS roles (
+                                     name VARCHAR(64) PRIMARY KEY,
+                                     description TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS permissions (
+                                           name VARCHAR(64) PRIMARY KEY,
+                                           description TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS role_permissions (
+                                                role VARCHAR(64) NOT NULL REFERENCES roles (name) ON DELETE CASCADE,
+                                                permission VARCHAR(64) NOT NULL REFERENCES permissions (name) ON DELETE CASCADE,
+                                                PRIMARY KEY (role, permission)
+);
+
+CREATE TABLE IF NOT EXISTS user_roles (
+                                          user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+                                          role VARCHAR(64) NOT NULL REFERENCES roles (name) ON DELETE CASCADE,
+                                          assigned_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+                                          PRIMARY KEY (user_id, role)
+);
+
+INSERT INTO roles (name, description) VALUES
+    ('admin', 'Full access, including deleting companies and managing roles'),
+    ('editor', 'Can read, create and update companies'),
+    ('viewer', 'Read-only access to companies');
+
+INSERT INTO permissions (name, description) VALUES
+    ('companies:read', 'Read companies'),
+    ('companies:create', 'Create companies'),
+    ('companies:update', 'Update companies'),
+    ('companies:delete', 'Delete companies'),
+    ('roles:manage', 'Assign and revoke user roles');
+
+INSERT INTO role_permissions (role, permission) VALUES
+    ('admin', 'companies:read'),
+    ('admin', 'companies:create'),
+    ('admin', 'companies:update'),
+    ('admin', 'companies:delete'),
+    ('admin', 'roles:manage'),
+    ('editor', 'companies:read'),
+    ('editor', 'companies:create'),
+    ('editor', 'companies:update'),
+    ('viewer', 'companies:read');
+
+-- Existing users keep the access they had before roles were introduced.
+INSERT INTO user_roles (user_id, role)
+SELECT id, 'editor' FROM users;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS user_roles;
+DROP TABLE IF EXISTS role_permissions;
+DROP TABLE IF EXISTS permissions;
+DROP TABLE IF EXISTS roles;
+-- +goose StatementEnd
@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE companies
+    ADD COLUMN created_by UUID,
+    ADD COLUMN updated_by UUID,
+    ADD COLUMN owner_id UUID REFERENCES users (id) ON DELETE SET NULL;
+
+CREATE INDEX idx_companies_owner_id ON companies (owner_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_companies_owner_id;
+ALTER TABLE companies
+    DROP COLUMN IF EXISTS owner_id,
+    DROP COLUMN IF EXISTS updated_by,
+    DROP COLUMN IF EXISTS created_by;
+-- +goose StatementEnd
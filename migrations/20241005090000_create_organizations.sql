@@ -0,0 +1,85 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS organizations (
+                                             id UUID PRIMARY KEY,
+                                             name VARCHAR(255) NOT NULL UNIQUE,
+                                             created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS organization_members (
+                                                    organization_id UUID NOT NULL REFERENCES organizations (id) ON DELETE CASCADE,
+                                                    user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+                                                    joined_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+                                                    PRIMARY KEY (organization_id, user_id)
+);
+
+CREATE INDEX idx_organization_members_user_id ON organization_members (user_id);
+
+INSERT INTO permissions (name, description) VALUES ('organizations:manage', 'Create organizations and manage their members');
+INSERT INTO role_permissions (role, permission) VALUES ('admin', 'organizations:manage');
+
+-- Everything that existed before tenants were introduced belongs to the default organization.
+INSERT INTO organizations (id, name) VALUES ('00000000-0000-0000-0000-000000000001', 'default');
+
+INSERT INTO organization_members (organization_id, user_id)
+SELECT '00000000-0000-0000-0000-000000000001', id FROM users;
+
+ALTER TABLE companies ADD COLUMN organization_id UUID REFERENCES organizations (id) ON DELETE CASCADE;
+UPDATE companies SET organization_id = '00000000-0000-0000-0000-000000000001';
+ALTER TABLE companies ALTER COLUMN organization_id SET NOT NULL;
+
+ALTER TABLE companies DROP CONSTRAINT IF EXISTS companies_name_key;
+ALTER TABLE companies ADD CONSTRAINT companies_organization_id_name_key UNIQUE (organization_id, name);
+
+ALTER TABLE outbox_events ADD COLUMN organization_id UUID;
+ALTER TABLE refresh_tokens ADD COLUMN organization_id UUID REFERENCES organizations (id) ON DELETE CASCADE;
+
+-- Tenant-scoped queries switch to this role, which is subject to row-level
+-- security even when the service connects as a superuser or table owner.
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_roles WHERE rolname = 'company_tenant') THEN
+        CREATE ROLE company_tenant NOLOGIN;
+    END IF;
+END
+$$;
+
+GRANT company_tenant TO CURRENT_USER;
+GRANT USAGE ON SCHEMA public TO company_tenant;
+GRANT SELECT, INSERT, UPDATE, DELETE ON ALL TABLES IN SCHEMA public TO company_tenant;
+GRANT USAGE, SELECT ON ALL SEQUENCES IN SCHEMA public TO company_tenant;
+ALTER DEFAULT PRIVILEGES IN SCHEMA public GRANT SELECT, INSERT, UPDATE, DELETE ON TABLES TO company_tenant;
+ALTER DEFAULT PRIVILEGES IN SCHEMA public GRANT USAGE, SELECT ON SEQUENCES TO company_tenant;
+
+ALTER TABLE companies ENABLE ROW LEVEL SECURITY;
+ALTER TABLE companies FORCE ROW LEVEL SECURITY;
+
+CREATE POLICY companies_tenant_isolation ON companies
+    USING (organization_id = NULLIF(current_setting('app.current_organization', true), '')::uuid)
+    WITH CHECK (organization_id = NULLIF(current_setting('app.current_organization', true), '')::uuid);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP POLICY IF EXISTS companies_tenant_isolation ON companies;
+ALTER TABLE companies NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE companies DISABLE ROW LEVEL SECURITY;
+
+ALTER DEFAULT PRIVILEGES IN SCHEMA public REVOKE USAGE, SELECT ON SEQUENCES FROM company_tenant;
+ALTER DEFAULT PRIVILEGES IN SCHEMA public REVOKE SELECT, INSERT, UPDATE, DELETE ON TABLES FROM company_tenant;
+REVOKE USAGE, SELECT ON ALL SEQUENCES IN SCHEMA public FROM company_tenant;
+REVOKE SELECT, INSERT, UPDATE, DELETE ON ALL TABLES IN SCHEMA public FROM company_tenant;
+REVOKE USAGE ON SCHEMA public FROM company_tenant;
+
+ALTER TABLE refresh_tokens DROP COLUMN IF EXISTS organization_id;
+ALTER TABLE outbox_events DROP COLUMN IF EXISTS organization_id;
+
+ALTER TABLE companies DROP CONSTRAINT IF EXISTS companies_organization_id_name_key;
+ALTER TABLE companies ADD CONSTRAINT companies_name_key UNIQUE (name);
+ALTER TABLE companies DROP COLUMN IF EXISTS organization_id;
+
+DROP TABLE IF EXISTS organization_members;
+DROP TABLE IF EXISTS organizations;
+
+DELETE FROM permissions WHERE name = 'organizations:manage';
+-- +goose StatementEnd
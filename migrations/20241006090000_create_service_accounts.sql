@@ -0,0 +1,39 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Service accounts are backed by a users row without a usable password, so
+-- that ownership and organization membership work the same as for people.
+CREATE TABLE IF NOT EXISTS service_accounts (
+                                                id UUID PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+                                                organization_id UUID NOT NULL REFERENCES organizations (id) ON DELETE CASCADE,
+                                                name VARCHAR(255) NOT NULL,
+                                                created_by UUID,
+                                                created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+                                                UNIQUE (organization_id, name)
+);
+
+CREATE TABLE IF NOT EXISTS api_keys (
+                                        id UUID PRIMARY KEY,
+                                        service_account_id UUID NOT NULL REFERENCES service_accounts (id) ON DELETE CASCADE,
+                                        organization_id UUID NOT NULL REFERENCES organizations (id) ON DELETE CASCADE,
+                                        prefix VARCHAR(32) NOT NULL UNIQUE,
+                                        key_hash VARCHAR(64) NOT NULL,
+                                        scopes TEXT[] NOT NULL,
+                                        expires_at TIMESTAMP WITH TIME ZONE,
+                                        last_used_at TIMESTAMP WITH TIME ZONE,
+                                        created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+                                        revoked_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX idx_api_keys_service_account_id ON api_keys (service_account_id);
+
+INSERT INTO permissions (name, description) VALUES ('service_accounts:manage', 'Manage service accounts and their API keys');
+INSERT INTO role_permissions (role, permission) VALUES ('admin', 'service_accounts:manage');
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM permissions WHERE name = 'service_accounts:manage';
+DELETE FROM users WHERE id IN (SELECT id FROM service_accounts);
+DROP TABLE IF EXISTS api_keys;
+DROP TABLE IF EXISTS service_accounts;
+-- +goose StatementEnd
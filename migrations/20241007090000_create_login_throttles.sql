@@ -0,0 +1,30 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS login_throttles (
+                                               kind VARCHAR(16) NOT NULL,
+                                               key TEXT NOT NULL,
+                                               failures INTEGER NOT NULL,
+                                               last_failure_at TIMESTAMP WITH TIME ZONE NOT NULL,
+                                               locked_until TIMESTAMP WITH TIME ZONE,
+                                               PRIMARY KEY (kind, key)
+);
+
+CREATE TABLE IF NOT EXISTS security_events (
+                                               id UUID PRIMARY KEY,
+                                               type VARCHAR(64) NOT NULL,
+                                               user_id UUID REFERENCES users (id) ON DELETE SET NULL,
+                                               username TEXT NOT NULL,
+                                               ip_address VARCHAR(64) NOT NULL,
+                                               reason TEXT,
+                                               created_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX idx_security_events_user_id ON security_events (user_id, created_at);
+CREATE INDEX idx_security_events_created_at ON security_events (created_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS security_events;
+DROP TABLE IF EXISTS login_throttles;
+-- +goose StatementEnd
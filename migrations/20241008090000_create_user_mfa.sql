@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS user_mfa (
+                                        user_id UUID PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+                                        secret VARCHAR(64) NOT NULL,
+                                        enabled BOOLEAN NOT NULL DEFAULT FALSE,
+                                        last_used_step BIGINT NOT NULL DEFAULT 0,
+                                        created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+                                        confirmed_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE TABLE IF NOT EXISTS mfa_recovery_codes (
+                                                  id UUID PRIMARY KEY,
+                                                  user_id UUID NOT NULL REFERENCES user_mfa (user_id) ON DELETE CASCADE,
+                                                  code_hash VARCHAR(64) NOT NULL,
+                                                  used_at TIMESTAMP WITH TIME ZONE,
+                                                  UNIQUE (user_id, code_hash)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS mfa_recovery_codes;
+DROP TABLE IF EXISTS user_mfa;
+-- +goose StatementEnd
@@ -0,0 +1,27 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE users ADD COLUMN email VARCHAR(255);
+ALTER TABLE users ADD COLUMN email_verified BOOLEAN NOT NULL DEFAULT FALSE;
+CREATE UNIQUE INDEX idx_users_email ON users (LOWER(email));
+
+CREATE TABLE IF NOT EXISTS one_time_tokens (
+                                               id UUID PRIMARY KEY,
+                                               user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+                                               purpose VARCHAR(32) NOT NULL,
+                                               token_hash VARCHAR(64) NOT NULL UNIQUE,
+                                               email VARCHAR(255),
+                                               expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+                                               created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+                                               used_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX idx_one_time_tokens_user_id ON one_time_tokens (user_id, purpose);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS one_time_tokens;
+DROP INDEX IF EXISTS idx_users_email;
+ALTER TABLE users DROP COLUMN IF EXISTS email_verified;
+ALTER TABLE users DROP COLUMN IF EXISTS email;
+-- +goose StatementEnd
@@ -0,0 +1,26 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS user_identities (
+                                               issuer VARCHAR(255) NOT NULL,
+                                               subject VARCHAR(255) NOT NULL,
+                                               user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+                                               created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+                                               PRIMARY KEY (issuer, subject)
+);
+
+CREATE INDEX idx_user_identities_user_id ON user_identities (user_id);
+
+CREATE TABLE IF NOT EXISTS sso_login_states (
+                                                state_hash VARCHAR(64) PRIMARY KEY,
+                                                nonce VARCHAR(128) NOT NULL,
+                                                code_verifier VARCHAR(128) NOT NULL,
+                                                expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+                                                created_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS sso_login_states;
+DROP TABLE IF EXISTS user_identities;
+-- +goose StatementEnd
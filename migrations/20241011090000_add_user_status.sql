@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+UPDATE users SET created_at = CURRENT_TIMESTAMP WHERE created_at IS NULL;
+ALTER TABLE users ALTER COLUMN created_at SET NOT NULL;
+ALTER TABLE users ADD COLUMN disabled_at TIMESTAMP WITH TIME ZONE;
+
+INSERT INTO permissions (name, description) VALUES ('users:manage', 'List, disable and enable users');
+INSERT INTO role_permissions (role, permission) VALUES ('admin', 'users:manage');
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM permissions WHERE name = 'users:manage';
+ALTER TABLE users DROP COLUMN IF EXISTS disabled_at;
+ALTER TABLE users ALTER COLUMN created_at DROP NOT NULL;
+-- +goose StatementEnd
@@ -0,0 +1,42 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Events keep the user ID after the user is deleted, since rows can no longer
+-- be updated.
+ALTER TABLE security_events DROP CONSTRAINT IF EXISTS security_events_user_id_fkey;
+
+-- Events recorded before this migration have no sequence and are not part
+-- of the chain.
+ALTER TABLE security_events ADD COLUMN sequence BIGINT UNIQUE;
+ALTER TABLE security_events ADD COLUMN prev_hash VARCHAR(64);
+ALTER TABLE security_events ADD COLUMN hash VARCHAR(64);
+CREATE INDEX idx_security_events_type ON security_events (type, created_at);
+
+CREATE OR REPLACE FUNCTION security_events_append_only() RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'security_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER security_events_no_update_delete
+    BEFORE UPDATE OR DELETE ON security_events
+    FOR EACH ROW EXECUTE FUNCTION security_events_append_only();
+CREATE TRIGGER security_events_no_truncate
+    BEFORE TRUNCATE ON security_events
+    FOR EACH STATEMENT EXECUTE FUNCTION security_events_append_only();
+
+INSERT INTO permissions (name, description) VALUES ('audit:read', 'Query and verify the security audit log');
+INSERT INTO role_permissions (role, permission) VALUES ('admin', 'audit:read');
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM permissions WHERE name = 'audit:read';
+DROP TRIGGER IF EXISTS security_events_no_truncate ON security_events;
+DROP TRIGGER IF EXISTS security_events_no_update_delete ON security_events;
+DROP FUNCTION IF EXISTS security_events_append_only();
+DROP INDEX IF EXISTS idx_security_events_type;
+ALTER TABLE security_events DROP COLUMN IF EXISTS hash;
+ALTER TABLE security_events DROP COLUMN IF EXISTS prev_hash;
+ALTER TABLE security_events DROP COLUMN IF EXISTS sequence;
+-- The foreign key is not restored because events of deleted users remain.
+-- +goose StatementEnd
@@ -0,0 +1,58 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS company_revisions (
+                                                 company_id UUID NOT NULL,
+                                                 revision BIGINT NOT NULL,
+                                                 organization_id UUID NOT NULL REFERENCES organizations (id) ON DELETE CASCADE,
+                                                 operation VARCHAR(16) NOT NULL,
+                                                 snapshot JSONB NOT NULL,
+                                                 actor_id UUID,
+                                                 request_id VARCHAR(128),
+                                                 created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+                                                 PRIMARY KEY (company_id, revision)
+);
+
+CREATE INDEX idx_company_revisions_created_at ON company_revisions (company_id, created_at);
+
+-- Revisions are history; the service only ever adds them.
+REVOKE UPDATE, DELETE ON company_revisions FROM company_tenant;
+
+ALTER TABLE company_revisions ENABLE ROW LEVEL SECURITY;
+
+CREATE POLICY company_revisions_tenant_isolation ON company_revisions
+    USING (organization_id = NULLIF(current_setting('app.current_organization', true), '')::uuid)
+    WITH CHECK (organization_id = NULLIF(current_setting('app.current_organization', true), '')::uuid);
+
+-- Existing companies start their history with their current state. The
+-- migration sets no organization, so row level security is lifted for the
+-- owner until the backfill is done; otherwise it would see no companies and
+-- could not insert their revisions.
+ALTER TABLE companies NO FORCE ROW LEVEL SECURITY;
+
+INSERT INTO company_revisions (company_id, revision, organization_id, operation, snapshot, actor_id, created_at)
+SELECT id, 1, organization_id, 'snapshot',
+       jsonb_strip_nulls(jsonb_build_object(
+           'id', id,
+           'organization_id', organization_id,
+           'name', name,
+           'description', description,
+           'amount_of_employees', amount_of_employees,
+           'registered', registered,
+           'type', type,
+           'owner_id', owner_id,
+           'created_by', created_by,
+           'updated_by', updated_by,
+           'created_at', created_at,
+           'updated_at', updated_at
+       )),
+       updated_by, updated_at
+FROM companies;
+
+ALTER TABLE companies FORCE ROW LEVEL SECURITY;
+ALTER TABLE company_revisions FORCE ROW LEVEL SECURITY;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS company_revisions;
+-- +goose StatementEnd
@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+-- version counts the changes of a company; it is the entity tag of the
+-- company for conditional requests.
+ALTER TABLE companies ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE companies DROP COLUMN IF EXISTS version;
+-- +goose StatementEnd
@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Responses to requests made with an Idempotency-Key, replayed when a client
+-- retries the request. scope identifies the caller so that keys of different
+-- callers never collide.
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+                                                scope VARCHAR(64) NOT NULL,
+                                                key VARCHAR(255) NOT NULL,
+                                                fingerprint VARCHAR(64) NOT NULL,
+                                                status_code INTEGER NOT NULL,
+                                                header JSONB NOT NULL,
+                                                body BYTEA NOT NULL,
+                                                created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+                                                expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+                                                PRIMARY KEY (scope, key)
+);
+
+CREATE INDEX idx_idempotency_keys_expires_at ON idempotency_keys (expires_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS idempotency_keys;
+-- +goose StatementEnd
@@ -0,0 +1,52 @@
+-- +goose Up
+-- +goose StatementBegin
+-- slug names a company in URLs. It is derived from the name when the company
+-- is created and does not change when the company is renamed.
+ALTER TABLE companies ADD COLUMN slug VARCHAR(64);
+
+-- Speeds up the lookups of taken slugs below until the unique constraint
+-- replaces it.
+CREATE INDEX idx_companies_organization_id_slug ON companies (organization_id, slug);
+
+-- Companies get the slug of their name, or the first free one with a numeric
+-- suffix, in the order they were created. Free is checked against every slug
+-- assigned so far, as a name like "Acme 2" can itself produce a suffixed slug.
+-- The migration sets no organization, so row level security is lifted for the
+-- owner meanwhile; otherwise the backfill would see no companies.
+ALTER TABLE companies NO FORCE ROW LEVEL SECURITY;
+
+DO $$
+DECLARE
+    company RECORD;
+    candidate TEXT;
+    n INTEGER;
+BEGIN
+    FOR company IN
+        SELECT id, organization_id,
+               COALESCE(NULLIF(TRIM(BOTH '-' FROM LOWER(REGEXP_REPLACE(name, '[^A-Za-z0-9]+', '-', 'g'))), ''), 'company') AS base
+        FROM companies
+        ORDER BY organization_id, created_at, id
+    LOOP
+        candidate := company.base;
+        n := 1;
+        WHILE EXISTS (SELECT 1 FROM companies WHERE organization_id = company.organization_id AND slug = candidate) LOOP
+            n := n + 1;
+            candidate := company.base || '-' || n;
+        END LOOP;
+        UPDATE companies SET slug = candidate WHERE id = company.id;
+    END LOOP;
+END
+$$;
+
+ALTER TABLE companies FORCE ROW LEVEL SECURITY;
+
+ALTER TABLE companies ALTER COLUMN slug SET NOT NULL;
+ALTER TABLE companies ADD CONSTRAINT companies_organization_id_slug_key UNIQUE (organization_id, slug);
+DROP INDEX idx_companies_organization_id_slug;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE companies DROP CONSTRAINT IF EXISTS companies_organization_id_slug_key;
+ALTER TABLE companies DROP COLUMN IF EXISTS slug;
+-- +goose StatementEnd
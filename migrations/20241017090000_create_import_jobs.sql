@@ -0,0 +1,47 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Imports of companies from uploaded files. processed_rows is the number of
+-- rows of the file that were imported or rejected, so that a job that stopped
+-- resumes after them. A worker holds a job while lease_until is in the future;
+-- lease_token tells it whether the job is still its own.
+CREATE TABLE IF NOT EXISTS import_jobs (
+                                           id UUID PRIMARY KEY,
+                                           organization_id UUID NOT NULL REFERENCES organizations (id) ON DELETE CASCADE,
+                                           created_by UUID,
+                                           format VARCHAR(16) NOT NULL,
+                                           data BYTEA NOT NULL,
+                                           columns JSONB NOT NULL,
+                                           mapping JSONB,
+                                           status VARCHAR(16) NOT NULL,
+                                           total_rows INTEGER NOT NULL,
+                                           processed_rows INTEGER NOT NULL DEFAULT 0,
+                                           succeeded_rows INTEGER NOT NULL DEFAULT 0,
+                                           failed_rows INTEGER NOT NULL DEFAULT 0,
+                                           error TEXT,
+                                           lease_token UUID,
+                                           lease_until TIMESTAMP WITH TIME ZONE,
+                                           created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+                                           updated_at TIMESTAMP WITH TIME ZONE NOT NULL,
+                                           started_at TIMESTAMP WITH TIME ZONE,
+                                           finished_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX idx_import_jobs_status ON import_jobs (status, lease_until) WHERE status IN ('queued', 'running');
+
+CREATE TABLE IF NOT EXISTS import_row_errors (
+                                                 id BIGSERIAL PRIMARY KEY,
+                                                 job_id UUID NOT NULL REFERENCES import_jobs (id) ON DELETE CASCADE,
+                                                 row_number INTEGER NOT NULL,
+                                                 field VARCHAR(64),
+                                                 code VARCHAR(64) NOT NULL,
+                                                 message TEXT NOT NULL
+);
+
+CREATE INDEX idx_import_row_errors_job_id ON import_row_errors (job_id, row_number);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS import_row_errors;
+DROP TABLE IF EXISTS import_jobs;
+-- +goose StatementEnd
@@ -0,0 +1,10 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Exports read the companies of an organization in creation order.
+CREATE INDEX idx_companies_organization_created_at ON companies (organization_id, created_at, id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_companies_organization_created_at;
+-- +goose StatementEnd
@@ -0,0 +1,14 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Secrets are sealed with KEY_ENCRYPTION_KEY by the service; existing
+-- plaintext secrets are sealed the next time they are loaded.
+ALTER TABLE user_mfa ALTER COLUMN secret TYPE BYTEA USING convert_to(secret, 'UTF8');
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+-- Sealed secrets cannot be decrypted here, so those enrollments are removed
+-- and the users have to enroll again.
+DELETE FROM user_mfa WHERE get_byte(secret, 0) = 1;
+ALTER TABLE user_mfa ALTER COLUMN secret TYPE VARCHAR(64) USING convert_from(secret, 'UTF8');
+-- +goose StatementEnd
@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Events are queued here by requests and appended to the hash chain in
+-- security_events by a single background appender, so that recording an
+-- event does not wait for the chain.
+CREATE TABLE IF NOT EXISTS security_event_queue (
+                                                    position BIGSERIAL PRIMARY KEY,
+                                                    id UUID NOT NULL UNIQUE,
+                                                    type VARCHAR(64) NOT NULL,
+                                                    user_id UUID,
+                                                    username TEXT NOT NULL,
+                                                    ip_address VARCHAR(64) NOT NULL,
+                                                    reason TEXT,
+                                                    created_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS security_event_queue;
+-- +goose StatementEnd
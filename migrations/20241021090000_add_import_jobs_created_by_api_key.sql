@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+-- The API key a job was uploaded with, so that the worker checks that the key
+-- can still create companies rather than the service account's roles.
+ALTER TABLE import_jobs ADD COLUMN created_by_api_key_id UUID;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE import_jobs DROP COLUMN IF EXISTS created_by_api_key_id;
+-- +goose StatementEnd
@@ -0,0 +1,201 @@
+package errors
+
+import "net/http"
+
+// Code identifies an error independently of its message. Codes are part of
+// the API: once published they are not renamed or reused.
+type Code string
+
+const (
+	CodeBadRequest            Code = "bad_request"
+	CodeInvalidPayload        Code = "invalid_payload"
+	CodeInvalidParameter      Code = "invalid_parameter"
+	CodeValidationFailed      Code = "validation_failed"
+	CodeUnauthorized          Code = "unauthorized"
+	CodeInvalidToken          Code = "invalid_token"
+	CodeForbidden             Code = "forbidden"
+	CodePermissionDenied      Code = "permission_denied"
+	CodeNoOrganization        Code = "no_active_organization"
+	CodeNotFound              Code = "not_found"
+	CodeMethodNotAllowed      Code = "method_not_allowed"
+	CodeConflict              Code = "conflict"
+	CodePreconditionFailed    Code = "precondition_failed"
+	CodePayloadTooLarge       Code = "payload_too_large"
+	CodeUnsupportedMedia      Code = "unsupported_media_type"
+	CodeNotAcceptable         Code = "not_acceptable"
+	CodeIdempotencyKeyReused  Code = "idempotency_key_reused"
+	CodeIdempotencyInProgress Code = "idempotency_request_in_progress"
+	CodeRateLimited           Code = "rate_limited"
+	CodeInternal              Code = "internal_error"
+
+	CodeInvalidCredentials  Code = "invalid_credentials"
+	CodeWeakPassword        Code = "weak_password"
+	CodePasswordNotSet      Code = "password_not_set"
+	CodeUsernameTaken       Code = "username_taken"
+	CodeEmailTaken          Code = "email_taken"
+	CodeInvalidEmail        Code = "invalid_email"
+	CodeEmailRequired       Code = "email_required"
+	CodeEmailNotVerified    Code = "email_not_verified"
+	CodeEmailVerified       Code = "email_already_verified"
+	CodeInvalidRefreshToken Code = "invalid_refresh_token"
+	CodeRefreshTokenReused  Code = "refresh_token_reused"
+	CodeInvalidOneTimeToken Code = "invalid_one_time_token"
+	CodeUserNotFound        Code = "user_not_found"
+	CodeUserDisabled        Code = "user_disabled"
+	CodeCannotModifySelf    Code = "cannot_modify_self"
+	CodeRoleNotFound        Code = "role_not_found"
+	CodeLocalLoginDisabled  Code = "local_login_disabled"
+	CodeSSODisabled         Code = "sso_disabled"
+	CodeInvalidSSOState     Code = "invalid_sso_state"
+	CodeSSOLoginFailed      Code = "sso_login_failed"
+	CodeAccountNotLinked    Code = "account_not_linked"
+	CodeMFANotEnrolled      Code = "mfa_not_enrolled"
+	CodeMFAAlreadyEnabled   Code = "mfa_already_enabled"
+	CodeInvalidMFACode      Code = "invalid_mfa_code"
+	CodeInvalidMFAToken     Code = "invalid_mfa_token"
+
+	CodeOrganizationNotFound  Code = "organization_not_found"
+	CodeOrganizationExists    Code = "organization_exists"
+	CodeNotOrganizationMember Code = "not_organization_member"
+
+	CodeServiceAccountNotFound Code = "service_account_not_found"
+	CodeServiceAccountExists   Code = "service_account_exists"
+	CodeAPIKeyNotFound         Code = "api_key_not_found"
+	CodeInvalidAPIKey          Code = "invalid_api_key"
+	CodeInvalidScope           Code = "invalid_scope"
+
+	CodeCompanyNotFound         Code = "company_not_found"
+	CodeCompanyExists           Code = "company_exists"
+	CodeCompanyRevisionNotFound Code = "company_revision_not_found"
+	CodeInvalidPatch            Code = "invalid_patch"
+	CodePatchTestFailed         Code = "patch_test_failed"
+	CodeOwnerNotFound           Code = "owner_not_found"
+	CodeNotCompanyOwner         Code = "not_company_owner"
+	CodeImportNotFound          Code = "import_not_found"
+	CodeInvalidImportFile       Code = "invalid_import_file"
+	CodeInvalidImportMapping    Code = "invalid_import_mapping"
+)
+
+// Definition documents an error code. Status is the status the code is
+// usually returned with; a few codes are returned with a different status
+// where the context calls for it, e.g. invalid_credentials is 403 when an
+// already signed in user confirms their password.
+type Definition struct {
+	Code        Code   `json:"code"`
+	Status      int    `json:"status"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+}
+
+// Catalogue lists every error code returned by the API.
+var Catalogue = []Definition{
+	{CodeBadRequest, http.StatusBadRequest, "Bad request", "The request cannot be processed as sent."},
+	{CodeInvalidPayload, http.StatusBadRequest, "Invalid request payload", "The request body is not valid JSON for the endpoint."},
+	{CodeInvalidParameter, http.StatusBadRequest, "Invalid parameter", "A path or query parameter or a header has an invalid value."},
+	{CodeValidationFailed, http.StatusBadRequest, "Validation failed", "One or more fields are invalid; errors lists each field with the violated constraint."},
+	{CodeUnauthorized, http.StatusUnauthorized, "Unauthorized", "The request requires authentication."},
+	{CodeInvalidToken, http.StatusUnauthorized, "Invalid token", "The access token is missing, malformed, expired or revoked."},
+	{CodeForbidden, http.StatusForbidden, "Forbidden", "The caller may not perform the request."},
+	{CodePermissionDenied, http.StatusForbidden, "Permission denied", "The caller lacks the permission or API key scope the route requires."},
+	{CodeNoOrganization, http.StatusForbidden, "No active organization", "The request needs an active organization and the caller has none."},
+	{CodeNotFound, http.StatusNotFound, "Not found", "The resource does not exist."},
+	{CodeMethodNotAllowed, http.StatusMethodNotAllowed, "Method not allowed", "The route does not support the HTTP method."},
+	{CodeConflict, http.StatusConflict, "Conflict", "The request conflicts with the current state of the resource."},
+	{CodePreconditionFailed, http.StatusPreconditionFailed, "Precondition failed", "The resource does not match the If-Match or If-None-Match header."},
+	{CodePayloadTooLarge, http.StatusRequestEntityTooLarge, "Payload too large", "The request body exceeds the size limit."},
+	{CodeUnsupportedMedia, http.StatusUnsupportedMediaType, "Unsupported media type", "The Content-Type of the request body is not accepted by the endpoint."},
+	{CodeNotAcceptable, http.StatusNotAcceptable, "Not acceptable", "The endpoint cannot respond with a media type the Accept header allows."},
+	{CodeIdempotencyKeyReused, http.StatusUnprocessableEntity, "Idempotency key reused", "The Idempotency-Key was already used for a different request."},
+	{CodeIdempotencyInProgress, http.StatusConflict, "Request in progress", "A request with the Idempotency-Key is still running; retry after the time given in the Retry-After header."},
+	{CodeRateLimited, http.StatusTooManyRequests, "Too many requests", "Too many attempts; retry after the time given in the Retry-After header."},
+	{CodeInternal, http.StatusInternalServerError, "Internal server error", "An unexpected error occurred; report the request_id when contacting support."},
+
+	{CodeInvalidCredentials, http.StatusUnauthorized, "Invalid credentials", "The username or password is wrong."},
+	{CodeWeakPassword, http.StatusBadRequest, "Weak password", "The password violates the password policy; errors lists each violated rule."},
+	{CodePasswordNotSet, http.StatusForbidden, "Password not set", "The user signs in with single sign-on and has no password."},
+	{CodeUsernameTaken, http.StatusBadRequest, "Username taken", "Another user has the username."},
+	{CodeEmailTaken, http.StatusBadRequest, "Email taken", "Another user has the email address."},
+	{CodeInvalidEmail, http.StatusBadRequest, "Invalid email", "The email address is malformed."},
+	{CodeEmailRequired, http.StatusBadRequest, "Email required", "The user has no email address."},
+	{CodeEmailNotVerified, http.StatusForbidden, "Email not verified", "The email address must be verified before signing in."},
+	{CodeEmailVerified, http.StatusBadRequest, "Email already verified", "The email address is already verified."},
+	{CodeInvalidRefreshToken, http.StatusUnauthorized, "Invalid refresh token", "The refresh token is unknown, expired or revoked."},
+	{CodeRefreshTokenReused, http.StatusUnauthorized, "Refresh token reused", "The refresh token was already used; the session has been revoked."},
+	{CodeInvalidOneTimeToken, http.StatusBadRequest, "Invalid token", "The password reset or email verification token is invalid or expired."},
+	{CodeUserNotFound, http.StatusNotFound, "User not found", "The user does not exist."},
+	{CodeUserDisabled, http.StatusForbidden, "User disabled", "The user has been disabled by an administrator."},
+	{CodeCannotModifySelf, http.StatusBadRequest, "Cannot modify self", "Administrators cannot disable their own account."},
+	{CodeRoleNotFound, http.StatusNotFound, "Role not found", "The role does not exist."},
+	{CodeLocalLoginDisabled, http.StatusForbidden, "Password login disabled", "Password login is disabled; sign in with single sign-on."},
+	{CodeSSODisabled, http.StatusNotFound, "Single sign-on disabled", "Single sign-on is not configured."},
+	{CodeInvalidSSOState, http.StatusBadRequest, "Invalid single sign-on state", "The single sign-on login is unknown or expired; start it again."},
+	{CodeSSOLoginFailed, http.StatusUnauthorized, "Single sign-on failed", "The identity provider did not authenticate the user."},
+	{CodeAccountNotLinked, http.StatusConflict, "Account not linked", "A local account with the same username or email exists and is not linked to the identity."},
+	{CodeMFANotEnrolled, http.StatusNotFound, "MFA not enrolled", "The user has not enrolled in two-factor authentication."},
+	{CodeMFAAlreadyEnabled, http.StatusConflict, "MFA already enabled", "Two-factor authentication is already enabled."},
+	{CodeInvalidMFACode, http.StatusBadRequest, "Invalid MFA code", "The one-time or recovery code is wrong."},
+	{CodeInvalidMFAToken, http.StatusUnauthorized, "Invalid MFA token", "The token from the first login step is invalid or expired."},
+
+	{CodeOrganizationNotFound, http.StatusNotFound, "Organization not found", "The organization does not exist."},
+	{CodeOrganizationExists, http.StatusConflict, "Organization exists", "An organization with the name already exists."},
+	{CodeNotOrganizationMember, http.StatusForbidden, "Not an organization member", "The user is not a member of the organization."},
+
+	{CodeServiceAccountNotFound, http.StatusNotFound, "Service account not found", "The service account does not exist."},
+	{CodeServiceAccountExists, http.StatusConflict, "Service account exists", "A service account with the name already exists."},
+	{CodeAPIKeyNotFound, http.StatusNotFound, "API key not found", "The API key does not exist."},
+	{CodeInvalidAPIKey, http.StatusUnauthorized, "Invalid API key", "The API key is unknown, expired or revoked."},
+	{CodeInvalidScope, http.StatusBadRequest, "Invalid scope", "An API key scope is unknown or missing."},
+
+	{CodeCompanyNotFound, http.StatusNotFound, "Company not found", "The company does not exist in the organization."},
+	{CodeCompanyExists, http.StatusBadRequest, "Company exists", "A company with the name or ID already exists."},
+	{CodeCompanyRevisionNotFound, http.StatusNotFound, "Company revision not found", "The company has no such revision."},
+	{CodeInvalidPatch, http.StatusUnprocessableEntity, "Invalid patch", "The patch cannot be applied to the company, e.g. its path does not exist."},
+	{CodePatchTestFailed, http.StatusConflict, "Patch test failed", "A test operation of the JSON Patch failed because the company has changed."},
+	{CodeOwnerNotFound, http.StatusNotFound, "Owner not found", "The new owner of the company does not exist."},
+	{CodeNotCompanyOwner, http.StatusForbidden, "Not the company owner", "Only the owner of the company or an admin may modify it."},
+	{CodeImportNotFound, http.StatusNotFound, "Import not found", "The import does not exist in the organization."},
+	{CodeInvalidImportFile, http.StatusUnprocessableEntity, "Invalid import file", "The uploaded file cannot be read in its format, e.g. a CSV file without a header."},
+	{CodeInvalidImportMapping, http.StatusBadRequest, "Invalid import mapping", "The mapping names an unknown field or column or leaves a required field unmapped; errors lists each field."},
+}
+
+var definitions = func() map[Code]Definition {
+	definitions := make(map[Code]Definition, len(Catalogue))
+	for _, definition := range Catalogue {
+		definitions[definition.Code] = definition
+	}
+	return definitions
+}()
+
+// Lookup returns the definition of the code.
+func Lookup(code Code) (Definition, bool) {
+	definition, ok := definitions[code]
+	return definition, ok
+}
+
+// CodeForStatus returns the generic code of an HTTP status.
+func CodeForStatus(status int) Code {
+	switch status {
+	case http.StatusBadRequest:
+		return CodeBadRequest
+	case http.StatusUnauthorized:
+		return CodeUnauthorized
+	case http.StatusForbidden:
+		return CodeForbidden
+	case http.StatusNotFound:
+		return CodeNotFound
+	case http.StatusMethodNotAllowed:
+		return CodeMethodNotAllowed
+	case http.StatusConflict:
+		return CodeConflict
+	case http.StatusPreconditionFailed:
+		return CodePreconditionFailed
+	case http.StatusRequestEntityTooLarge:
+		return CodePayloadTooLarge
+	case http.StatusUnsupportedMediaType:
+		return CodeUnsupportedMedia
+	case http.StatusTooManyRequests:
+		return CodeRateLimited
+	default:
+		return CodeInternal
+	}
+}
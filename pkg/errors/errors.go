@@ -1,70 +1,77 @@
 package errors
 
 import (
-	"encoding/json"
-	"errors"
 	"net/http"
+
+	"github.com/assylzhan-a/company-task/pkg/i18n"
+	"github.com/go-playground/validator/v10"
 )
 
 type AppError struct {
-	Message    string `json:"message"`
-	StatusCode int    `json:"-"`
+	Code       Code         `json:"code"`
+	Message    string       `json:"message"`
+	StatusCode int          `json:"-"`
+	Errors     []FieldError `json:"errors,omitempty"`
+
+	// key and params translate Message; Message is the key itself if key
+	// is empty.
+	key    string
+	params []string
+	// validationErrors are kept to translate the messages of Errors.
+	validationErrors validator.ValidationErrors
 }
 
 func (e *AppError) Error() string {
 	return e.Message
 }
 
-func NewNotFoundError(message string) *AppError {
+// New returns an error with the code and the status the catalogue gives it.
+func New(code Code, message string) *AppError {
+	status := http.StatusInternalServerError
+	if definition, ok := Lookup(code); ok {
+		status = definition.Status
+	}
 	return &AppError{
+		Code:       code,
 		Message:    message,
-		StatusCode: http.StatusNotFound,
+		StatusCode: status,
 	}
 }
 
+// Newf is New with a message that has placeholders {0}, {1}, ... for the
+// params. The message is translated before the params are substituted, so
+// it must be a constant for the translation catalogs to find it.
+func Newf(code Code, message string, params ...string) *AppError {
+	appErr := New(code, i18n.Format(message, params...))
+	appErr.key = message
+	appErr.params = params
+	return appErr
+}
+
+func NewNotFoundError(message string) *AppError {
+	return New(CodeNotFound, message)
+}
+
 func NewBadRequestError(message string) *AppError {
-	return &AppError{
-		Message:    message,
-		StatusCode: http.StatusBadRequest,
-	}
+	return New(CodeBadRequest, message)
 }
 
 func NewInternalServerError(message string) *AppError {
-	return &AppError{
-		Message:    message,
-		StatusCode: http.StatusInternalServerError,
-	}
+	return New(CodeInternal, message)
 }
 
 func NewUnauthorizedError(message string) *AppError {
-	return &AppError{
-		Message:    message,
-		StatusCode: http.StatusUnauthorized,
-	}
+	return New(CodeUnauthorized, message)
 }
 
-func NewConflictError(message string) *AppError {
-	return &AppError{
-		Message:    message,
-		StatusCode: http.StatusConflict,
-	}
+func NewForbiddenError(message string) *AppError {
+	return New(CodeForbidden, message)
 }
 
-func NewTooManyRequestsError(message string) *AppError {
-	return &AppError{
-		Message:    message,
-		StatusCode: http.StatusTooManyRequests,
-	}
+func NewConflictError(message string) *AppError {
+	return New(CodeConflict, message)
 }
 
-func RespondWithError(w http.ResponseWriter, err error) {
-	var appErr *AppError
-	var e *AppError
-	if errors.As(err, &e) {
-		appErr = e
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(appErr.StatusCode)
-	json.NewEncoder(w).Encode(map[string]string{"error": appErr.Message})
+func NewTooManyRequestsError(message string) *AppError {
+	return New(CodeRateLimited, message)
 }
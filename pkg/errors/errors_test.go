@@ -0,0 +1,119 @@
+package errors
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-playground/validator/v10"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func respond(t *testing.T, err error) (*httptest.ResponseRecorder, Problem) {
+	return respondIn(t, "", err)
+}
+
+func respondIn(t *testing.T, acceptLanguage string, err error) (*httptest.ResponseRecorder, Problem) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/v1/companies/42", nil)
+	req.Header.Set("Accept-Language", acceptLanguage)
+	req = req.WithContext(context.WithValue(req.Context(), middleware.RequestIDKey, "request-1"))
+	rec := httptest.NewRecorder()
+	RespondWithError(rec, req, err)
+
+	var problem Problem
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &problem))
+	return rec, problem
+}
+
+func TestRespondWithError(t *testing.T) {
+	rec, problem := respond(t, fmt.Errorf("loading company: %w", NewNotFoundError("Company not found")))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, ContentTypeProblem, rec.Header().Get("Content-Type"))
+	assert.Equal(t, Problem{
+		Type:      "urn:company-task:problem:not_found",
+		Title:     "Not found",
+		Status:    http.StatusNotFound,
+		Detail:    "Company not found",
+		Instance:  "/v1/companies/42",
+		Code:      CodeNotFound,
+		RequestID: "request-1",
+	}, problem)
+}
+
+func TestRespondWithErrorInRussian(t *testing.T) {
+	appErr := Newf(CodePermissionDenied, "Missing permission: {0}", "companies:read")
+	appErr.Errors = []FieldError{NewFieldError("password", "min_length", "12", "password must be at least {0} characters long", "12")}
+	assert.Equal(t, "Missing permission: companies:read", appErr.Error())
+
+	rec, problem := respondIn(t, "ru-RU,ru;q=0.9,en;q=0.8", appErr)
+	assert.Equal(t, "ru", rec.Header().Get("Content-Language"))
+	assert.Equal(t, "Недостаточно прав", problem.Title)
+	assert.Equal(t, "Отсутствует право: companies:read", problem.Detail)
+	assert.Equal(t, "Пароль должен содержать не менее 12 символов", problem.Errors[0].Message)
+
+	rec, problem = respondIn(t, "en", appErr)
+	assert.Equal(t, "en", rec.Header().Get("Content-Language"))
+	assert.Equal(t, "Missing permission: companies:read", problem.Detail)
+	assert.Equal(t, "password must be at least 12 characters long", problem.Errors[0].Message)
+}
+
+func TestRespondWithUnknownError(t *testing.T) {
+	var nilAppErr *AppError
+	for _, err := range []error{nil, fmt.Errorf("connection refused"), nilAppErr} {
+		rec, problem := respond(t, err)
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		assert.Equal(t, CodeInternal, problem.Code)
+		assert.Equal(t, "An unexpected error occurred", problem.Detail)
+	}
+}
+
+func TestNewValidationError(t *testing.T) {
+	type company struct {
+		Name      string `json:"name" validate:"required,max=5"`
+		Employees int    `json:"employees" validate:"min=1"`
+	}
+	validate := validator.New()
+	validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+		return field.Tag.Get("json")
+	})
+
+	appErr := NewValidationError(validate.Struct(&company{Name: "Too long"}))
+	assert.Equal(t, CodeValidationFailed, appErr.Code)
+	assert.Equal(t, http.StatusBadRequest, appErr.StatusCode)
+	assert.Equal(t, []FieldError{
+		{Field: "name", Constraint: "max", Param: "5", Message: "name must be at most 5 characters long"},
+		{Field: "employees", Constraint: "min", Param: "1", Message: "employees must be at least 1"},
+	}, appErr.Errors)
+
+	other := NewValidationError(fmt.Errorf("not a validation error"))
+	assert.Equal(t, CodeBadRequest, other.Code)
+	assert.Empty(t, other.Errors)
+}
+
+func TestCatalogue(t *testing.T) {
+	seen := map[Code]bool{}
+	for _, definition := range Catalogue {
+		assert.Contains(t, messagesRU, definition.Title, "untranslated title of %s", definition.Code)
+		assert.False(t, seen[definition.Code], "duplicate code %s", definition.Code)
+		seen[definition.Code] = true
+		assert.NotEmpty(t, definition.Title, definition.Code)
+		assert.NotEmpty(t, definition.Description, definition.Code)
+		assert.GreaterOrEqual(t, definition.Status, 400, definition.Code)
+	}
+
+	for _, status := range []int{400, 401, 403, 404, 405, 409, 429, 500, 502} {
+		definition, ok := Lookup(CodeForStatus(status))
+		require.True(t, ok, status)
+		if status != 502 {
+			assert.Equal(t, status, definition.Status)
+		}
+	}
+	assert.Equal(t, http.StatusInternalServerError, New("no_such_code", "message").StatusCode)
+}
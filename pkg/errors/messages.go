@@ -0,0 +1,23 @@
+package errors
+
+import (
+	"fmt"
+
+	"github.com/assylzhan-a/company-task/pkg/i18n"
+)
+
+// catalogs translate the English titles and messages, which serve as keys,
+// per locale. Messages without a translation are returned in English.
+var catalogs = map[string]map[string]string{
+	"ru": messagesRU,
+}
+
+func init() {
+	for _, trans := range i18n.Translators() {
+		for message, translation := range catalogs[trans.Locale()] {
+			if err := trans.Add(message, translation, false); err != nil {
+				panic(fmt.Sprintf("translating %q: %v", message, err))
+			}
+		}
+	}
+}
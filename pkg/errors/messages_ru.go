@@ -0,0 +1,251 @@
+package errors
+
+// messagesRU translates the titles of the catalogue and the error messages
+// into Russian.
+var messagesRU = map[string]string{
+	// Titles
+	"Bad request":                  "Некорректный запрос",
+	"Invalid request payload":      "Некорректное тело запроса",
+	"Invalid parameter":            "Некорректный параметр",
+	"Validation failed":            "Ошибка проверки данных",
+	"Unauthorized":                 "Требуется аутентификация",
+	"Invalid token":                "Недействительный токен",
+	"Forbidden":                    "Доступ запрещён",
+	"Permission denied":            "Недостаточно прав",
+	"No active organization":       "Нет активной организации",
+	"Not found":                    "Не найдено",
+	"Method not allowed":           "Метод не поддерживается",
+	"Conflict":                     "Конфликт",
+	"Precondition failed":          "Предварительное условие не выполнено",
+	"Payload too large":            "Слишком большое тело запроса",
+	"Unsupported media type":       "Неподдерживаемый тип содержимого",
+	"Not acceptable":               "Неприемлемый тип ответа",
+	"Idempotency key reused":       "Ключ идемпотентности уже использован",
+	"Request in progress":          "Запрос ещё выполняется",
+	"Too many requests":            "Слишком много запросов",
+	"Internal server error":        "Внутренняя ошибка сервера",
+	"Invalid credentials":          "Неверные учётные данные",
+	"Weak password":                "Слабый пароль",
+	"Password not set":             "Пароль не задан",
+	"Username taken":               "Имя пользователя занято",
+	"Email taken":                  "Адрес электронной почты занят",
+	"Invalid email":                "Некорректный адрес электронной почты",
+	"Email required":               "Требуется адрес электронной почты",
+	"Email not verified":           "Адрес электронной почты не подтверждён",
+	"Email already verified":       "Адрес электронной почты уже подтверждён",
+	"Invalid refresh token":        "Недействительный токен обновления",
+	"Refresh token reused":         "Токен обновления использован повторно",
+	"User not found":               "Пользователь не найден",
+	"User disabled":                "Пользователь заблокирован",
+	"Cannot modify self":           "Нельзя изменить собственную учётную запись",
+	"Role not found":               "Роль не найдена",
+	"Password login disabled":      "Вход по паролю отключён",
+	"Single sign-on disabled":      "Единый вход не настроен",
+	"Invalid single sign-on state": "Недействительный сеанс единого входа",
+	"Single sign-on failed":        "Ошибка единого входа",
+	"Account not linked":           "Учётная запись не связана",
+	"MFA not enrolled":             "Двухфакторная аутентификация не подключена",
+	"MFA already enabled":          "Двухфакторная аутентификация уже включена",
+	"Invalid MFA code":             "Неверный код подтверждения",
+	"Invalid MFA token":            "Недействительный токен двухфакторной аутентификации",
+	"Organization not found":       "Организация не найдена",
+	"Organization exists":          "Организация уже существует",
+	"Not an organization member":   "Не является участником организации",
+	"Service account not found":    "Сервисный аккаунт не найден",
+	"Service account exists":       "Сервисный аккаунт уже существует",
+	"API key not found":            "API-ключ не найден",
+	"Invalid API key":              "Недействительный API-ключ",
+	"Invalid scope":                "Недопустимая область доступа",
+	"Company not found":            "Компания не найдена",
+	"Company exists":               "Компания уже существует",
+	"Company revision not found":   "Версия компании не найдена",
+	"Invalid patch":                "Некорректный патч",
+	"Patch test failed":            "Проверка патча не пройдена",
+	"Owner not found":              "Владелец не найден",
+	"Not the company owner":        "Не является владельцем компании",
+	"Import not found":             "Импорт не найден",
+	"Invalid import file":          "Некорректный файл импорта",
+	"Invalid import mapping":       "Некорректное сопоставление столбцов",
+
+	// Requests and authentication
+	"An unexpected error occurred":                "Произошла непредвиденная ошибка",
+	"One or more fields are invalid":              "Одно или несколько полей заполнены неверно",
+	"No route matches the request":                "Маршрут не найден",
+	"The route does not support the {0} method":   "Маршрут не поддерживает метод {0}",
+	"A valid {0} header is required":              "Требуется корректный заголовок {0}",
+	"Invalid {0}":                                 "Некорректное значение {0}",
+	"Invalid {0}, expected an RFC 3339 timestamp": "Некорректное значение {0}, ожидается время в формате RFC 3339",
+	"The company ID does not match the URL":       "Идентификатор компании не совпадает с URL",
+	"Invalid company ID":                          "Некорректный идентификатор компании",
+	"Invalid user ID":                             "Некорректный идентификатор пользователя",
+	"Invalid organization ID":                     "Некорректный идентификатор организации",
+	"Invalid service account ID":                  "Некорректный идентификатор сервисного аккаунта",
+	"Invalid API key ID":                          "Некорректный идентификатор API-ключа",
+	"Invalid import ID":                           "Некорректный идентификатор импорта",
+	"Invalid disabled filter":                     "Некорректный фильтр disabled",
+	"The from and to revisions are required":      "Необходимо указать версии from и to",
+	"Content-Type must be {0}":                    "Заголовок Content-Type должен быть {0}",
+	"Accept must allow {0}":                       "Заголовок Accept должен допускать {0}",
+	"Request body must not exceed {0} bytes":      "Тело запроса не должно превышать {0} байт",
+	"Request body is empty":                       "Тело запроса пустое",
+	"Request body must contain a single JSON value, found more at offset {0}": "Тело запроса должно содержать одно значение JSON, найдены данные после позиции {0}",
+	"Malformed JSON at offset {0}":                                            "Некорректный JSON в позиции {0}",
+	"Request body ends unexpectedly":                                          "Тело запроса неожиданно обрывается",
+	"Request body must be a JSON {0}":                                         "Тело запроса должно быть JSON типа {0}",
+	"{0} must be of type {1}":                                                 "{0} должно иметь тип {1}",
+	"{0} is not a known field":                                                "{0} не является известным полем",
+	"{0} is a required field":                                                 "{0} является обязательным полем",
+	"{0} must be at most {1} characters":                                      "{0} должен содержать не более {1} символов",
+	"{0} must be {1}":                                                         "{0} должно быть {1}",
+	"The Idempotency-Key was already used for a different request":            "Idempotency-Key уже использован для другого запроса",
+	"A request with the Idempotency-Key is still in progress":                 "Запрос с этим Idempotency-Key ещё выполняется",
+	"Authentication required":                                                 "Требуется аутентификация",
+	"Authorization header is required":                                        "Требуется заголовок Authorization",
+	"Invalid authorization header format":                                     "Некорректный формат заголовка Authorization",
+	"Token has been revoked":                                                  "Токен отозван",
+	"Missing permission: {0}":                                                 "Отсутствует право: {0}",
+	"Identity provider returned {0}":                                          "Поставщик удостоверений вернул ошибку {0}",
+
+	// Resources
+	"The company does not match the If-Match or If-None-Match header":         "Компания не соответствует заголовку If-Match или If-None-Match",
+	"Company was changed concurrently":                                        "Компания была изменена параллельным запросом",
+	"Another company with the same slug was created concurrently":             "Компания с таким же slug была создана параллельным запросом",
+	"A batch holds at most {0} operations":                                    "Пакет содержит не более {0} операций",
+	"The {0} operation needs {1}":                                             "Для операции {0} нужно указать {1}",
+	"Unknown operation {0}":                                                   "Неизвестная операция {0}",
+	"Test operation {0} failed at {1}":                                        "Проверка {0} не пройдена для {1}",
+	"Operation {0} cannot be applied at {1}":                                  "Операцию {0} нельзя применить к {1}",
+	"The patch sets a field that does not exist or a value of the wrong type": "Патч задаёт несуществующее поле или значение неверного типа",
+	"Company with this name already exists":                                   "Компания с таким названием уже существует",
+	"Company with this name or ID already exists":                             "Компания с таким названием или идентификатором уже существует",
+	"Only the owner or an admin may modify this company":                      "Изменять компанию может только её владелец или администратор",
+	"Username is already taken":                                               "Имя пользователя уже занято",
+	"Email is already registered":                                             "Адрес электронной почты уже зарегистрирован",
+	"MFA is already enabled":                                                  "Двухфакторная аутентификация уже включена",
+	"MFA is not enrolled":                                                     "Двухфакторная аутентификация не подключена",
+	"Organization already exists":                                             "Организация уже существует",
+	"User is not a member of the organization":                                "Пользователь не является участником организации",
+	"Service account already exists":                                          "Сервисный аккаунт уже существует",
+	"The {0} file cannot be read: {1}":                                        "Файл {0} не удалось прочитать: {1}",
+	"The {0} file has no rows":                                                "Файл {0} не содержит строк",
+	"An import that is {0} cannot be run":                                     "Импорт в состоянии {0} нельзя запустить",
+	"The uploader can no longer create companies in the organization":         "Загрузивший файл больше не может создавать компании в организации",
+	"The mapping cannot change after rows were imported":                      "Сопоставление нельзя изменить после импорта строк",
+	"The mapping cannot be used for the file":                                 "Сопоставление нельзя применить к файлу",
+	"The file has no column {0}":                                              "В файле нет столбца {0}",
+	"{0} must be mapped to a column":                                          "{0} должно быть сопоставлено со столбцом",
+	"Row {0} has a company with the same {1}":                                 "В строке {0} есть компания с таким же {1}",
+
+	// Domain errors
+	"username cannot be empty":                              "Имя пользователя не может быть пустым",
+	"password cannot be empty":                              "Пароль не может быть пустым",
+	"invalid username or password":                          "Неверное имя пользователя или пароль",
+	"username is already taken":                             "Имя пользователя уже занято",
+	"password does not meet the policy":                     "Пароль не соответствует требованиям",
+	"too many failed login attempts, retry after {0}":       "Слишком много неудачных попыток входа, повторите через {0}",
+	"too many password reset requests, retry after {0}":     "Слишком много запросов на сброс пароля, повторите через {0}",
+	"invalid or expired refresh token":                      "Токен обновления недействителен или истёк",
+	"refresh token has already been used":                   "Токен обновления уже использован",
+	"user not found":                                        "Пользователь не найден",
+	"role not found":                                        "Роль не найдена",
+	"user is disabled":                                      "Пользователь заблокирован",
+	"administrators cannot disable themselves":              "Администратор не может заблокировать сам себя",
+	"user has no password and signs in with single sign-on": "У пользователя нет пароля, он входит через единый вход",
+	"invalid or expired token":                              "Токен недействителен или истёк",
+	"email is already registered":                           "Адрес электронной почты уже зарегистрирован",
+	"invalid email address":                                 "Некорректный адрес электронной почты",
+	"email is required":                                     "Требуется адрес электронной почты",
+	"email address is not verified":                         "Адрес электронной почты не подтверждён",
+	"email address is already verified":                     "Адрес электронной почты уже подтверждён",
+	"verification email could not be sent":                  "Не удалось отправить письмо для подтверждения",
+	"password login is disabled, use single sign-on":        "Вход по паролю отключён, используйте единый вход",
+	"single sign-on is not configured":                      "Единый вход не настроен",
+	"invalid or expired sso login":                          "Сеанс единого входа недействителен или истёк",
+	"single sign-on login failed":                           "Не удалось выполнить единый вход",
+	"organization name cannot be empty":                     "Название организации не может быть пустым",
+	"organization not found":                                "Организация не найдена",
+	"organization already exists":                           "Организация уже существует",
+	"user is not a member of the organization":              "Пользователь не является участником организации",
+	"mfa is not enrolled":                                   "Двухфакторная аутентификация не подключена",
+	"mfa is already enabled":                                "Двухфакторная аутентификация уже включена",
+	"invalid mfa code":                                      "Неверный код подтверждения",
+	"invalid or expired mfa token":                          "Токен двухфакторной аутентификации недействителен или истёк",
+	"service account name cannot be empty":                  "Название сервисного аккаунта не может быть пустым",
+	"service account not found":                             "Сервисный аккаунт не найден",
+	"service account already exists":                        "Сервисный аккаунт уже существует",
+	"api key not found":                                     "API-ключ не найден",
+	"invalid or expired api key":                            "API-ключ недействителен или истёк",
+	"unknown or missing api key scope":                      "Область доступа API-ключа неизвестна или не указана",
+	"api key expiry must be in the future":                  "Срок действия API-ключа должен быть в будущем",
+	"password must be at least {0} characters long":         "Пароль должен содержать не менее {0} символов",
+	"password must contain an uppercase letter":             "Пароль должен содержать заглавную букву",
+	"password must contain a lowercase letter":              "Пароль должен содержать строчную букву",
+	"password must contain a digit":                         "Пароль должен содержать цифру",
+	"password must contain a symbol":                        "Пароль должен содержать специальный символ",
+	"password appears in a list of breached passwords":      "Пароль есть в списке скомпрометированных паролей",
+	"An account with the same username or email already exists and is not linked to this identity": "Учётная запись с таким именем пользователя или адресом электронной почты уже существует и не связана с этим удостоверением",
+
+	// Internal errors
+	"Failed to add organization member":    "Не удалось добавить участника организации",
+	"Failed to assign role":                "Не удалось назначить роль",
+	"Failed to begin transaction":          "Не удалось начать транзакцию",
+	"Failed to change password":            "Не удалось изменить пароль",
+	"Failed to commit transaction":         "Не удалось завершить транзакцию",
+	"Failed to choose company slug":        "Не удалось выбрать slug компании",
+	"Failed to claim import":               "Не удалось получить импорт для обработки",
+	"Failed to compare revisions":          "Не удалось сравнить версии",
+	"Failed to compute company statistics": "Не удалось рассчитать статистику компаний",
+	"Failed to confirm MFA":                "Не удалось подтвердить двухфакторную аутентификацию",
+	"Failed to create API key":             "Не удалось создать API-ключ",
+	"Failed to create company":             "Не удалось создать компанию",
+	"Failed to create company revision":    "Не удалось сохранить версию компании",
+	"Failed to create import":              "Не удалось создать импорт",
+	"Failed to create organization":        "Не удалось создать организацию",
+	"Failed to create outbox event":        "Не удалось сохранить событие",
+	"Failed to create service account":     "Не удалось создать сервисный аккаунт",
+	"Failed to delete account":             "Не удалось удалить учётную запись",
+	"Failed to delete company":             "Не удалось удалить компанию",
+	"Failed to delete outbox event":        "Не удалось удалить событие",
+	"Failed to disable MFA":                "Не удалось отключить двухфакторную аутентификацию",
+	"Failed to encode company revision":    "Не удалось сохранить версию компании",
+	"Failed to enroll MFA":                 "Не удалось подключить двухфакторную аутентификацию",
+	"Failed to generate company ID":        "Не удалось сгенерировать ID компании",
+	"Failed to get company":                "Не удалось получить компанию",
+	"Failed to get company revision":       "Не удалось получить версию компании",
+	"Failed to get import":                 "Не удалось получить импорт",
+	"Failed to get outbox events":          "Не удалось получить события",
+	"Failed to get profile":                "Не удалось получить профиль",
+	"Failed to get user roles":             "Не удалось получить роли пользователя",
+	"Failed to list API keys":              "Не удалось получить список API-ключей",
+	"Failed to list audit events":          "Не удалось получить журнал аудита",
+	"Failed to list company revisions":     "Не удалось получить историю компании",
+	"Failed to list import errors":         "Не удалось получить ошибки импорта",
+	"Failed to list organizations":         "Не удалось получить список организаций",
+	"Failed to list roles":                 "Не удалось получить список ролей",
+	"Failed to list service accounts":      "Не удалось получить список сервисных аккаунтов",
+	"Failed to list users":                 "Не удалось получить список пользователей",
+	"Failed to log in":                     "Не удалось выполнить вход",
+	"Failed to log out":                    "Не удалось выполнить выход",
+	"Failed to queue import":               "Не удалось поставить импорт в очередь",
+	"Failed to read import":                "Не удалось прочитать импорт",
+	"Failed to refresh token":              "Не удалось обновить токен",
+	"Failed to regenerate recovery codes":  "Не удалось создать новые коды восстановления",
+	"Failed to register user":              "Не удалось зарегистрировать пользователя",
+	"Failed to remove organization member": "Не удалось удалить участника организации",
+	"Failed to request password reset":     "Не удалось запросить сброс пароля",
+	"Failed to reset password":             "Не удалось сбросить пароль",
+	"Failed to revoke API key":             "Не удалось отозвать API-ключ",
+	"Failed to revoke role":                "Не удалось отозвать роль",
+	"Failed to save import progress":       "Не удалось сохранить ход импорта",
+	"Failed to scan company revision":      "Не удалось прочитать версию компании",
+	"Failed to scan outbox event":          "Не удалось прочитать событие",
+	"Failed to send verification email":    "Не удалось отправить письмо для подтверждения",
+	"Failed to set tenant":                 "Не удалось выбрать организацию",
+	"Failed to set tenant role":            "Не удалось выбрать роль организации",
+	"Failed to switch organization":        "Не удалось сменить организацию",
+	"Failed to update company":             "Не удалось обновить компанию",
+	"Failed to update user":                "Не удалось обновить пользователя",
+	"Failed to verify audit log":           "Не удалось проверить журнал аудита",
+	"Failed to verify email":               "Не удалось подтвердить адрес электронной почты",
+	"Failed to write company statistics":   "Не удалось сформировать статистику компаний",
+}
@@ -0,0 +1,88 @@
+package errors
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/assylzhan-a/company-task/pkg/i18n"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// ContentTypeProblem is the media type of error responses (RFC 7807).
+const ContentTypeProblem = "application/problem+json"
+
+// TypeURIPrefix prefixes the code to form the type of a problem, so that the
+// type stays a stable URI that does not depend on where the API is hosted.
+const TypeURIPrefix = "urn:company-task:problem:"
+
+// Problem is the body of error responses. Besides the members defined by
+// RFC 7807 it carries the stable error code, the ID of the request for
+// support and the invalid fields of validation errors.
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	Code      Code         `json:"code"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+
+	language string
+}
+
+// NewProblem describes the error as a problem in the language the request
+// accepts. Errors other than *AppError, including nil, become an internal
+// error whose message is not disclosed.
+func NewProblem(r *http.Request, err error) *Problem {
+	var appErr *AppError
+	if !errors.As(err, &appErr) || appErr == nil {
+		appErr = NewInternalServerError("An unexpected error occurred")
+	}
+
+	status := appErr.StatusCode
+	if status < 400 || status > 599 {
+		status = http.StatusInternalServerError
+	}
+	code := appErr.Code
+	if code == "" {
+		code = CodeForStatus(status)
+	}
+	title := http.StatusText(status)
+	if definition, ok := Lookup(code); ok {
+		title = definition.Title
+	}
+
+	trans := i18n.Translator(r.Header.Get("Accept-Language"))
+	detail := i18n.T(trans, appErr.Message)
+	if appErr.key != "" {
+		detail = i18n.T(trans, appErr.key, appErr.params...)
+	}
+
+	return &Problem{
+		Type:      TypeURIPrefix + string(code),
+		Title:     i18n.T(trans, title),
+		Status:    status,
+		Detail:    detail,
+		Instance:  r.URL.Path,
+		Code:      code,
+		RequestID: middleware.GetReqID(r.Context()),
+		Errors:    appErr.translateFieldErrors(trans),
+		language:  trans.Locale(),
+	}
+}
+
+func RespondWithError(w http.ResponseWriter, r *http.Request, err error) {
+	problem := NewProblem(r, err)
+	if problem.Status == http.StatusInternalServerError {
+		slog.ErrorContext(r.Context(), "Request failed", "error", err, "path", r.URL.Path, "request_id", problem.RequestID)
+	}
+
+	w.Header().Set("Content-Type", ContentTypeProblem)
+	w.Header().Set("Content-Language", problem.language)
+	w.Header().Add("Vary", "Accept-Language")
+	w.WriteHeader(problem.Status)
+	json.NewEncoder(w).Encode(problem)
+}
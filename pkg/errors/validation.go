@@ -0,0 +1,123 @@
+package errors
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/assylzhan-a/company-task/pkg/i18n"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+)
+
+// FieldError describes an invalid field of a request. Constraint is the name
+// of the violated validation rule, e.g. required or max, and Param its
+// argument if it has one. Offset is the position in the request body of
+// fields that could not be decoded.
+type FieldError struct {
+	Field      string `json:"field"`
+	Constraint string `json:"constraint"`
+	Param      string `json:"param,omitempty"`
+	Message    string `json:"message"`
+	Offset     int64  `json:"offset,omitempty"`
+
+	key    string
+	params []string
+}
+
+// NewFieldError describes an invalid field with a message that is translated
+// like the message of Newf.
+func NewFieldError(field, constraint, param, message string, params ...string) FieldError {
+	return FieldError{
+		Field:      field,
+		Constraint: constraint,
+		Param:      param,
+		Message:    i18n.Format(message, params...),
+		key:        message,
+		params:     params,
+	}
+}
+
+// NewValidationError reports the fields rejected by the validator. Any other
+// error is reported as a bad request with its own message.
+func NewValidationError(err error) *AppError {
+	var validationErrors validator.ValidationErrors
+	if !errors.As(err, &validationErrors) {
+		return NewBadRequestError(err.Error())
+	}
+
+	appErr := New(CodeValidationFailed, "One or more fields are invalid")
+	appErr.validationErrors = validationErrors
+	appErr.Errors = translateValidationErrors(validationErrors, i18n.Default())
+	return appErr
+}
+
+// translateFieldErrors returns the field errors of the AppError with their
+// messages in the language of the translator.
+func (e *AppError) translateFieldErrors(trans ut.Translator) []FieldError {
+	if e.validationErrors != nil {
+		return translateValidationErrors(e.validationErrors, trans)
+	}
+
+	var translated []FieldError
+	for _, fieldErr := range e.Errors {
+		if fieldErr.key != "" {
+			fieldErr.Message = i18n.T(trans, fieldErr.key, fieldErr.params...)
+		} else {
+			fieldErr.Message = i18n.T(trans, fieldErr.Message)
+		}
+		translated = append(translated, fieldErr)
+	}
+	return translated
+}
+
+func translateValidationErrors(validationErrors validator.ValidationErrors, trans ut.Translator) []FieldError {
+	fieldErrors := make([]FieldError, 0, len(validationErrors))
+	for _, fieldErr := range validationErrors {
+		message := fieldErr.Translate(trans)
+		// Translate falls back to the raw validator message for rules
+		// without a registered translation.
+		if message == fieldErr.Error() {
+			message = fieldName(fieldErr) + " " + constraintMessage(fieldErr)
+		}
+		fieldErrors = append(fieldErrors, FieldError{
+			Field:      fieldName(fieldErr),
+			Constraint: fieldErr.Tag(),
+			Param:      fieldErr.Param(),
+			Message:    message,
+		})
+	}
+	return fieldErrors
+}
+
+// fieldName returns the path of the field without the name of the validated
+// struct, e.g. "name" rather than "Company.name".
+func fieldName(fieldErr validator.FieldError) string {
+	namespace := fieldErr.Namespace()
+	if i := strings.Index(namespace, "."); i >= 0 {
+		return namespace[i+1:]
+	}
+	return fieldErr.Field()
+}
+
+func constraintMessage(fieldErr validator.FieldError) string {
+	switch fieldErr.Tag() {
+	case "required":
+		return "is required"
+	case "min":
+		if fieldErr.Kind() == reflect.String {
+			return fmt.Sprintf("must be at least %s characters long", fieldErr.Param())
+		}
+		return fmt.Sprintf("must be at least %s", fieldErr.Param())
+	case "max":
+		if fieldErr.Kind() == reflect.String {
+			return fmt.Sprintf("must be at most %s characters long", fieldErr.Param())
+		}
+		return fmt.Sprintf("must be at most %s", fieldErr.Param())
+	case "oneof":
+		return "must be one of " + strings.Join(strings.Fields(fieldErr.Param()), ", ")
+	default:
+		return "is invalid"
+	}
+}
@@ -0,0 +1,113 @@
+// Package i18n selects the language of messages returned to clients.
+package i18n
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-playground/locales"
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/ru"
+	ut "github.com/go-playground/universal-translator"
+)
+
+// DefaultLocale is used when the client accepts none of the supported
+// locales. Messages are written in it, so it needs no catalog.
+const DefaultLocale = "en"
+
+var supported = []locales.Translator{en.New(), ru.New()}
+
+var universal = ut.New(supported[0], supported...)
+
+// Locales returns the supported locales, the default first.
+func Locales() []string {
+	names := make([]string, len(supported))
+	for i, locale := range supported {
+		names[i] = locale.Locale()
+	}
+	return names
+}
+
+// Translators returns the translator of every supported locale, the default
+// first, for registering translations.
+func Translators() []ut.Translator {
+	translators := make([]ut.Translator, len(supported))
+	for i, locale := range supported {
+		translators[i], _ = universal.GetTranslator(locale.Locale())
+	}
+	return translators
+}
+
+// Default returns the translator of the default locale.
+func Default() ut.Translator {
+	return universal.GetFallback()
+}
+
+// Translator returns the translator of the supported locale the client
+// prefers according to an Accept-Language header, e.g. "ru-RU,ru;q=0.9".
+func Translator(acceptLanguage string) ut.Translator {
+	trans, _ := universal.FindTranslator(preferredLocales(acceptLanguage)...)
+	return trans
+}
+
+// T translates the message and substitutes the params for its placeholders
+// {0}, {1}, ... Messages without a translation are returned in the default
+// locale.
+func T(trans ut.Translator, message string, params ...string) string {
+	if trans != nil {
+		if translated, err := trans.T(message, params...); err == nil && translated != "" {
+			return translated
+		}
+	}
+	return Format(message, params...)
+}
+
+// Format substitutes the params for the placeholders of the message.
+func Format(message string, params ...string) string {
+	for i, param := range params {
+		message = strings.ReplaceAll(message, "{"+strconv.Itoa(i)+"}", param)
+	}
+	return message
+}
+
+// preferredLocales lists the languages of an Accept-Language header by
+// decreasing quality. Each language tag is followed by its base language, so
+// that "ru-RU" matches the "ru" locale.
+func preferredLocales(acceptLanguage string) []string {
+	type weighted struct {
+		tag     string
+		quality float64
+	}
+	var tags []weighted
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		tag := strings.TrimSpace(fields[0])
+		if tag == "" || tag == "*" {
+			continue
+		}
+		quality := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if value, ok := strings.CutPrefix(param, "q="); ok {
+				if q, err := strconv.ParseFloat(value, 64); err == nil {
+					quality = q
+				}
+			}
+		}
+		if quality > 0 {
+			tags = append(tags, weighted{tag, quality})
+		}
+	}
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].quality > tags[j].quality })
+
+	var preferred []string
+	for _, t := range tags {
+		tag := strings.ReplaceAll(t.tag, "-", "_")
+		preferred = append(preferred, tag)
+		if base, _, found := strings.Cut(tag, "_"); found {
+			preferred = append(preferred, base)
+		}
+	}
+	return preferred
+}
@@ -0,0 +1,34 @@
+package i18n
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTranslatorFollowsAcceptLanguage(t *testing.T) {
+	tests := []struct {
+		acceptLanguage string
+		locale         string
+	}{
+		{"", "en"},
+		{"ru", "ru"},
+		{"ru-RU,ru;q=0.9,en;q=0.8", "ru"},
+		{"en-US,ru;q=0.9", "en"},
+		{"fr-FR, ru;q=0.5", "ru"},
+		{"ru;q=0, en", "en"},
+		{"de, fr;q=0.8", "en"},
+		{"*", "en"},
+		{"en;q=0.2, RU-ru;q=0.7", "ru"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.locale, Translator(tt.acceptLanguage).Locale(), tt.acceptLanguage)
+	}
+}
+
+func TestTFallsBackToTheMessage(t *testing.T) {
+	assert.Equal(t, "Invalid limit", T(Translator("ru"), "Invalid {0}", "limit"))
+	assert.Equal(t, "Invalid limit", T(nil, "Invalid {0}", "limit"))
+	assert.Equal(t, []string{"en", "ru"}, Locales())
+	assert.Equal(t, DefaultLocale, Default().Locale())
+}
@@ -0,0 +1,87 @@
+// Package secretbox encrypts secrets stored in the database with a key
+// encryption key (KEK) from the configuration, using AES-256-GCM.
+package secretbox
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+// KeySize is the size of a key encryption key in bytes.
+const KeySize = 32
+
+// version is the first byte of every sealed value, leaving room to change the
+// format or rotate the KEK later.
+const version byte = 1
+
+var ErrInvalidCiphertext = errors.New("invalid ciphertext")
+
+// Box seals and opens values with a single key encryption key.
+type Box struct {
+	aead cipher.AEAD
+}
+
+// New returns a box for a KeySize byte key.
+func New(key []byte) (*Box, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("key encryption key must be %d bytes, got %d", KeySize, len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Box{aead: aead}, nil
+}
+
+// ParseKey decodes a base64 encoded key encryption key.
+func ParseKey(encoded string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, errors.New("key encryption key must be base64 encoded")
+	}
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("key encryption key must be %d bytes, got %d", KeySize, len(key))
+	}
+	return key, nil
+}
+
+// Seal encrypts plaintext. The additional data, typically the ID of the row
+// the value belongs to, is authenticated but not stored, so a sealed value
+// cannot be copied to another row.
+func (b *Box) Seal(plaintext, additionalData []byte) ([]byte, error) {
+	nonce := make([]byte, b.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	sealed := make([]byte, 0, 1+len(nonce)+len(plaintext)+b.aead.Overhead())
+	sealed = append(sealed, version)
+	sealed = append(sealed, nonce...)
+	return b.aead.Seal(sealed, nonce, plaintext, additionalData), nil
+}
+
+// Open decrypts a value returned by Seal with the same additional data.
+func (b *Box) Open(sealed, additionalData []byte) ([]byte, error) {
+	if !IsSealed(sealed) || len(sealed) < 1+b.aead.NonceSize()+b.aead.Overhead() {
+		return nil, ErrInvalidCiphertext
+	}
+	nonce := sealed[1 : 1+b.aead.NonceSize()]
+	plaintext, err := b.aead.Open(nil, nonce, sealed[1+b.aead.NonceSize():], additionalData)
+	if err != nil {
+		return nil, ErrInvalidCiphertext
+	}
+	return plaintext, nil
+}
+
+// IsSealed reports whether the value looks like the output of Seal, to tell
+// it apart from values stored before encryption was introduced.
+func IsSealed(value []byte) bool {
+	return len(value) > 0 && value[0] == version
+}
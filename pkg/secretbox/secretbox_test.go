@@ -0,0 +1,69 @@
+package secretbox
+
+import (
+	"bytes"
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newBox(t *testing.T) *Box {
+	t.Helper()
+	box, err := New(bytes.Repeat([]byte{7}, KeySize))
+	require.NoError(t, err)
+	return box
+}
+
+func TestSealOpen(t *testing.T) {
+	box := newBox(t)
+
+	sealed, err := box.Seal([]byte("secret"), []byte("row-1"))
+	require.NoError(t, err)
+	assert.True(t, IsSealed(sealed))
+	assert.NotContains(t, string(sealed), "secret")
+
+	opened, err := box.Open(sealed, []byte("row-1"))
+	require.NoError(t, err)
+	assert.Equal(t, "secret", string(opened))
+
+	again, err := box.Seal([]byte("secret"), []byte("row-1"))
+	require.NoError(t, err)
+	assert.NotEqual(t, sealed, again, "every seal uses a fresh nonce")
+}
+
+func TestOpenRejectsTampering(t *testing.T) {
+	box := newBox(t)
+	sealed, err := box.Seal([]byte("secret"), []byte("row-1"))
+	require.NoError(t, err)
+
+	_, err = box.Open(sealed, []byte("row-2"))
+	assert.ErrorIs(t, err, ErrInvalidCiphertext, "additional data must match")
+
+	tampered := append([]byte(nil), sealed...)
+	tampered[len(tampered)-1] ^= 1
+	_, err = box.Open(tampered, []byte("row-1"))
+	assert.ErrorIs(t, err, ErrInvalidCiphertext)
+
+	_, err = box.Open(sealed[:5], []byte("row-1"))
+	assert.ErrorIs(t, err, ErrInvalidCiphertext)
+
+	other, err := New(bytes.Repeat([]byte{8}, KeySize))
+	require.NoError(t, err)
+	_, err = other.Open(sealed, []byte("row-1"))
+	assert.ErrorIs(t, err, ErrInvalidCiphertext, "a different key cannot open it")
+}
+
+func TestParseKey(t *testing.T) {
+	key, err := ParseKey(base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, KeySize)))
+	require.NoError(t, err)
+	assert.Len(t, key, KeySize)
+
+	_, err = ParseKey("not base64!")
+	assert.Error(t, err)
+	_, err = ParseKey(base64.StdEncoding.EncodeToString([]byte("short")))
+	assert.Error(t, err)
+	_, err = New([]byte("short"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,344 @@
+// Package tabular reads and writes tables in CSV, newline-delimited JSON and
+// XLSX files. The first row of a CSV or XLSX file names the columns; the
+// columns of an NDJSON file are the keys of its objects in the order they
+// first appear.
+package tabular
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/assylzhan-a/company-task/pkg/xlsx"
+)
+
+// Format is the format of a file.
+type Format string
+
+const (
+	CSV    Format = "csv"
+	NDJSON Format = "ndjson"
+	XLSX   Format = "xlsx"
+)
+
+// Formats lists the supported formats.
+var Formats = []Format{CSV, NDJSON, XLSX}
+
+var mediaTypes = map[Format]string{
+	CSV:    "text/csv",
+	NDJSON: "application/x-ndjson",
+	XLSX:   "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+}
+
+// ErrInvalidFile is returned for files that cannot be read in their format.
+var ErrInvalidFile = errors.New("tabular: invalid file")
+
+// ParseFormat returns the format with the name, e.g. "csv".
+func ParseFormat(name string) (Format, bool) {
+	for _, format := range Formats {
+		if string(format) == strings.ToLower(name) {
+			return format, true
+		}
+	}
+	return "", false
+}
+
+// FormatForMediaType returns the format of files of the media type.
+func FormatForMediaType(mediaType string) (Format, bool) {
+	for format, formatMediaType := range mediaTypes {
+		if strings.EqualFold(mediaType, formatMediaType) {
+			return format, true
+		}
+	}
+	return "", false
+}
+
+// MediaType returns the media type of files of the format.
+func (f Format) MediaType() string {
+	return mediaTypes[f]
+}
+
+// Table is the content of a file. Every row has a value for every column;
+// missing values are empty.
+type Table struct {
+	Columns []string
+	Rows    []Row
+}
+
+// Row is a row of a table. Line is the line of the row in a CSV or NDJSON
+// file and its row number in an XLSX file, which is what users see when they
+// open the file.
+type Row struct {
+	Line   int
+	Values []string
+}
+
+// Read reads the whole table from a file of the format. It is meant for
+// small files; use a Reader to read the rows one at a time.
+func Read(format Format, data []byte) (*Table, error) {
+	reader, err := NewReader(format, data)
+	if err != nil {
+		return nil, err
+	}
+	table := &Table{Columns: reader.Columns}
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			return table, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		table.Rows = append(table.Rows, row)
+	}
+}
+
+// Reader reads the rows of a file one at a time. Column names are trimmed
+// and must be unique; rows without any value are skipped.
+type Reader struct {
+	Columns []string
+	// next returns the line and the values of the next record, or io.EOF.
+	next func() (int, []string, error)
+}
+
+// NewReader reads the columns of a file of the format.
+func NewReader(format Format, data []byte) (*Reader, error) {
+	switch format {
+	case CSV:
+		return newCSVReader(data)
+	case NDJSON:
+		return newNDJSONReader(data)
+	case XLSX:
+		return newXLSXReader(data)
+	default:
+		return nil, fmt.Errorf("tabular: unknown format %q", format)
+	}
+}
+
+// Read returns the next row, or io.EOF after the last one.
+func (r *Reader) Read() (Row, error) {
+	for {
+		line, values, err := r.next()
+		if err != nil {
+			return Row{}, err
+		}
+		if len(values) > len(r.Columns) {
+			for _, value := range values[len(r.Columns):] {
+				if strings.TrimSpace(value) != "" {
+					return Row{}, fmt.Errorf("%w: line %d has more values than the header has columns", ErrInvalidFile, line)
+				}
+			}
+			values = values[:len(r.Columns)]
+		}
+		empty := true
+		for _, value := range values {
+			if strings.TrimSpace(value) != "" {
+				empty = false
+				break
+			}
+		}
+		if empty {
+			continue
+		}
+		for len(values) < len(r.Columns) {
+			values = append(values, "")
+		}
+		return Row{Line: line, Values: values}, nil
+	}
+}
+
+func newCSVReader(data []byte) (*Reader, error) {
+	// Spreadsheet applications start UTF-8 files with a byte order mark.
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+
+	next := func() (int, []string, error) {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return 0, nil, io.EOF
+		}
+		if err != nil {
+			return 0, nil, fmt.Errorf("%w: %v", ErrInvalidFile, err)
+		}
+		line, _ := reader.FieldPos(0)
+		return line, record, nil
+	}
+	_, header, err := next()
+	if err == io.EOF {
+		return nil, fmt.Errorf("%w: the file has no header", ErrInvalidFile)
+	}
+	if err != nil {
+		return nil, err
+	}
+	columns, err := readColumns(header)
+	if err != nil {
+		return nil, err
+	}
+	return &Reader{Columns: columns, next: next}, nil
+}
+
+func newXLSXReader(data []byte) (*Reader, error) {
+	reader, err := xlsx.NewReader(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidFile, err)
+	}
+	next := func() (int, []string, error) {
+		row, err := reader.Read()
+		if err == io.EOF {
+			return 0, nil, io.EOF
+		}
+		if err != nil {
+			return 0, nil, fmt.Errorf("%w: %v", ErrInvalidFile, err)
+		}
+		return row.Number, row.Cells, nil
+	}
+	_, header, err := next()
+	if err == io.EOF {
+		return nil, fmt.Errorf("%w: the file has no header", ErrInvalidFile)
+	}
+	if err != nil {
+		return nil, err
+	}
+	columns, err := readColumns(header)
+	if err != nil {
+		return nil, err
+	}
+	return &Reader{Columns: columns, next: next}, nil
+}
+
+// newNDJSONReader reads an object per line. Strings are read as they are,
+// null as an empty value and any other value as its JSON text. The columns
+// are the keys of all the objects, so the file is read twice: once for the
+// columns and once for the rows.
+func newNDJSONReader(data []byte) (*Reader, error) {
+	reader := &Reader{Columns: []string{}}
+	columns := make(map[string]int)
+	objects := newObjectScanner(data)
+	for {
+		_, keys, _, err := objects.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range keys {
+			if _, ok := columns[key]; !ok {
+				columns[key] = len(reader.Columns)
+				reader.Columns = append(reader.Columns, key)
+			}
+		}
+	}
+
+	objects = newObjectScanner(data)
+	reader.next = func() (int, []string, error) {
+		line, _, object, err := objects.next()
+		if err != nil {
+			return 0, nil, err
+		}
+		values := make([]string, len(reader.Columns))
+		for key, value := range object {
+			values[columns[key]] = value
+		}
+		return line, values, nil
+	}
+	return reader, nil
+}
+
+// objectScanner reads the objects of an NDJSON file, skipping blank lines.
+type objectScanner struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func newObjectScanner(data []byte) *objectScanner {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, len(data)+1)
+	return &objectScanner{scanner: scanner}
+}
+
+// next returns the line, keys and values of the next object, or io.EOF.
+func (s *objectScanner) next() (int, []string, map[string]string, error) {
+	for s.scanner.Scan() {
+		s.line++
+		text := bytes.TrimSpace(s.scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		keys, object, err := readObject(text)
+		if err != nil {
+			return 0, nil, nil, fmt.Errorf("%w: line %d: %v", ErrInvalidFile, s.line, err)
+		}
+		return s.line, keys, object, nil
+	}
+	if err := s.scanner.Err(); err != nil {
+		return 0, nil, nil, fmt.Errorf("%w: %v", ErrInvalidFile, err)
+	}
+	return 0, nil, nil, io.EOF
+}
+
+// readObject returns the keys of the JSON object in order and its values.
+func readObject(data []byte) ([]string, map[string]string, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return nil, nil, errors.New("expected a JSON object")
+	}
+
+	var keys []string
+	object := make(map[string]string)
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, nil, err
+		}
+		key := strings.TrimSpace(token.(string))
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err != nil {
+			return nil, nil, err
+		}
+		if _, ok := object[key]; ok {
+			return nil, nil, fmt.Errorf("duplicate key %q", key)
+		}
+		keys = append(keys, key)
+		object[key] = jsonText(value)
+	}
+	if _, err := decoder.Token(); err != nil {
+		return nil, nil, err
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, nil, errors.New("expected a single JSON object")
+	}
+	return keys, object, nil
+}
+
+func jsonText(value json.RawMessage) string {
+	var s string
+	if err := json.Unmarshal(value, &s); err == nil {
+		return s
+	}
+	if string(value) == "null" {
+		return ""
+	}
+	return string(value)
+}
+
+func readColumns(names []string) ([]string, error) {
+	seen := make(map[string]bool, len(names))
+	columns := make([]string, len(names))
+	for i, name := range names {
+		name = strings.TrimSpace(name)
+		if name != "" && seen[name] {
+			return nil, fmt.Errorf("%w: duplicate column %q", ErrInvalidFile, name)
+		}
+		seen[name] = true
+		columns[i] = name
+	}
+	return columns, nil
+}
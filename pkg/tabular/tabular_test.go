@@ -0,0 +1,94 @@
+package tabular
+
+import (
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadCSV(t *testing.T) {
+	data := "\xef\xbb\xbfname, amount_of_employees ,registered\n" +
+		"Acme,10,true\n" +
+		"\n" +
+		"\"Multi\nline\",3\n" +
+		",,\n" +
+		"Beta,5,false,\n"
+
+	table, err := Read(CSV, []byte(data))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"name", "amount_of_employees", "registered"}, table.Columns)
+	assert.Equal(t, []Row{
+		{Line: 2, Values: []string{"Acme", "10", "true"}},
+		{Line: 4, Values: []string{"Multi\nline", "3", ""}},
+		{Line: 7, Values: []string{"Beta", "5", "false"}},
+	}, table.Rows)
+}
+
+func TestReadNDJSON(t *testing.T) {
+	data := `{"name":"Acme","amount_of_employees":10,"registered":true}` + "\n" +
+		"\n" +
+		`{"registered":false,"name":"Beta","description":null,"tags":["a"]}` + "\n"
+
+	table, err := Read(NDJSON, []byte(data))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"name", "amount_of_employees", "registered", "description", "tags"}, table.Columns)
+	assert.Equal(t, []Row{
+		{Line: 1, Values: []string{"Acme", "10", "true", "", ""}},
+		{Line: 3, Values: []string{"Beta", "", "false", "", `["a"]`}},
+	}, table.Rows)
+}
+
+func TestReaderReadsRowsOneAtATime(t *testing.T) {
+	reader, err := NewReader(CSV, []byte("name\nAcme\n\"Beta\n"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"name"}, reader.Columns)
+
+	row, err := reader.Read()
+	require.NoError(t, err)
+	assert.Equal(t, Row{Line: 2, Values: []string{"Acme"}}, row)
+	_, err = reader.Read()
+	assert.ErrorIs(t, err, ErrInvalidFile)
+
+	reader, err = NewReader(NDJSON, []byte(`{"name":"Acme"}`))
+	require.NoError(t, err)
+	_, err = reader.Read()
+	require.NoError(t, err)
+	_, err = reader.Read()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestReadRejectsInvalidFiles(t *testing.T) {
+	for name, test := range map[string]struct {
+		format Format
+		data   string
+	}{
+		"empty CSV":          {CSV, ""},
+		"duplicate column":   {CSV, "name,name\n"},
+		"extra values":       {CSV, "name\nAcme,10\n"},
+		"unterminated quote": {CSV, "name\n\"Acme\n"},
+		"not an object":      {NDJSON, `["Acme"]`},
+		"two objects":        {NDJSON, `{"name":"Acme"} {"name":"Beta"}`},
+		"duplicate key":      {NDJSON, `{"name":"Acme","name":"Beta"}`},
+		"not a spreadsheet":  {XLSX, "name\nAcme\n"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := Read(test.format, []byte(test.data))
+			assert.ErrorIs(t, err, ErrInvalidFile)
+		})
+	}
+}
+
+func TestFormats(t *testing.T) {
+	format, ok := ParseFormat("XLSX")
+	assert.True(t, ok)
+	assert.Equal(t, XLSX, format)
+	_, ok = ParseFormat("xls")
+	assert.False(t, ok)
+
+	format, ok = FormatForMediaType("text/CSV")
+	assert.True(t, ok)
+	assert.Equal(t, CSV, format)
+	assert.Equal(t, "application/x-ndjson", NDJSON.MediaType())
+}
@@ -0,0 +1,178 @@
+package tabular
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/assylzhan-a/company-task/pkg/xlsx"
+)
+
+// Writer writes a table to a file row by row.
+type Writer interface {
+	// Write writes a row with a value for every column.
+	Write(values []interface{}) error
+	// Close writes any buffered rows and completes the file. It does not
+	// close the underlying writer.
+	Close() error
+}
+
+// NewWriter starts a file of the format on w with the columns. Values may be
+// strings, booleans, integers, floats, times or nil for a missing value. CSV
+// files hold them as text, with times in RFC 3339 and nil as an empty value.
+// NDJSON files hold an object per row with the columns as keys and typed
+// values, and XLSX files hold a typed cell per value.
+//
+// Strings that a spreadsheet application would take for a formula, i.e.
+// those starting with =, +, -, @, a tab or a carriage return, are written to
+// CSV and XLSX files with a leading ', so that opening an export does not
+// run formulas from its data.
+func NewWriter(format Format, w io.Writer, columns []string) (Writer, error) {
+	switch format {
+	case CSV:
+		writer := &csvWriter{writer: csv.NewWriter(w), columns: len(columns)}
+		return writer, writer.writer.Write(columns)
+	case NDJSON:
+		return &ndjsonWriter{writer: bufio.NewWriter(w), columns: columns}, nil
+	case XLSX:
+		writer := &xlsxWriter{writer: xlsx.NewWriter(w), columns: len(columns)}
+		header := make([]interface{}, len(columns))
+		for i, column := range columns {
+			header[i] = column
+		}
+		return writer, writer.writer.WriteRow(header)
+	default:
+		return nil, fmt.Errorf("tabular: unknown format %q", format)
+	}
+}
+
+func checkValues(values []interface{}, columns int) error {
+	if len(values) != columns {
+		return fmt.Errorf("tabular: %d values for %d columns", len(values), columns)
+	}
+	return nil
+}
+
+type csvWriter struct {
+	writer  *csv.Writer
+	columns int
+}
+
+func (w *csvWriter) Write(values []interface{}) error {
+	if err := checkValues(values, w.columns); err != nil {
+		return err
+	}
+	record := make([]string, len(values))
+	for i, value := range values {
+		text, err := formatText(neutralizeFormula(value))
+		if err != nil {
+			return err
+		}
+		record[i] = text
+	}
+	return w.writer.Write(record)
+}
+
+func (w *csvWriter) Close() error {
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+// neutralizeFormula prefixes strings that start like a formula with '.
+// Numbers are not strings, so negative numbers are kept.
+func neutralizeFormula(value interface{}) interface{} {
+	text, ok := value.(string)
+	if !ok || text == "" || !strings.ContainsRune("=+-@\t\r", rune(text[0])) {
+		return value
+	}
+	return "'" + text
+}
+
+func formatText(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64), nil
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano), nil
+	default:
+		return "", fmt.Errorf("tabular: cannot write a value of type %T", value)
+	}
+}
+
+type ndjsonWriter struct {
+	writer  *bufio.Writer
+	columns []string
+}
+
+// Write writes the keys in the order of the columns, which encoding a map
+// would not.
+func (w *ndjsonWriter) Write(values []interface{}) error {
+	if err := checkValues(values, len(w.columns)); err != nil {
+		return err
+	}
+	w.writer.WriteByte('{')
+	for i, value := range values {
+		switch v := value.(type) {
+		case nil, string, bool, int, int64, float64:
+		case time.Time:
+			value = v.UTC().Format(time.RFC3339Nano)
+		default:
+			return fmt.Errorf("tabular: cannot write a value of type %T", value)
+		}
+		key, err := json.Marshal(w.columns[i])
+		if err != nil {
+			return err
+		}
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			w.writer.WriteByte(',')
+		}
+		w.writer.Write(key)
+		w.writer.WriteByte(':')
+		w.writer.Write(encoded)
+	}
+	_, err := w.writer.WriteString("}\n")
+	return err
+}
+
+func (w *ndjsonWriter) Close() error {
+	return w.writer.Flush()
+}
+
+type xlsxWriter struct {
+	writer  *xlsx.Writer
+	columns int
+}
+
+func (w *xlsxWriter) Write(values []interface{}) error {
+	if err := checkValues(values, w.columns); err != nil {
+		return err
+	}
+	cells := make([]interface{}, len(values))
+	for i, value := range values {
+		cells[i] = neutralizeFormula(value)
+	}
+	return w.writer.WriteRow(cells)
+}
+
+func (w *xlsxWriter) Close() error {
+	return w.writer.Close()
+}
@@ -0,0 +1,78 @@
+package tabular
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriterRoundTrip(t *testing.T) {
+	columns := []string{"name", "amount_of_employees", "registered", "created_at", "description"}
+	rows := [][]interface{}{
+		{"Acme, Inc.", 10, true, time.Date(2024, 10, 13, 9, 0, 0, 500, time.FixedZone("", 3600)), nil},
+		{"Beta", int64(5), false, time.Date(2024, 10, 14, 9, 0, 0, 0, time.UTC), "Multi\nline"},
+	}
+	want := []Row{
+		{Values: []string{"Acme, Inc.", "10", "true", "2024-10-13T08:00:00.0000005Z", ""}},
+		{Values: []string{"Beta", "5", "false", "2024-10-14T09:00:00Z", "Multi\nline"}},
+	}
+
+	for _, format := range Formats {
+		t.Run(string(format), func(t *testing.T) {
+			var buf bytes.Buffer
+			w, err := NewWriter(format, &buf, columns)
+			require.NoError(t, err)
+			for _, row := range rows {
+				require.NoError(t, w.Write(row))
+			}
+			require.NoError(t, w.Close())
+
+			table, err := Read(format, buf.Bytes())
+			require.NoError(t, err)
+			assert.Equal(t, columns, table.Columns)
+			require.Len(t, table.Rows, len(want))
+			for i, row := range table.Rows {
+				assert.Equal(t, want[i].Values, row.Values)
+			}
+		})
+	}
+}
+
+func TestWriterNeutralizesFormulas(t *testing.T) {
+	row := []interface{}{"=HYPERLINK(\"http://example.com\")", "+1", "-1", "@SUM(A1)", "\tx", "a=b", -1}
+	want := []string{"'=HYPERLINK(\"http://example.com\")", "'+1", "'-1", "'@SUM(A1)", "'\tx", "a=b", "-1"}
+
+	for format, neutralized := range map[Format]bool{CSV: true, XLSX: true, NDJSON: false} {
+		t.Run(string(format), func(t *testing.T) {
+			var buf bytes.Buffer
+			w, err := NewWriter(format, &buf, []string{"a", "b", "c", "d", "e", "f", "g"})
+			require.NoError(t, err)
+			require.NoError(t, w.Write(row))
+			require.NoError(t, w.Close())
+
+			table, err := Read(format, buf.Bytes())
+			require.NoError(t, err)
+			require.Len(t, table.Rows, 1)
+			if neutralized {
+				assert.Equal(t, want, table.Rows[0].Values)
+			} else {
+				assert.Equal(t, "=HYPERLINK(\"http://example.com\")", table.Rows[0].Values[0])
+			}
+		})
+	}
+}
+
+func TestNDJSONWriterKeepsTypesAndOrder(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(NDJSON, &buf, []string{"name", "amount_of_employees", "registered", "description"})
+	require.NoError(t, err)
+	require.NoError(t, w.Write([]interface{}{"Acme", 10, true, nil}))
+	require.NoError(t, w.Close())
+	assert.Equal(t, `{"name":"Acme","amount_of_employees":10,"registered":true,"description":null}`+"\n", buf.String())
+
+	assert.Error(t, w.Write([]interface{}{"Acme"}))
+	assert.Error(t, w.Write([]interface{}{"Acme", 10, true, []string{"a"}}))
+}
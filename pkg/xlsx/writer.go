@@ -0,0 +1,170 @@
+package xlsx
+
+import (
+	"archive/zip"
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+// ErrTooManyRows is returned for rows past the last row of a worksheet.
+var ErrTooManyRows = errors.New("xlsx: worksheet is full")
+
+// maxRows is the number of rows of a worksheet.
+const maxRows = 1048576
+
+// The parts of a workbook with a single worksheet, besides the worksheet.
+var workbookParts = []struct{ name, content string }{
+	{"[Content_Types].xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+		`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+		`</Types>`},
+	{"_rels/.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+		`</Relationships>`},
+	{"xl/workbook.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+		`<sheets><sheet name="Sheet1" sheetId="1" r:id="rId1"/></sheets>` +
+		`</workbook>`},
+	{"xl/_rels/workbook.xml.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+		`</Relationships>`},
+}
+
+// Writer writes a workbook with a single worksheet row by row, so that the
+// rows are not held in memory. Strings are written inline rather than to a
+// shared string table for the same reason.
+type Writer struct {
+	archive *zip.Writer
+	sheet   *bufio.Writer
+	rows    int
+	err     error
+}
+
+// NewWriter starts a workbook on w. Close must be called to complete it.
+func NewWriter(w io.Writer) *Writer {
+	writer := &Writer{archive: zip.NewWriter(w)}
+	for _, part := range workbookParts {
+		if writer.err = writer.writePart(part.name, part.content); writer.err != nil {
+			return writer
+		}
+	}
+	sheet, err := writer.archive.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		writer.err = err
+		return writer
+	}
+	writer.sheet = bufio.NewWriter(sheet)
+	writer.sheet.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	return writer
+}
+
+func (w *Writer) writePart(name, content string) error {
+	part, err := w.archive.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(part, content)
+	return err
+}
+
+// WriteRow appends a row. Cells may be strings, booleans, integers, floats,
+// times, which are written as RFC 3339 text, or nil for an empty cell.
+func (w *Writer) WriteRow(cells []interface{}) error {
+	if w.err != nil {
+		return w.err
+	}
+	if w.rows == maxRows {
+		return ErrTooManyRows
+	}
+	if len(cells) > maxColumns {
+		return fmt.Errorf("xlsx: a row has at most %d cells", maxColumns)
+	}
+	w.rows++
+	fmt.Fprintf(w.sheet, `<row r="%d">`, w.rows)
+	for i, value := range cells {
+		if value == nil {
+			continue
+		}
+		ref := columnName(i) + strconv.Itoa(w.rows)
+		switch v := value.(type) {
+		case string:
+			w.writeString(ref, v)
+		case time.Time:
+			w.writeString(ref, v.UTC().Format(time.RFC3339Nano))
+		case bool:
+			b := "0"
+			if v {
+				b = "1"
+			}
+			fmt.Fprintf(w.sheet, `<c r="%s" t="b"><v>%s</v></c>`, ref, b)
+		case int:
+			fmt.Fprintf(w.sheet, `<c r="%s"><v>%d</v></c>`, ref, v)
+		case int64:
+			fmt.Fprintf(w.sheet, `<c r="%s"><v>%d</v></c>`, ref, v)
+		case float64:
+			fmt.Fprintf(w.sheet, `<c r="%s"><v>%s</v></c>`, ref, strconv.FormatFloat(v, 'g', -1, 64))
+		default:
+			w.err = fmt.Errorf("xlsx: cannot write a cell of type %T", value)
+			return w.err
+		}
+	}
+	_, w.err = w.sheet.WriteString(`</row>`)
+	return w.err
+}
+
+func (w *Writer) writeString(ref, s string) {
+	fmt.Fprintf(w.sheet, `<c r="%s" t="inlineStr"><is><t xml:space="preserve">`, ref)
+	for _, c := range s {
+		switch {
+		case c == '&':
+			w.sheet.WriteString("&amp;")
+		case c == '<':
+			w.sheet.WriteString("&lt;")
+		case c == '>':
+			w.sheet.WriteString("&gt;")
+		case c == '\r':
+			// XML parsers would normalize a literal carriage return away.
+			w.sheet.WriteString("&#13;")
+		case c < ' ' && c != '\t' && c != '\n', c == 0xFFFE, c == 0xFFFF:
+			// Characters that XML cannot represent.
+			w.sheet.WriteRune(utf8.RuneError)
+		default:
+			w.sheet.WriteRune(c)
+		}
+	}
+	w.sheet.WriteString(`</t></is></c>`)
+}
+
+// Close completes the worksheet and the workbook. It does not close the
+// underlying writer.
+func (w *Writer) Close() error {
+	if w.err != nil {
+		return w.err
+	}
+	w.sheet.WriteString(`</sheetData></worksheet>`)
+	if w.err = w.sheet.Flush(); w.err != nil {
+		return w.err
+	}
+	w.err = w.archive.Close()
+	return w.err
+}
+
+// columnName returns the letters of the zero-based column, e.g. "AA" for 26.
+func columnName(column int) string {
+	var name []byte
+	for column++; column > 0; column = (column - 1) / 26 {
+		name = append([]byte{byte('A' + (column-1)%26)}, name...)
+	}
+	return string(name)
+}
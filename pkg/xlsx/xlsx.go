@@ -0,0 +1,316 @@
+// Package xlsx reads the cells of the first worksheet of an Office Open XML
+// spreadsheet (.xlsx) as text and writes workbooks with a single worksheet.
+// Formulas are read as their cached values and styles, including number
+// formats, are ignored.
+package xlsx
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrInvalidFile is returned for files that are not spreadsheets.
+	ErrInvalidFile = errors.New("xlsx: not a spreadsheet")
+	// ErrNoWorksheet is returned for workbooks without a worksheet.
+	ErrNoWorksheet = errors.New("xlsx: workbook has no worksheet")
+)
+
+// Row is a row of a worksheet. Number is the row number shown by spreadsheet
+// applications, starting at 1; rows without cells are skipped. Cells holds
+// the text of the cells from column A up to the last cell of the row, with
+// empty strings for missing cells.
+type Row struct {
+	Number int
+	Cells  []string
+}
+
+// Limits of the reader. Parts are decompressed, so a small file can expand to
+// far more data than its size suggests. They are variables so that tests can
+// lower them.
+var (
+	// maxPartBytes bounds the decompressed size of every part that is read.
+	maxPartBytes int64 = 256 << 20
+	// maxSharedStrings bounds the number of shared strings, which are held
+	// in memory while the worksheet is read.
+	maxSharedStrings = 1 << 20
+)
+
+// Reader reads the rows of the first worksheet of a workbook one at a time,
+// so that the rows are not held in memory.
+type Reader struct {
+	sheet   io.ReadCloser
+	limited *io.LimitedReader
+	decoder *xml.Decoder
+	shared  []string
+	rows    int
+	last    int
+	err     error
+}
+
+// NewReader opens the first worksheet of the workbook and reads its shared
+// strings.
+func NewReader(data []byte) (*Reader, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, ErrInvalidFile
+	}
+	files := make(map[string]*zip.File, len(archive.File))
+	for _, file := range archive.File {
+		files[strings.TrimPrefix(file.Name, "/")] = file
+	}
+
+	sheet, err := firstWorksheet(files)
+	if err != nil {
+		return nil, err
+	}
+	var shared []string
+	if file, ok := files["xl/sharedStrings.xml"]; ok {
+		if shared, err = readSharedStrings(file); err != nil {
+			return nil, err
+		}
+	}
+
+	part, err := sheet.Open()
+	if err != nil {
+		return nil, fmt.Errorf("xlsx: %w", err)
+	}
+	limited := &io.LimitedReader{R: part, N: maxPartBytes}
+	return &Reader{sheet: part, limited: limited, decoder: xml.NewDecoder(limited), shared: shared}, nil
+}
+
+type workbook struct {
+	Sheets []struct {
+		ID string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+type relationships struct {
+	Relationships []struct {
+		ID     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+// firstWorksheet resolves the first sheet of the workbook to its part.
+func firstWorksheet(files map[string]*zip.File) (*zip.File, error) {
+	var book workbook
+	if err := decodePart(files, "xl/workbook.xml", &book); err != nil {
+		return nil, err
+	}
+	if len(book.Sheets) == 0 {
+		return nil, ErrNoWorksheet
+	}
+	var rels relationships
+	if err := decodePart(files, "xl/_rels/workbook.xml.rels", &rels); err != nil {
+		return nil, err
+	}
+	for _, rel := range rels.Relationships {
+		if rel.ID != book.Sheets[0].ID {
+			continue
+		}
+		// Targets are relative to xl/ unless they are absolute.
+		name := path.Join("xl", rel.Target)
+		if strings.HasPrefix(rel.Target, "/") {
+			name = strings.TrimPrefix(rel.Target, "/")
+		}
+		if file, ok := files[name]; ok {
+			return file, nil
+		}
+	}
+	return nil, ErrNoWorksheet
+}
+
+func decodePart(files map[string]*zip.File, name string, v interface{}) error {
+	file, ok := files[name]
+	if !ok {
+		return ErrInvalidFile
+	}
+	return decodeFile(file, v)
+}
+
+func decodeFile(file *zip.File, v interface{}) error {
+	reader, err := file.Open()
+	if err != nil {
+		return fmt.Errorf("xlsx: %w", err)
+	}
+	defer reader.Close()
+	limited := &io.LimitedReader{R: reader, N: maxPartBytes}
+	if err := xml.NewDecoder(limited).Decode(v); err != nil {
+		return partError(file.Name, limited, err)
+	}
+	return nil
+}
+
+// partError reports an error decoding a part, which is most likely due to
+// its size if the limit was reached.
+func partError(name string, limited *io.LimitedReader, err error) error {
+	if limited.N <= 0 {
+		return fmt.Errorf("%w: %s is larger than %d bytes", ErrInvalidFile, name, maxPartBytes)
+	}
+	return fmt.Errorf("%w: %s: %v", ErrInvalidFile, name, err)
+}
+
+// richText is the text of a shared or inline string, which is either a
+// single text element or runs of formatted text.
+type richText struct {
+	Text string   `xml:"t"`
+	Runs []string `xml:"r>t"`
+}
+
+func (t richText) String() string {
+	if len(t.Runs) == 0 {
+		return t.Text
+	}
+	return t.Text + strings.Join(t.Runs, "")
+}
+
+// readSharedStrings decodes the strings one at a time, so that their number
+// is checked before they are all held.
+func readSharedStrings(file *zip.File) ([]string, error) {
+	reader, err := file.Open()
+	if err != nil {
+		return nil, fmt.Errorf("xlsx: %w", err)
+	}
+	defer reader.Close()
+	limited := &io.LimitedReader{R: reader, N: maxPartBytes}
+
+	var shared []string
+	decoder := xml.NewDecoder(limited)
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return shared, nil
+		}
+		if err != nil {
+			return nil, partError(file.Name, limited, err)
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "si" {
+			continue
+		}
+		if len(shared) == maxSharedStrings {
+			return nil, fmt.Errorf("%w: more than %d shared strings", ErrInvalidFile, maxSharedStrings)
+		}
+		var item richText
+		if err := decoder.DecodeElement(&item, &start); err != nil {
+			return nil, partError(file.Name, limited, err)
+		}
+		shared = append(shared, item.String())
+	}
+}
+
+type cell struct {
+	Ref    string   `xml:"r,attr"`
+	Type   string   `xml:"t,attr"`
+	Value  string   `xml:"v"`
+	Inline richText `xml:"is"`
+}
+
+// Read returns the next row that has cells, or io.EOF after the last one.
+// Rows past the last row of a worksheet are rejected.
+func (r *Reader) Read() (Row, error) {
+	if r.err != nil {
+		return Row{}, r.err
+	}
+	row, err := r.read()
+	if err != nil {
+		r.err = err
+		r.sheet.Close()
+	}
+	return row, err
+}
+
+func (r *Reader) read() (Row, error) {
+	for {
+		token, err := r.decoder.Token()
+		if err == io.EOF {
+			return Row{}, io.EOF
+		}
+		if err != nil {
+			return Row{}, partError("worksheet", r.limited, err)
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "row" {
+			continue
+		}
+
+		var row struct {
+			Number int    `xml:"r,attr"`
+			Cells  []cell `xml:"c"`
+		}
+		if err := r.decoder.DecodeElement(&row, &start); err != nil {
+			return Row{}, partError("worksheet", r.limited, err)
+		}
+		if row.Number == 0 {
+			row.Number = r.last + 1
+		}
+		r.rows++
+		if row.Number > maxRows || r.rows > maxRows {
+			return Row{}, fmt.Errorf("%w: more than %d rows", ErrInvalidFile, maxRows)
+		}
+		r.last = row.Number
+
+		var cells []string
+		for _, c := range row.Cells {
+			column := len(cells)
+			if c.Ref != "" {
+				if column, err = columnIndex(c.Ref); err != nil {
+					return Row{}, err
+				}
+			}
+			for len(cells) <= column {
+				cells = append(cells, "")
+			}
+			if cells[column], err = cellText(c, r.shared); err != nil {
+				return Row{}, err
+			}
+		}
+		if len(cells) > 0 {
+			return Row{Number: row.Number, Cells: cells}, nil
+		}
+	}
+}
+
+func cellText(c cell, shared []string) (string, error) {
+	switch c.Type {
+	case "s":
+		index, err := strconv.Atoi(c.Value)
+		if err != nil || index < 0 || index >= len(shared) {
+			return "", fmt.Errorf("%w: cell %s refers to a missing shared string", ErrInvalidFile, c.Ref)
+		}
+		return shared[index], nil
+	case "inlineStr":
+		return c.Inline.String(), nil
+	case "b":
+		if c.Value == "1" {
+			return "true", nil
+		}
+		return "false", nil
+	default:
+		return c.Value, nil
+	}
+}
+
+// columnIndex returns the zero-based column of a cell reference such as "C7".
+func columnIndex(ref string) (int, error) {
+	column := 0
+	i := 0
+	for ; i < len(ref) && 'A' <= ref[i] && ref[i] <= 'Z'; i++ {
+		column = column*26 + int(ref[i]-'A'+1)
+	}
+	if i == 0 || column > maxColumns {
+		return 0, fmt.Errorf("%w: invalid cell reference %q", ErrInvalidFile, ref)
+	}
+	return column - 1, nil
+}
+
+// maxColumns is the number of columns of a worksheet, up to XFD.
+const maxColumns = 16384
@@ -0,0 +1,144 @@
+package xlsx
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newWorkbook zips the parts of a workbook whose only sheet is sheet.
+func newWorkbook(t *testing.T, sheet, sharedStrings string) []byte {
+	t.Helper()
+	parts := map[string]string{
+		"xl/workbook.xml": `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+			<sheets><sheet name="Companies" sheetId="1" r:id="rId1"/></sheets></workbook>`,
+		"xl/_rels/workbook.xml.rels": `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+			<Relationship Id="rId2" Type="sharedStrings" Target="sharedStrings.xml"/>
+			<Relationship Id="rId1" Type="worksheet" Target="worksheets/sheet1.xml"/></Relationships>`,
+		"xl/worksheets/sheet1.xml": `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>` + sheet + `</sheetData></worksheet>`,
+	}
+	if sharedStrings != "" {
+		parts["xl/sharedStrings.xml"] = `<sst xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` + sharedStrings + `</sst>`
+	}
+
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	for name, content := range parts {
+		w, err := archive.Create(name)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, archive.Close())
+	return buf.Bytes()
+}
+
+// readRows reads all the rows of the workbook.
+func readRows(data []byte) ([]Row, error) {
+	reader, err := NewReader(data)
+	if err != nil {
+		return nil, err
+	}
+	var rows []Row
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, row)
+	}
+}
+
+func TestReadRows(t *testing.T) {
+	data := newWorkbook(t, `
+		<row r="1"><c r="A1" t="s"><v>0</v></c><c r="B1" t="s"><v>1</v></c><c r="D1" t="inlineStr"><is><t>registered</t></is></c></row>
+		<row r="3"><c r="A3" t="s"><v>2</v></c><c r="B3"><v>42</v></c><c r="D3" t="b"><v>1</v></c></row>
+		<row r="4"></row>
+		<row r="5"><c r="C5" t="str"><v>computed</v></c></row>`,
+		`<si><t>name</t></si><si><t>employees</t></si><si><r><t>Acme</t></r><r><t xml:space="preserve"> Corp</t></r></si>`)
+
+	rows, err := readRows(data)
+	require.NoError(t, err)
+	assert.Equal(t, []Row{
+		{Number: 1, Cells: []string{"name", "employees", "", "registered"}},
+		{Number: 3, Cells: []string{"Acme Corp", "42", "", "true"}},
+		{Number: 5, Cells: []string{"", "", "computed"}},
+	}, rows)
+}
+
+func TestReadRowsRejectsInvalidFiles(t *testing.T) {
+	_, err := readRows([]byte("name,employees\n"))
+	assert.ErrorIs(t, err, ErrInvalidFile)
+
+	_, err = readRows(newWorkbook(t, `<row r="1"><c r="A1" t="s"><v>3</v></c></row>`, `<si><t>name</t></si>`))
+	assert.ErrorIs(t, err, ErrInvalidFile)
+
+	_, err = readRows(newWorkbook(t, `<row r="1"><c r="1A"><v>1</v></c></row>`, ""))
+	assert.ErrorIs(t, err, ErrInvalidFile)
+}
+
+func TestReadRowsEnforcesLimits(t *testing.T) {
+	defer func(partBytes int64, sharedStrings int) { maxPartBytes, maxSharedStrings = partBytes, sharedStrings }(maxPartBytes, maxSharedStrings)
+
+	maxSharedStrings = 2
+	_, err := readRows(newWorkbook(t, "", `<si><t>a</t></si><si><t>b</t></si><si><t>c</t></si>`))
+	assert.ErrorIs(t, err, ErrInvalidFile)
+
+	var sheet strings.Builder
+	for i := 1; i <= 100; i++ {
+		fmt.Fprintf(&sheet, `<row r="%d"><c r="A%d"><v>%d</v></c></row>`, i, i, i)
+	}
+	data := newWorkbook(t, sheet.String(), "")
+	maxPartBytes = 1024
+	reader, err := NewReader(data)
+	require.NoError(t, err)
+	row, err := reader.Read()
+	require.NoError(t, err)
+	assert.Equal(t, Row{Number: 1, Cells: []string{"1"}}, row)
+	for err == nil {
+		_, err = reader.Read()
+	}
+	assert.ErrorIs(t, err, ErrInvalidFile)
+	assert.Contains(t, err.Error(), "larger than 1024 bytes")
+
+	_, err = readRows(newWorkbook(t, `<row r="1048577"><c r="A1048577"><v>1</v></c></row>`, ""))
+	assert.ErrorIs(t, err, ErrInvalidFile)
+}
+
+func TestWriterRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	require.NoError(t, w.WriteRow([]interface{}{"name", "employees", "registered", "founded", "note"}))
+	require.NoError(t, w.WriteRow([]interface{}{" Acme & <Sons> ", 42, true, time.Date(2024, 10, 13, 9, 0, 0, 0, time.UTC), nil}))
+	require.NoError(t, w.WriteRow([]interface{}{"Line\r\nbreak\x00", int64(7), false, nil, 1.5}))
+	require.NoError(t, w.Close())
+
+	rows, err := readRows(buf.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, []Row{
+		{Number: 1, Cells: []string{"name", "employees", "registered", "founded", "note"}},
+		{Number: 2, Cells: []string{" Acme & <Sons> ", "42", "true", "2024-10-13T09:00:00Z"}},
+		{Number: 3, Cells: []string{"Line\r\nbreak�", "7", "false", "", "1.5"}},
+	}, rows)
+
+	assert.Error(t, NewWriter(&buf).WriteRow([]interface{}{struct{}{}}))
+}
+
+func TestColumnName(t *testing.T) {
+	for column, name := range map[int]string{0: "A", 25: "Z", 26: "AA", 701: "ZZ", 702: "AAA", maxColumns - 1: "XFD"} {
+		assert.Equal(t, name, columnName(column))
+		index, err := columnIndex(name + "1")
+		require.NoError(t, err)
+		assert.Equal(t, column, index)
+	}
+}
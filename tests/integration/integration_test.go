@@ -2,43 +2,73 @@ package main
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
+	"database/sql"
 	"encoding/json"
 	"fmt"
 	"github.com/jackc/pgx/v4/pgxpool"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/assylzhan-a/company-task/config"
+	"github.com/assylzhan-a/company-task/internal/auth"
 	"github.com/assylzhan-a/company-task/internal/db"
 	"github.com/assylzhan-a/company-task/internal/db/repository"
 	handler "github.com/assylzhan-a/company-task/internal/delivery/http"
 	"github.com/assylzhan-a/company-task/internal/domain/entity"
 	uc "github.com/assylzhan-a/company-task/internal/domain/usecase"
+	"github.com/assylzhan-a/company-task/internal/mailer"
+	"github.com/assylzhan-a/company-task/internal/oidc"
+	"github.com/assylzhan-a/company-task/internal/oidc/oidctest"
+	repoports "github.com/assylzhan-a/company-task/internal/ports/repository"
+	ports "github.com/assylzhan-a/company-task/internal/ports/usecase"
+	"github.com/assylzhan-a/company-task/internal/requestid"
+	"github.com/assylzhan-a/company-task/internal/tenant"
 	"github.com/assylzhan-a/company-task/internal/worker"
+	customError "github.com/assylzhan-a/company-task/pkg/errors"
 	"github.com/assylzhan-a/company-task/pkg/logger"
+	"github.com/assylzhan-a/company-task/pkg/secretbox"
+	"github.com/assylzhan-a/company-task/pkg/tabular"
+	"github.com/assylzhan-a/company-task/pkg/totp"
 	"github.com/go-chi/chi/v5"
+	"github.com/golang-jwt/jwt/v4"
 	"github.com/google/uuid"
+	"github.com/pressly/goose/v3"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
 var (
-	testRouter *chi.Mux
-	testDB     *pgxpool.Pool
+	testRouter   *chi.Mux
+	testDB       *pgxpool.Pool
+	testMailer   = &capturingMailer{}
+	testBreaches = breachList{"password123": true}
+	testProvider *oidctest.Provider
+	testImports  ports.ImportUseCase
+	testStats    ports.CompanyStatsUseCase
+	testRoles    ports.RoleUseCase
+	testEvents   repoports.SecurityEventRepository
 )
 
 func TestMain(m *testing.M) {
-	cfg := config.Load()
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Println("Invalid configuration:", err)
+		os.Exit(1)
+	}
 	cfg.DatabaseURL = os.Getenv("DATABASE_URL")
 
 	log := logger.NewLogger(cfg.LogLevel)
 
 	//waiting for the docker db to be ready before running
-	var err error
 	var retries int
 	for retries < 5 {
 		testDB, err = db.NewPostgresConnection(cfg.DatabaseURL, log)
@@ -63,14 +93,102 @@ func TestMain(m *testing.M) {
 	// Set up repositories and use cases
 	userRepo := repository.NewUserRepository(testDB)
 	companyRepo := repository.NewCompanyRepository(testDB)
+	tokenRepo := repository.NewTokenRepository(testDB)
+	apiKeyRepo := repository.NewAPIKeyRepository(testDB)
+	loginThrottleRepo := repository.NewLoginThrottleRepository(testDB)
+	securityEventRepo := repository.NewSecurityEventRepository(testDB)
+	testEvents = securityEventRepo
+	box, err := secretbox.New(bytes.Repeat([]byte{1}, secretbox.KeySize))
+	if err != nil {
+		log.Error("Failed to create key encryption box", "error", err)
+		os.Exit(1)
+	}
+	mfaRepo := repository.NewMFARepository(testDB, box)
+	ssoStateRepo := repository.NewSSOStateRepository(testDB)
+	keySet, err := auth.NewRotatingKeySet(auth.AlgorithmES256, repository.NewSigningKeyRepository(testDB), box, auth.KeyRotation{
+		Interval:      time.Hour,
+		Prepublish:    time.Minute,
+		VerifyGrace:   15 * time.Minute,
+		CheckInterval: time.Minute,
+	}, log)
+	if err != nil {
+		log.Error("Failed to create key set", "error", err)
+		os.Exit(1)
+	}
+	if err := keySet.Rotate(context.Background()); err != nil {
+		log.Error("Failed to generate signing key", "error", err)
+		os.Exit(1)
+	}
+	tokenService := auth.NewTokenService(keySet, "test-issuer", "test-audience", 15*time.Minute)
+	denylist := auth.NewDenylist(tokenRepo, time.Minute, log)
+	authenticator := auth.NewAuthenticator(tokenService, denylist, apiKeyRepo, securityEventRepo)
+
+	roleRepo := repository.NewRoleRepository(testDB)
 
-	userUseCase := uc.NewUserUseCase(userRepo)
-	companyUseCase := uc.NewCompanyUseCase(companyRepo, log)
+	organizationRepo := repository.NewOrganizationRepository(testDB)
+
+	testProvider = oidctest.NewProvider("company-service", "test-secret")
+	ssoProvider := oidc.NewClient(oidc.Config{
+		IssuerURL:    testProvider.Issuer(),
+		ClientID:     testProvider.ClientID,
+		ClientSecret: testProvider.ClientSecret,
+		RedirectURL:  "https://api.example.com/v1/users/sso/callback",
+	}, nil)
+
+	userUseCase := uc.NewUserUseCase(userRepo, tokenRepo, roleRepo, organizationRepo, loginThrottleRepo, securityEventRepo, mfaRepo, testMailer, testBreaches, ssoStateRepo, ssoProvider, tokenService, denylist, uc.UserUseCaseConfig{
+		RefreshTokenTTL:       time.Hour,
+		DefaultRole:           entity.RoleViewer,
+		DefaultOrganizationID: entity.DefaultOrganizationID,
+		PasswordPolicy:        entity.DefaultPasswordPolicy(),
+		LockoutPolicy: entity.LockoutPolicy{
+			FreeAttempts:       2,
+			BaseDelay:          time.Minute,
+			MaxDelay:           time.Hour,
+			MaxAccountFailures: 5,
+			MaxIPFailures:      100,
+			LockoutDuration:    time.Hour,
+			Window:             time.Hour,
+		},
+		MFAChallengeTTL:       time.Minute,
+		EmailVerificationTTL:  time.Hour,
+		PasswordResetTTL:      time.Hour,
+		PasswordResetThrottle: entity.DefaultPasswordResetPolicy(),
+		BaseURL:               "https://app.example.com",
+		SSO: uc.SSOConfig{
+			UsernameClaim: "preferred_username",
+			RoleClaim:     "groups",
+			RoleMapping:   entity.RoleMapping{"engineering": {entity.RoleEditor}},
+			LoginTimeout:  time.Minute,
+		},
+	})
+	mfaUseCase := uc.NewMFAUseCase(mfaRepo, userRepo, "test-issuer")
+	roleUseCase := uc.NewRoleUseCase(roleRepo, userRepo, securityEventRepo)
+	testRoles = roleUseCase
+	auditUseCase := uc.NewAuditUseCase(securityEventRepo)
+	organizationUseCase := uc.NewOrganizationUseCase(organizationRepo)
+	serviceAccountUseCase := uc.NewServiceAccountUseCase(apiKeyRepo)
+	companyUseCase := uc.NewCompanyUseCase(companyRepo, log, uc.CompanyUseCaseConfig{MaxBatchOperations: 10})
+	testImports = uc.NewImportUseCase(repository.NewImportRepository(testDB), companyUseCase, userRepo, roleRepo, organizationRepo, apiKeyRepo, log,
+		uc.ImportUseCaseConfig{Lease: time.Minute})
+	testStats = uc.NewCompanyStatsUseCase(companyRepo, uc.CompanyStatsUseCaseConfig{CacheTTL: time.Hour, CacheSize: 100})
 
 	// Set up router
 	testRouter = chi.NewRouter()
-	handler.NewUserHandler(testRouter, userUseCase)
-	handler.NewCompanyHandler(testRouter, companyUseCase)
+	testRouter.Use(requestid.Middleware)
+	testRouter.Use(handler.BodyLimit(4 << 10))
+	idempotency := handler.Idempotency(repository.NewIdempotencyRepository(testDB), time.Hour, log)
+	handler.NewErrorHandler(testRouter)
+	handler.NewUserHandler(testRouter, userUseCase, authenticator, idempotency)
+	handler.NewMFAHandler(testRouter, mfaUseCase, authenticator, idempotency)
+	handler.NewSSOHandler(testRouter, userUseCase)
+	handler.NewCompanyHandler(testRouter, companyUseCase, authenticator, idempotency, log)
+	handler.NewImportHandler(testRouter, testImports, authenticator, idempotency, 1<<20)
+	handler.NewCompanyStatsHandler(testRouter, testStats, authenticator)
+	handler.NewRoleHandler(testRouter, roleUseCase, authenticator, idempotency)
+	handler.NewOrganizationHandler(testRouter, organizationUseCase, authenticator, idempotency)
+	handler.NewServiceAccountHandler(testRouter, serviceAccountUseCase, authenticator, idempotency)
+	handler.NewAuditHandler(testRouter, auditUseCase, authenticator)
+	handler.NewJWKSHandler(testRouter, keySet)
 
 	// Run tests
 	code := m.Run()
@@ -80,6 +198,7 @@ func TestMain(m *testing.M) {
 
 	// Close DB connection
 	testDB.Close()
+	testProvider.Close()
 
 	os.Exit(code)
 }
@@ -97,6 +216,16 @@ func TestUserRegistrationAndLogin(t *testing.T) {
 
 	assert.Equal(t, http.StatusCreated, registerRec.Code)
 
+	// Registration grants only the default role; the admin used by the
+	// other tests is promoted the way the service does at startup.
+	var userID uuid.UUID
+	err := testDB.QueryRow(context.Background(), "SELECT id FROM users WHERE username = $1", "testuser").Scan(&userID)
+	require.NoError(t, err)
+	roles, err := testRoles.GetUserRoles(context.Background(), userID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{entity.RoleViewer}, roles)
+	require.NoError(t, testRoles.BootstrapAdmin(context.Background(), "testuser"))
+
 	loginPayload := map[string]string{
 		"username": "testuser",
 		"password": "testpassword",
@@ -109,9 +238,77 @@ func TestUserRegistrationAndLogin(t *testing.T) {
 
 	assert.Equal(t, http.StatusOK, loginRec.Code)
 
-	var loginResponse map[string]string
+	var loginResponse map[string]interface{}
 	json.Unmarshal(loginRec.Body.Bytes(), &loginResponse)
 	assert.Contains(t, loginResponse, "token")
+	assert.Contains(t, loginResponse, "refresh_token")
+}
+
+func TestRefreshTokenRotationAndLogout(t *testing.T) {
+	tokens := login(t)
+
+	refresh := func(refreshToken string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(map[string]string{"refresh_token": refreshToken})
+		req := httptest.NewRequest("POST", "/v1/users/refresh", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		testRouter.ServeHTTP(rec, req)
+		return rec
+	}
+
+	refreshRec := refresh(tokens.RefreshToken)
+	require.Equal(t, http.StatusOK, refreshRec.Code)
+
+	var rotated entity.TokenPair
+	json.Unmarshal(refreshRec.Body.Bytes(), &rotated)
+	assert.NotEqual(t, tokens.RefreshToken, rotated.RefreshToken)
+
+	// Reusing the rotated token revokes the whole session.
+	assert.Equal(t, http.StatusUnauthorized, refresh(tokens.RefreshToken).Code)
+	assert.Equal(t, http.StatusUnauthorized, refresh(rotated.RefreshToken).Code)
+
+	deleteReq := httptest.NewRequest("DELETE", fmt.Sprintf("/v1/companies/%s", uuid.New()), nil)
+	deleteReq.Header.Set("Authorization", "Bearer "+rotated.AccessToken)
+	deleteRec := httptest.NewRecorder()
+	testRouter.ServeHTTP(deleteRec, deleteReq)
+	assert.Equal(t, http.StatusUnauthorized, deleteRec.Code)
+
+	// Logging out revokes the access token of the session.
+	tokens = login(t)
+	logoutReq := httptest.NewRequest("POST", "/v1/users/logout", nil)
+	logoutReq.Header.Set("Authorization", "Bearer "+tokens.AccessToken)
+	logoutRec := httptest.NewRecorder()
+	testRouter.ServeHTTP(logoutRec, logoutReq)
+	assert.Equal(t, http.StatusNoContent, logoutRec.Code)
+
+	assert.Equal(t, http.StatusUnauthorized, refresh(tokens.RefreshToken).Code)
+
+	logoutRec = httptest.NewRecorder()
+	testRouter.ServeHTTP(logoutRec, logoutReq)
+	assert.Equal(t, http.StatusUnauthorized, logoutRec.Code)
+}
+
+func TestJWKSPublishesSigningKey(t *testing.T) {
+	token := getJWTToken(t)
+	parsed, _, err := new(jwt.Parser).ParseUnverified(token, &jwt.RegisteredClaims{})
+	require.NoError(t, err)
+	assert.Equal(t, "ES256", parsed.Method.Alg())
+
+	req := httptest.NewRequest("GET", "/.well-known/jwks.json", nil)
+	rec := httptest.NewRecorder()
+	testRouter.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var jwks auth.JWKS
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &jwks))
+
+	var kids []string
+	for _, key := range jwks.Keys {
+		kids = append(kids, key.KeyID)
+		assert.Equal(t, "EC", key.KeyType)
+		assert.Equal(t, "P-256", key.Curve)
+	}
+	assert.Contains(t, kids, parsed.Header["kid"])
 }
 
 func TestCompanyOperations(t *testing.T) {
@@ -138,7 +335,15 @@ func TestCompanyOperations(t *testing.T) {
 	var createdCompany entity.Company
 	json.Unmarshal(createRec.Body.Bytes(), &createdCompany)
 
+	// Reads require authentication; the organization comes from the token.
+	anonymousReq := httptest.NewRequest("GET", fmt.Sprintf("/v1/companies/%s", createdCompany.ID), nil)
+	anonymousReq.Header.Set("X-Organization-ID", entity.DefaultOrganizationID.String())
+	anonymousRec := httptest.NewRecorder()
+	testRouter.ServeHTTP(anonymousRec, anonymousReq)
+	assert.Equal(t, http.StatusUnauthorized, anonymousRec.Code)
+
 	getReq := httptest.NewRequest("GET", fmt.Sprintf("/v1/companies/%s", createdCompany.ID), nil)
+	getReq.Header.Set("Authorization", "Bearer "+token)
 	getRec := httptest.NewRecorder()
 	testRouter.ServeHTTP(getRec, getReq)
 
@@ -168,10 +373,1538 @@ func TestCompanyOperations(t *testing.T) {
 	assert.Equal(t, http.StatusNoContent, deleteRec.Code)
 }
 
+func TestCompanyPatchFormats(t *testing.T) {
+	token := getJWTToken(t)
+	request := func(method, path, contentType, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		testRouter.ServeHTTP(rec, req)
+		return rec
+	}
+	get := func(id uuid.UUID) entity.Company {
+		rec := request("GET", "/v1/companies/"+id.String(), "", "")
+		require.Equal(t, http.StatusOK, rec.Code)
+		var company entity.Company
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &company))
+		return company
+	}
+
+	companyID := uuid.New()
+	path := "/v1/companies/" + companyID.String()
+	require.Equal(t, http.StatusCreated, request("POST", "/v1/companies", "application/json",
+		`{"id":"`+companyID.String()+`","name":"PatchCo","description":"Patched","amount_of_employees":5,"registered":true,"type":"NonProfit"}`).Code)
+
+	// A merge patch clears fields set to null.
+	rec := request("PATCH", path, "application/merge-patch+json", `{"description":null,"amount_of_employees":6}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	company := get(companyID)
+	assert.Nil(t, company.Description)
+	assert.Equal(t, 6, company.AmountOfEmployees)
+
+	// A JSON patch applies only if its test operations pass.
+	rec = request("PATCH", path, "application/json-patch+json",
+		`[{"op":"test","path":"/name","value":"PatchCo"},{"op":"replace","path":"/name","value":"PatchCo2"}]`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, "PatchCo2", get(companyID).Name)
+
+	cases := []struct {
+		name, contentType, body string
+		status                  int
+		code                    customError.Code
+	}{
+		{"stale test", "application/json-patch+json", `[{"op":"test","path":"/name","value":"PatchCo"},{"op":"replace","path":"/name","value":"Other"}]`, http.StatusConflict, customError.CodePatchTestFailed},
+		{"missing path", "application/json-patch+json", `[{"op":"remove","path":"/website"}]`, http.StatusUnprocessableEntity, customError.CodeInvalidPatch},
+		{"read-only field", "application/merge-patch+json", `{"owner_id":"` + uuid.NewString() + `"}`, http.StatusUnprocessableEntity, customError.CodeInvalidPatch},
+		{"invalid result", "application/merge-patch+json", `{"name":null}`, http.StatusBadRequest, customError.CodeValidationFailed},
+		{"unsupported type", "text/plain", `name=Other`, http.StatusUnsupportedMediaType, customError.CodeUnsupportedMedia},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := request("PATCH", path, tc.contentType, tc.body)
+			require.Equal(t, tc.status, rec.Code, rec.Body.String())
+			var problem customError.Problem
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &problem))
+			assert.Equal(t, tc.code, problem.Code)
+			assert.Contains(t, rec.Header().Get("Accept-Patch"), "application/merge-patch+json")
+		})
+	}
+	assert.Equal(t, "PatchCo2", get(companyID).Name)
+}
+
+func TestCompanyPut(t *testing.T) {
+	token := getJWTToken(t)
+	put := func(path, body string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("PUT", path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		for name, values := range header {
+			req.Header[name] = values
+		}
+		rec := httptest.NewRecorder()
+		testRouter.ServeHTTP(rec, req)
+		return rec
+	}
+
+	companyID := uuid.New()
+	path := "/v1/companies/" + companyID.String()
+	body := `{"name":"PutCo","amount_of_employees":5,"registered":true,"type":"Cooperative"}`
+
+	rec := put(path, body, http.Header{"If-None-Match": {"*"}})
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	created := rec.Header().Get("ETag")
+	assert.Equal(t, `"1"`, created)
+
+	// Creating it again is refused, replacing it unconditionally is not.
+	assert.Equal(t, http.StatusPreconditionFailed, put(path, body, http.Header{"If-None-Match": {"*"}}).Code)
+	rec = put(path, `{"name":"PutCo","description":"Replaced","amount_of_employees":6,"registered":true,"type":"Cooperative"}`, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, `"2"`, rec.Header().Get("ETag"))
+
+	// A replacement based on the first version is stale.
+	rec = put(path, body, http.Header{"If-Match": {created}})
+	require.Equal(t, http.StatusPreconditionFailed, rec.Code)
+	var problem customError.Problem
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &problem))
+	assert.Equal(t, customError.CodePreconditionFailed, problem.Code)
+
+	rec = put(path, body, http.Header{"If-Match": {`"2"`}})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var company entity.Company
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &company))
+	assert.Nil(t, company.Description)
+	assert.Equal(t, 5, company.AmountOfEmployees)
+
+	assert.Equal(t, http.StatusPreconditionFailed, put("/v1/companies/"+uuid.NewString(), body, http.Header{"If-Match": {"*"}}).Code)
+	assert.Equal(t, http.StatusBadRequest, put(path, `{"id":"`+uuid.NewString()+`","name":"PutCo","amount_of_employees":5,"registered":true,"type":"Cooperative"}`, nil).Code)
+
+	req := httptest.NewRequest("GET", path+"/history", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	historyRec := httptest.NewRecorder()
+	testRouter.ServeHTTP(historyRec, req)
+	require.Equal(t, http.StatusOK, historyRec.Code)
+	var history struct {
+		Revisions []entity.CompanyRevision `json:"revisions"`
+	}
+	require.NoError(t, json.Unmarshal(historyRec.Body.Bytes(), &history))
+	require.Len(t, history.Revisions, 3)
+	assert.Equal(t, entity.CompanyRevisionCreated, history.Revisions[0].Operation)
+	assert.Equal(t, entity.CompanyRevisionUpdated, history.Revisions[1].Operation)
+	assert.Equal(t, entity.CompanyRevisionUpdated, history.Revisions[2].Operation)
+}
+
+func TestServerGeneratedIDsAndSlugs(t *testing.T) {
+	token := getJWTToken(t)
+	create := func(name string) entity.Company {
+		req := httptest.NewRequest("POST", "/v1/companies", strings.NewReader(`{"name":"`+name+`","amount_of_employees":5,"registered":true,"type":"Cooperative"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		testRouter.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+		var company entity.Company
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &company))
+		assert.Equal(t, uuid.Version(7), company.ID.Version())
+		assert.Equal(t, "/v1/companies/"+company.ID.String(), rec.Header().Get("Location"))
+		return company
+	}
+	getBySlug := func(slug string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/v1/companies/by-slug/"+slug, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		testRouter.ServeHTTP(rec, req)
+		return rec
+	}
+
+	first := create("Slug Co")
+	assert.Equal(t, "slug-co", first.Slug)
+	second := create("Slug-Co")
+	assert.Equal(t, "slug-co-2", second.Slug)
+
+	// Renaming a company keeps its slug.
+	req := httptest.NewRequest("PATCH", "/v1/companies/"+first.ID.String(), strings.NewReader(`{"name":"Renamed Co"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	testRouter.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = getBySlug("slug-co")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var company entity.Company
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &company))
+	assert.Equal(t, first.ID, company.ID)
+	assert.Equal(t, "Renamed Co", company.Name)
+	assert.Equal(t, "slug-co", company.Slug)
+	assert.Equal(t, `"2"`, rec.Header().Get("ETag"))
+
+	assert.Equal(t, http.StatusNotFound, getBySlug("renamed-co").Code)
+}
+
+// TestCompanySlugBackfill runs the slug migration on a separate database,
+// since the test database is already migrated.
+func TestCompanySlugBackfill(t *testing.T) {
+	const (
+		database      = "company_task_slug_backfill"
+		beforeSlugs   = 20241015090000
+		addingSlugs   = 20241016090000
+		migrationsDir = "../../migrations/"
+	)
+	ctx := context.Background()
+	_, err := testDB.Exec(ctx, "DROP DATABASE IF EXISTS "+database)
+	require.NoError(t, err)
+	_, err = testDB.Exec(ctx, "CREATE DATABASE "+database)
+	require.NoError(t, err)
+
+	dbURL, err := url.Parse(os.Getenv("DATABASE_URL"))
+	require.NoError(t, err)
+	dbURL.Path = "/" + database
+	sqlDB, err := sql.Open("postgres", dbURL.String())
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		sqlDB.Close()
+		testDB.Exec(context.Background(), "DROP DATABASE IF EXISTS "+database)
+	})
+
+	require.NoError(t, goose.SetDialect("postgres"))
+	require.NoError(t, goose.UpTo(sqlDB, migrationsDir, beforeSlugs))
+
+	// "Acme 2" slugifies to the suffixed slug of the second "acme".
+	names := []string{"Acme", "acme", "Acme 2"}
+	ids := make([]uuid.UUID, len(names))
+	createdAt := time.Now()
+	for i, name := range names {
+		ids[i] = uuid.New()
+		_, err := sqlDB.ExecContext(ctx, `
+			INSERT INTO companies (id, organization_id, name, amount_of_employees, registered, type, created_at, updated_at)
+			VALUES ($1, $2, $3, 1, true, 'Cooperative', $4, $4)
+		`, ids[i], entity.DefaultOrganizationID, name, createdAt.Add(time.Duration(i)*time.Second))
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, goose.UpTo(sqlDB, migrationsDir, addingSlugs))
+
+	slugs := make([]string, len(ids))
+	for i, id := range ids {
+		require.NoError(t, sqlDB.QueryRowContext(ctx, "SELECT slug FROM companies WHERE id = $1", id).Scan(&slugs[i]))
+	}
+	assert.Equal(t, []string{"acme", "acme-2", "acme-2-2"}, slugs)
+}
+
+func TestCompanyBatch(t *testing.T) {
+	token := getJWTToken(t)
+	type batchResponse struct {
+		Mode    string `json:"mode"`
+		Results []struct {
+			Index   int                  `json:"index"`
+			Op      string               `json:"op"`
+			ID      *uuid.UUID           `json:"id"`
+			Status  int                  `json:"status"`
+			Company *entity.Company      `json:"company"`
+			Error   *customError.Problem `json:"error"`
+		} `json:"results"`
+	}
+	batch := func(body string) (int, batchResponse) {
+		req := httptest.NewRequest("POST", "/v1/companies:batch", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		testRouter.ServeHTTP(rec, req)
+		var response batchResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response), rec.Body.String())
+		return rec.Code, response
+	}
+	countCompanies := func(names ...string) int {
+		var count int
+		require.NoError(t, testDB.QueryRow(context.Background(), "SELECT count(*) FROM companies WHERE name = ANY($1)", names).Scan(&count))
+		return count
+	}
+
+	// The second create conflicts with the first, so neither is stored.
+	code, response := batch(`{"operations":[
+		{"op":"create","company":{"name":"BatchOne","amount_of_employees":5,"registered":true,"type":"Cooperative"}},
+		{"op":"create","company":{"name":"BatchOne","amount_of_employees":6,"registered":true,"type":"Cooperative"}}
+	]}`)
+	require.Equal(t, http.StatusConflict, code)
+	assert.Equal(t, "atomic", response.Mode)
+	assert.Equal(t, http.StatusFailedDependency, response.Results[0].Status)
+	assert.Equal(t, http.StatusConflict, response.Results[1].Status)
+	assert.Equal(t, customError.CodeCompanyExists, response.Results[1].Error.Code)
+	assert.Equal(t, 0, countCompanies("BatchOne"))
+
+	code, response = batch(`{"mode":"atomic","operations":[
+		{"op":"create","company":{"name":"BatchOne","amount_of_employees":5,"registered":true,"type":"Cooperative"}},
+		{"op":"create","company":{"name":"BatchTwo","amount_of_employees":6,"registered":true,"type":"Cooperative"}}
+	]}`)
+	require.Equal(t, http.StatusOK, code)
+	require.Len(t, response.Results, 2)
+	assert.Equal(t, http.StatusCreated, response.Results[0].Status)
+	assert.Equal(t, "batchone", response.Results[0].Company.Slug)
+	assert.Equal(t, 2, countCompanies("BatchOne", "BatchTwo"))
+	first, second := *response.Results[0].ID, *response.Results[1].ID
+
+	var outboxEvents int
+	require.NoError(t, testDB.QueryRow(context.Background(),
+		"SELECT count(*) FROM outbox_events WHERE payload->>'id' = ANY($1)", []string{first.String(), second.String()}).Scan(&outboxEvents))
+	assert.Equal(t, 2, outboxEvents)
+
+	code, response = batch(`{"mode":"best_effort","operations":[
+		{"op":"patch","id":"` + first.String() + `","patch":{"amount_of_employees":50}},
+		{"op":"patch","id":"` + second.String() + `","patch":{"amount_of_employees":0}},
+		{"op":"delete","id":"` + second.String() + `"},
+		{"op":"delete","id":"` + uuid.NewString() + `"}
+	]}`)
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, http.StatusOK, response.Results[0].Status)
+	assert.Equal(t, 50, response.Results[0].Company.AmountOfEmployees)
+	assert.Equal(t, http.StatusBadRequest, response.Results[1].Status)
+	assert.Equal(t, customError.CodeValidationFailed, response.Results[1].Error.Code)
+	assert.Equal(t, http.StatusNoContent, response.Results[2].Status)
+	assert.Equal(t, http.StatusNotFound, response.Results[3].Status)
+	assert.Equal(t, 1, countCompanies("BatchOne", "BatchTwo"))
+
+	req := httptest.NewRequest("POST", "/v1/companies:batch", strings.NewReader(`{"operations":[]}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	testRouter.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestCompanyImport(t *testing.T) {
+	token := getJWTToken(t)
+	send := func(method, target, contentType, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		testRouter.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := send("POST", "/v1/companies/imports", "text/csv", "Company,Amount of employees,Registered,Type\n"+
+		"ImportOne,10,yes,Corporations\n"+
+		"ImportTwo,many,no,Sole Proprietorship\n"+
+		"ImportThree,3,true,Cooperative\n")
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	var job entity.ImportJob
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &job))
+	assert.Equal(t, entity.ImportUploaded, job.Status)
+	assert.Equal(t, 3, job.TotalRows)
+	assert.Equal(t, "/v1/companies/imports/"+job.ID.String(), rec.Header().Get("Location"))
+
+	// The name column is not named after its field, so it has to be mapped.
+	rec = send("POST", "/v1/companies/imports/"+job.ID.String()+"/dry-run", "", "")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), customError.CodeInvalidImportMapping)
+
+	mapping := `{"mapping":{"name":"Company","amount_of_employees":"Amount of employees","registered":"Registered","type":"Type"}}`
+	rec = send("POST", "/v1/companies/imports/"+job.ID.String()+"/dry-run", "application/json", mapping)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var report entity.ImportReport
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+	assert.Equal(t, 2, report.ValidRows)
+	require.Len(t, report.Errors, 1)
+	assert.Equal(t, 3, report.Errors[0].Row)
+	assert.Equal(t, "amount_of_employees", report.Errors[0].Field)
+
+	var count int
+	require.NoError(t, testDB.QueryRow(context.Background(), "SELECT count(*) FROM companies WHERE name LIKE 'Import%'").Scan(&count))
+	assert.Equal(t, 0, count)
+
+	rec = send("POST", "/v1/companies/imports/"+job.ID.String()+"/run", "application/json", mapping)
+	require.Equal(t, http.StatusAccepted, rec.Code, rec.Body.String())
+	rec = send("POST", "/v1/companies/imports/"+job.ID.String()+"/run", "", "")
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	processed, err := testImports.ProcessNext(context.Background())
+	require.NoError(t, err)
+	assert.True(t, processed)
+
+	rec = send("GET", "/v1/companies/imports/"+job.ID.String(), "", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &job))
+	assert.Equal(t, entity.ImportCompleted, job.Status)
+	assert.Equal(t, 3, job.ProcessedRows)
+	assert.Equal(t, 2, job.SucceededRows)
+	assert.Equal(t, 1, job.FailedRows)
+
+	rec = send("GET", "/v1/companies/imports/"+job.ID.String()+"/errors?limit=1", "", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var page struct {
+		Errors    []*entity.ImportRowError `json:"errors"`
+		NextAfter *int                     `json:"next_after"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &page))
+	require.Len(t, page.Errors, 1)
+	assert.Equal(t, 3, page.Errors[0].Row)
+	require.NotNil(t, page.NextAfter)
+
+	// Imported companies are created through the use case, so they emit
+	// events like companies created through the API.
+	require.NoError(t, testDB.QueryRow(context.Background(), "SELECT count(*) FROM companies WHERE name LIKE 'Import%'").Scan(&count))
+	assert.Equal(t, 2, count)
+	require.NoError(t, testDB.QueryRow(context.Background(),
+		"SELECT count(*) FROM outbox_events WHERE payload->>'name' IN ('ImportOne', 'ImportThree')").Scan(&count))
+	assert.Equal(t, 2, count)
+
+	rec = send("POST", "/v1/companies/imports", "application/pdf", "%PDF")
+	assert.Equal(t, http.StatusUnsupportedMediaType, rec.Code)
+	rec = send("POST", "/v1/companies/imports?format=ndjson", "application/octet-stream", "{\"name\":")
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+}
+
+func TestCompanyExport(t *testing.T) {
+	token := getJWTToken(t)
+	for i, name := range []string{"ExportOne", "ExportTwo", "ExportThree"} {
+		body := fmt.Sprintf(`{"name":%q,"amount_of_employees":%d,"registered":true,"type":"NonProfit"}`, name, 10*(i+1))
+		req := httptest.NewRequest("POST", "/v1/companies", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		testRouter.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	}
+	export := func(target string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", target, nil)
+		for name, values := range header {
+			req.Header[name] = values
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		testRouter.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// CSV by default, filtered like listing and oldest first.
+	rec := export("/v1/companies/export?q=export&registered=true&max_employees=20", nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, "text/csv", rec.Header().Get("Content-Type"))
+	table, err := tabular.Read(tabular.CSV, rec.Body.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, entity.CompanyExportColumns, table.Columns)
+	require.Len(t, table.Rows, 2)
+	assert.Equal(t, "ExportOne", table.Rows[0].Values[2])
+	assert.Equal(t, "ExportTwo", table.Rows[1].Values[2])
+
+	rec = export("/v1/companies/export?q=export&min_employees=20&type=NonProfit", http.Header{
+		"Accept":          {"application/x-ndjson"},
+		"Accept-Encoding": {"gzip"},
+	})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, "gzip", rec.Header().Get("Content-Encoding"))
+	reader, err := gzip.NewReader(rec.Body)
+	require.NoError(t, err)
+	data, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	table, err = tabular.Read(tabular.NDJSON, data)
+	require.NoError(t, err)
+	require.Len(t, table.Rows, 2)
+	assert.Equal(t, "ExportTwo", table.Rows[0].Values[2])
+
+	rec = export("/v1/companies/export?q=exportone&format=xlsx", nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Empty(t, rec.Header().Get("Content-Encoding"))
+	table, err = tabular.Read(tabular.XLSX, rec.Body.Bytes())
+	require.NoError(t, err)
+	require.Len(t, table.Rows, 1)
+	assert.Equal(t, "10", table.Rows[0].Values[4])
+
+	rec = export("/v1/companies/export", http.Header{"Accept": {"application/json"}})
+	assert.Equal(t, http.StatusNotAcceptable, rec.Code)
+	rec = export("/v1/companies/export?type=Partnership", nil)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestCompanyStats(t *testing.T) {
+	token := getJWTToken(t)
+	request := func(method, target, body string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		for name, values := range header {
+			req.Header[name] = values
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		testRouter.ServeHTTP(rec, req)
+		return rec
+	}
+	for _, company := range []struct {
+		name      string
+		employees int
+		kind      string
+	}{{"StatsOne", 5, "Cooperative"}, {"StatsTwo", 20, "Cooperative"}, {"StatsThree", 300, "Corporations"}} {
+		body := fmt.Sprintf(`{"name":%q,"amount_of_employees":%d,"registered":true,"type":%q}`, company.name, company.employees, company.kind)
+		rec := request("POST", "/v1/companies", body, nil)
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	}
+	stats := func(target string) entity.CompanyStats {
+		rec := request("GET", target, "", nil)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var stats entity.CompanyStats
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &stats))
+		return stats
+	}
+
+	byType := stats("/v1/companies/stats?q=stats&group_by=type,registered")
+	assert.Equal(t, int64(3), byType.Count)
+	assert.Equal(t, int64(325), byType.TotalEmployees)
+	require.Len(t, byType.Groups, 2)
+	assert.Equal(t, entity.CompanyType("Cooperative"), byType.Groups[0].Type)
+	assert.Equal(t, int64(2), byType.Groups[0].Count)
+	assert.True(t, *byType.Groups[0].Registered)
+
+	byEmployees := stats("/v1/companies/stats?q=stats&group_by=employees&group_by=created&bucket=month&employee_bounds=10,100")
+	require.Len(t, byEmployees.Groups, 3)
+	assert.Equal(t, entity.BucketMonth, byEmployees.Bucket)
+	assert.Equal(t, "0-9", byEmployees.Groups[0].Employees)
+	assert.Equal(t, "10-99", byEmployees.Groups[1].Employees)
+	assert.Equal(t, "100+", byEmployees.Groups[2].Employees)
+	now := time.Now().UTC()
+	assert.Equal(t, time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC), byEmployees.Groups[0].Created.UTC())
+
+	rec := request("GET", "/v1/companies/stats?q=stats&group_by=type", "", http.Header{"Accept": {"text/csv"}})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, "type,count,total_employees\nCooperative,2,25\nCorporations,1,300\n", rec.Body.String())
+
+	// Results are cached until the outbox event of a change is published.
+	rec = request("POST", "/v1/companies", `{"name":"StatsFour","amount_of_employees":1,"registered":true,"type":"NonProfit"}`, nil)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	assert.Equal(t, int64(3), stats("/v1/companies/stats?q=stats&group_by=type,registered").Count)
+	outboxWorker := worker.NewOutboxWorker(repository.NewCompanyRepository(testDB), &mockKafkaProducer{}, logger.NewLogger("error")).WithListener(testStats)
+	require.NoError(t, outboxWorker.ProcessOutboxEvents(context.Background()))
+	assert.Equal(t, int64(4), stats("/v1/companies/stats?q=stats&group_by=type,registered").Count)
+
+	rec = request("GET", "/v1/companies/stats?group_by=size", "", nil)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	rec = request("GET", "/v1/companies/stats?group_by=employees&employee_bounds=50,10", "", nil)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	rec = request("GET", "/v1/companies/stats", "", http.Header{"Accept": {"application/xml"}})
+	assert.Equal(t, http.StatusNotAcceptable, rec.Code)
+}
+
+func TestIdempotencyKeys(t *testing.T) {
+	token := getJWTToken(t)
+	post := func(key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/v1/companies", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set(handler.HeaderIdempotencyKey, key)
+		rec := httptest.NewRecorder()
+		testRouter.ServeHTTP(rec, req)
+		return rec
+	}
+	companyBody := func(name string) string {
+		return `{"id":"` + uuid.NewString() + `","name":"` + name + `","amount_of_employees":5,"registered":true,"type":"Cooperative"}`
+	}
+
+	key := uuid.NewString()
+	body := companyBody("IdemCo")
+	first := post(key, body)
+	require.Equal(t, http.StatusCreated, first.Code, first.Body.String())
+	assert.Empty(t, first.Header().Get(handler.HeaderIdempotentReplayed))
+
+	// The retry is answered with the stored response instead of failing
+	// because the company exists.
+	retry := post(key, body)
+	require.Equal(t, http.StatusCreated, retry.Code, retry.Body.String())
+	assert.Equal(t, "true", retry.Header().Get(handler.HeaderIdempotentReplayed))
+	assert.Equal(t, first.Body.String(), retry.Body.String())
+	assert.Equal(t, first.Header().Get("ETag"), retry.Header().Get("ETag"))
+
+	rec := post(key, companyBody("IdemCo 2"))
+	require.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	var problem customError.Problem
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &problem))
+	assert.Equal(t, customError.CodeIdempotencyKeyReused, problem.Code)
+
+	// Keys are scoped to the caller: another user's request with the same
+	// key is not answered with the stored response.
+	registerBody, _ := json.Marshal(map[string]string{"username": "idemviewer", "password": "idem viewer password"})
+	registerReq := httptest.NewRequest("POST", "/v1/users/register", bytes.NewBuffer(registerBody))
+	registerReq.Header.Set("Content-Type", "application/json")
+	registerRec := httptest.NewRecorder()
+	testRouter.ServeHTTP(registerRec, registerReq)
+	require.Equal(t, http.StatusCreated, registerRec.Code)
+	viewer := loginAs(t, "idemviewer", "idem viewer password")
+	require.NotEmpty(t, viewer.AccessToken)
+	otherReq := httptest.NewRequest("POST", "/v1/companies", strings.NewReader(body))
+	otherReq.Header.Set("Content-Type", "application/json")
+	otherReq.Header.Set("Authorization", "Bearer "+viewer.AccessToken)
+	otherReq.Header.Set(handler.HeaderIdempotencyKey, key)
+	otherRec := httptest.NewRecorder()
+	testRouter.ServeHTTP(otherRec, otherReq)
+	assert.Equal(t, http.StatusForbidden, otherRec.Code)
+	assert.Empty(t, otherRec.Header().Get(handler.HeaderIdempotentReplayed))
+
+	// Requests without a principal are not idempotent, so login responses are
+	// never stored.
+	loginKey := uuid.NewString()
+	loginReq := httptest.NewRequest("POST", "/v1/users/login", bytes.NewBuffer(registerBody))
+	loginReq.Header.Set("Content-Type", "application/json")
+	loginReq.Header.Set(handler.HeaderIdempotencyKey, loginKey)
+	loginRec := httptest.NewRecorder()
+	testRouter.ServeHTTP(loginRec, loginReq)
+	require.Equal(t, http.StatusOK, loginRec.Code)
+	var stored int
+	require.NoError(t, testDB.QueryRow(context.Background(), "SELECT count(*) FROM idempotency_keys WHERE key = $1", loginKey).Scan(&stored))
+	assert.Zero(t, stored)
+
+	// Keys apply to every authenticated change, not only to companies.
+	adminPost := func(path, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set(handler.HeaderIdempotencyKey, key)
+		rec := httptest.NewRecorder()
+		testRouter.ServeHTTP(rec, req)
+		return rec
+	}
+	accountKey := uuid.NewString()
+	firstAccount := adminPost("/v1/admin/service-accounts", accountKey, `{"name":"idempotent"}`)
+	require.Equal(t, http.StatusCreated, firstAccount.Code, firstAccount.Body.String())
+	retryAccount := adminPost("/v1/admin/service-accounts", accountKey, `{"name":"idempotent"}`)
+	require.Equal(t, http.StatusCreated, retryAccount.Code, retryAccount.Body.String())
+	assert.Equal(t, "true", retryAccount.Header().Get(handler.HeaderIdempotentReplayed))
+	assert.Equal(t, firstAccount.Body.String(), retryAccount.Body.String())
+	var accounts int
+	require.NoError(t, testDB.QueryRow(context.Background(), "SELECT count(*) FROM service_accounts WHERE name = 'idempotent'").Scan(&accounts))
+	assert.Equal(t, 1, accounts)
+
+	// Responses that carry secrets are not stored; their retries run again.
+	var account entity.ServiceAccount
+	require.NoError(t, json.Unmarshal(firstAccount.Body.Bytes(), &account))
+	apiKeyKey := uuid.NewString()
+	keyRec := adminPost(fmt.Sprintf("/v1/admin/service-accounts/%s/keys", account.ID), apiKeyKey, `{"scopes":["companies:read"]}`)
+	require.Equal(t, http.StatusCreated, keyRec.Code, keyRec.Body.String())
+	assert.Equal(t, "no-store", keyRec.Header().Get("Cache-Control"))
+	require.NoError(t, testDB.QueryRow(context.Background(), "SELECT count(*) FROM idempotency_keys WHERE key = $1", apiKeyKey).Scan(&stored))
+	assert.Zero(t, stored)
+
+	// Concurrent duplicates run the request once; those arriving while it
+	// runs are told to retry.
+	key = uuid.NewString()
+	body = companyBody("IdemConcurrent")
+	codes := make([]int, 5)
+	var wg sync.WaitGroup
+	for i := range codes {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			codes[i] = post(key, body).Code
+		}(i)
+	}
+	wg.Wait()
+	for _, code := range codes {
+		assert.Contains(t, []int{http.StatusCreated, http.StatusConflict}, code)
+	}
+	assert.Equal(t, http.StatusCreated, post(key, body).Code)
+	var count int
+	require.NoError(t, testDB.QueryRow(context.Background(), "SELECT count(*) FROM companies WHERE name = 'IdemConcurrent'").Scan(&count))
+	assert.Equal(t, 1, count)
+}
+
+func TestProblemResponses(t *testing.T) {
+	token := getJWTToken(t)
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"id":                  uuid.New().String(),
+		"name":                "A name that is far too long",
+		"amount_of_employees": 0,
+		"registered":          true,
+		"type":                "Cooperative",
+	})
+	req := httptest.NewRequest("POST", "/v1/companies", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("X-Request-ID", "problem-test-1")
+	rec := httptest.NewRecorder()
+	testRouter.ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, customError.ContentTypeProblem, rec.Header().Get("Content-Type"))
+	var problem customError.Problem
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &problem))
+	assert.Equal(t, customError.CodeValidationFailed, problem.Code)
+	assert.Equal(t, "urn:company-task:problem:validation_failed", problem.Type)
+	assert.Equal(t, "problem-test-1", problem.RequestID)
+	assert.Equal(t, "/v1/companies", problem.Instance)
+	require.Len(t, problem.Errors, 2)
+	assert.Equal(t, "name", problem.Errors[0].Field)
+	assert.Equal(t, "max", problem.Errors[0].Constraint)
+	assert.Equal(t, "amount_of_employees", problem.Errors[1].Field)
+	assert.Equal(t, "required", problem.Errors[1].Constraint)
+
+	req = httptest.NewRequest("POST", "/v1/companies", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept-Language", "ru-RU,ru;q=0.9,en;q=0.8")
+	rec = httptest.NewRecorder()
+	testRouter.ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "ru", rec.Header().Get("Content-Language"))
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &problem))
+	assert.Equal(t, customError.CodeValidationFailed, problem.Code)
+	assert.Equal(t, "Ошибка проверки данных", problem.Title)
+	assert.Equal(t, "name должен содержать максимум 15 символов", problem.Errors[0].Message)
+
+	cases := []struct {
+		method, path string
+		status       int
+		code         customError.Code
+	}{
+		{"GET", "/v1/companies/not-a-uuid", http.StatusBadRequest, customError.CodeInvalidParameter},
+		{"GET", "/v1/no-such-route", http.StatusNotFound, customError.CodeNotFound},
+		{"DELETE", "/v1/users/login", http.StatusMethodNotAllowed, customError.CodeMethodNotAllowed},
+		{"DELETE", "/v1/companies/" + uuid.New().String(), http.StatusNotFound, customError.CodeCompanyNotFound},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(tc.method, tc.path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		testRouter.ServeHTTP(rec, req)
+
+		assert.Equal(t, tc.status, rec.Code, tc.path)
+		var problem customError.Problem
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &problem))
+		assert.Equal(t, tc.code, problem.Code, tc.path)
+		assert.NotEmpty(t, problem.RequestID, tc.path)
+	}
+}
+
+func TestStrictDecoding(t *testing.T) {
+	token := getJWTToken(t)
+
+	cases := []struct {
+		name, contentType, body string
+		status                  int
+		code                    customError.Code
+		field                   string
+	}{
+		{"missing content type", "", `{"name":"Acme"}`, http.StatusUnsupportedMediaType, customError.CodeUnsupportedMedia, ""},
+		{"form content type", "application/x-www-form-urlencoded", "name=Acme", http.StatusUnsupportedMediaType, customError.CodeUnsupportedMedia, ""},
+		{"too large", "application/json", `{"description":"` + strings.Repeat("a", 5<<10) + `"}`, http.StatusRequestEntityTooLarge, customError.CodePayloadTooLarge, ""},
+		{"empty", "application/json", " ", http.StatusBadRequest, customError.CodeInvalidPayload, ""},
+		{"malformed", "application/json; charset=utf-8", `{"name":}`, http.StatusBadRequest, customError.CodeInvalidPayload, ""},
+		{"two values", "application/json", `{"name":"Acme"} {"name":"Other"}`, http.StatusBadRequest, customError.CodeInvalidPayload, ""},
+		{"wrong type", "application/json", `{"name":"Acme","amount_of_employees":"ten"}`, http.StatusBadRequest, customError.CodeInvalidPayload, "amount_of_employees"},
+		{"unknown field", "application/json", `{"name":"Acme","employees":10}`, http.StatusBadRequest, customError.CodeInvalidPayload, "employees"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/v1/companies", strings.NewReader(tc.body))
+			req.Header.Set("Authorization", "Bearer "+token)
+			if tc.contentType != "" {
+				req.Header.Set("Content-Type", tc.contentType)
+			}
+			rec := httptest.NewRecorder()
+			testRouter.ServeHTTP(rec, req)
+
+			require.Equal(t, tc.status, rec.Code, rec.Body.String())
+			var problem customError.Problem
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &problem))
+			assert.Equal(t, tc.code, problem.Code)
+			if tc.field != "" {
+				require.Len(t, problem.Errors, 1)
+				assert.Equal(t, tc.field, problem.Errors[0].Field)
+			}
+		})
+	}
+
+	// Fields missing from a request are reported by name.
+	req := httptest.NewRequest("POST", "/v1/users/refresh", strings.NewReader(`{}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	testRouter.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	var problem customError.Problem
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &problem))
+	assert.Equal(t, customError.CodeValidationFailed, problem.Code)
+	require.Len(t, problem.Errors, 1)
+	assert.Equal(t, "refresh_token", problem.Errors[0].Field)
+	assert.Equal(t, "required", problem.Errors[0].Constraint)
+}
+
+func TestCompanyHistory(t *testing.T) {
+	token := getJWTToken(t)
+	request := func(method, path string, payload interface{}, header http.Header) *httptest.ResponseRecorder {
+		var body []byte
+		if payload != nil {
+			body, _ = json.Marshal(payload)
+		}
+		req := httptest.NewRequest(method, path, bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		for name, values := range header {
+			req.Header[name] = values
+		}
+		rec := httptest.NewRecorder()
+		testRouter.ServeHTTP(rec, req)
+		return rec
+	}
+
+	companyID := uuid.New()
+	createRec := request("POST", "/v1/companies", map[string]interface{}{
+		"id":                  companyID.String(),
+		"name":                "HistoryCo",
+		"amount_of_employees": 10,
+		"registered":          true,
+		"type":                "Cooperative",
+	}, http.Header{"X-Request-Id": {"history-create-1"}})
+	require.Equal(t, http.StatusCreated, createRec.Code)
+	assert.Equal(t, "history-create-1", createRec.Header().Get("X-Request-ID"))
+
+	time.Sleep(10 * time.Millisecond)
+	betweenRevisions := time.Now()
+	time.Sleep(10 * time.Millisecond)
+
+	require.Equal(t, http.StatusOK, request("PATCH", "/v1/companies/"+companyID.String(),
+		map[string]interface{}{"name": "HistoryCo2", "amount_of_employees": 20}, nil).Code)
+	require.Equal(t, http.StatusNoContent, request("DELETE", "/v1/companies/"+companyID.String(), nil, nil).Code)
+
+	historyRec := request("GET", "/v1/companies/"+companyID.String()+"/history", nil, nil)
+	require.Equal(t, http.StatusOK, historyRec.Code)
+	var history struct {
+		Revisions []entity.CompanyRevision `json:"revisions"`
+	}
+	require.NoError(t, json.Unmarshal(historyRec.Body.Bytes(), &history))
+	require.Len(t, history.Revisions, 3)
+	assert.Equal(t, entity.CompanyRevisionCreated, history.Revisions[0].Operation)
+	assert.Equal(t, "history-create-1", history.Revisions[0].RequestID)
+	assert.NotNil(t, history.Revisions[0].ActorID)
+	assert.Equal(t, entity.CompanyRevisionUpdated, history.Revisions[1].Operation)
+	assert.Equal(t, "HistoryCo2", history.Revisions[1].Snapshot.Name)
+	assert.Equal(t, entity.CompanyRevisionDeleted, history.Revisions[2].Operation)
+
+	// The company can still be read as it was before it was deleted.
+	asOfRec := request("GET", "/v1/companies/"+companyID.String()+"?as_of="+url.QueryEscape(betweenRevisions.Format(time.RFC3339Nano)), nil, nil)
+	require.Equal(t, http.StatusOK, asOfRec.Code)
+	var asOf entity.Company
+	require.NoError(t, json.Unmarshal(asOfRec.Body.Bytes(), &asOf))
+	assert.Equal(t, "HistoryCo", asOf.Name)
+	assert.Equal(t, http.StatusNotFound, request("GET", "/v1/companies/"+companyID.String()+"?as_of="+
+		url.QueryEscape(time.Now().Format(time.RFC3339Nano)), nil, nil).Code)
+	assert.Equal(t, http.StatusNotFound, request("GET", "/v1/companies/"+companyID.String(), nil, nil).Code)
+
+	// Reading companies, now or in the past, requires companies:read.
+	require.Equal(t, http.StatusCreated, request("POST", "/v1/users/register",
+		map[string]string{"username": "historynoread", "password": "history no read password"}, nil).Code)
+	var noReadID uuid.UUID
+	require.NoError(t, testDB.QueryRow(context.Background(), "SELECT id FROM users WHERE username = $1", "historynoread").Scan(&noReadID))
+	require.Equal(t, http.StatusNoContent, request("DELETE", "/v1/admin/users/"+noReadID.String()+"/roles/"+entity.RoleViewer, nil, nil).Code)
+	noRead := loginAs(t, "historynoread", "history no read password")
+	require.NotEmpty(t, noRead.AccessToken)
+	assert.Equal(t, http.StatusForbidden, request("GET", "/v1/companies/"+companyID.String()+"?as_of="+
+		url.QueryEscape(betweenRevisions.Format(time.RFC3339Nano)), nil, http.Header{"Authorization": {"Bearer " + noRead.AccessToken}}).Code)
+	assert.Equal(t, http.StatusForbidden, request("GET", "/v1/companies/"+companyID.String(), nil,
+		http.Header{"Authorization": {"Bearer " + noRead.AccessToken}}).Code)
+	assert.Equal(t, http.StatusForbidden, request("GET", "/v1/companies/by-slug/historyco", nil,
+		http.Header{"Authorization": {"Bearer " + noRead.AccessToken}}).Code)
+	assert.Equal(t, http.StatusUnauthorized, request("GET", "/v1/companies/"+companyID.String()+"?as_of="+
+		url.QueryEscape(betweenRevisions.Format(time.RFC3339Nano)), nil, http.Header{"Authorization": nil}).Code)
+
+	diffRec := request("GET", "/v1/companies/"+companyID.String()+"/history/diff?from=1&to=2", nil, nil)
+	require.Equal(t, http.StatusOK, diffRec.Code)
+	var diff entity.CompanyDiff
+	require.NoError(t, json.Unmarshal(diffRec.Body.Bytes(), &diff))
+	var fields []string
+	for _, change := range diff.Changes {
+		fields = append(fields, change.Field)
+	}
+	assert.Contains(t, fields, "name")
+	assert.Contains(t, fields, "amount_of_employees")
+	assert.NotContains(t, fields, "type")
+
+	assert.Equal(t, http.StatusNotFound, request("GET", "/v1/companies/"+companyID.String()+"/history/diff?from=1&to=9", nil, nil).Code)
+}
+
+func TestRoleBasedAccessControl(t *testing.T) {
+	adminToken := getJWTToken(t)
+
+	registerBody, _ := json.Marshal(map[string]string{"username": "vieweruser", "password": "viewerpassword"})
+	registerReq := httptest.NewRequest("POST", "/v1/users/register", bytes.NewBuffer(registerBody))
+	registerReq.Header.Set("Content-Type", "application/json")
+	registerRec := httptest.NewRecorder()
+	testRouter.ServeHTTP(registerRec, registerReq)
+	require.Equal(t, http.StatusCreated, registerRec.Code)
+
+	var viewerID uuid.UUID
+	err := testDB.QueryRow(context.Background(), "SELECT id FROM users WHERE username = $1", "vieweruser").Scan(&viewerID)
+	require.NoError(t, err)
+
+	createCompany := func(token, name string) int {
+		body, _ := json.Marshal(map[string]interface{}{
+			"id":                  uuid.New().String(),
+			"name":                name,
+			"amount_of_employees": 10,
+			"registered":          true,
+			"type":                "Cooperative",
+		})
+		req := httptest.NewRequest("POST", "/v1/companies", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		testRouter.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	viewerToken := loginAs(t, "vieweruser", "viewerpassword").AccessToken
+	assert.Equal(t, http.StatusForbidden, createCompany(viewerToken, "ViewerCompany"))
+
+	rolesReq := httptest.NewRequest("GET", "/v1/admin/roles", nil)
+	rolesReq.Header.Set("Authorization", "Bearer "+viewerToken)
+	rolesRec := httptest.NewRecorder()
+	testRouter.ServeHTTP(rolesRec, rolesReq)
+	assert.Equal(t, http.StatusForbidden, rolesRec.Code)
+
+	assignReq := httptest.NewRequest("PUT", fmt.Sprintf("/v1/admin/users/%s/roles/editor", viewerID), nil)
+	assignReq.Header.Set("Authorization", "Bearer "+adminToken)
+	assignRec := httptest.NewRecorder()
+	testRouter.ServeHTTP(assignRec, assignReq)
+	require.Equal(t, http.StatusNoContent, assignRec.Code)
+
+	editorToken := loginAs(t, "vieweruser", "viewerpassword").AccessToken
+	assert.Equal(t, http.StatusCreated, createCompany(editorToken, "EditorCompany"))
+}
+
+func TestCompanyOwnership(t *testing.T) {
+	ownerToken := loginAs(t, "vieweruser", "viewerpassword").AccessToken
+	adminToken := getJWTToken(t)
+
+	companyID := uuid.New()
+	body, _ := json.Marshal(map[string]interface{}{
+		"id":                  companyID.String(),
+		"name":                "OwnedCompany",
+		"amount_of_employees": 5,
+		"registered":          true,
+		"type":                "NonProfit",
+	})
+	createReq := httptest.NewRequest("POST", "/v1/companies", bytes.NewBuffer(body))
+	createReq.Header.Set("Content-Type", "application/json")
+	createReq.Header.Set("Authorization", "Bearer "+ownerToken)
+	createRec := httptest.NewRecorder()
+	testRouter.ServeHTTP(createRec, createReq)
+	require.Equal(t, http.StatusCreated, createRec.Code)
+
+	var created entity.Company
+	json.Unmarshal(createRec.Body.Bytes(), &created)
+	require.NotNil(t, created.OwnerID)
+	require.NotNil(t, created.CreatedBy)
+	assert.Equal(t, *created.OwnerID, *created.CreatedBy)
+
+	var adminID uuid.UUID
+	err := testDB.QueryRow(context.Background(), "SELECT id FROM users WHERE username = $1", "testuser").Scan(&adminID)
+	require.NoError(t, err)
+
+	transferBody, _ := json.Marshal(map[string]string{"owner_id": adminID.String()})
+	transferReq := httptest.NewRequest("POST", fmt.Sprintf("/v1/companies/%s/transfer", companyID), bytes.NewBuffer(transferBody))
+	transferReq.Header.Set("Content-Type", "application/json")
+	transferReq.Header.Set("Authorization", "Bearer "+ownerToken)
+	transferRec := httptest.NewRecorder()
+	testRouter.ServeHTTP(transferRec, transferReq)
+	require.Equal(t, http.StatusOK, transferRec.Code)
+
+	// The previous owner can no longer modify the company, but an admin can.
+	patch := func(token string) int {
+		patchBody, _ := json.Marshal(map[string]interface{}{"amount_of_employees": 6})
+		req := httptest.NewRequest("PATCH", fmt.Sprintf("/v1/companies/%s", companyID), bytes.NewBuffer(patchBody))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		testRouter.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	assert.Equal(t, http.StatusForbidden, patch(ownerToken))
+	assert.Equal(t, http.StatusOK, patch(adminToken))
+}
+
+func TestTenantIsolation(t *testing.T) {
+	adminToken := getJWTToken(t)
+
+	orgBody, _ := json.Marshal(map[string]string{"name": "Other Org"})
+	orgReq := httptest.NewRequest("POST", "/v1/admin/organizations", bytes.NewBuffer(orgBody))
+	orgReq.Header.Set("Content-Type", "application/json")
+	orgReq.Header.Set("Authorization", "Bearer "+adminToken)
+	orgRec := httptest.NewRecorder()
+	testRouter.ServeHTTP(orgRec, orgReq)
+	require.Equal(t, http.StatusCreated, orgRec.Code)
+
+	var org entity.Organization
+	json.Unmarshal(orgRec.Body.Bytes(), &org)
+
+	var adminID uuid.UUID
+	err := testDB.QueryRow(context.Background(), "SELECT id FROM users WHERE username = $1", "testuser").Scan(&adminID)
+	require.NoError(t, err)
+
+	memberReq := httptest.NewRequest("PUT", fmt.Sprintf("/v1/admin/organizations/%s/members/%s", org.ID, adminID), nil)
+	memberReq.Header.Set("Authorization", "Bearer "+adminToken)
+	memberRec := httptest.NewRecorder()
+	testRouter.ServeHTTP(memberRec, memberReq)
+	require.Equal(t, http.StatusNoContent, memberRec.Code)
+
+	createCompany := func(token string, id uuid.UUID) int {
+		body, _ := json.Marshal(map[string]interface{}{
+			"id":                  id.String(),
+			"name":                "TenantCompany",
+			"amount_of_employees": 3,
+			"registered":          true,
+			"type":                "Corporations",
+		})
+		req := httptest.NewRequest("POST", "/v1/companies", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		testRouter.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	defaultCompanyID := uuid.New()
+	require.Equal(t, http.StatusCreated, createCompany(adminToken, defaultCompanyID))
+
+	switchBody, _ := json.Marshal(map[string]string{"organization_id": org.ID.String()})
+	switchReq := httptest.NewRequest("POST", "/v1/users/switch-organization", bytes.NewBuffer(switchBody))
+	switchReq.Header.Set("Content-Type", "application/json")
+	switchReq.Header.Set("Authorization", "Bearer "+adminToken)
+	switchRec := httptest.NewRecorder()
+	testRouter.ServeHTTP(switchRec, switchReq)
+	require.Equal(t, http.StatusOK, switchRec.Code)
+
+	var switched entity.TokenPair
+	json.Unmarshal(switchRec.Body.Bytes(), &switched)
+
+	// Names are unique per organization only.
+	assert.Equal(t, http.StatusCreated, createCompany(switched.AccessToken, uuid.New()))
+
+	getReq := httptest.NewRequest("GET", fmt.Sprintf("/v1/companies/%s", defaultCompanyID), nil)
+	getReq.Header.Set("Authorization", "Bearer "+switched.AccessToken)
+	getRec := httptest.NewRecorder()
+	testRouter.ServeHTTP(getRec, getReq)
+	assert.Equal(t, http.StatusNotFound, getRec.Code)
+
+	deleteReq := httptest.NewRequest("DELETE", fmt.Sprintf("/v1/companies/%s", defaultCompanyID), nil)
+	deleteReq.Header.Set("Authorization", "Bearer "+switched.AccessToken)
+	deleteRec := httptest.NewRecorder()
+	testRouter.ServeHTTP(deleteRec, deleteReq)
+	assert.Equal(t, http.StatusNotFound, deleteRec.Code)
+}
+
+func TestServiceAccountAPIKeys(t *testing.T) {
+	adminToken := getJWTToken(t)
+
+	accountBody, _ := json.Marshal(map[string]string{"name": "importer"})
+	accountReq := httptest.NewRequest("POST", "/v1/admin/service-accounts", bytes.NewBuffer(accountBody))
+	accountReq.Header.Set("Content-Type", "application/json")
+	accountReq.Header.Set("Authorization", "Bearer "+adminToken)
+	accountRec := httptest.NewRecorder()
+	testRouter.ServeHTTP(accountRec, accountReq)
+	require.Equal(t, http.StatusCreated, accountRec.Code)
+
+	var account entity.ServiceAccount
+	json.Unmarshal(accountRec.Body.Bytes(), &account)
+	assert.Equal(t, entity.DefaultOrganizationID, account.OrganizationID)
+
+	createKey := func(scopes ...string) (uuid.UUID, string) {
+		body, _ := json.Marshal(map[string]interface{}{"scopes": scopes})
+		req := httptest.NewRequest("POST", fmt.Sprintf("/v1/admin/service-accounts/%s/keys", account.ID), bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+adminToken)
+		rec := httptest.NewRecorder()
+		testRouter.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusCreated, rec.Code)
+
+		var key struct {
+			ID  uuid.UUID `json:"id"`
+			Key string    `json:"key"`
+		}
+		json.Unmarshal(rec.Body.Bytes(), &key)
+		require.True(t, strings.HasPrefix(key.Key, entity.APIKeyPrefix+"_"))
+		return key.ID, key.Key
+	}
+
+	var companyID uuid.UUID
+	createCompany := func(apiKey string) int {
+		companyID = uuid.New()
+		body, _ := json.Marshal(map[string]interface{}{
+			"id":                  companyID.String(),
+			"name":                "ServiceCompany-" + uuid.NewString()[:8],
+			"amount_of_employees": 5,
+			"registered":          true,
+			"type":                "Corporations",
+		})
+		req := httptest.NewRequest("POST", "/v1/companies", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(auth.HeaderAPIKey, apiKey)
+		rec := httptest.NewRecorder()
+		testRouter.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	writeKeyID, writeKey := createKey(entity.ScopeCompaniesWrite)
+	_, readKey := createKey(entity.ScopeCompaniesRead)
+
+	assert.Equal(t, http.StatusCreated, createCompany(writeKey))
+	// Deleting needs its own scope.
+	deleteReq := httptest.NewRequest("DELETE", "/v1/companies/"+companyID.String(), nil)
+	deleteReq.Header.Set(auth.HeaderAPIKey, writeKey)
+	deleteRec := httptest.NewRecorder()
+	testRouter.ServeHTTP(deleteRec, deleteReq)
+	assert.Equal(t, http.StatusForbidden, deleteRec.Code)
+	assert.Equal(t, http.StatusForbidden, createCompany(readKey))
+	assert.Equal(t, http.StatusUnauthorized, createCompany(writeKey+"x"))
+
+	listReq := httptest.NewRequest("GET", fmt.Sprintf("/v1/admin/service-accounts/%s/keys", account.ID), nil)
+	listReq.Header.Set("Authorization", "Bearer "+adminToken)
+	listRec := httptest.NewRecorder()
+	testRouter.ServeHTTP(listRec, listReq)
+	require.Equal(t, http.StatusOK, listRec.Code)
+	assert.NotContains(t, listRec.Body.String(), writeKey)
+	assert.Contains(t, listRec.Body.String(), "last_used_at")
+
+	revokeReq := httptest.NewRequest("DELETE", fmt.Sprintf("/v1/admin/service-accounts/%s/keys/%s", account.ID, writeKeyID), nil)
+	revokeReq.Header.Set("Authorization", "Bearer "+adminToken)
+	revokeRec := httptest.NewRecorder()
+	testRouter.ServeHTTP(revokeRec, revokeReq)
+	require.Equal(t, http.StatusNoContent, revokeRec.Code)
+
+	assert.Equal(t, http.StatusUnauthorized, createCompany(writeKey))
+}
+
+func TestPasswordPolicyAndLoginThrottling(t *testing.T) {
+	weakBody, _ := json.Marshal(map[string]string{"username": "lockoutuser", "password": "password123"})
+	weakReq := httptest.NewRequest("POST", "/v1/users/register", bytes.NewBuffer(weakBody))
+	weakReq.Header.Set("Content-Type", "application/json")
+	weakRec := httptest.NewRecorder()
+	testRouter.ServeHTTP(weakRec, weakReq)
+	assert.Equal(t, http.StatusBadRequest, weakRec.Code)
+	assert.Contains(t, weakRec.Body.String(), "breached")
+
+	registerBody, _ := json.Marshal(map[string]string{"username": "lockoutuser", "password": "correct horse battery"})
+	registerReq := httptest.NewRequest("POST", "/v1/users/register", bytes.NewBuffer(registerBody))
+	registerReq.Header.Set("Content-Type", "application/json")
+	registerRec := httptest.NewRecorder()
+	testRouter.ServeHTTP(registerRec, registerReq)
+	require.Equal(t, http.StatusCreated, registerRec.Code)
+
+	attempt := func(password string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(map[string]string{"username": "lockoutuser", "password": password})
+		req := httptest.NewRequest("POST", "/v1/users/login", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.RemoteAddr = "198.51.100.7:4321"
+		rec := httptest.NewRecorder()
+		testRouter.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// The first failures beyond the free attempts start a delay.
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusUnauthorized, attempt("wrong password").Code)
+	}
+
+	throttled := attempt("correct horse battery")
+	assert.Equal(t, http.StatusTooManyRequests, throttled.Code)
+	assert.NotEmpty(t, throttled.Header().Get("Retry-After"))
+
+	appendSecurityEvents(t)
+	var failures int
+	err := testDB.QueryRow(context.Background(),
+		"SELECT COUNT(*) FROM security_events WHERE username = $1 AND type = $2", "lockoutuser", entity.SecurityEventLoginFailed).
+		Scan(&failures)
+	require.NoError(t, err)
+	assert.Equal(t, 3, failures)
+}
+
+func TestMFALogin(t *testing.T) {
+	registerBody, _ := json.Marshal(map[string]string{"username": "mfauser", "password": "mfa user password"})
+	registerReq := httptest.NewRequest("POST", "/v1/users/register", bytes.NewBuffer(registerBody))
+	registerReq.Header.Set("Content-Type", "application/json")
+	registerRec := httptest.NewRecorder()
+	testRouter.ServeHTTP(registerRec, registerReq)
+	require.Equal(t, http.StatusCreated, registerRec.Code)
+
+	token := loginAs(t, "mfauser", "mfa user password").AccessToken
+	post := func(path, bearer string, payload interface{}) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(payload)
+		req := httptest.NewRequest("POST", path, bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.RemoteAddr = "203.0.113.9:1234"
+		if bearer != "" {
+			req.Header.Set("Authorization", "Bearer "+bearer)
+		}
+		rec := httptest.NewRecorder()
+		testRouter.ServeHTTP(rec, req)
+		return rec
+	}
+
+	enrollRec := post("/v1/users/mfa/enroll", token, nil)
+	require.Equal(t, http.StatusOK, enrollRec.Code)
+	var enrollment entity.MFAEnrollment
+	json.Unmarshal(enrollRec.Body.Bytes(), &enrollment)
+	assert.True(t, strings.HasPrefix(enrollment.OTPAuthURI, "otpauth://totp/"))
+
+	// The secret is stored encrypted.
+	var storedSecret []byte
+	require.NoError(t, testDB.QueryRow(context.Background(), `
+		SELECT m.secret FROM user_mfa m JOIN users u ON u.id = m.user_id WHERE u.username = 'mfauser'
+	`).Scan(&storedSecret))
+	assert.True(t, secretbox.IsSealed(storedSecret))
+	assert.NotContains(t, string(storedSecret), enrollment.Secret)
+
+	// Confirm with the previous period's code, leaving the current one for the login.
+	previousCode, err := totp.Code(enrollment.Secret, totp.Step(time.Now())-1)
+	require.NoError(t, err)
+	confirmRec := post("/v1/users/mfa/confirm", token, map[string]string{"code": previousCode})
+	require.Equal(t, http.StatusOK, confirmRec.Code)
+	var confirmed struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+	json.Unmarshal(confirmRec.Body.Bytes(), &confirmed)
+	require.Len(t, confirmed.RecoveryCodes, entity.RecoveryCodeCount)
+
+	login := func() entity.MFAChallenge {
+		rec := post("/v1/users/login", "", map[string]string{"username": "mfauser", "password": "mfa user password"})
+		require.Equal(t, http.StatusOK, rec.Code)
+		var challenge entity.MFAChallenge
+		json.Unmarshal(rec.Body.Bytes(), &challenge)
+		require.True(t, challenge.MFARequired)
+		return challenge
+	}
+
+	challenge := login()
+	// The challenge token is not an access token.
+	assert.Equal(t, http.StatusUnauthorized, post("/v1/users/logout", challenge.MFAToken, nil).Code)
+	// A code that was already used is rejected.
+	assert.Equal(t, http.StatusUnauthorized, post("/v1/users/login/mfa", "", map[string]string{
+		"mfa_token": challenge.MFAToken, "code": previousCode}).Code)
+
+	currentCode, err := totp.Code(enrollment.Secret, totp.Step(time.Now()))
+	require.NoError(t, err)
+	mfaRec := post("/v1/users/login/mfa", "", map[string]string{"mfa_token": challenge.MFAToken, "code": currentCode})
+	require.Equal(t, http.StatusOK, mfaRec.Code)
+	var tokens entity.TokenPair
+	json.Unmarshal(mfaRec.Body.Bytes(), &tokens)
+	assert.NotEmpty(t, tokens.AccessToken)
+
+	// Recovery codes work once.
+	challenge = login()
+	recoveryPayload := map[string]string{"mfa_token": challenge.MFAToken, "code": confirmed.RecoveryCodes[0]}
+	assert.Equal(t, http.StatusOK, post("/v1/users/login/mfa", "", recoveryPayload).Code)
+	assert.Equal(t, http.StatusUnauthorized, post("/v1/users/login/mfa", "", recoveryPayload).Code)
+}
+
+func TestPasswordResetAndEmailVerification(t *testing.T) {
+	post := func(path, bearer string, payload interface{}) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(payload)
+		req := httptest.NewRequest("POST", path, bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.RemoteAddr = "203.0.113.21:1234"
+		if bearer != "" {
+			req.Header.Set("Authorization", "Bearer "+bearer)
+		}
+		rec := httptest.NewRecorder()
+		testRouter.ServeHTTP(rec, req)
+		return rec
+	}
+
+	registerRec := post("/v1/users/register", "", map[string]string{
+		"username": "resetuser", "password": "reset user password", "email": "Reset.User@Example.com"})
+	require.Equal(t, http.StatusCreated, registerRec.Code)
+	verifyToken := testMailer.tokenFor(t, "reset.user@example.com")
+
+	duplicateRec := post("/v1/users/register", "", map[string]string{
+		"username": "otheruser", "password": "other user password", "email": "reset.user@EXAMPLE.com"})
+	assert.Equal(t, http.StatusBadRequest, duplicateRec.Code)
+
+	// Resetting an unverified address does not send anything.
+	sent := testMailer.count()
+	assert.Equal(t, http.StatusAccepted, post("/v1/users/password-reset/request", "", map[string]string{"email": "reset.user@example.com"}).Code)
+	assert.Equal(t, sent, testMailer.count())
+
+	assert.Equal(t, http.StatusNoContent, post("/v1/users/verify-email", "", map[string]string{"token": verifyToken}).Code)
+	assert.Equal(t, http.StatusBadRequest, post("/v1/users/verify-email", "", map[string]string{"token": verifyToken}).Code)
+
+	session := loginAs(t, "resetuser", "reset user password")
+	require.NotEmpty(t, session.RefreshToken)
+
+	assert.Equal(t, http.StatusAccepted, post("/v1/users/password-reset/request", "", map[string]string{"email": "reset.user@example.com"}).Code)
+	assert.Equal(t, http.StatusAccepted, post("/v1/users/password-reset/request", "", map[string]string{"email": "nobody@example.com"}).Code)
+	resetToken := testMailer.tokenFor(t, "reset.user@example.com")
+
+	assert.Equal(t, http.StatusBadRequest, post("/v1/users/password-reset/confirm", "", map[string]string{
+		"token": resetToken, "password": "password123"}).Code)
+	assert.Equal(t, http.StatusNoContent, post("/v1/users/password-reset/confirm", "", map[string]string{
+		"token": resetToken, "password": "a new reset password"}).Code)
+	assert.Equal(t, http.StatusBadRequest, post("/v1/users/password-reset/confirm", "", map[string]string{
+		"token": resetToken, "password": "another new password"}).Code)
+
+	// Existing sessions end with the reset.
+	assert.Equal(t, http.StatusUnauthorized, post("/v1/users/refresh", "", map[string]string{"refresh_token": session.RefreshToken}).Code)
+	assert.Empty(t, loginAs(t, "resetuser", "reset user password").AccessToken)
+	assert.NotEmpty(t, loginAs(t, "resetuser", "a new reset password").AccessToken)
+}
+
+func TestUserManagement(t *testing.T) {
+	request := func(method, path, bearer string, payload interface{}) *httptest.ResponseRecorder {
+		var body []byte
+		if payload != nil {
+			body, _ = json.Marshal(payload)
+		}
+		req := httptest.NewRequest(method, path, bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.RemoteAddr = "203.0.113.31:1234"
+		if bearer != "" {
+			req.Header.Set("Authorization", "Bearer "+bearer)
+		}
+		rec := httptest.NewRecorder()
+		testRouter.ServeHTTP(rec, req)
+		return rec
+	}
+
+	require.Equal(t, http.StatusCreated, request("POST", "/v1/users/register", "", map[string]string{
+		"username": "manageduser", "password": "managed user password"}).Code)
+	session := loginAs(t, "manageduser", "managed user password")
+	require.NotEmpty(t, session.AccessToken)
+
+	meRec := request("GET", "/v1/users/me", session.AccessToken, nil)
+	require.Equal(t, http.StatusOK, meRec.Code)
+	var profile entity.UserProfile
+	require.NoError(t, json.Unmarshal(meRec.Body.Bytes(), &profile))
+	assert.Equal(t, "manageduser", profile.Username)
+	assert.False(t, profile.CreatedAt.IsZero())
+	assert.NotContains(t, meRec.Body.String(), "password")
+
+	// Changing the password needs the current one and ends other sessions.
+	assert.Equal(t, http.StatusForbidden, request("POST", "/v1/users/me/password", session.AccessToken, map[string]string{
+		"current_password": "wrong", "new_password": "a changed password"}).Code)
+	changeRec := request("POST", "/v1/users/me/password", session.AccessToken, map[string]string{
+		"current_password": "managed user password", "new_password": "a changed password"})
+	require.Equal(t, http.StatusOK, changeRec.Code)
+	assert.Equal(t, http.StatusUnauthorized, request("POST", "/v1/users/refresh", "", map[string]string{"refresh_token": session.RefreshToken}).Code)
+	var changed entity.TokenPair
+	require.NoError(t, json.Unmarshal(changeRec.Body.Bytes(), &changed))
+	assert.Equal(t, http.StatusOK, request("GET", "/v1/users/me", changed.AccessToken, nil).Code)
+
+	// Only administrators manage users.
+	assert.Equal(t, http.StatusForbidden, request("GET", "/v1/admin/users", changed.AccessToken, nil).Code)
+	adminToken := getJWTToken(t)
+	listRec := request("GET", "/v1/admin/users?q=managed", adminToken, nil)
+	require.Equal(t, http.StatusOK, listRec.Code)
+	var list struct {
+		Users []entity.User `json:"users"`
+	}
+	require.NoError(t, json.Unmarshal(listRec.Body.Bytes(), &list))
+	require.Len(t, list.Users, 1)
+	userID := list.Users[0].ID
+
+	assert.Equal(t, http.StatusNoContent, request("POST", "/v1/admin/users/"+userID.String()+"/disable", adminToken, nil).Code)
+	assert.Equal(t, http.StatusUnauthorized, request("GET", "/v1/users/me", changed.AccessToken, nil).Code)
+	assert.Empty(t, loginAs(t, "manageduser", "a changed password").AccessToken)
+	assert.Equal(t, http.StatusOK, request("GET", "/v1/admin/users?disabled=true", adminToken, nil).Code)
+
+	assert.Equal(t, http.StatusNoContent, request("POST", "/v1/admin/users/"+userID.String()+"/enable", adminToken, nil).Code)
+	session = loginAs(t, "manageduser", "a changed password")
+	require.NotEmpty(t, session.AccessToken)
+
+	assert.Equal(t, http.StatusForbidden, request("DELETE", "/v1/users/me", session.AccessToken, map[string]string{"password": "wrong"}).Code)
+	assert.Equal(t, http.StatusNoContent, request("DELETE", "/v1/users/me", session.AccessToken, map[string]string{"password": "a changed password"}).Code)
+	assert.Empty(t, loginAs(t, "manageduser", "a changed password").AccessToken)
+}
+
+func TestSecurityAuditLog(t *testing.T) {
+	request := func(method, path, bearer string, payload interface{}) *httptest.ResponseRecorder {
+		var body []byte
+		if payload != nil {
+			body, _ = json.Marshal(payload)
+		}
+		req := httptest.NewRequest(method, path, bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.RemoteAddr = "203.0.113.41:1234"
+		if bearer != "" {
+			req.Header.Set("Authorization", "Bearer "+bearer)
+		}
+		rec := httptest.NewRecorder()
+		testRouter.ServeHTTP(rec, req)
+		return rec
+	}
+
+	require.Equal(t, http.StatusCreated, request("POST", "/v1/users/register", "", map[string]string{
+		"username": "audituser", "password": "audit user password"}).Code)
+	assert.Empty(t, loginAs(t, "audituser", "wrong password").AccessToken)
+	session := loginAs(t, "audituser", "audit user password")
+	require.NotEmpty(t, session.AccessToken)
+	require.Equal(t, http.StatusOK, request("POST", "/v1/users/refresh", "", map[string]string{"refresh_token": session.RefreshToken}).Code)
+
+	// Denied requests are recorded too, in the background.
+	assert.Equal(t, http.StatusForbidden, request("GET", "/v1/admin/audit-events", session.AccessToken, nil).Code)
+
+	var userID uuid.UUID
+	require.NoError(t, testDB.QueryRow(context.Background(), "SELECT id FROM users WHERE username = $1", "audituser").Scan(&userID))
+	require.Eventually(t, func() bool {
+		var queued int
+		err := testDB.QueryRow(context.Background(), "SELECT COUNT(*) FROM security_event_queue WHERE user_id = $1 AND type = $2",
+			userID, entity.SecurityEventPermissionDenied).Scan(&queued)
+		return err == nil && queued > 0
+	}, 5*time.Second, 20*time.Millisecond)
+	adminToken := getJWTToken(t)
+	require.Equal(t, http.StatusNoContent, request("PUT", "/v1/admin/users/"+userID.String()+"/roles/editor", adminToken, nil).Code)
+	appendSecurityEvents(t)
+
+	listRec := request("GET", "/v1/admin/audit-events?user_id="+userID.String(), adminToken, nil)
+	require.Equal(t, http.StatusOK, listRec.Code)
+	var list struct {
+		Events []entity.SecurityEvent `json:"events"`
+	}
+	require.NoError(t, json.Unmarshal(listRec.Body.Bytes(), &list))
+	var types []string
+	for _, event := range list.Events {
+		types = append(types, event.Type)
+		require.NotNil(t, event.Sequence)
+		assert.Len(t, event.Hash, 64)
+	}
+	assert.Equal(t, []string{
+		entity.SecurityEventLoginFailed,
+		entity.SecurityEventLoginSucceeded,
+		entity.SecurityEventTokenRefreshed,
+		entity.SecurityEventPermissionDenied,
+		entity.SecurityEventRoleAssigned,
+	}, types)
+
+	filteredRec := request("GET", "/v1/admin/audit-events?action=permission_denied&user_id="+userID.String(), adminToken, nil)
+	require.Equal(t, http.StatusOK, filteredRec.Code)
+	require.NoError(t, json.Unmarshal(filteredRec.Body.Bytes(), &list))
+	require.Len(t, list.Events, 1)
+	assert.Contains(t, list.Events[0].Reason, entity.PermissionAuditRead)
+
+	verifyRec := request("GET", "/v1/admin/audit-events/verify", adminToken, nil)
+	require.Equal(t, http.StatusOK, verifyRec.Code)
+	var verification entity.ChainVerification
+	require.NoError(t, json.Unmarshal(verifyRec.Body.Bytes(), &verification))
+	assert.True(t, verification.Valid)
+	assert.Positive(t, verification.Events)
+
+	// The table rejects changes to recorded events.
+	_, err := testDB.Exec(context.Background(), "UPDATE security_events SET reason = 'tampered' WHERE user_id = $1", userID)
+	assert.Error(t, err)
+	_, err = testDB.Exec(context.Background(), "DELETE FROM security_events WHERE user_id = $1", userID)
+	assert.Error(t, err)
+}
+
+func TestSSOLogin(t *testing.T) {
+	testProvider.SetClaims(map[string]interface{}{
+		"sub":                "sso-employee-1",
+		"preferred_username": "ssouser",
+		"email":              "sso.user@example.com",
+		"email_verified":     true,
+		"groups":             []string{"engineering"},
+	})
+
+	ssoLogin := func(tamperState bool) *httptest.ResponseRecorder {
+		loginReq := httptest.NewRequest("GET", "/v1/users/sso/login", nil)
+		loginRec := httptest.NewRecorder()
+		testRouter.ServeHTTP(loginRec, loginReq)
+		require.Equal(t, http.StatusFound, loginRec.Code)
+
+		callback, err := testProvider.Authorize(loginRec.Header().Get("Location"))
+		require.NoError(t, err)
+		require.Equal(t, "/v1/users/sso/callback", callback.Path)
+
+		callbackReq := httptest.NewRequest("GET", callback.RequestURI(), nil)
+		for _, cookie := range loginRec.Result().Cookies() {
+			if tamperState {
+				cookie.Value = "another-state"
+			}
+			callbackReq.AddCookie(cookie)
+		}
+		callbackRec := httptest.NewRecorder()
+		testRouter.ServeHTTP(callbackRec, callbackReq)
+		return callbackRec
+	}
+
+	// The callback has to come from the browser that started the login.
+	assert.Equal(t, http.StatusBadRequest, ssoLogin(true).Code)
+
+	rec := ssoLogin(false)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var tokens entity.TokenPair
+	json.Unmarshal(rec.Body.Bytes(), &tokens)
+
+	claims := jwt.MapClaims{}
+	_, _, err := jwt.NewParser().ParseUnverified(tokens.AccessToken, claims)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []interface{}{entity.RoleViewer, entity.RoleEditor}, claims["roles"])
+
+	// The second login finds the linked user instead of provisioning again.
+	require.Equal(t, http.StatusOK, ssoLogin(false).Code)
+	var users int
+	err = testDB.QueryRow(context.Background(), "SELECT COUNT(*) FROM users WHERE username = 'ssouser'").Scan(&users)
+	require.NoError(t, err)
+	assert.Equal(t, 1, users)
+
+	// Provisioned users have no password to log in with.
+	assert.Empty(t, loginAs(t, "ssouser", "").AccessToken)
+}
+
+// breachList stands in for the range API, which tests do not call.
+type breachList map[string]bool
+
+func (l breachList) IsBreached(ctx context.Context, password string) bool {
+	return l[password]
+}
+
+// capturingMailer keeps sent messages so that tests can follow the links.
+type capturingMailer struct {
+	mu       sync.Mutex
+	messages []mailer.Message
+}
+
+func (m *capturingMailer) Send(ctx context.Context, message mailer.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = append(m.messages, message)
+	return nil
+}
+
+func (m *capturingMailer) count() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.messages)
+}
+
+// tokenFor returns the token from the last link sent to the address.
+func (m *capturingMailer) tokenFor(t *testing.T, to string) string {
+	t.Helper()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		if m.messages[i].To != to {
+			continue
+		}
+		body := m.messages[i].Body
+		start := strings.Index(body, "?token=")
+		require.NotEqual(t, -1, start)
+		token, _, _ := strings.Cut(body[start+len("?token="):], "\n")
+		return token
+	}
+	t.Fatalf("no message sent to %s", to)
+	return ""
+}
+
 func getJWTToken(t *testing.T) string {
+	return login(t).AccessToken
+}
+
+func login(t *testing.T) entity.TokenPair {
+	return loginAs(t, "testuser", "testpassword")
+}
+
+func loginAs(t *testing.T, username, password string) entity.TokenPair {
 	loginPayload := map[string]string{
-		"username": "testuser",
-		"password": "testpassword",
+		"username": username,
+		"password": password,
 	}
 	loginBody, _ := json.Marshal(loginPayload)
 	loginReq := httptest.NewRequest("POST", "/v1/users/login", bytes.NewBuffer(loginBody))
@@ -179,9 +1912,9 @@ func getJWTToken(t *testing.T) string {
 	loginRec := httptest.NewRecorder()
 	testRouter.ServeHTTP(loginRec, loginReq)
 
-	var loginResponse map[string]string
-	json.Unmarshal(loginRec.Body.Bytes(), &loginResponse)
-	return loginResponse["token"]
+	var tokens entity.TokenPair
+	json.Unmarshal(loginRec.Body.Bytes(), &tokens)
+	return tokens
 }
 
 func TestOutboxWorker(t *testing.T) {
@@ -203,7 +1936,9 @@ func TestOutboxWorker(t *testing.T) {
 		CreatedAt: time.Now(),
 	}
 
-	err := companyRepo.CreateWithOutboxEvent(context.Background(), testCompany, outboxEvent)
+	ctx := tenant.WithOrganization(context.Background(), entity.DefaultOrganizationID)
+	revision := entity.NewCompanyRevision(testCompany, entity.CompanyRevisionCreated, nil, "")
+	err := companyRepo.CreateWithOutboxEvent(ctx, testCompany, outboxEvent, revision)
 	require.NoError(t, err)
 
 	mockProducer := &mockKafkaProducer{}
@@ -216,14 +1951,17 @@ func TestOutboxWorker(t *testing.T) {
 	assert.Empty(t, events, "Outbox should be empty after processing")
 
 	assert.True(t, mockProducer.MessageSent, "Message should have been sent to Kafka")
+	assert.Equal(t, entity.DefaultOrganizationID.String(), mockProducer.Headers["organization_id"])
 }
 
 type mockKafkaProducer struct {
 	MessageSent bool
+	Headers     map[string]string
 }
 
-func (m *mockKafkaProducer) Produce(ctx context.Context, topic string, key, value []byte) error {
+func (m *mockKafkaProducer) Produce(ctx context.Context, topic string, key, value []byte, headers map[string]string) error {
 	m.MessageSent = true
+	m.Headers = headers
 	return nil
 }
 
@@ -240,7 +1978,12 @@ func cleanupDatabase(log logger.Logger) {
 	defer tx.Rollback(context.Background())
 
 	_, err = tx.Exec(context.Background(), `
-		DROP TABLE users, companies, outbox_events, goose_db_version;
+		DROP TABLE users, companies, outbox_events, refresh_tokens, revoked_tokens, signing_keys,
+			roles, permissions, role_permissions, user_roles, organizations, organization_members,
+			service_accounts, api_keys, login_throttles, security_events, security_event_queue,
+			user_mfa, mfa_recovery_codes, one_time_tokens, user_identities, sso_login_states, company_revisions,
+			idempotency_keys, import_row_errors, import_jobs, goose_db_version;
+		DROP FUNCTION IF EXISTS security_events_append_only();
 	`)
 	if err != nil {
 		log.Error("Failed to drop tables", "error", err)
@@ -252,3 +1995,16 @@ func cleanupDatabase(log logger.Logger) {
 		log.Error("Failed to commit transaction during cleanup", "error", err)
 	}
 }
+
+// appendSecurityEvents appends the queued security events to the chain, as
+// the appender of the service does in the background.
+func appendSecurityEvents(t *testing.T) {
+	t.Helper()
+	for {
+		n, err := testEvents.AppendQueued(context.Background(), 500)
+		require.NoError(t, err)
+		if n == 0 {
+			return
+		}
+	}
+}